@@ -0,0 +1,62 @@
+// Command cli generates documentation for a local directory, archive or
+// source file without running the HTTP server, for use in CI:
+//
+//	cli -o docs.md ./src
+//
+// It reads the same environment (and .env) as the server and exits
+// non-zero when the job fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/handlers"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run is the command with arguments args, reporting to stderr. It returns
+// the exit status: 2 for bad usage and 1 when the job fails.
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file, or directory for multi-project inputs")
+	format := flags.String("format", "", "output format; defaults to the output file's extension")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: cli -o OUTPUT [-format FORMAT] PATH\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *output == "" {
+		flags.Usage()
+		return 2
+	}
+
+	logger := log.New(stderr, "", log.LstdFlags)
+	godotenv.Load()
+	cfg := config.New()
+	if err := cfg.Validate(); err != nil {
+		logger.Print(err)
+		return 1
+	}
+	handlers.Setup(cfg)
+
+	written, err := handlers.RunLocal(flags.Arg(0), *output, *format)
+	if err != nil {
+		logger.Print(err)
+		return 1
+	}
+	logger.Printf("Documentation written to %s", written)
+	return 0
+}
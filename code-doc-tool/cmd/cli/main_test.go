@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inTempDir runs the test from a fresh temporary directory with the stub
+// analyzer, so no agent is needed and work files stay out of the tree.
+func inTempDir(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	t.Setenv("ANALYZER", "stub")
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	inTempDir(t)
+	for _, args := range [][]string{
+		nil,
+		{"src"},
+		{"-o", "docs.md"},
+		{"-o", "docs.md", "a", "b"},
+		{"-bogus", "-o", "docs.md", "src"},
+	} {
+		var stderr bytes.Buffer
+		if code := run(args, &stderr); code != 2 {
+			t.Errorf("run(%q) = %d, want 2", args, code)
+		}
+		if !strings.Contains(stderr.String(), "Usage: cli -o OUTPUT") {
+			t.Errorf("run(%q) printed no usage:\n%s", args, stderr.String())
+		}
+	}
+}
+
+func TestRunDirectory(t *testing.T) {
+	dir := inTempDir(t)
+	writeFile(t, filepath.Join(dir, "src", "main.go"), "package main\n\nfunc main() {}\n")
+	output := filepath.Join(dir, "docs.md")
+
+	var stderr bytes.Buffer
+	if code := run([]string{"-o", output, "src"}, &stderr); code != 0 {
+		t.Fatalf("exit %d:\n%s", code, stderr.String())
+	}
+	if data, err := os.ReadFile(output); err != nil || !strings.Contains(string(data), "main.go") {
+		t.Errorf("output %q, %v", data, err)
+	}
+	if !strings.Contains(stderr.String(), "Documentation written to "+output) {
+		t.Errorf("stderr:\n%s", stderr.String())
+	}
+}
+
+func TestRunArchive(t *testing.T) {
+	dir := inTempDir(t)
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, _ := w.Create("app/util.py")
+	f.Write([]byte("def util():\n    pass\n"))
+	w.Close()
+	writeFile(t, filepath.Join(dir, "app.zip"), buf.String())
+
+	// -format wins over the output's extension
+	output := filepath.Join(dir, "docs.out")
+	var stderr bytes.Buffer
+	if code := run([]string{"-o", output, "-format", "md", "app.zip"}, &stderr); code != 0 {
+		t.Fatalf("exit %d:\n%s", code, stderr.String())
+	}
+	if data, err := os.ReadFile(output); err != nil || !strings.Contains(string(data), "util.py") {
+		t.Errorf("output %q, %v", data, err)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	dir := inTempDir(t)
+	writeFile(t, filepath.Join(dir, "src", "README.md"), "# Nothing to analyze\n")
+	output := filepath.Join(dir, "docs.md")
+
+	var stderr bytes.Buffer
+	if code := run([]string{"-o", output, "src"}, &stderr); code != 1 {
+		t.Fatalf("exit %d, want 1:\n%s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "no source files found") {
+		t.Errorf("stderr doesn't say why:\n%s", stderr.String())
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output written for a failed job: %v", err)
+	}
+
+	if code := run([]string{"-o", output, "missing"}, &stderr); code != 1 {
+		t.Errorf("missing input: exit %d, want 1", code)
+	}
+}
@@ -2,14 +2,15 @@ package main
 
 import (
 	"log"
-	"os"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/joho/godotenv"
 
+	"code-doc-tool/internal/config"
 	"code-doc-tool/internal/handlers"
 )
 
@@ -18,35 +19,30 @@ func main() {
 		log.Println("No .env file found")
 	}
 
-	app := fiber.New(fiber.Config{
-		BodyLimit: 100 * 1024 * 1024, // 100MB
-	})
+	cfg := config.New()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	handlers.Init(cfg)
+
+	app := fiber.New(handlers.ServerConfig())
 
-	app.Use(logger.New())
+	app.Use(requestid.New())
+	app.Use(logger.New(logger.Config{
+		Format: "[${time}] ${locals:requestid} ${status} - ${latency} ${method} ${path}\n",
+	}))
 	app.Use(recover.New())
+	app.Use(handlers.ResponseHeaders)
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowMethods: "GET,POST,HEAD,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept",
+		AllowHeaders: "Origin, Content-Type, Accept, X-Request-ID",
 	}))
 
 	app.Static("/", "./web/static")
 
-	setupRoutes(app)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3000"
-	}
-
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(app.Listen(":" + port))
-}
-
-func setupRoutes(app *fiber.App) {
-	api := app.Group("/api")
+	handlers.Routes(app)
 
-	api.Post("/upload", handlers.UploadCodebase)
-	api.Get("/download/:filename", handlers.DownloadDocumentation)
-	api.Get("/status/:jobId", handlers.GetStatus)
+	log.Printf("Server starting on port %s", cfg.Port)
+	log.Fatal(app.Listen(":" + cfg.Port))
 }
@@ -1,7 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Config struct {
@@ -9,15 +15,346 @@ type Config struct {
 	UploadPath  string
 	OutputPath  string
 	MaxFileSize int64
+
+	// JSONBodyLimit caps request bodies on every route but the archive
+	// upload, which is bounded by MaxFileSize instead
+	JSONBodyLimit int64
+
+	// AdminToken is the bearer token the metrics and admin endpoints
+	// require; without one they are disabled
+	AdminToken string
+
+	// Architecture diagrams accepted alongside an upload, and the largest
+	// size of each
+	DiagramMaxCount int
+	DiagramMaxSize  int64
+
+	// Analyzer selects "http" (the agent below) or "stub" for offline runs
+	Analyzer string
+
+	// Optional JSON file of extra agent instructions keyed by language or
+	// path glob
+	PromptAugmentationsFile string
+
+	// Optional JSON file of format templates replacing the default for
+	// files of a language or projects of a type
+	FormatTemplatesFile string
+
+	// Analyze agent endpoint and the multipart field names it expects
+	AgentURL         string
+	AgentFileField   string
+	AgentFormatField string
+
+	// Dotted path of the document in the agent's JSON reply, for agents
+	// that nest it, e.g. "result.document"
+	AgentResponsePath string
+
+	// Largest agent reply read, in bytes; bigger ones fail the call
+	AgentMaxResponseSize int64
+
+	// Keep-alive connection pool to the agent: idle connections kept in
+	// total and to the agent, and how long an idle one is kept
+	AgentMaxIdleConns        int
+	AgentMaxIdleConnsPerHost int
+	AgentIdleConnTimeout     time.Duration
+
+	// Extensions of the files sent for analysis, and whether extraction
+	// skips everything that can't be analyzed
+	SourceExtensions   []string
+	ExtractSourcesOnly bool
+
+	// Documentation files, such as READMEs and ADRs, whose text is sent
+	// to the agent as context for jobs asking for doc_inputs, and the most
+	// of it sent per project
+	DocInputGlobs    []string
+	DocInputMaxBytes int
+
+	// SkipGenerated leaves out files marked as generated, e.g. "Code
+	// generated ... DO NOT EDIT." or "@generated"; jobs can opt back in
+	SkipGenerated bool
+
+	// FollowSymlinks makes source collection descend into symlinked
+	// directories that stay within the project, each directory once
+	FollowSymlinks bool
+
+	// ErrorTable lists the errors and HTTP error statuses found in each
+	// file's source under its Error Handling section
+	ErrorTable bool
+
+	// SchemaDocs reads SQL files into a Data Model / Schema section and
+	// asks the agent to focus on the schema in SQL and migration files
+	SchemaDocs bool
+
+	// RedactSecrets replaces credentials found in source files with
+	// placeholders before they are sent to the agent
+	RedactSecrets bool
+
+	// ExtractSkipCorrupt skips unreadable archive entries instead of
+	// failing the whole job
+	ExtractSkipCorrupt bool
+
+	// Number of files analyzed in parallel per job and across all jobs, and
+	// whether smaller files are dispatched first
+	AnalyzeConcurrency       int
+	AnalyzeGlobalConcurrency int
+	AnalyzeSmallestFirst     bool
+
+	// AnalyzeAdaptive lets each job's concurrency move between
+	// AnalyzeConcurrencyMin and AnalyzeConcurrencyMax, starting at
+	// AnalyzeConcurrency: up while agent calls average under
+	// AnalyzeLatencyTarget and rarely fail, down when they don't
+	AnalyzeAdaptive       bool
+	AnalyzeConcurrencyMin int
+	AnalyzeConcurrencyMax int
+	AnalyzeLatencyTarget  time.Duration
+
+	// AnalyzeRampUp, when positive, starts each job with AnalyzeRampStart
+	// workers and adds the rest one at a time over this interval, so a
+	// large job doesn't open every agent call at once
+	AnalyzeRampUp    time.Duration
+	AnalyzeRampStart int
+
+	// Files sent to the agent per request; 1 analyzes files one by one
+	AnalyzeBatchSize int
+
+	// Time allowed for one agent request analyzing a single file, and for
+	// a batch request carrying several files
+	AnalyzeTimeout      time.Duration
+	AnalyzeBatchTimeout time.Duration
+
+	// FileAnalysisBudget bounds the whole analysis of one file, retries
+	// included; a file still unfinished is abandoned and dead-lettered so
+	// the job moves on. 0 disables it
+	FileAnalysisBudget time.Duration
+
+	// Extra attempts for a file whose analysis fails, and the delay before
+	// the first retry (doubled on each further retry)
+	AnalyzeRetries    int
+	AnalyzeRetryDelay time.Duration
+
+	// Extra runs of a whole job that failed for a transient reason, such
+	// as the agent being unreachable or the disk full, and the delay
+	// before the first (doubled on each further run)
+	JobRetries    int
+	JobRetryDelay time.Duration
+
+	// Agent confidence scores below this flag a file's section for review;
+	// 0 disables the check
+	MinConfidence float64
+
+	// Consecutive agent failures that open the circuit breaker, failing
+	// jobs fast until the cooldown has passed; 0 disables it
+	AgentBreakerThreshold int
+	AgentBreakerCooldown  time.Duration
+
+	// Default ordering of file sections in the combined document: path,
+	// directory, language or size
+	DocumentOrder string
+
+	// Files larger than this many bytes get a short note instead of being
+	// sent to the agent, unless a job asks for them; 0 disables the check
+	LargeFileThreshold int64
+
+	// Per-language overrides of LargeFileThreshold, e.g.
+	// "JavaScript=200000,Go=2000000"
+	LanguageSizeLimits map[string]int64
+
+	// Default project overview mode: off, aggregate (from the per-file
+	// overviews) or agent (synthesized by one more agent call)
+	ProjectOverview string
+
+	// Default detail level asked of the agent: brief, standard or detailed
+	DocDetail string
+
+	// Line ending of text outputs (markdown, plain text): lf or crlf
+	LineEnding string
+
+	// Sub-projects of a multi-project upload documented at once, and the
+	// order their outputs are listed in: detected or name
+	ProjectConcurrency int
+	ProjectOrder       string
+
+	// Levels every heading is demoted by, for embedding the document under
+	// an external heading
+	HeadingOffset int
+
+	// Maximum source lines embedded per file when include_source is set
+	SourceSnippetMaxLines int
+
+	// Largest single source file an upload with sync=true is documented
+	// for within the request; bigger uploads are processed as usual
+	SyncMaxBytes int64
+
+	// Uploads being saved, downloaded or extracted at once before new ones
+	// are turned away with 503, and the Retry-After sent with it
+	MaxInflightUploads int
+	UploadRetryAfter   time.Duration
+
+	// Limits for archives fetched through /api/upload-url
+	ArchiveDownloadTimeout  time.Duration
+	AllowPrivateArchiveURLs bool
+
+	// Files held open at once across all jobs, and how long to wait for a
+	// free slot before giving up
+	MaxOpenFiles        int
+	OpenFileWaitTimeout time.Duration
+
+	// Optional .docx whose styles the docx output reuses, and
+	// header/footer text applied to every page
+	DocxTemplate string
+	DocxHeader   string
+	DocxFooter   string
+
+	// ValidateDocx checks every generated .docx is well-formed OOXML,
+	// falling back to markdown when it isn't
+	ValidateDocx bool
+
+	// DocFooter ends every document with generation metadata rendered from
+	// DocFooterTemplate; see services.RenderFooter for its placeholders
+	DocFooter         bool
+	DocFooterTemplate string
+
+	// OutputDirTemplate places each job's documents in a subdirectory of
+	// the output root, e.g. "{project}/{date}/"; empty keeps them flat
+	OutputDirTemplate string
+
+	// OutputCollision decides what happens when a document's file name is
+	// already taken: overwrite, suffix (name-1, name-2, ...) or fail
+	OutputCollision string
+
+	// DiskQuota caps the bytes uploads and outputs may occupy together;
+	// new uploads are turned away with 507 beyond it. 0 disables it
+	DiskQuota int64
+
+	// OutputTTL is how long generated documents are kept; 0 keeps them
+	OutputTTL time.Duration
+
+	// JobStallTimeout fails a job that has neither progressed nor logged
+	// anything for this long; it must outlast an agent call with all its
+	// retries
+	JobStallTimeout time.Duration
+
+	// envErrors are the variables New couldn't parse and replaced with
+	// their default; Validate reports them
+	envErrors []error
 }
 
 func New() *Config {
-	return &Config{
-		Port:        getEnv("PORT", "3000"),
-		UploadPath:  getEnv("UPLOAD_PATH", "./uploads"),
-		OutputPath:  getEnv("OUTPUT_PATH", "./output"),
-		MaxFileSize: 100 * 1024 * 1024, // 100MB
+	c := &Config{
+		Port:                     getEnv("PORT", "3000"),
+		UploadPath:               getEnv("UPLOAD_PATH", "./uploads"),
+		OutputPath:               getEnv("OUTPUT_PATH", "./output"),
+		MaxFileSize:              getEnvInt64("MAX_FILE_SIZE", 100*1024*1024), // 100MB
+		JSONBodyLimit:            getEnvInt64("JSON_BODY_LIMIT", 1024*1024),   // 1MB
+		AdminToken:               getEnv("ADMIN_TOKEN", ""),
+		DiagramMaxCount:          getEnvInt("DIAGRAM_MAX_COUNT", 10),
+		DiagramMaxSize:           getEnvInt64("DIAGRAM_MAX_SIZE", 5*1024*1024), // 5MB
+		Analyzer:                 getEnv("ANALYZER", "http"),
+		PromptAugmentationsFile:  getEnv("PROMPT_AUGMENTATIONS_FILE", ""),
+		FormatTemplatesFile:      getEnv("FORMAT_TEMPLATES_FILE", ""),
+		AgentURL:                 getEnv("AGENT_URL", "http://localhost:8000/analyze"),
+		AgentFileField:           getEnv("AGENT_FILE_FIELD", "code_file"),
+		AgentFormatField:         getEnv("AGENT_FORMAT_FIELD", "format"),
+		AgentResponsePath:        getEnv("AGENT_RESPONSE_PATH", "document"),
+		AgentMaxResponseSize:     getEnvInt64("AGENT_MAX_RESPONSE_SIZE", 10*1024*1024), // 10MB
+		AgentMaxIdleConns:        getEnvInt("AGENT_MAX_IDLE_CONNS", 100),
+		AgentMaxIdleConnsPerHost: getEnvInt("AGENT_MAX_IDLE_CONNS_PER_HOST", 16),
+		AgentIdleConnTimeout:     getEnvDuration("AGENT_IDLE_CONN_TIMEOUT", 90*time.Second),
+		SourceExtensions:         getEnvList("SOURCE_EXTENSIONS", []string{".py", ".js", ".ts", ".php", ".go", ".ipynb"}),
+		DocInputGlobs:            getEnvList("DOC_INPUT_GLOBS", []string{"README*", "CHANGELOG*", "docs/**/*.md", "doc/**/*.md"}),
+		DocInputMaxBytes:         getEnvInt("DOC_INPUT_MAX_BYTES", 32*1024),
+		ExtractSourcesOnly:       getEnvBool("EXTRACT_SOURCES_ONLY", false),
+		FollowSymlinks:           getEnvBool("FOLLOW_SYMLINKS", false),
+		ErrorTable:               getEnvBool("ERROR_TABLE", true),
+		SchemaDocs:               getEnvBool("SCHEMA_DOCS", true),
+		SkipGenerated:            getEnvBool("SKIP_GENERATED", true),
+		ExtractSkipCorrupt:       getEnvBool("EXTRACT_SKIP_CORRUPT", false),
+		RedactSecrets:            getEnvBool("REDACT_SECRETS", false),
+		AnalyzeConcurrency:       getEnvInt("ANALYZE_CONCURRENCY", 4),
+		AnalyzeGlobalConcurrency: getEnvInt("ANALYZE_GLOBAL_CONCURRENCY", 16),
+		AnalyzeSmallestFirst:     getEnvBool("ANALYZE_SMALLEST_FIRST", true),
+		AnalyzeAdaptive:          getEnvBool("ANALYZE_ADAPTIVE", false),
+		AnalyzeConcurrencyMin:    getEnvInt("ANALYZE_CONCURRENCY_MIN", 1),
+		AnalyzeConcurrencyMax:    getEnvInt("ANALYZE_CONCURRENCY_MAX", 16),
+		AnalyzeLatencyTarget:     getEnvDuration("ANALYZE_LATENCY_TARGET", 30*time.Second),
+		AnalyzeRampUp:            getEnvDuration("ANALYZE_RAMP_UP", 0),
+		AnalyzeRampStart:         getEnvInt("ANALYZE_RAMP_START", 1),
+		AnalyzeBatchSize:         getEnvInt("ANALYZE_BATCH_SIZE", 1),
+		AnalyzeTimeout:           getEnvDuration("ANALYZE_TIMEOUT", 5*time.Minute),
+		AnalyzeBatchTimeout:      getEnvDuration("ANALYZE_BATCH_TIMEOUT", 15*time.Minute),
+		FileAnalysisBudget:       getEnvDuration("FILE_ANALYSIS_BUDGET", 0),
+		AnalyzeRetries:           getEnvInt("ANALYZE_RETRIES", 2),
+		AnalyzeRetryDelay:        getEnvDuration("ANALYZE_RETRY_DELAY", time.Second),
+		JobRetries:               getEnvInt("JOB_RETRIES", 1),
+		JobRetryDelay:            getEnvDuration("JOB_RETRY_DELAY", 30*time.Second),
+		MinConfidence:            getEnvFloat("MIN_CONFIDENCE", 0),
+		AgentBreakerThreshold:    getEnvInt("AGENT_BREAKER_THRESHOLD", 5),
+		AgentBreakerCooldown:     getEnvDuration("AGENT_BREAKER_COOLDOWN", 30*time.Second),
+		DocumentOrder:            getEnv("DOCUMENT_ORDER", "path"),
+		LargeFileThreshold:       getEnvInt64("LARGE_FILE_THRESHOLD", 1024*1024), // 1MB
+		ProjectConcurrency:       getEnvInt("PROJECT_CONCURRENCY", 1),
+		ProjectOrder:             getEnv("PROJECT_ORDER", "detected"),
+		HeadingOffset:            getEnvInt("HEADING_OFFSET", 0),
+		ProjectOverview:          getEnv("PROJECT_OVERVIEW", "off"),
+		DocDetail:                getEnv("DOC_DETAIL", "standard"),
+		LineEnding:               getEnv("LINE_ENDING", "lf"),
+		LanguageSizeLimits:       getEnvInt64Map("LANGUAGE_SIZE_LIMITS"),
+		SourceSnippetMaxLines:    getEnvInt("SOURCE_SNIPPET_MAX_LINES", 50),
+		SyncMaxBytes:             getEnvInt64("SYNC_MAX_BYTES", 256*1024), // 256KB
+		MaxInflightUploads:       getEnvInt("MAX_INFLIGHT_UPLOADS", 8),
+		UploadRetryAfter:         getEnvDuration("UPLOAD_RETRY_AFTER", 10*time.Second),
+		ArchiveDownloadTimeout:   getEnvDuration("ARCHIVE_DOWNLOAD_TIMEOUT", 2*time.Minute),
+		AllowPrivateArchiveURLs:  getEnvBool("ALLOW_PRIVATE_ARCHIVE_URLS", false),
+		MaxOpenFiles:             getEnvInt("MAX_OPEN_FILES", 256),
+		OpenFileWaitTimeout:      getEnvDuration("OPEN_FILE_WAIT_TIMEOUT", 30*time.Second),
+		DocxTemplate:             getEnv("DOCX_TEMPLATE", ""),
+		DocxHeader:               getEnv("DOCX_HEADER", ""),
+		DocxFooter:               getEnv("DOCX_FOOTER", ""),
+		ValidateDocx:             getEnvBool("VALIDATE_DOCX", false),
+		DocFooter:                getEnvBool("DOC_FOOTER", true),
+		DocFooterTemplate:        getEnv("DOC_FOOTER_TEMPLATE", "Generated by code-doc-tool {version} on {time} (job {job})"),
+		OutputDirTemplate:        getEnv("OUTPUT_DIR_TEMPLATE", ""),
+		OutputCollision:          getEnv("OUTPUT_COLLISION", "overwrite"),
+		DiskQuota:                getEnvInt64("DISK_QUOTA", 0),
+		OutputTTL:                getEnvDuration("OUTPUT_TTL", 0),
+		JobStallTimeout:          getEnvDuration("JOB_STALL_TIMEOUT", time.Hour),
+	}
+	c.envErrors = parseErrors()
+	return c
+}
+
+var (
+	parseErrorsMu sync.Mutex
+	// parseErrorsByKey holds why the value of each variable that failed to
+	// parse was ignored
+	parseErrorsByKey = map[string]error{}
+)
+
+// recordParse notes whether the value of key parsed.
+func recordParse(key, value string, err error) {
+	parseErrorsMu.Lock()
+	defer parseErrorsMu.Unlock()
+	if err == nil {
+		delete(parseErrorsByKey, key)
+		return
 	}
+	parseErrorsByKey[key] = fmt.Errorf("%s: cannot parse %q", key, value)
+}
+
+// parseErrors returns the recorded parse failures, ordered by variable.
+func parseErrors() []error {
+	parseErrorsMu.Lock()
+	defer parseErrorsMu.Unlock()
+	keys := make([]string, 0, len(parseErrorsByKey))
+	for key := range parseErrorsByKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = parseErrorsByKey[key]
+	}
+	return errs
 }
 
 func getEnv(key, defaultValue string) string {
@@ -26,3 +363,89 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		n, err := strconv.Atoi(value)
+		recordParse(key, value, err)
+		if err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		recordParse(key, value, err)
+		if err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		f, err := strconv.ParseFloat(value, 64)
+		recordParse(key, value, err)
+		if err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		b, err := strconv.ParseBool(value)
+		recordParse(key, value, err)
+		if err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma separated list.
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvInt64Map reads a comma separated list of key=number pairs. Entries
+// with a malformed number are kept as -1 so validation can report them.
+func getEnvInt64Map(key string) map[string]int64 {
+	values := map[string]int64{}
+	for _, item := range getEnvList(key, nil) {
+		k, v, _ := strings.Cut(item, "=")
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			n = -1
+		}
+		values[strings.TrimSpace(k)] = n
+	}
+	return values
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		d, err := time.ParseDuration(value)
+		recordParse(key, value, err)
+		if err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
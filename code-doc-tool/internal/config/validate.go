@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks that every setting is within a usable range so the server
+// fails fast at startup instead of misbehaving at runtime.
+func (c *Config) Validate() error {
+	// Values that didn't parse were replaced by defaults, which would hide
+	// the mistake
+	errs := append([]error(nil), c.envErrors...)
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port <= 65535, "PORT must be between 1 and 65535, got %q", c.Port)
+	check(c.UploadPath != "", "UPLOAD_PATH must not be empty")
+	check(c.OutputPath != "", "OUTPUT_PATH must not be empty")
+	check(c.MaxFileSize > 0, "MAX_FILE_SIZE must be positive, got %d", c.MaxFileSize)
+	check(c.JSONBodyLimit > 0 && c.JSONBodyLimit <= c.MaxFileSize,
+		"JSON_BODY_LIMIT must be between 1 and MAX_FILE_SIZE, got %d", c.JSONBodyLimit)
+	check(c.DiagramMaxCount >= 0, "DIAGRAM_MAX_COUNT must not be negative, got %d", c.DiagramMaxCount)
+	check(c.DiagramMaxSize > 0 && c.DiagramMaxSize <= c.MaxFileSize,
+		"DIAGRAM_MAX_SIZE must be between 1 and MAX_FILE_SIZE, got %d", c.DiagramMaxSize)
+
+	check(c.Analyzer == "http" || c.Analyzer == "stub", "ANALYZER must be http or stub, got %q", c.Analyzer)
+	agentURL, err := url.Parse(c.AgentURL)
+	check(err == nil && (agentURL.Scheme == "http" || agentURL.Scheme == "https") && agentURL.Host != "",
+		"AGENT_URL must be an http(s) URL, got %q", c.AgentURL)
+	check(c.AgentFileField != "", "AGENT_FILE_FIELD must not be empty")
+	check(c.AgentFormatField != "", "AGENT_FORMAT_FIELD must not be empty")
+	check(!slices.Contains(strings.Split(c.AgentResponsePath, "."), ""), "AGENT_RESPONSE_PATH must be a dotted path such as result.document, got %q", c.AgentResponsePath)
+	check(c.AgentMaxResponseSize > 0, "AGENT_MAX_RESPONSE_SIZE must be positive, got %d", c.AgentMaxResponseSize)
+
+	check(len(c.SourceExtensions) > 0, "SOURCE_EXTENSIONS must list at least one extension")
+	for _, ext := range c.SourceExtensions {
+		check(strings.HasPrefix(ext, "."), "SOURCE_EXTENSIONS entries must start with a dot, got %q", ext)
+	}
+	check(c.DocInputMaxBytes > 0, "DOC_INPUT_MAX_BYTES must be positive, got %d", c.DocInputMaxBytes)
+	check(c.AnalyzeConcurrency >= 1, "ANALYZE_CONCURRENCY must be at least 1, got %d", c.AnalyzeConcurrency)
+	if c.AnalyzeAdaptive {
+		check(c.AnalyzeConcurrencyMin >= 1, "ANALYZE_CONCURRENCY_MIN must be at least 1, got %d", c.AnalyzeConcurrencyMin)
+		check(c.AnalyzeConcurrencyMax >= c.AnalyzeConcurrencyMin,
+			"ANALYZE_CONCURRENCY_MAX must be at least ANALYZE_CONCURRENCY_MIN, got %d", c.AnalyzeConcurrencyMax)
+		check(c.AnalyzeLatencyTarget > 0, "ANALYZE_LATENCY_TARGET must be positive, got %s", c.AnalyzeLatencyTarget)
+	}
+	check(c.AnalyzeRampUp >= 0, "ANALYZE_RAMP_UP must not be negative, got %s", c.AnalyzeRampUp)
+	if c.AnalyzeRampUp > 0 {
+		check(c.AnalyzeRampStart >= 1, "ANALYZE_RAMP_START must be at least 1, got %d", c.AnalyzeRampStart)
+	}
+	check(c.AgentMaxIdleConns >= 0, "AGENT_MAX_IDLE_CONNS must not be negative, got %d", c.AgentMaxIdleConns)
+	check(c.AgentMaxIdleConnsPerHost >= 1, "AGENT_MAX_IDLE_CONNS_PER_HOST must be at least 1, got %d", c.AgentMaxIdleConnsPerHost)
+	check(c.AgentIdleConnTimeout >= 0, "AGENT_IDLE_CONN_TIMEOUT must not be negative, got %s", c.AgentIdleConnTimeout)
+	check(c.AnalyzeGlobalConcurrency >= 1, "ANALYZE_GLOBAL_CONCURRENCY must be at least 1, got %d", c.AnalyzeGlobalConcurrency)
+	check(c.AnalyzeBatchSize >= 1, "ANALYZE_BATCH_SIZE must be at least 1, got %d", c.AnalyzeBatchSize)
+	check(c.AnalyzeTimeout > 0, "ANALYZE_TIMEOUT must be positive, got %s", c.AnalyzeTimeout)
+	check(c.AgentBreakerThreshold >= 0, "AGENT_BREAKER_THRESHOLD must not be negative, got %d", c.AgentBreakerThreshold)
+	check(c.AgentBreakerCooldown > 0, "AGENT_BREAKER_COOLDOWN must be positive, got %s", c.AgentBreakerCooldown)
+	check(c.FileAnalysisBudget >= 0, "FILE_ANALYSIS_BUDGET must not be negative, got %s", c.FileAnalysisBudget)
+	check(c.AnalyzeBatchTimeout >= c.AnalyzeTimeout, "ANALYZE_BATCH_TIMEOUT must be at least ANALYZE_TIMEOUT (%s), got %s", c.AnalyzeTimeout, c.AnalyzeBatchTimeout)
+	check(c.AnalyzeRetries >= 0, "ANALYZE_RETRIES must not be negative, got %d", c.AnalyzeRetries)
+	check(c.AnalyzeRetryDelay >= 0, "ANALYZE_RETRY_DELAY must not be negative, got %s", c.AnalyzeRetryDelay)
+	check(c.JobRetries >= 0, "JOB_RETRIES must not be negative, got %d", c.JobRetries)
+	check(c.JobRetryDelay >= 0, "JOB_RETRY_DELAY must not be negative, got %s", c.JobRetryDelay)
+	switch c.DocumentOrder {
+	case "path", "directory", "language", "size":
+	default:
+		check(false, "DOCUMENT_ORDER must be one of path, directory, language, size, got %q", c.DocumentOrder)
+	}
+	switch c.ProjectOverview {
+	case "off", "aggregate", "agent":
+	default:
+		check(false, "PROJECT_OVERVIEW must be one of off, aggregate, agent, got %q", c.ProjectOverview)
+	}
+	switch c.DocDetail {
+	case "brief", "standard", "detailed":
+	default:
+		check(false, "DOC_DETAIL must be one of brief, standard, detailed, got %q", c.DocDetail)
+	}
+	switch c.OutputCollision {
+	case "overwrite", "suffix", "fail":
+	default:
+		check(false, "OUTPUT_COLLISION must be one of overwrite, suffix, fail, got %q", c.OutputCollision)
+	}
+	check(strings.EqualFold(c.LineEnding, "lf") || strings.EqualFold(c.LineEnding, "crlf"), "LINE_ENDING must be lf or crlf, got %q", c.LineEnding)
+	check(c.MinConfidence >= 0 && c.MinConfidence <= 1, "MIN_CONFIDENCE must be between 0 and 1, got %g", c.MinConfidence)
+	check(c.ProjectConcurrency >= 1, "PROJECT_CONCURRENCY must be at least 1, got %d", c.ProjectConcurrency)
+	check(c.ProjectOrder == "detected" || c.ProjectOrder == "name", "PROJECT_ORDER must be detected or name, got %q", c.ProjectOrder)
+	check(c.LargeFileThreshold >= 0, "LARGE_FILE_THRESHOLD must not be negative, got %d", c.LargeFileThreshold)
+	check(c.HeadingOffset >= 0 && c.HeadingOffset <= 5, "HEADING_OFFSET must be between 0 and 5, got %d", c.HeadingOffset)
+	for lang, limit := range c.LanguageSizeLimits {
+		check(lang != "" && limit >= 0, "LANGUAGE_SIZE_LIMITS entries must be Language=bytes, got %q=%d", lang, limit)
+	}
+	check(c.SourceSnippetMaxLines >= 0, "SOURCE_SNIPPET_MAX_LINES must not be negative, got %d", c.SourceSnippetMaxLines)
+	check(c.SyncMaxBytes >= 0, "SYNC_MAX_BYTES must not be negative, got %d", c.SyncMaxBytes)
+	check(c.MaxInflightUploads >= 1, "MAX_INFLIGHT_UPLOADS must be at least 1, got %d", c.MaxInflightUploads)
+	check(c.UploadRetryAfter >= time.Second, "UPLOAD_RETRY_AFTER must be at least 1s, got %s", c.UploadRetryAfter)
+	check(c.ArchiveDownloadTimeout > 0, "ARCHIVE_DOWNLOAD_TIMEOUT must be positive, got %s", c.ArchiveDownloadTimeout)
+	// Extraction holds the archive and one output file open at once
+	check(c.MaxOpenFiles >= 2, "MAX_OPEN_FILES must be at least 2, got %d", c.MaxOpenFiles)
+	check(c.OpenFileWaitTimeout > 0, "OPEN_FILE_WAIT_TIMEOUT must be positive, got %s", c.OpenFileWaitTimeout)
+	if c.PromptAugmentationsFile != "" {
+		_, err := os.Stat(c.PromptAugmentationsFile)
+		check(err == nil, "PROMPT_AUGMENTATIONS_FILE must be an existing file, got %q", c.PromptAugmentationsFile)
+	}
+	if c.FormatTemplatesFile != "" {
+		_, err := os.Stat(c.FormatTemplatesFile)
+		check(err == nil, "FORMAT_TEMPLATES_FILE must be an existing file, got %q", c.FormatTemplatesFile)
+	}
+	if c.DocxTemplate != "" {
+		info, err := os.Stat(c.DocxTemplate)
+		check(err == nil && !info.IsDir(), "DOCX_TEMPLATE must be an existing file, got %q", c.DocxTemplate)
+		check(strings.EqualFold(filepath.Ext(c.DocxTemplate), ".docx"), "DOCX_TEMPLATE must be a .docx file, got %q", c.DocxTemplate)
+	}
+	if c.OutputDirTemplate != "" {
+		unknown := regexp.MustCompile(`\{[^}]*\}`).ReplaceAllStringFunc(c.OutputDirTemplate, func(p string) string {
+			if p == "{project}" || p == "{job}" || p == "{date}" {
+				return ""
+			}
+			return p
+		})
+		check(!strings.Contains(unknown, "{"), "OUTPUT_DIR_TEMPLATE supports {project}, {job} and {date}, got %q", c.OutputDirTemplate)
+		check(!filepath.IsAbs(c.OutputDirTemplate) && !strings.Contains(c.OutputDirTemplate, ".."),
+			"OUTPUT_DIR_TEMPLATE must be a relative path without .., got %q", c.OutputDirTemplate)
+	}
+	if c.DocFooter {
+		unknown := regexp.MustCompile(`\{[^}]*\}`).ReplaceAllStringFunc(c.DocFooterTemplate, func(p string) string {
+			if p == "{version}" || p == "{job}" || p == "{time}" || p == "{date}" {
+				return ""
+			}
+			return p
+		})
+		check(strings.TrimSpace(c.DocFooterTemplate) != "", "DOC_FOOTER_TEMPLATE must not be empty while DOC_FOOTER is on")
+		check(!strings.Contains(unknown, "{"), "DOC_FOOTER_TEMPLATE supports {version}, {job}, {time} and {date}, got %q", c.DocFooterTemplate)
+	}
+	check(c.DiskQuota >= 0, "DISK_QUOTA must not be negative, got %d", c.DiskQuota)
+	check(c.OutputTTL >= 0, "OUTPUT_TTL must not be negative, got %s", c.OutputTTL)
+	check(c.JobStallTimeout >= 0, "JOB_STALL_TIMEOUT must not be negative, got %s", c.JobStallTimeout)
+	if c.JobStallTimeout > 0 {
+		wait := c.longestAgentWait()
+		check(c.JobStallTimeout > wait, "JOB_STALL_TIMEOUT must be longer than an agent call with all its retries (%s), got %s", wait, c.JobStallTimeout)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// longestAgentWait is how long a healthy job may wait on one agent call
+// without progressing: every attempt running into the batch timeout (any
+// job may batch), plus the doubling delays between them.
+func (c *Config) longestAgentWait() time.Duration {
+	timeout := max(c.AnalyzeTimeout, c.AnalyzeBatchTimeout)
+	wait := time.Duration(0)
+	delay := c.AnalyzeRetryDelay
+	for attempt := 0; attempt <= c.AnalyzeRetries; attempt++ {
+		wait += timeout
+		if attempt < c.AnalyzeRetries {
+			wait += delay
+			delay *= 2
+		}
+	}
+	return wait
+}
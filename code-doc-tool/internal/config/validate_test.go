@@ -0,0 +1,137 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	if err := New().Validate(); err != nil {
+		t.Fatalf("default configuration is invalid: %v", err)
+	}
+}
+
+func TestLongestAgentWait(t *testing.T) {
+	// Three 15m batch calls and the 1s and 2s delays between them
+	c := New()
+	if got, want := c.longestAgentWait(), 45*time.Minute+3*time.Second; got != want {
+		t.Errorf("default longest agent wait %s, want %s", got, want)
+	}
+	if c.JobStallTimeout <= c.longestAgentWait() {
+		t.Errorf("default JOB_STALL_TIMEOUT %s fails healthy jobs", c.JobStallTimeout)
+	}
+	c.AnalyzeRetries = 0
+	if got := c.longestAgentWait(); got != c.AnalyzeBatchTimeout {
+		t.Errorf("longest wait without retries %s, want the batch timeout", got)
+	}
+}
+
+func TestValidateRetrySettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"negative retries", func(c *Config) { c.AnalyzeRetries = -1 }, "ANALYZE_RETRIES"},
+		{"negative delay", func(c *Config) { c.AnalyzeRetryDelay = -time.Second }, "ANALYZE_RETRY_DELAY"},
+		{"negative job retries", func(c *Config) { c.JobRetries = -1 }, "JOB_RETRIES"},
+		{"zero timeout", func(c *Config) { c.AnalyzeTimeout = 0 }, "ANALYZE_TIMEOUT"},
+		{"batch timeout below timeout", func(c *Config) { c.AnalyzeBatchTimeout = time.Second }, "ANALYZE_BATCH_TIMEOUT"},
+		{"negative ramp-up", func(c *Config) { c.AnalyzeRampUp = -time.Second }, "ANALYZE_RAMP_UP"},
+		{"ramp starting without workers", func(c *Config) { c.AnalyzeRampUp = time.Second; c.AnalyzeRampStart = 0 }, "ANALYZE_RAMP_START"},
+		{"stall timeout within a batch call", func(c *Config) { c.JobStallTimeout = 10 * time.Minute }, "JOB_STALL_TIMEOUT"},
+		{"stall timeout within the retries", func(c *Config) { c.JobStallTimeout = 45 * time.Minute }, "JOB_STALL_TIMEOUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			tt.change(c)
+			if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsUnparsedValues(t *testing.T) {
+	t.Setenv("ANALYZE_RETRIES", "two")
+	t.Setenv("ANALYZE_RETRY_DELAY", "5")
+	t.Setenv("REDACT_SECRETS", "yes please")
+	c := New()
+	if c.AnalyzeRetries != 2 || c.AnalyzeRetryDelay != time.Second {
+		t.Errorf("defaults not kept: %d, %s", c.AnalyzeRetries, c.AnalyzeRetryDelay)
+	}
+	err := c.Validate()
+	for _, want := range []string{`ANALYZE_RETRIES: cannot parse "two"`, `ANALYZE_RETRY_DELAY: cannot parse "5"`, "REDACT_SECRETS"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want it to mention %s", err, want)
+		}
+	}
+
+	t.Setenv("ANALYZE_RETRIES", "1")
+	t.Setenv("ANALYZE_RETRY_DELAY", "5s")
+	t.Setenv("REDACT_SECRETS", "true")
+	if err := New().Validate(); err != nil {
+		t.Errorf("fixed values still reported: %v", err)
+	}
+}
+
+func TestLanguageSizeLimits(t *testing.T) {
+	t.Setenv("LANGUAGE_SIZE_LIMITS", "JavaScript=200000, Go=2000000")
+	c := New()
+	if c.LanguageSizeLimits["JavaScript"] != 200000 || c.LanguageSizeLimits["Go"] != 2000000 {
+		t.Errorf("got %v", c.LanguageSizeLimits)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	t.Setenv("LANGUAGE_SIZE_LIMITS", "JavaScript=lots")
+	if err := New().Validate(); err == nil || !strings.Contains(err.Error(), "LANGUAGE_SIZE_LIMITS") {
+		t.Errorf("got %v, want an error about LANGUAGE_SIZE_LIMITS", err)
+	}
+}
+
+func TestValidateOutputDirTemplate(t *testing.T) {
+	for template, ok := range map[string]bool{
+		"{project}/{date}/":   true,
+		"docs/{job}":          true,
+		"/srv/docs/{project}": false,
+		"../{project}":        false,
+		"{project}/../..":     false,
+		"{team}/{project}":    false,
+	} {
+		c := New()
+		c.OutputDirTemplate = template
+		err := c.Validate()
+		if ok && err != nil {
+			t.Errorf("%q rejected: %v", template, err)
+		}
+		if !ok && (err == nil || !strings.Contains(err.Error(), "OUTPUT_DIR_TEMPLATE")) {
+			t.Errorf("%q: got %v, want an error about OUTPUT_DIR_TEMPLATE", template, err)
+		}
+	}
+}
+
+func TestValidateAgentResponsePath(t *testing.T) {
+	for path, ok := range map[string]bool{
+		"document":         true,
+		"result.document":  true,
+		"data.output.text": true,
+		"":                 false,
+		".document":        false,
+		"result..document": false,
+		"result.document.": false,
+	} {
+		c := New()
+		c.AgentResponsePath = path
+		err := c.Validate()
+		if ok && err != nil {
+			t.Errorf("%q rejected: %v", path, err)
+		}
+		if !ok && (err == nil || !strings.Contains(err.Error(), "AGENT_RESPONSE_PATH")) {
+			t.Errorf("%q: got %v, want an error about AGENT_RESPONSE_PATH", path, err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PauseProcessing stops new jobs from starting: uploads are still
+// accepted but their jobs stay queued until ResumeProcessing. Jobs
+// already processing carry on.
+func PauseProcessing(c *fiber.Ctx) error {
+	if dispatch.Close() {
+		log.Printf("Processing paused; new jobs will be queued")
+	}
+	return c.JSON(fiber.Map{"paused": true})
+}
+
+// ResumeProcessing starts the queued jobs and lets new ones start again.
+func ResumeProcessing(c *fiber.Ctx) error {
+	if dispatch.Open() {
+		log.Printf("Processing resumed")
+	}
+	return c.JSON(fiber.Map{"paused": false})
+}
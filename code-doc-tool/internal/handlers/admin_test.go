@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+)
+
+// adminRequest sends an admin request with the configured token.
+func adminRequest(t *testing.T, app *fiber.App, target string) {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodPost, target, nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+cfg.AdminToken)
+	if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("%s returned %d: %s", target, resp.StatusCode, body)
+	}
+}
+
+// statsPaused reads the paused flag from /api/stats.
+func statsPaused(t *testing.T, app *fiber.App) bool {
+	t.Helper()
+	_, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/stats", nil))
+	var stats models.JobStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		t.Fatal(err)
+	}
+	return stats.Paused
+}
+
+func TestPauseResume(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AdminToken = "s3cret"
+		c.MaxInflightUploads = 1
+	})
+	app := newTestApp()
+
+	adminRequest(t, app, "/api/admin/pause")
+	if !statsPaused(t, app) {
+		t.Error("stats don't report the pause")
+	}
+
+	// More uploads than intake slots all queue while paused
+	var queued []string
+	for range 2 {
+		jobID := upload(t, app, testProject, map[string]string{"format": "md"})
+		queued = append(queued, jobID)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, jobID := range queued {
+		if job, _ := jobs.Get(jobID); job.Status != models.JobStatusQueued {
+			t.Fatalf("job %s while paused, want queued", job.Status)
+		}
+	}
+
+	adminRequest(t, app, "/api/admin/resume")
+	if statsPaused(t, app) {
+		t.Error("stats still report the pause")
+	}
+	for _, jobID := range queued {
+		if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+			t.Errorf("job %s: %s", job.Status, job.Message)
+		}
+	}
+}
+
+func TestPauseRequiresAdmin(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.AdminToken = "s3cret" })
+	app := newTestApp()
+
+	for _, target := range []string{"/api/admin/pause", "/api/admin/resume"} {
+		resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodPost, target, nil))
+		if resp.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("%s without a token got %d %s, want 401", target, resp.StatusCode, body)
+		}
+	}
+	if statsPaused(t, app) {
+		t.Error("an anonymous request paused processing")
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/services"
+)
+
+// agentCheckTimeout bounds the preflight so a hung agent can't hang the
+// check itself.
+const agentCheckTimeout = 30 * time.Second
+
+// AgentCheck verifies the configured agent speaks the expected protocol by
+// sending it a small known file.
+func AgentCheck(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(services.WithTraceID(context.Background(), traceID(c)), agentCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := services.CheckAgent(ctx, cfg); err != nil {
+		return errorResponse(c, fiber.StatusBadGateway, ErrCodeAgentCheckFailed, "Agent check failed: "+err.Error())
+	}
+	return c.JSON(fiber.Map{
+		"status":     "pass",
+		"agent_url":  cfg.AgentURL,
+		"latency_ms": time.Since(start).Milliseconds(),
+	})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+)
+
+func TestAgentCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		agent  http.HandlerFunc
+		status int
+	}{
+		{"compliant", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]string{"document": "## Overview\nA probe."})
+		}, fiber.StatusOK},
+		{"wrong envelope", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]string{"text": "## Overview"})
+		}, fiber.StatusBadGateway},
+		{"not json", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>hello</html>"))
+		}, fiber.StatusBadGateway},
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}, fiber.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := httptest.NewServer(tt.agent)
+			defer agent.Close()
+			setupTest(t, func(c *config.Config) {
+				c.AgentURL = agent.URL + "/analyze"
+			})
+			resp, body := doRequest(t, newTestApp(), httptest.NewRequest(fiber.MethodGet, "/api/agent-check", nil))
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got %d %s, want %d", resp.StatusCode, body, tt.status)
+			}
+			if tt.status != fiber.StatusOK && errorCode(t, body) != ErrCodeAgentCheckFailed {
+				t.Errorf("error code: %s", body)
+			}
+		})
+	}
+}
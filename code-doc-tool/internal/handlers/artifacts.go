@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/services"
+)
+
+// artifact describes one file a client can fetch for a job.
+type artifact struct {
+	Kind        string `json:"kind"`
+	Project     string `json:"project,omitempty"`
+	Format      string `json:"format"`
+	Size        int64  `json:"size,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	ContentType string `json:"content_type"`
+	DownloadURL string `json:"download_url"`
+}
+
+// GetJobArtifacts lists everything a job has produced that still exists:
+// its documents in each format, the markdown behind them and its log.
+func GetJobArtifacts(c *fiber.Ctx) error {
+	jobID := c.Params("jobId")
+	job, ok := jobs.Get(jobID)
+	if !ok {
+		return errorResponse(c, fiber.StatusNotFound, ErrCodeJobNotFound, "Job not found")
+	}
+
+	artifacts := []artifact{}
+	for _, output := range job.Outputs {
+		for _, filename := range append([]string{output.Filename}, output.Artifacts...) {
+			info, err := os.Stat(filepath.Join("./output", filename))
+			if err != nil {
+				continue
+			}
+			artifacts = append(artifacts, artifact{
+				Kind:        "document",
+				Project:     output.Project,
+				Format:      strings.TrimPrefix(filepath.Ext(filename), "."),
+				Size:        info.Size(),
+				SHA256:      output.Digests[filename],
+				ContentType: services.ContentTypeFor(filename),
+				DownloadURL: "/api/download/" + filename,
+			})
+		}
+
+		if info, err := os.Stat(services.MarkdownPath(output.Filename)); err == nil {
+			downloadURL := "/api/jobs/" + jobID + "/markdown"
+			if len(job.Outputs) > 1 {
+				downloadURL += "?project=" + url.QueryEscape(output.Project)
+			}
+			artifacts = append(artifacts, artifact{
+				Kind:        "markdown",
+				Project:     output.Project,
+				Format:      "md",
+				Size:        info.Size(),
+				ContentType: "text/markdown; charset=utf-8",
+				DownloadURL: downloadURL,
+			})
+		}
+	}
+
+	// The log lives in memory and is served a page at a time
+	artifacts = append(artifacts, artifact{
+		Kind:        "log",
+		Format:      "json",
+		ContentType: fiber.MIMEApplicationJSON,
+		DownloadURL: "/api/jobs/" + jobID + "/log",
+	})
+
+	return c.JSON(fiber.Map{
+		"job_id":    jobID,
+		"status":    job.Status,
+		"artifacts": artifacts,
+	})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+)
+
+func TestJobArtifacts(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md,txt"})
+	if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+
+	resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/artifacts", nil))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("got %d %s", resp.StatusCode, body)
+	}
+	var manifest struct {
+		Artifacts []artifact `json:"artifacts"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	documents := map[string]artifact{}
+	kinds := map[string]int{}
+	for _, a := range manifest.Artifacts {
+		kinds[a.Kind]++
+		if a.Kind == "document" {
+			documents[a.Format] = a
+		}
+	}
+	if kinds["document"] != 2 || kinds["markdown"] != 1 || kinds["log"] != 1 {
+		t.Fatalf("artifacts %+v, want two documents, the markdown and the log", manifest.Artifacts)
+	}
+	for format, contentType := range map[string]string{"md": "text/markdown", "txt": "text/plain"} {
+		a, ok := documents[format]
+		if !ok {
+			t.Errorf("no %s document", format)
+			continue
+		}
+		filename := jobID + "_documentation." + format
+		if a.DownloadURL != "/api/download/"+filename {
+			t.Errorf("%s download URL %s", format, a.DownloadURL)
+		}
+		info, err := os.Stat(filepath.Join("./output", filename))
+		if err != nil || a.Size != info.Size() {
+			t.Errorf("%s size %d, file %v", format, a.Size, err)
+		}
+		if a.ContentType != services.ContentTypeFor(filename) || !strings.HasPrefix(a.ContentType, contentType) {
+			t.Errorf("%s content type %q", format, a.ContentType)
+		}
+		resp, data := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, a.DownloadURL, nil))
+		if resp.StatusCode != fiber.StatusOK || int64(len(data)) != a.Size {
+			t.Errorf("download of %s: %d, %d bytes", a.DownloadURL, resp.StatusCode, len(data))
+		}
+	}
+
+	// Files removed since are left out
+	os.Remove(filepath.Join("./output", jobID+"_documentation.txt"))
+	_, body = doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/artifacts", nil))
+	manifest.Artifacts = nil
+	json.Unmarshal(body, &manifest)
+	for _, a := range manifest.Artifacts {
+		if a.Format == "txt" {
+			t.Errorf("deleted txt output still listed: %+v", a)
+		}
+	}
+
+	resp, body = doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/00000000-0000-0000-0000-000000000000/artifacts", nil))
+	if resp.StatusCode != fiber.StatusNotFound || errorCode(t, body) != ErrCodeJobNotFound {
+		t.Errorf("unknown job got %d %s", resp.StatusCode, body)
+	}
+}
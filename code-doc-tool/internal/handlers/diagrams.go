@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"mime/multipart"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/services"
+	"code-doc-tool/internal/utils"
+)
+
+// uploadedDiagram is an architecture diagram sent with an upload, checked
+// to be an image and named as it will be stored.
+type uploadedDiagram struct {
+	file *multipart.FileHeader
+	name string
+}
+
+// formDiagrams checks the images sent in the "diagrams" field of a
+// multipart upload. Requests without the field have none.
+func formDiagrams(c *fiber.Ctx) ([]uploadedDiagram, error) {
+	if mediaType(string(c.Request().Header.ContentType())) != fiber.MIMEMultipartForm {
+		return nil, nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, nil
+	}
+	files := form.File["diagrams"]
+	if len(files) > cfg.DiagramMaxCount {
+		return nil, fmt.Errorf("at most %d diagrams can be uploaded, got %d", cfg.DiagramMaxCount, len(files))
+	}
+
+	diagrams := make([]uploadedDiagram, 0, len(files))
+	for i, file := range files {
+		if file.Size > cfg.DiagramMaxSize {
+			return nil, fmt.Errorf("diagram %s is larger than %d bytes", file.Filename, cfg.DiagramMaxSize)
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("diagram %s could not be read", file.Filename)
+		}
+		ext, err := services.DiagramExtension(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Filename, err)
+		}
+		diagrams = append(diagrams, uploadedDiagram{file: file, name: services.DiagramFilename(i+1, file.Filename, ext)})
+	}
+	return diagrams, nil
+}
+
+// saveDiagrams stores a job's diagrams where its Architecture section
+// picks them up.
+func saveDiagrams(c *fiber.Ctx, jobID string, diagrams []uploadedDiagram) error {
+	if len(diagrams) == 0 {
+		return nil
+	}
+	dir := services.DiagramDir(jobID)
+	if err := utils.CreateDir(dir); err != nil {
+		return err
+	}
+	for _, d := range diagrams {
+		if err := c.SaveFile(d.file, filepath.Join(dir, d.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
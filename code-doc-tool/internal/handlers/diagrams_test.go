@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+)
+
+// diagramUpload builds a multipart upload of files as a zip archive with
+// diagrams, keyed by filename, in the "diagrams" field.
+func diagramUpload(t *testing.T, files map[string]string, fields map[string]string, diagrams ...[2]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		w.WriteField(name, value)
+	}
+	part, _ := w.CreateFormFile("codebase", "project.zip")
+	part.Write(testZip(t, files))
+	for _, d := range diagrams {
+		part, _ := w.CreateFormFile("diagrams", d[0])
+		part.Write([]byte(d[1]))
+	}
+	w.Close()
+
+	req := httptest.NewRequest(fiber.MethodPost, "/api/upload", &body)
+	req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+	return req
+}
+
+func TestUploadDiagrams(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 8, 6)))
+
+	req := diagramUpload(t, testProject, map[string]string{"format": "md"}, [2]string{"data flow.png", img.String()})
+	resp, body := doRequest(t, app, req)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+	}
+	var uploaded UploadResponse
+	json.Unmarshal(body, &uploaded)
+	job := waitJob(t, uploaded.JobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+
+	stored := filepath.Join(services.DiagramDir(job.ID), "01-data-flow.png")
+	if data, err := os.ReadFile(stored); err != nil || !bytes.Equal(data, img.Bytes()) {
+		t.Fatalf("diagram not stored: %v", err)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	want := "# Architecture\n\n![Figure 1: data flow](.cache/diagrams/" + job.ID + "/01-data-flow.png)"
+	if !strings.Contains(doc, want) {
+		t.Errorf("document is missing %q:\n%s", want, doc)
+	}
+}
+
+func TestUploadInvalidDiagram(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.DiagramMaxCount = 1 })
+	app := newTestApp()
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	tests := map[string][][2]string{
+		"not an image":   {{"notes.png", "just text"}},
+		"too many":       {{"a.png", img.String()}, {"b.png", img.String()}},
+		"svg is refused": {{"flow.svg", `<svg xmlns="http://www.w3.org/2000/svg"/>`}},
+	}
+	for name, diagrams := range tests {
+		resp, body := doRequest(t, app, diagramUpload(t, testProject, nil, diagrams...))
+		if resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeInvalidDiagram {
+			t.Errorf("%s: got %d %s, want 400 %s", name, resp.StatusCode, body, ErrCodeInvalidDiagram)
+		}
+	}
+}
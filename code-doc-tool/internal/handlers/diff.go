@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/services"
+)
+
+// DiffDocuments compares the combined markdown of two jobs, given as the
+// from and to query parameters. ?project= picks one project of
+// multi-project jobs.
+func DiffDocuments(c *fiber.Ctx) error {
+	from, to := c.Query("from"), c.Query("to")
+	for _, id := range []string{from, to} {
+		if !isJobID(id) {
+			return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, "from and to must be job IDs")
+		}
+	}
+
+	fromDoc, err := jobMarkdown(from, c.Query("project"))
+	if err != nil {
+		return markdownError(c, from, err)
+	}
+	toDoc, err := jobMarkdown(to, c.Query("project"))
+	if err != nil {
+		return markdownError(c, to, err)
+	}
+
+	diff := services.DiffDocuments(from, fromDoc, to, toDoc)
+	return c.JSON(fiber.Map{
+		"from":             from,
+		"to":               to,
+		"identical":        diff.Unified == "",
+		"added_sections":   diff.Added,
+		"removed_sections": diff.Removed,
+		"changed_sections": diff.Changed,
+		"unified":          diff.Unified,
+	})
+}
+
+// markdownError answers a request for a job's markdown that jobMarkdown
+// couldn't read.
+func markdownError(c *fiber.Ctx, jobID string, err error) error {
+	if errors.Is(err, errProjectNotFound) {
+		return errorResponse(c, fiber.StatusNotFound, ErrCodeNotFound, "Project not found in job "+jobID)
+	}
+	return errorResponse(c, fiber.StatusConflict, ErrCodeMarkdownUnavailable, "No cached markdown for job "+jobID)
+}
+
+// errProjectNotFound is returned for a project a job didn't document.
+var errProjectNotFound = errors.New("project not found in job")
+
+// jobMarkdown reads the combined markdown saved for a job, picking the
+// named project's document when the job produced several.
+func jobMarkdown(jobID, project string) (string, error) {
+	filename := outputFilename(jobID, "md")
+	if job, ok := jobs.Get(jobID); ok && len(job.Outputs) > 0 {
+		filename = job.Outputs[0].Filename
+		if project != "" {
+			filename = ""
+			for _, output := range job.Outputs {
+				if output.Project == project {
+					filename = output.Filename
+				}
+			}
+			if filename == "" {
+				return "", errProjectNotFound
+			}
+		}
+	}
+
+	data, err := os.ReadFile(services.MarkdownPath(filename))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	changed := map[string]string{}
+	for name, content := range testProject {
+		changed[name] = content
+	}
+	delete(changed, "util.go")
+	changed["extra.go"] = "package main\n\nfunc Extra() {}\n\nfunc More() {}\n"
+
+	var ids []string
+	for _, files := range []map[string]string{testProject, changed} {
+		jobID := upload(t, app, files, map[string]string{"format": "md"})
+		if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+			t.Fatalf("job %s: %s", job.Status, job.Message)
+		}
+		ids = append(ids, jobID)
+	}
+
+	get := func(query string) (int, []byte) {
+		resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/diff?"+query, nil))
+		return resp.StatusCode, body
+	}
+
+	status, body := get("from=" + ids[0] + "&to=" + ids[1])
+	if status != fiber.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	var diff struct {
+		Identical bool     `json:"identical"`
+		Added     []string `json:"added_sections"`
+		Removed   []string `json:"removed_sections"`
+	}
+	json.Unmarshal(body, &diff)
+	if diff.Identical || !slices.Contains(diff.Added, "extra.go") || !slices.Contains(diff.Removed, "util.go") {
+		t.Errorf("diff %s", body)
+	}
+
+	missing := "00000000-0000-0000-0000-000000000000"
+	tests := []struct {
+		query  string
+		status int
+		code   string
+	}{
+		{"from=" + ids[0] + "&to=" + missing, fiber.StatusConflict, ErrCodeMarkdownUnavailable},
+		{"from=" + ids[0] + "&to=" + ids[1] + "&project=web", fiber.StatusNotFound, ErrCodeNotFound},
+		{"from=nope&to=" + ids[1], fiber.StatusBadRequest, ErrCodeBadRequest},
+	}
+	for _, tt := range tests {
+		if status, body := get(tt.query); status != tt.status || errorCode(t, body) != tt.code {
+			t.Errorf("%s: got %d %s, want %d %s", tt.query, status, body, tt.status, tt.code)
+		}
+	}
+
+	resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+ids[0]+"/markdown?project=web", nil))
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("markdown of an unknown project: got %d %s", resp.StatusCode, body)
+	}
+}
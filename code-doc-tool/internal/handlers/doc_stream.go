@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/services"
+)
+
+// docStreams holds the documents of running jobs that can be streamed.
+var (
+	docStreamsMu sync.Mutex
+	docStreams   = map[string]*services.DocStream{}
+)
+
+// openDocStream starts a stream for a job whose output is text based.
+func openDocStream(jobID string, g services.Generator) {
+	stream, ok := services.NewDocStream(g)
+	if !ok {
+		return
+	}
+	docStreamsMu.Lock()
+	docStreams[jobID] = stream
+	docStreamsMu.Unlock()
+}
+
+// docStream returns a running job's stream, or nil when it has none.
+func docStream(jobID string) *services.DocStream {
+	docStreamsMu.Lock()
+	defer docStreamsMu.Unlock()
+	return docStreams[jobID]
+}
+
+// closeDocStream ends a job's stream once the job is over; later requests
+// are served the finished file. Safe to call more than once.
+func closeDocStream(jobID string) {
+	docStreamsMu.Lock()
+	stream := docStreams[jobID]
+	delete(docStreams, jobID)
+	docStreamsMu.Unlock()
+	if stream != nil {
+		stream.Close()
+	}
+}
+
+// StreamJobDocument sends a running job's markdown, text or wiki document
+// with chunked transfer as it is assembled: the project summary first,
+// then each file's documentation once it and every file before it are
+// done. Jobs that have finished, produce Word documents or document
+// several projects are answered like GetJobDocument.
+func StreamJobDocument(c *fiber.Ctx) error {
+	jobID := c.Params("jobId")
+	job, ok := jobs.Get(jobID)
+	if !ok {
+		return errorResponse(c, fiber.StatusNotFound, ErrCodeJobNotFound, "Job not found")
+	}
+	stream := docStream(jobID)
+	if stream == nil {
+		return GetJobDocument(c)
+	}
+
+	// Hold the response until there is something to send, so a job that
+	// fails first still gets an error status
+	for {
+		data, closed, changed := stream.Read(0)
+		if len(data) > 0 {
+			break
+		}
+		if closed {
+			return GetJobDocument(c)
+		}
+		select {
+		case <-changed:
+		case <-c.Context().Done():
+			return nil
+		}
+	}
+
+	filename := outputFilename(jobID, job.Format)
+	c.Set("Content-Type", services.ContentTypeFor(filename))
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Set("Cache-Control", "no-cache")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		offset := 0
+		for {
+			data, closed, changed := stream.Read(offset)
+			w.Write(data)
+			offset += len(data)
+			// A failed flush means the client has gone
+			if err := w.Flush(); err != nil || closed {
+				return
+			}
+			<-changed
+		}
+	})
+	return nil
+}
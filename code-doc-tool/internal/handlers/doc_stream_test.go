@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestStreamJobDocument(t *testing.T) {
+	setupTest(t, nil)
+	release := make(chan struct{})
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		name := filepath.Base(path)
+		if name == "util.go" {
+			<-release
+		}
+		return "# " + name + "\n\nDocs for " + name + ".\n", nil
+	})
+	app := newTestApp()
+	base := serve(t, app)
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md"})
+	resp, err := http.Get(base + "/api/jobs/" + jobID + "/document/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK || !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), "text/markdown") {
+		t.Fatalf("stream returned %d %s", resp.StatusCode, resp.Header.Get(fiber.HeaderContentType))
+	}
+
+	// The summary and the first file arrive while the second is analyzed
+	var got []byte
+	buf := make([]byte, 4096)
+	for !strings.Contains(string(got), "# main.go") {
+		n, err := resp.Body.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			t.Fatalf("stream ended early with %v:\n%s", err, got)
+		}
+	}
+	if strings.Contains(string(got), "Docs for util.go") {
+		t.Fatalf("util.go streamed before it was analyzed:\n%s", got)
+	}
+	close(release)
+
+	rest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, rest...)
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if want := readOutput(t, job.Outputs[0].Filename); string(got) != want {
+		t.Errorf("streamed\n%q\nwant the written document\n%q", got, want)
+	}
+
+	// Once finished the stream serves the file
+	resp2, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/document/stream", nil))
+	if resp2.StatusCode != fiber.StatusOK || string(body) != string(got) {
+		t.Errorf("finished job's stream %d:\n%s", resp2.StatusCode, body)
+	}
+}
+
+func TestStreamJobDocumentDocx(t *testing.T) {
+	setupTest(t, nil)
+	release := make(chan struct{})
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		<-release
+		return "# doc\n", nil
+	})
+	app := newTestApp()
+
+	// Word documents are only served once complete
+	jobID := upload(t, app, testProject, map[string]string{"format": "docx"})
+	resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/document/stream", nil))
+	if resp.StatusCode != fiber.StatusConflict || errorCode(t, body) != ErrCodeJobNotReady {
+		t.Errorf("got %d %s, want 409 %s", resp.StatusCode, body, ErrCodeJobNotReady)
+	}
+	close(release)
+	waitJob(t, jobID)
+
+	resp, body = doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/00000000-0000-0000-0000-000000000000/document/stream", nil))
+	if resp.StatusCode != fiber.StatusNotFound || errorCode(t, body) != ErrCodeJobNotFound {
+		t.Errorf("unknown job: got %d %s", resp.StatusCode, body)
+	}
+}
@@ -3,61 +3,335 @@ package handlers
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
 )
 
 func DownloadDocumentation(c *fiber.Ctx) error {
-	filename := c.Params("filename")
+	// Outputs may sit in subdirectories laid out by OUTPUT_DIR_TEMPLATE;
+	// cleaning against the root keeps the path inside ./output
+	filename := strings.TrimPrefix(path.Clean("/"+c.Params("*")), "/")
 
 	// Validate filename
 	if filename == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Filename is required",
-		})
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeFilenameRequired, "Filename is required")
+	}
+	// Dot-directories hold job records and caches, not documents
+	for _, segment := range strings.Split(filename, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return errorResponse(c, fiber.StatusNotFound, ErrCodeNotFound, "Documentation not found")
+		}
 	}
 
 	// Construct file path
-	filePath := filepath.Join("./output", filename)
+	filePath := filepath.Join("./output", filepath.FromSlash(filename))
 
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Documentation not found",
-		})
+		return errorResponse(c, fiber.StatusNotFound, ErrCodeNotFound, "Documentation not found")
 	}
 
-	// Set headers for file download
-	c.Set("Content-Type", "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	if err := c.SendFile(filePath); err != nil {
+		return err
+	}
 
-	return c.SendFile(filePath)
+	// Set headers for file download; SendFile guesses the content type from
+	// the extension, so ours is applied afterwards
+	c.Set("Content-Type", services.ContentTypeFor(filename))
+	if digest, ok := outputDigest(filename); ok {
+		if header, ok := services.DigestHeader(digest); ok {
+			c.Set("Digest", header)
+		}
+	}
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", path.Base(filename)))
+	return nil
 }
 
 func GetStatus(c *fiber.Ctx) error {
 	jobID := c.Params("jobId")
 
-	// Check if output file exists
-	outputPath := fmt.Sprintf("./output/%s_documentation.docx", jobID)
-	if _, err := os.Stat(outputPath); err == nil {
-		return c.JSON(fiber.Map{
+	if job, ok := jobs.Get(jobID); ok {
+		resp := fiber.Map{
+			"status":     job.Status,
+			"progress":   job.Progress,
+			"message":    job.Message,
+			"updated_at": job.UpdatedAt,
+		}
+		if job.TraceID != "" {
+			resp["trace_id"] = job.TraceID
+		}
+		if job.ProjectType != "" {
+			resp["project_type"] = job.ProjectType
+		}
+		if len(job.Languages) > 0 {
+			resp["languages"] = job.Languages
+		}
+		if len(job.DeadLetters) > 0 {
+			resp["dead_letters"] = job.DeadLetters
+		}
+		if len(job.RetriedFiles) > 0 {
+			resp["retried_files"] = job.RetriedFiles
+		}
+		if job.Redactions > 0 {
+			resp["redactions"] = job.Redactions
+		}
+		if job.LowConfidence > 0 {
+			resp["low_confidence"] = job.LowConfidence
+		}
+		if len(job.SkippedEntries) > 0 {
+			resp["skipped_entries"] = job.SkippedEntries
+		}
+		if job.Attempts > 1 {
+			resp["attempts"] = job.Attempts
+		}
+		switch job.Status {
+		case models.JobStatusCompleted, models.JobStatusCompletedWithFallback:
+			if len(job.Outputs) == 0 {
+				format := job.Format
+				if format == "" {
+					format = services.DefaultFormat
+				}
+				resp["download_url"] = "/api/download/" + outputFilename(jobID, format)
+				if expires, ok := services.OutputExpiry(filepath.Join("./output", outputFilename(jobID, format)), cfg.OutputTTL); ok {
+					resp["expires_at"] = expires
+				}
+				break
+			}
+
+			// Multi-project archives produce one document per project
+			outputs := make([]fiber.Map, 0, len(job.Outputs))
+			for _, output := range job.Outputs {
+				entry := fiber.Map{
+					"project":      output.Project,
+					"type":         output.Type,
+					"download_url": "/api/download/" + output.Filename,
+				}
+				if digest, ok := output.Digests[output.Filename]; ok {
+					entry["sha256"] = digest
+				}
+				if len(output.Artifacts) > 0 {
+					artifacts := make([]fiber.Map, 0, len(output.Artifacts)+1)
+					for _, filename := range append([]string{output.Filename}, output.Artifacts...) {
+						item := fiber.Map{
+							"format":       strings.TrimPrefix(filepath.Ext(filename), "."),
+							"download_url": "/api/download/" + filename,
+						}
+						if digest, ok := output.Digests[filename]; ok {
+							item["sha256"] = digest
+						}
+						artifacts = append(artifacts, item)
+					}
+					entry["artifacts"] = artifacts
+				}
+				if len(output.Files) > 0 {
+					entry["files"] = output.Files
+				}
+				if expires, ok := services.OutputExpiry(filepath.Join("./output", output.Filename), cfg.OutputTTL); ok {
+					entry["expires_at"] = expires
+				}
+				outputs = append(outputs, entry)
+			}
+			resp["download_url"] = outputs[0]["download_url"]
+			if digest, ok := outputs[0]["sha256"]; ok {
+				resp["sha256"] = digest
+			}
+			if expires, ok := outputs[0]["expires_at"]; ok {
+				resp["expires_at"] = expires
+			}
+			resp["outputs"] = outputs
+		case models.JobStatusFailed:
+			resp["error"] = errorBody(ErrCodeJobFailed, job.Message)
+		}
+		return c.JSON(resp)
+	}
+
+	// Check if output file exists; the job may have been produced in any
+	// format, so look for each extension rather than assuming .docx
+	if filename, ok := findOutput(jobID); ok {
+		resp := fiber.Map{
 			"status":       "completed",
 			"message":      "Documentation generated successfully",
-			"download_url": fmt.Sprintf("/api/download/%s_documentation.docx", jobID),
-		})
+			"download_url": "/api/download/" + filename,
+		}
+		if expires, ok := services.OutputExpiry(filepath.Join("./output", filename), cfg.OutputTTL); ok {
+			resp["expires_at"] = expires
+		}
+		return c.JSON(resp)
 	}
 
-	// Check if upload directory exists (processing)
-	uploadPath := fmt.Sprintf("./uploads/%s", jobID)
-	if _, err := os.Stat(uploadPath); err == nil {
+	// Check if the job's working directory exists (processing)
+	if _, ok := findWorkDir(jobID); ok {
 		return c.JSON(fiber.Map{
 			"status":  "processing",
 			"message": "Documentation is being generated",
 		})
 	}
 
-	return c.Status(404).JSON(fiber.Map{
-		"error": "Job not found",
-	})
+	return errorResponse(c, fiber.StatusNotFound, ErrCodeJobNotFound, "Job not found")
+}
+
+// GetJobDocument serves a completed job's document in the format picked from
+// the Accept header. Formats other than the one the job produced are
+// rendered from its saved markdown on first request. ?project= selects one
+// document of a multi-project job.
+func GetJobDocument(c *fiber.Ctx) error {
+	jobID := c.Params("jobId")
+	job, ok := jobs.Get(jobID)
+	if !ok {
+		return errorResponse(c, fiber.StatusNotFound, ErrCodeJobNotFound, "Job not found")
+	}
+	if job.Status != models.JobStatusCompleted && job.Status != models.JobStatusCompletedWithFallback {
+		return errorResponse(c, fiber.StatusConflict, ErrCodeJobNotReady, "Job has not completed")
+	}
+
+	filename := outputFilename(jobID, job.Format)
+	if len(job.Outputs) > 0 {
+		filename = job.Outputs[0].Filename
+		if project := c.Query("project"); project != "" {
+			filename = ""
+			for _, output := range job.Outputs {
+				if output.Project == project {
+					filename = output.Filename
+				}
+			}
+			if filename == "" {
+				return errorResponse(c, fiber.StatusNotFound, ErrCodeNotFound, "Project not found in job")
+			}
+		}
+	}
+
+	// The format actually produced goes first so a wildcard Accept picks it;
+	// after a fallback that is markdown rather than the requested format
+	produced := strings.TrimPrefix(filepath.Ext(filename), ".")
+	offers := []string{mediaType(services.ContentTypeFor(filename))}
+	formats := map[string]string{offers[0]: produced}
+	for _, format := range services.Formats {
+		generator, _ := services.NewGenerator(format)
+		if mt := mediaType(generator.ContentType()); formats[mt] == "" {
+			offers = append(offers, mt)
+			formats[mt] = format
+		}
+	}
+
+	format := produced
+	if c.Get(fiber.HeaderAccept) != "" {
+		accepted := c.Accepts(offers...)
+		if accepted == "" {
+			return errorResponse(c, fiber.StatusNotAcceptable, ErrCodeNotAcceptable,
+				"Available formats: "+strings.Join(offers, ", "))
+		}
+		format = formats[accepted]
+	}
+
+	path := filepath.Join("./output", filename)
+	if format != produced {
+		var err error
+		if path, err = renderFormat(jobID, filename, format); err != nil {
+			if os.IsNotExist(err) {
+				return errorResponse(c, fiber.StatusNotAcceptable, ErrCodeNotAcceptable,
+					"Only "+offers[0]+" is available for this job")
+			}
+			return errorResponse(c, fiber.StatusInternalServerError, ErrCodeInternal, "Failed to render document")
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		return errorResponse(c, fiber.StatusNotFound, ErrCodeNotFound, "Documentation not found")
+	}
+
+	if err := c.SendFile(path); err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	c.Set("Content-Type", services.ContentTypeFor(name))
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", name))
+	c.Vary(fiber.HeaderAccept)
+	return nil
+}
+
+// GetJobMarkdown returns the combined markdown a job's generators consumed.
+// ?project= selects one document of a multi-project job.
+func GetJobMarkdown(c *fiber.Ctx) error {
+	jobID := c.Params("jobId")
+	job, ok := jobs.Get(jobID)
+	if ok && models.JobActive(job.Status) {
+		return errorResponse(c, fiber.StatusConflict, ErrCodeJobNotReady, "Job has not completed")
+	}
+
+	markdown, err := jobMarkdown(jobID, c.Query("project"))
+	if err != nil {
+		if !ok {
+			return errorResponse(c, fiber.StatusNotFound, ErrCodeJobNotFound, "Job not found")
+		}
+		return markdownError(c, jobID, err)
+	}
+
+	c.Set("Content-Type", "text/markdown; charset=utf-8")
+	return c.SendString(markdown)
+}
+
+// outputDigest returns the SHA-256 recorded for an output file when it
+// was generated. Output names start with their job's ID.
+func outputDigest(filename string) (string, bool) {
+	base := path.Base(filename)
+	if len(base) < 36 {
+		return "", false
+	}
+	job, ok := jobs.Get(base[:36])
+	if !ok {
+		return "", false
+	}
+	for _, output := range job.Outputs {
+		if digest, ok := output.Digests[filename]; ok {
+			return digest, true
+		}
+	}
+	return "", false
+}
+
+// findOutput looks for a job's document on disk when the job itself is no
+// longer known, trying each supported format.
+func findOutput(jobID string) (string, bool) {
+	for _, format := range services.Formats {
+		filename := outputFilename(jobID, format)
+		if _, err := os.Stat(filepath.Join("./output", filename)); err == nil {
+			return filename, true
+		}
+	}
+	return "", false
+}
+
+// renderFormat renders the saved markdown behind filename into format,
+// reusing an earlier rendering when there is one.
+func renderFormat(jobID, filename, format string) (string, error) {
+	generator, err := services.NewGenerator(format)
+	if err != nil {
+		return "", err
+	}
+	// Kept apart from the saved markdown, which has neither the
+	// configured line endings nor the footer, even when format is md
+	markdownPath := services.MarkdownPath(filename)
+	path := strings.TrimSuffix(markdownPath, ".md") + ".render." + generator.Extension()
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	markdown, err := os.ReadFile(markdownPath)
+	if err != nil {
+		return "", err
+	}
+	if err := services.GenerateWithFooter(generator, string(markdown), path, documentFooter(jobID)); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// mediaType strips parameters such as charset from a content type.
+func mediaType(contentType string) string {
+	return strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
 }
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+)
+
+func TestGetJobDocumentNegotiation(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	jobID := upload(t, app, testProject, map[string]string{"format": "md"})
+	if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+
+	get := func(accept string) (int, string, string) {
+		req := httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/document", nil)
+		if accept != "" {
+			req.Header.Set(fiber.HeaderAccept, accept)
+		}
+		resp, body := doRequest(t, app, req)
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderContentType), string(body)
+	}
+
+	tests := []struct {
+		accept, contentType string
+		status              int
+	}{
+		{"", "text/markdown", fiber.StatusOK},
+		{"*/*", "text/markdown", fiber.StatusOK},
+		{"text/markdown", "text/markdown", fiber.StatusOK},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", fiber.StatusOK},
+		{"text/plain;q=0.5, application/pdf", "text/plain", fiber.StatusOK},
+		{"application/pdf", "", fiber.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		status, contentType, body := get(tt.accept)
+		if status != tt.status {
+			t.Errorf("Accept %q: got %d %s, want %d", tt.accept, status, body, tt.status)
+			continue
+		}
+		if tt.status == fiber.StatusOK && !strings.HasPrefix(contentType, tt.contentType) {
+			t.Errorf("Accept %q: got %s, want %s", tt.accept, contentType, tt.contentType)
+		}
+	}
+
+	// Other formats are rendered from the saved markdown
+	if _, _, body := get("text/plain"); !strings.Contains(body, "main.go") {
+		t.Errorf("plain text rendering: %q", body)
+	}
+}
+
+func TestGetJobDocumentLineEnding(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.LineEnding = "crlf" })
+	app := newTestApp()
+	jobID := upload(t, app, testProject, map[string]string{"format": "txt"})
+	if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+
+	// Rendered on request from the saved markdown, which is kept with LF
+	req := httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/document", nil)
+	req.Header.Set(fiber.HeaderAccept, "text/markdown")
+	resp, body := doRequest(t, app, req)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("got %d %s", resp.StatusCode, body)
+	}
+	doc := string(body)
+	if !strings.Contains(doc, "main.go") || strings.Count(doc, "\n") != strings.Count(doc, "\r\n") {
+		t.Errorf("markdown doesn't use CRLF throughout: %q", doc)
+	}
+}
+
+func TestGetJobDocumentNotReady(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	jobID := "5b0a4a8e-8a43-4f4e-9d8e-2f0b1f3c6d11"
+	jobs.Create(jobID, func() {})
+
+	resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/document", nil))
+	if resp.StatusCode != fiber.StatusConflict || errorCode(t, body) != ErrCodeJobNotReady {
+		t.Errorf("got %d %s, want 409", resp.StatusCode, body)
+	}
+}
+
+func TestGetStatusExpiresAt(t *testing.T) {
+	for _, ttl := range []time.Duration{0, 2 * time.Hour} {
+		setupTest(t, func(c *config.Config) {
+			c.OutputTTL = ttl
+		})
+		app := newTestApp()
+		jobID := upload(t, app, testProject, map[string]string{"format": "md"})
+		job := waitJob(t, jobID)
+
+		// Pin the output's mtime so the expected expiry is exact
+		modified := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		if err := os.Chtimes(filepath.Join("./output", job.Outputs[0].Filename), modified, modified); err != nil {
+			t.Fatal(err)
+		}
+
+		_, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/status/"+jobID, nil))
+		var status struct {
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		if err := json.Unmarshal(body, &status); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case ttl == 0 && status.ExpiresAt != nil:
+			t.Errorf("expires_at %v without a TTL", status.ExpiresAt)
+		case ttl > 0 && (status.ExpiresAt == nil || !status.ExpiresAt.Equal(modified.Add(ttl))):
+			t.Errorf("expires_at %v, want %v", status.ExpiresAt, modified.Add(ttl))
+		}
+	}
+}
+
+func TestGetJobMarkdown(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	jobID := upload(t, app, testProject, map[string]string{"format": "docx"})
+	if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+
+	resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/markdown", nil))
+	if resp.StatusCode != fiber.StatusOK || !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), "text/markdown") {
+		t.Fatalf("got %d %s", resp.StatusCode, resp.Header.Get(fiber.HeaderContentType))
+	}
+	for _, want := range []string{"# main.go", "# util.go", "\n---\n"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("markdown is missing %q:\n%s", want, body)
+		}
+	}
+
+	pending := "5b0a4a8e-8a43-4f4e-9d8e-2f0b1f3c6d11"
+	jobs.Create(pending, func() {})
+	tests := []struct {
+		id     string
+		status int
+		code   string
+	}{
+		{pending, fiber.StatusConflict, ErrCodeJobNotReady},
+		{"00000000-0000-0000-0000-000000000000", fiber.StatusNotFound, ErrCodeJobNotFound},
+		{"not-a-job", fiber.StatusBadRequest, ErrCodeBadRequest},
+	}
+	for _, tt := range tests {
+		resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+tt.id+"/markdown", nil))
+		if resp.StatusCode != tt.status || errorCode(t, body) != tt.code {
+			t.Errorf("%s: got %d %s, want %d %s", tt.id, resp.StatusCode, body, tt.status, tt.code)
+		}
+	}
+}
+
+func TestRequireJobID(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	for _, target := range []string{
+		"/api/status/..%2F..%2Fetc",
+		"/api/status/5B0A4A8E-8A43-4F4E-9D8E-2F0B1F3C6D11",
+		"/api/jobs/*/log",
+		"/api/jobs/abc/events",
+		"/api/jobs/abc/document",
+		"/api/jobs/abc/document/stream",
+		"/api/jobs/abc/artifacts",
+	} {
+		resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, target, nil))
+		if resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeBadRequest {
+			t.Errorf("%s: got %d %s, want 400", target, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestStatusDownloadURL(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	for _, tt := range []struct{ format, contentType string }{
+		{"md", "text/markdown"},
+		{"txt", "text/plain"},
+		{"docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	} {
+		jobID := upload(t, app, testProject, map[string]string{"format": tt.format})
+		waitJob(t, jobID)
+
+		_, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/status/"+jobID, nil))
+		var status struct {
+			DownloadURL string `json:"download_url"`
+		}
+		json.Unmarshal(body, &status)
+		if !strings.HasSuffix(status.DownloadURL, "."+tt.format) {
+			t.Errorf("%s job: download URL %q", tt.format, status.DownloadURL)
+			continue
+		}
+		resp, _ := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, status.DownloadURL, nil))
+		if resp.StatusCode != fiber.StatusOK || !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), tt.contentType) {
+			t.Errorf("%s download: got %d %s", tt.format, resp.StatusCode, resp.Header.Get(fiber.HeaderContentType))
+		}
+	}
+}
+
+func TestDownloadDigest(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	jobID := upload(t, app, testProject, map[string]string{"format": "docx"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	filename := job.Outputs[0].Filename
+
+	resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/download/"+filename, nil))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("download returned %d", resp.StatusCode)
+	}
+	sum := sha256.Sum256(body)
+	if want := "sha-256=" + base64.StdEncoding.EncodeToString(sum[:]); resp.Header.Get("Digest") != want {
+		t.Errorf("Digest %q, want %q for the bytes sent", resp.Header.Get("Digest"), want)
+	}
+
+	// Files the server didn't record get no digest rather than a wrong one
+	os.WriteFile(filepath.Join("./output", "stray.md"), []byte("# Stray\n"), 0644)
+	resp, _ = doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/download/stray.md", nil))
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get("Digest") != "" {
+		t.Errorf("stray file: got %d with Digest %q", resp.StatusCode, resp.Header.Get("Digest"))
+	}
+}
+
+func TestDownloadHidesInternalState(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	jobID := upload(t, app, testProject, nil)
+	if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	os.WriteFile(filepath.Join("./output", ".hidden.md"), []byte("# Hidden\n"), 0644)
+
+	for _, path := range []string{
+		"/api/download/.jobs/" + jobID + ".json",
+		"/api/download/.cache/" + jobID + ".json",
+		"/api/download/.cache/" + jobID + "_documentation.md",
+		"/api/download/sub/../.jobs/" + jobID + ".json",
+		"/api/download/.hidden.md",
+	} {
+		resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, path, nil))
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("%s: got %d %.80s", path, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestOutputDirTemplate(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.OutputDirTemplate = "docs/{job}" })
+	app := newTestApp()
+	jobID := upload(t, app, testProject, map[string]string{"format": "md"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+
+	want := "docs/" + jobID + "/" + jobID + "_documentation.md"
+	if job.Outputs[0].Filename != want {
+		t.Fatalf("output %s, want %s", job.Outputs[0].Filename, want)
+	}
+	if _, err := os.Stat(filepath.Join("./output", filepath.FromSlash(want))); err != nil {
+		t.Fatal(err)
+	}
+	_, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/status/"+jobID, nil))
+	var status struct {
+		DownloadURL string `json:"download_url"`
+	}
+	json.Unmarshal(body, &status)
+	resp, doc := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, status.DownloadURL, nil))
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(doc), "main.go") {
+		t.Errorf("download of %s: got %d %s", status.DownloadURL, resp.StatusCode, doc)
+	}
+}
+
+func TestOutputDirTemplateUnwritable(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.OutputDirTemplate = "docs/{job}"
+		c.JobRetries = 0
+	})
+	app := newTestApp()
+	// A file where the directory should go can't be written into
+	os.MkdirAll("./output", 0755)
+	os.WriteFile("./output/docs", []byte("in the way"), 0644)
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md"})
+	if job := waitJob(t, jobID); job.Status != models.JobStatusFailed {
+		t.Errorf("job %s: %s, want it failed", job.Status, job.Message)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Machine-readable error codes returned in the error envelope.
+const (
+	ErrCodeBadRequest          = "bad_request"
+	ErrCodeNoFile              = "no_file"
+	ErrCodeInvalidFileType     = "invalid_file_type"
+	ErrCodeInvalidSections     = "invalid_sections"
+	ErrCodeInvalidFormat       = "invalid_format"
+	ErrCodeInvalidPath         = "invalid_path"
+	ErrCodeInvalidLanguage     = "invalid_language"
+	ErrCodeInvalidOrder        = "invalid_order"
+	ErrCodeInvalidURL          = "invalid_url"
+	ErrCodeForbiddenURL        = "forbidden_url"
+	ErrCodeFilenameRequired    = "filename_required"
+	ErrCodeUnauthorized        = "unauthorized"
+	ErrCodeForbidden           = "forbidden"
+	ErrCodeNotFound            = "not_found"
+	ErrCodeJobNotFound         = "job_not_found"
+	ErrCodeJobFailed           = "job_failed"
+	ErrCodeJobNotReady         = "job_not_ready"
+	ErrCodeNotAcceptable       = "not_acceptable"
+	ErrCodeMarkdownUnavailable = "markdown_unavailable"
+	ErrCodeAgentCheckFailed    = "agent_check_failed"
+	ErrCodeServerBusy          = "server_busy"
+	ErrCodeQuotaExceeded       = "quota_exceeded"
+	ErrCodeBodyTooLarge        = "body_too_large"
+	ErrCodeInvalidDiagram      = "invalid_diagram"
+	ErrCodeInternal            = "internal_error"
+)
+
+// errorResponse writes the shared {"error": {"code", "message"}} envelope.
+func errorResponse(c *fiber.Ctx, status int, code, message string) error {
+	// Errors may be rendered by ErrorHandler after the middleware chain has
+	// unwound, so the charset is set here rather than in ResponseHeaders
+	return c.Status(status).JSON(fiber.Map{
+		"error": errorBody(code, message),
+	}, fiber.MIMEApplicationJSONCharsetUTF8)
+}
+
+func errorBody(code, message string) fiber.Map {
+	return fiber.Map{
+		"code":    code,
+		"message": message,
+	}
+}
+
+// ErrorHandler renders errors returned from handlers and middleware (e.g.
+// body limit or unknown routes) in the same envelope.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	code := ErrCodeInternal
+	message := "Internal server error"
+
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		status = fe.Code
+		message = fe.Message
+		switch {
+		case status == fiber.StatusNotFound:
+			code = ErrCodeNotFound
+		case status < fiber.StatusInternalServerError:
+			code = ErrCodeBadRequest
+		}
+	}
+	return errorResponse(c, status, code, message)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	tests := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{fiber.MethodGet, "/api/nope", fiber.StatusNotFound, ErrCodeNotFound},
+		{fiber.MethodGet, "/api/diff?a=x&b=y", fiber.StatusBadRequest, ErrCodeBadRequest},
+		{fiber.MethodGet, "/api/status/5b0a4a8e-8a43-4f4e-9d8e-2f0b1f3c6d11", fiber.StatusNotFound, ErrCodeJobNotFound},
+		{fiber.MethodPost, "/api/upload", fiber.StatusBadRequest, ErrCodeNoFile},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.method == fiber.MethodPost {
+			req.Header.Set(fiber.HeaderContentType, "multipart/form-data; boundary=x")
+		}
+		resp, body := doRequest(t, app, req)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, resp.StatusCode, tt.status)
+		}
+		if resp.Header.Get(fiber.HeaderContentType) != fiber.MIMEApplicationJSONCharsetUTF8 {
+			t.Errorf("%s %s: content type %q", tt.method, tt.path, resp.Header.Get(fiber.HeaderContentType))
+		}
+		var envelope struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error.Code != tt.code || envelope.Error.Message == "" {
+			t.Errorf("%s %s: body %s, want code %s", tt.method, tt.path, strings.TrimSpace(string(body)), tt.code)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// eventKeepAlive is how often an idle stream gets a comment line so
+// proxies don't close it.
+const eventKeepAlive = 15 * time.Second
+
+// StreamJobEvents streams a job's progress as server-sent events: progress
+// updates, one file event per analyzed file and a final done event, after
+// which the stream ends. Reconnecting clients resume after Last-Event-ID.
+func StreamJobEvents(c *fiber.Ctx) error {
+	jobID := c.Params("jobId")
+	if _, ok := jobs.Get(jobID); !ok {
+		return errorResponse(c, fiber.StatusNotFound, ErrCodeJobNotFound, "Job not found")
+	}
+	seq := 0
+	if last, err := strconv.Atoi(c.Get("Last-Event-ID")); err == nil {
+		seq = last + 1
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			events, finished, changed, ok := jobs.Events(jobID, seq)
+			if !ok {
+				return
+			}
+			for _, event := range events {
+				data, _ := json.Marshal(event)
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+				seq = event.Seq + 1
+			}
+			// A failed flush means the client has gone
+			if err := w.Flush(); err != nil || finished {
+				return
+			}
+
+			select {
+			case <-changed:
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+		}
+	})
+	return nil
+}
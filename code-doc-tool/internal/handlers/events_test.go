@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+)
+
+// serve runs app on a local port for clients that read responses as they
+// stream, and returns its base URL.
+func serve(t *testing.T, app *fiber.App) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + ln.Addr().String()
+}
+
+// eventStream reads server-sent events from a job's stream.
+type eventStream struct {
+	t       *testing.T
+	resp    *http.Response
+	scanner *bufio.Scanner
+}
+
+func openEvents(t *testing.T, base, jobID, lastEventID string) *eventStream {
+	t.Helper()
+	req, _ := http.NewRequest(fiber.MethodGet, base+"/api/jobs/"+jobID+"/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != fiber.StatusOK || !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), "text/event-stream") {
+		t.Fatalf("stream returned %d %s", resp.StatusCode, resp.Header.Get(fiber.HeaderContentType))
+	}
+	return &eventStream{t: t, resp: resp, scanner: bufio.NewScanner(resp.Body)}
+}
+
+// next returns the next event, or false once the stream has ended.
+func (s *eventStream) next() (models.JobEvent, bool) {
+	s.t.Helper()
+	var event models.JobEvent
+	var id, kind string
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		switch {
+		case line == "" && kind != "":
+			if kind != event.Type || id == "" {
+				s.t.Fatalf("event %q with id %q carries %+v", kind, id, event)
+			}
+			return event, true
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			kind = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				s.t.Fatal(err)
+			}
+		}
+	}
+	return event, false
+}
+
+func TestStreamJobEvents(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.AnalyzeRetries = 0 })
+	release := make(chan struct{})
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		<-release
+		if strings.HasSuffix(path, "util.go") {
+			return "", &services.AgentStatusError{StatusCode: fiber.StatusBadRequest, Body: "rejected"}
+		}
+		return "# " + filepath.Base(path) + "\n", nil
+	})
+	app := newTestApp()
+	base := serve(t, app)
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md"})
+
+	// Connected while the job runs, the stream carries events as they come
+	stream := openEvents(t, base, jobID, "")
+	first, ok := stream.next()
+	if !ok || first.Type != models.JobEventProgress {
+		t.Fatalf("first event %+v, want progress", first)
+	}
+	close(release)
+
+	var events []models.JobEvent
+	for {
+		event, ok := stream.next()
+		if !ok {
+			break
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 || events[len(events)-1].Type != models.JobEventDone {
+		t.Fatalf("events %+v, want the stream to end with done", events)
+	}
+	done := events[len(events)-1]
+	if done.Status != models.JobStatusCompleted {
+		t.Errorf("done event %+v", done)
+	}
+
+	files := map[string]models.JobEvent{}
+	seq := first.Seq
+	for _, event := range events {
+		if event.Seq <= seq {
+			t.Errorf("event %d after %d", event.Seq, seq)
+		}
+		seq = event.Seq
+		if event.Type == models.JobEventFile {
+			files[event.File] = event
+		}
+	}
+	if len(files) != 2 || files["main.go"].Status != services.FileStatusOK {
+		t.Fatalf("file events %+v, want one each for main.go and util.go", files)
+	}
+	if util := files["util.go"]; util.Status != services.FileStatusFailed || !strings.Contains(util.Error, "rejected") {
+		t.Errorf("util.go event %+v", util)
+	}
+
+	// Reconnecting picks up after the last event seen
+	resumed := openEvents(t, base, jobID, "0")
+	if event, ok := resumed.next(); !ok || event.Seq != 1 {
+		t.Errorf("resumed stream starts with %+v, want event 1", event)
+	}
+
+	// A finished job's stream replays its history and ends
+	replay := openEvents(t, base, jobID, "")
+	count := 0
+	for _, ok := replay.next(); ok; _, ok = replay.next() {
+		count++
+	}
+	if count != len(events)+1 {
+		t.Errorf("replayed %d events, want %d", count, len(events)+1)
+	}
+}
+
+func TestStreamJobEventsNotFound(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	req := httptest.NewRequest(fiber.MethodGet, "/api/jobs/00000000-0000-0000-0000-000000000000/events", nil)
+	resp, body := doRequest(t, app, req)
+	if resp.StatusCode != fiber.StatusNotFound || errorCode(t, body) != ErrCodeJobNotFound {
+		t.Errorf("got %d %s", resp.StatusCode, body)
+	}
+}
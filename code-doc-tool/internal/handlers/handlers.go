@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/services"
+	"code-doc-tool/internal/utils"
+)
+
+var (
+	cfg  = config.New()
+	jobs = services.NewJobStore()
+
+	// analyzer documents individual files; set from cfg by Init
+	analyzer services.Analyzer
+
+	// languageSizeLimits maps canonical language names to their large file
+	// threshold
+	languageSizeLimits map[string]int64
+
+	// augmentations adds per-file instructions to the format template
+	augmentations *services.PromptAugmentations
+
+	// formatTemplates replace the default format template by language or
+	// project type
+	formatTemplates *services.FormatTemplates
+
+	// docInputs picks the documentation files sent as context
+	docInputs *services.DocInputs
+
+	// analyzeSlots caps agent calls across all jobs; each job is further
+	// capped by its own worker count
+	analyzeSlots = services.NewSemaphore(cfg.AnalyzeGlobalConcurrency)
+
+	// intakeSlots bounds uploads still being saved, downloaded or extracted
+	intakeSlots = services.NewSemaphore(cfg.MaxInflightUploads)
+
+	// agentBreaker fails agent calls fast while the agent looks down
+	agentBreaker = services.NewCircuitBreaker(cfg.AgentBreakerThreshold, cfg.AgentBreakerCooldown)
+
+	// dispatch holds new jobs queued while processing is paused
+	dispatch = services.NewGate()
+
+	// diskQuota caps what uploads and outputs may occupy together
+	diskQuota = services.NewDiskQuota(cfg.DiskQuota, cfg.UploadPath, "./output")
+)
+
+// Init sets the configuration used by the handlers, recovers jobs a
+// restart interrupted and starts the background job watchdog and output
+// sweeper.
+func Init(c *config.Config) {
+	Setup(c)
+	if err := jobs.PersistTo("./output/.jobs"); err != nil {
+		log.Fatalf("Failed to create job store directory: %v", err)
+	}
+	recoverJobs()
+	go jobs.Watchdog(context.Background(), cfg.JobStallTimeout)
+	go services.OutputSweeper(context.Background(), "./output", cfg.OutputTTL)
+}
+
+// Setup sets the configuration used by the pipeline without any of the
+// server's background work, for running jobs in-process.
+func Setup(c *config.Config) {
+	cfg = c
+	var err error
+	if analyzer, err = services.NewAnalyzer(cfg); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Analyzer == "http" && !cfg.RedactSecrets {
+		log.Printf("REDACT_SECRETS is off; source files are sent to the agent as-is")
+	}
+	languageSizeLimits = map[string]int64{}
+	for name, limit := range cfg.LanguageSizeLimits {
+		lang, ok := services.NormalizeLanguage(name)
+		if !ok {
+			log.Fatalf("LANGUAGE_SIZE_LIMITS: unknown language %q", name)
+		}
+		languageSizeLimits[lang] = limit
+	}
+	augmentations, formatTemplates = nil, nil
+	if cfg.PromptAugmentationsFile != "" {
+		if augmentations, err = services.LoadPromptAugmentations(cfg.PromptAugmentationsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if docInputs, err = services.NewDocInputs(cfg.DocInputGlobs); err != nil {
+		log.Fatalf("DOC_INPUT_GLOBS: %v", err)
+	}
+	if cfg.FormatTemplatesFile != "" {
+		if formatTemplates, err = services.LoadFormatTemplates(cfg.FormatTemplatesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	analyzeSlots = services.NewSemaphore(cfg.AnalyzeGlobalConcurrency)
+	intakeSlots = services.NewSemaphore(cfg.MaxInflightUploads)
+	diskQuota = services.NewDiskQuota(cfg.DiskQuota, cfg.UploadPath, "./output")
+	agentBreaker = services.NewCircuitBreaker(cfg.AgentBreakerThreshold, cfg.AgentBreakerCooldown)
+	utils.SetOpenFileLimit(cfg.MaxOpenFiles, cfg.OpenFileWaitTimeout)
+	services.SetLineEnding(cfg.LineEnding)
+	services.SetDocxStyle(services.DocxStyle{
+		Template: cfg.DocxTemplate,
+		Header:   cfg.DocxHeader,
+		Footer:   cfg.DocxFooter,
+	})
+	services.SetDocxValidation(cfg.ValidateDocx)
+	services.SetAgentPool(services.AgentPool{
+		MaxIdleConns:        cfg.AgentMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.AgentMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.AgentIdleConnTimeout,
+	})
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+)
+
+// setupTest runs the handlers in a fresh temporary working directory with
+// an empty job store and the stub analyzer. configure may adjust the
+// configuration before it is applied.
+func setupTest(t *testing.T, configure func(c *config.Config)) *config.Config {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	c := config.New()
+	c.Analyzer = services.AnalyzerStub
+	c.UploadPath = "./uploads"
+	if configure != nil {
+		configure(c)
+	}
+	Setup(c)
+	jobs = services.NewJobStore()
+	dispatch = services.NewGate()
+	return c
+}
+
+// newTestApp serves the API routes the way cmd/main.go does, behind
+// middleware if given.
+func newTestApp(middleware ...fiber.Handler) *fiber.App {
+	app := fiber.New(ServerConfig())
+	for _, handler := range middleware {
+		app.Use(handler)
+	}
+	app.Use(ResponseHeaders)
+	Routes(app)
+	return app
+}
+
+// testZip returns a zip archive holding files, keyed by path.
+func testZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, files[name])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testTar returns an uncompressed tar archive holding files, keyed by
+// path, for tests that need the upload as large as its contents.
+func testTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, name := range names {
+		w.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))})
+		io.WriteString(w, files[name])
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadRequest builds a multipart upload of data as filename with the
+// given form fields.
+func uploadRequest(t *testing.T, filename string, data []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		w.WriteField(name, value)
+	}
+	part, err := w.CreateFormFile("codebase", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	w.Close()
+
+	req := httptest.NewRequest(fiber.MethodPost, "/api/upload", &body)
+	req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+	return req
+}
+
+// doRequest runs req against app and returns the response and its body.
+func doRequest(t *testing.T, app *fiber.App, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+// upload posts files as a zip archive and returns the new job's ID.
+func upload(t *testing.T, app *fiber.App, files map[string]string, fields map[string]string) string {
+	t.Helper()
+	resp, body := doRequest(t, app, uploadRequest(t, "project.zip", testZip(t, files), fields))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+	}
+	var uploaded UploadResponse
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatal(err)
+	}
+	return uploaded.JobID
+}
+
+// waitJob waits for a job to finish and returns it.
+func waitJob(t *testing.T, jobID string) models.Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := jobs.Get(jobID); ok && !models.JobActive(job.Status) {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	job, _ := jobs.Get(jobID)
+	t.Fatalf("job %s still %s: %s", jobID, job.Status, job.Message)
+	return job
+}
+
+// readOutput returns the content of a job's output file.
+func readOutput(t *testing.T, filename string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("./output", filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// errorCode returns the code of an error envelope.
+func errorCode(t *testing.T, body []byte) string {
+	t.Helper()
+	var envelope struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("not an error envelope: %s", body)
+	}
+	return envelope.Error.Code
+}
+
+// funcAnalyzer adapts a function to services.Analyzer.
+type funcAnalyzer func(ctx context.Context, path, formatTemplate string) (string, error)
+
+func (f funcAnalyzer) Analyze(ctx context.Context, path, formatTemplate string) (string, error) {
+	return f(ctx, path, formatTemplate)
+}
+
+// analyzeTest writes files into a temporary project and returns their
+// paths, in the order given, with job options for analyzing them.
+func analyzeTest(t *testing.T, jobID string, files []string, contents map[string]string) ([]string, jobOptions) {
+	t.Helper()
+	root := t.TempDir()
+	var paths []string
+	for _, name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(contents[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	opts, _, err := newJobOptions(jobRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.basePath = root
+	opts.languageOf = services.DetectLanguage
+	opts.cache = services.NewDocCache(opts.templateKey())
+	if _, ok := jobs.Get(jobID); !ok {
+		jobs.Create(jobID, func() {})
+	}
+	return paths, opts
+}
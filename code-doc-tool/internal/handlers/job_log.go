@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultLogLimit = 100
+	maxLogLimit     = 1000
+)
+
+// GetJobLog returns a page of a job's log entries. Query params: limit,
+// offset and order (asc or desc by time).
+func GetJobLog(c *fiber.Ctx) error {
+	jobID := c.Params("jobId")
+
+	entries, ok := jobs.Logs(jobID)
+	if !ok {
+		return errorResponse(c, fiber.StatusNotFound, ErrCodeJobNotFound, "Job not found")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultLogLimit)))
+	if err != nil || limit < 1 || limit > maxLogLimit {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, "limit must be between 1 and 1000")
+	}
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, "offset must be a non-negative integer")
+	}
+	order := c.Query("order", "asc")
+	if order != "asc" && order != "desc" {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, "order must be asc or desc")
+	}
+
+	// Entries are appended in time order, so desc is just a reversal
+	if order == "desc" {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+
+	total := len(entries)
+	start := min(offset, total)
+	end := min(start+limit, total)
+
+	return c.JSON(fiber.Map{
+		"job_id":  jobID,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+		"order":   order,
+		"entries": entries[start:end],
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestGetJobLog(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	jobID := "5b0a4a8e-8a43-4f4e-9d8e-2f0b1f3c6d11"
+	jobs.Create(jobID, func() {})
+	for i := 0; i < 5; i++ {
+		jobs.AppendLog(jobID, models.LogLevelInfo, fmt.Sprintf("entry %d", i))
+	}
+
+	page := func(query string) (int, []string, int) {
+		resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/log"+query, nil))
+		var result struct {
+			Total   int                  `json:"total"`
+			Entries []models.JobLogEntry `json:"entries"`
+		}
+		json.Unmarshal(body, &result)
+		var messages []string
+		for _, entry := range result.Entries {
+			messages = append(messages, entry.Message)
+		}
+		return resp.StatusCode, messages, result.Total
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "[entry 0 entry 1 entry 2 entry 3 entry 4]"},
+		{"?limit=2&offset=1", "[entry 1 entry 2]"},
+		{"?order=desc&limit=2", "[entry 4 entry 3]"},
+		{"?offset=10", "[]"},
+	}
+	for _, tt := range tests {
+		status, messages, total := page(tt.query)
+		if status != fiber.StatusOK || fmt.Sprint(messages) != tt.want || total != 5 {
+			t.Errorf("%q: got %d %v (total %d), want %s", tt.query, status, messages, total, tt.want)
+		}
+	}
+	for _, query := range []string{"?limit=0", "?limit=1001", "?offset=-1", "?order=up"} {
+		if status, _, _ := page(query); status != fiber.StatusBadRequest {
+			t.Errorf("%q: got %d, want 400", query, status)
+		}
+	}
+
+	resp, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/00000000-0000-0000-0000-000000000000/log", nil))
+	if resp.StatusCode != fiber.StatusNotFound || errorCode(t, body) != ErrCodeJobNotFound {
+		t.Errorf("unknown job: got %d %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+	"code-doc-tool/internal/utils"
+)
+
+// jobFailure is why an attempt at a job failed, with the message the job
+// reports if it isn't retried.
+type jobFailure struct {
+	err     error
+	message string
+}
+
+// isTransient reports whether a job that failed with err may succeed if
+// run again: the agent was unreachable, or the disk briefly full or
+// failing.
+func isTransient(err error) bool {
+	return errors.Is(err, services.ErrAgentUnavailable) ||
+		utils.IsDiskFull(err) ||
+		errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.EMFILE) ||
+		errors.Is(err, syscall.ENFILE)
+}
+
+// resetAttempt clears what a failed attempt left behind so the next one
+// starts from the upload again.
+func resetAttempt(jobID string, opts jobOptions) {
+	if err := os.RemoveAll(filepath.Join(opts.workDir, "extracted")); err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to remove extracted files: %v", err)
+	}
+	jobs.Modify(jobID, func(job *models.Job) {
+		job.DeadLetters = nil
+		job.RetriedFiles = nil
+		job.SkippedEntries = nil
+		job.Redactions = 0
+		job.LowConfidence = 0
+	})
+	// Clients streaming the document would see it start over, so the retry
+	// isn't streamed; they get the finished document instead
+	closeDocStream(jobID)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+	"code-doc-tool/internal/utils"
+)
+
+// RunLocal documents a local directory, archive or source file in-process
+// and writes the result to output, in format or, when format is empty,
+// the format named by output's extension. Additional formats in a comma
+// separated format are written beside output. A multi-project input
+// writes its documents into output as a directory. An existing output
+// file is handled as OUTPUT_COLLISION says; the path written is returned.
+// Setup must have been called first.
+func RunLocal(input, output, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(output), ".")
+	}
+	opts, _, err := newJobOptions(jobRequest{Format: format})
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(input)
+	if err != nil {
+		return "", err
+	}
+	jobID := uuid.New().String()
+	if opts.workDir, err = newWorkDir(jobID); err != nil {
+		return "", err
+	}
+	filePath := input
+	if !info.IsDir() {
+		// Like an upload, the file sits in the job's working directory
+		filePath = filepath.Join(opts.workDir, filepath.Base(input))
+		if err := copyFile(input, filePath); err != nil {
+			utils.CleanupDir(opts.workDir)
+			return "", err
+		}
+	}
+
+	processCodebase(registerJob(jobID, opts), jobID, filePath, filepath.Base(input), opts)
+
+	job, _ := jobs.Get(jobID)
+	if job.Status == models.JobStatusFailed {
+		return "", fmt.Errorf("%s", job.Message)
+	}
+	if len(job.Outputs) == 1 {
+		if output, err = services.ResolveCollision(output, cfg.OutputCollision); err != nil {
+			return "", err
+		}
+		if err := moveFile(filepath.Join("./output", job.Outputs[0].Filename), output); err != nil {
+			if cfg.OutputCollision != services.CollisionOverwrite {
+				os.Remove(output)
+			}
+			return "", err
+		}
+		// Further formats land beside output, differing only in extension
+		base := strings.TrimSuffix(output, filepath.Ext(output))
+		for _, artifact := range job.Outputs[0].Artifacts {
+			if err := moveFile(filepath.Join("./output", artifact), base+filepath.Ext(artifact)); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		if err := utils.CreateDir(output); err != nil {
+			return "", err
+		}
+		for _, out := range job.Outputs {
+			for _, filename := range append([]string{out.Filename}, out.Artifacts...) {
+				if err := moveFile(filepath.Join("./output", filename), filepath.Join(output, filepath.Base(filename))); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	if job.Status == models.JobStatusCompletedWithFallback {
+		return output, fmt.Errorf("%s", job.Message)
+	}
+	return output, nil
+}
+
+// moveFile renames src to dst, copying when they sit on different devices.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/services"
+)
+
+// writeProject writes files into a new temporary directory.
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunLocalCollision(t *testing.T) {
+	for _, tt := range []struct {
+		strategy, want string
+		err            error
+	}{
+		{services.CollisionOverwrite, "docs.md", nil},
+		{services.CollisionSuffix, "docs-1.md", nil},
+		{services.CollisionFail, "", services.ErrOutputExists},
+	} {
+		t.Run(tt.strategy, func(t *testing.T) {
+			setupTest(t, func(c *config.Config) { c.OutputCollision = tt.strategy })
+			input := writeProject(t, testProject)
+			output := filepath.Join(t.TempDir(), "docs.md")
+			os.WriteFile(output, []byte("previous"), 0644)
+
+			written, err := RunLocal(input, output, "")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if data, _ := os.ReadFile(output); string(data) != "previous" {
+					t.Error("the existing output was changed")
+				}
+				return
+			}
+			if filepath.Base(written) != tt.want {
+				t.Errorf("wrote %s, want %s", filepath.Base(written), tt.want)
+			}
+			if data, _ := os.ReadFile(written); !strings.Contains(string(data), "main.go") {
+				t.Errorf("%s doesn't hold the document:\n%s", written, data)
+			}
+		})
+	}
+}
+
+func TestRunLocalCollisionConcurrent(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.OutputCollision = services.CollisionSuffix })
+	input := writeProject(t, testProject)
+	output := filepath.Join(t.TempDir(), "docs.md")
+
+	const runs = 4
+	var mu sync.Mutex
+	var written []string
+	var wg sync.WaitGroup
+	for range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, err := RunLocal(input, output, "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			written = append(written, filepath.Base(path))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Strings(written)
+	if strings.Join(written, " ") != "docs-1.md docs-2.md docs-3.md docs.md" {
+		t.Errorf("wrote %v, want a name each", written)
+	}
+	for _, name := range written {
+		if data, _ := os.ReadFile(filepath.Join(filepath.Dir(output), name)); !strings.Contains(string(data), "main.go") {
+			t.Errorf("%s doesn't hold a document", name)
+		}
+	}
+}
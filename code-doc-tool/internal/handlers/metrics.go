@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetMetrics reports disk usage against the quota and how busy the intake
+// and analysis slots are.
+func GetMetrics(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"disk": diskQuota.Usage(),
+		"uploads": fiber.Map{
+			"inflight": len(intakeSlots),
+			"limit":    cap(intakeSlots),
+		},
+		"analyze": fiber.Map{
+			"inflight": len(analyzeSlots),
+			"limit":    cap(analyzeSlots),
+			"circuit":  agentBreaker.State(),
+		},
+	})
+}
+
+// statsTopLanguages is how many languages GetStats lists.
+const statsTopLanguages = 10
+
+// GetStats reports aggregates across all jobs: counts by outcome, success
+// and failure rates, average duration, files analyzed and the most common
+// languages, and whether processing is paused.
+func GetStats(c *fiber.Ctx) error {
+	stats := jobs.Stats(statsTopLanguages)
+	stats.Paused = dispatch.IsClosed()
+	return c.JSON(stats)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+)
+
+func TestGetStats(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.JobRetries = 0 })
+	app := newTestApp()
+
+	for _, files := range []map[string]string{
+		testProject,
+		{"app/main.py": "print(1)\n", "app/util.go": "package app\n"},
+		{"README.md": "# Nothing to analyze\n"},
+	} {
+		waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	}
+
+	_, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/stats", nil))
+	var stats models.JobStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 3 || stats.Completed != 2 || stats.Failed != 1 || stats.Processing != 0 {
+		t.Errorf("counts %+v", stats)
+	}
+	if stats.SuccessRate < 0.66 || stats.SuccessRate > 0.67 || stats.FailureRate < 0.33 || stats.FailureRate > 0.34 {
+		t.Errorf("rates %g and %g, want 2/3 and 1/3", stats.SuccessRate, stats.FailureRate)
+	}
+	if stats.FilesAnalyzed != 4 {
+		t.Errorf("files analyzed %d, want 4", stats.FilesAnalyzed)
+	}
+	if len(stats.Languages) != 2 || stats.Languages[0] != (models.LanguageCount{Language: "Go", Files: 3}) {
+		t.Errorf("languages %+v, want Go first with 3 files", stats.Languages)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ResponseHeaders sets security headers on every response and makes sure
+// JSON bodies declare their charset.
+func ResponseHeaders(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+
+	err := c.Next()
+
+	contentType := string(c.Response().Header.ContentType())
+	if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) && !strings.Contains(contentType, "charset") {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	}
+	return err
+}
+
+// RequireJobID turns away requests whose :jobId isn't a job ID before
+// any handler looks the job up.
+func RequireJobID(c *fiber.Ctx) error {
+	if !isJobID(c.Params("jobId")) {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, "Invalid job ID")
+	}
+	return c.Next()
+}
+
+// RequireAdmin lets through only requests bearing cfg.AdminToken, and
+// none at all when no token is configured.
+func RequireAdmin(c *fiber.Ctx) error {
+	if cfg.AdminToken == "" {
+		return errorResponse(c, fiber.StatusForbidden, ErrCodeForbidden, "Admin endpoints are disabled; set ADMIN_TOKEN to enable them")
+	}
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return errorResponse(c, fiber.StatusUnauthorized, ErrCodeUnauthorized, "Admin token required")
+	}
+	return c.Next()
+}
+
+// largeBodyRoutes may receive bodies up to MAX_FILE_SIZE; everything else
+// is held to cfg.JSONBodyLimit.
+var largeBodyRoutes = map[string]bool{
+	"/api/upload": true,
+}
+
+// LimitBody holds request bodies to their route's limit. The server only
+// buffers bodies up to JSONBodyLimit and streams the rest (see
+// ServerConfig), so an oversized body is turned away after reading at
+// most one byte past the limit rather than all of it. Bodies within the
+// limit are buffered here for the handlers.
+func LimitBody(c *fiber.Ctx) error {
+	limit := cfg.JSONBodyLimit
+	// Routing ignores case and a trailing slash, so the lookup does too
+	if largeBodyRoutes[strings.ToLower(strings.TrimRight(c.Path(), "/"))] {
+		limit = cfg.MaxFileSize
+	}
+	req := c.Request()
+	if int64(req.Header.ContentLength()) > limit {
+		return bodyTooLarge(c, limit)
+	}
+	if req.IsBodyStream() {
+		body, err := io.ReadAll(io.LimitReader(c.Context().RequestBodyStream(), limit+1))
+		if err != nil {
+			c.Context().SetConnectionClose()
+			return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, "Failed to read request body")
+		}
+		if int64(len(body)) > limit {
+			return bodyTooLarge(c, limit)
+		}
+		req.SetBodyRaw(body)
+	}
+	return c.Next()
+}
+
+// bodyTooLarge answers 413 and closes the connection, since the rest of
+// the body is left unread.
+func bodyTooLarge(c *fiber.Ctx, limit int64) error {
+	c.Context().SetConnectionClose()
+	return errorResponse(c, fiber.StatusRequestEntityTooLarge, ErrCodeBodyTooLarge,
+		fmt.Sprintf("Request body exceeds the %d byte limit for this endpoint", limit))
+}
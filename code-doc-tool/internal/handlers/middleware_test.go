@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	jobID := upload(t, app, testProject, nil)
+	waitJob(t, jobID)
+
+	for _, target := range []string{
+		"/api/status/" + jobID,
+		"/api/status/00000000-0000-0000-0000-000000000000",
+		"/api/stats",
+	} {
+		resp, _ := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, target, nil))
+		if got := resp.Header.Get(fiber.HeaderContentType); got != fiber.MIMEApplicationJSONCharsetUTF8 {
+			t.Errorf("%s: Content-Type = %q", target, got)
+		}
+		if got := resp.Header.Get(fiber.HeaderXContentTypeOptions); got != "nosniff" {
+			t.Errorf("%s: X-Content-Type-Options = %q", target, got)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name, token, auth string
+		status            int
+		code              string
+	}{
+		{"disabled", "", "Bearer anything", fiber.StatusForbidden, ErrCodeForbidden},
+		{"missing", "s3cret", "", fiber.StatusUnauthorized, ErrCodeUnauthorized},
+		{"wrong", "s3cret", "Bearer nope", fiber.StatusUnauthorized, ErrCodeUnauthorized},
+		{"not bearer", "s3cret", "Basic s3cret", fiber.StatusUnauthorized, ErrCodeUnauthorized},
+		{"valid", "s3cret", "Bearer s3cret", fiber.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTest(t, func(c *config.Config) { c.AdminToken = tt.token })
+			app := newTestApp()
+			req := httptest.NewRequest(fiber.MethodGet, "/api/metrics", nil)
+			if tt.auth != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.auth)
+			}
+			resp, body := doRequest(t, app, req)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got %d %s, want %d", resp.StatusCode, body, tt.status)
+			}
+			if tt.code != "" && errorCode(t, body) != tt.code {
+				t.Errorf("got %s, want %s", body, tt.code)
+			}
+			if tt.status == fiber.StatusOK && !strings.Contains(string(body), `"quota_bytes"`) {
+				t.Errorf("metrics missing disk usage: %s", body)
+			}
+		})
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.JSONBodyLimit = 1024; c.MaxFileSize = 64 * 1024 })
+	app := newTestApp()
+
+	large := `{"archive_url": "https://example.com/` + strings.Repeat("a", 2048) + `.zip"}`
+	for name, body := range map[string]io.Reader{
+		"sized":   strings.NewReader(large),
+		"chunked": io.MultiReader(strings.NewReader(large)),
+	} {
+		req := httptest.NewRequest(fiber.MethodPost, "/api/upload-url", body)
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		if req.ContentLength < 0 {
+			req.TransferEncoding = []string{"chunked"}
+		}
+		resp, respBody := doRequest(t, app, req)
+		if resp.StatusCode != fiber.StatusRequestEntityTooLarge || errorCode(t, respBody) != ErrCodeBodyTooLarge {
+			t.Errorf("%s JSON body got %d %s, want 413 %s", name, resp.StatusCode, respBody, ErrCodeBodyTooLarge)
+		}
+		if !strings.Contains(string(respBody), "exceeds the 1024 byte limit") {
+			t.Errorf("%s JSON body: error doesn't state the limit: %s", name, respBody)
+		}
+	}
+
+	// Archives well over the JSON limit are still accepted for upload
+	var source strings.Builder
+	source.WriteString("package main\n\n")
+	for i := range 400 {
+		fmt.Fprintf(&source, "// %08x%08x\n", i*2654435761, i*40503)
+	}
+	files := map[string]string{"main.go": source.String()}
+	archive := testZip(t, files)
+	if len(archive) <= 1024 {
+		t.Fatalf("archive of %d bytes is under the limit", len(archive))
+	}
+	for _, chunked := range []bool{false, true} {
+		req := uploadRequest(t, "project.zip", archive, map[string]string{"format": "md"})
+		if chunked {
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+		}
+		resp, body := doRequest(t, app, req)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("upload of %d bytes (chunked %v) got %d %s", len(archive), chunked, resp.StatusCode, body)
+		}
+		var uploaded UploadResponse
+		if err := json.Unmarshal(body, &uploaded); err != nil {
+			t.Fatal(err)
+		}
+		if job := waitJob(t, uploaded.JobID); job.Status != models.JobStatusCompleted {
+			t.Errorf("upload (chunked %v): job %s: %s", chunked, job.Status, job.Message)
+		}
+	}
+
+	// but not past MAX_FILE_SIZE, however the body is sent
+	for _, chunked := range []bool{false, true} {
+		req := uploadRequest(t, "project.zip", archive, map[string]string{"padding": strings.Repeat("x", 64*1024)})
+		if chunked {
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+		}
+		resp, body := doRequest(t, app, req)
+		if resp.StatusCode != fiber.StatusRequestEntityTooLarge || !strings.Contains(string(body), "exceeds the 65536 byte limit") {
+			t.Errorf("oversized upload (chunked %v) got %d %s", chunked, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,1485 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+	"code-doc-tool/internal/utils"
+)
+
+// jobLogf writes to the server log and to the job's own log.
+func jobLogf(jobID, level, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if job, ok := jobs.Get(jobID); ok && job.TraceID != "" {
+		log.Printf("[job %s trace %s] %s", jobID, job.TraceID, message)
+	} else {
+		log.Printf("[job %s] %s", jobID, message)
+	}
+	jobs.AppendLog(jobID, level, message)
+}
+
+// extractFilter keeps analyzable sources, project manifests, ignore files
+// and anything with a language override, so large archives don't spill
+// binaries and media onto disk.
+func extractFilter(opts jobOptions) func(name string) bool {
+	exts := map[string]bool{}
+	for _, ext := range cfg.SourceExtensions {
+		exts[strings.ToLower(ext)] = true
+	}
+	overridden := map[string]bool{}
+	for rel := range opts.LanguageOverrides {
+		overridden[path.Join(filepath.ToSlash(opts.Subpath), rel)] = true
+	}
+
+	return func(name string) bool {
+		name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+		base := path.Base(name)
+		if opts.Changelog > 0 && (name == ".git" || strings.HasPrefix(name, ".git/") || strings.Contains(name, "/.git/")) {
+			return true
+		}
+		if exts[strings.ToLower(path.Ext(base))] || services.IsProjectManifest(base) || services.IsWorkspaceManifest(base) || services.IsIgnoreFile(base) {
+			return true
+		}
+		if cfg.SchemaDocs && services.IsSchemaFile(base) {
+			return true
+		}
+		if opts.DocInputs && docInputs.MatchNested(name) {
+			return true
+		}
+		// Possibly a script; its shebang is only readable once extracted
+		if path.Ext(base) == "" && !strings.HasPrefix(base, ".") {
+			return true
+		}
+		// Overrides are relative to the subpath but archives often wrap
+		// everything in a top-level folder, so match on the suffix too
+		for rel := range overridden {
+			if name == rel || strings.HasSuffix(name, "/"+rel) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// stageSourceFile copies an uploaded source file into extractPath so the
+// rest of the pipeline treats it like an extracted archive. The upload
+// itself stays put, since a retry or a resume after a restart clears
+// extractPath and stages it again.
+func stageSourceFile(filePath, extractPath string) error {
+	if err := utils.CreateDir(extractPath); err != nil {
+		return err
+	}
+	return copyFile(filePath, filepath.Join(extractPath, filepath.Base(filePath)))
+}
+
+// hasFiles reports whether dir contains at least one regular file.
+func hasFiles(dir string) bool {
+	found := false
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// progressFunc reports how many of total files have been analyzed.
+type progressFunc func(done, total int)
+
+func processCodebase(ctx context.Context, jobID, filePath, filename string, opts jobOptions) {
+	jobLogf(jobID, models.LogLevelInfo, "Starting processing of %s", filename)
+	// Deferred first so it runs once the work directory is gone
+	defer diskQuota.Invalidate()
+	defer utils.CleanupDir(opts.workDir)
+	defer opts.doneIntake()
+	defer closeDocStream(jobID)
+
+	// Transient failures are retried as a whole job, with the delay
+	// doubling each time
+	delay := cfg.JobRetryDelay
+	for attempt := 1; ; attempt++ {
+		jobs.Modify(jobID, func(job *models.Job) {
+			job.Attempts = attempt
+		})
+		failure := processAttempt(ctx, jobID, filePath, filename, opts)
+		if failure == nil {
+			return
+		}
+		if ctx.Err() != nil || attempt > cfg.JobRetries || !isTransient(failure.err) {
+			jobs.Fail(jobID, failure.message)
+			return
+		}
+
+		jobLogf(jobID, models.LogLevelWarn, "Attempt %d failed, retrying the job in %s: %v", attempt, delay, failure.err)
+		jobs.Update(jobID, 0, fmt.Sprintf("Retrying in %s: %s", delay, failure.message))
+		resetAttempt(jobID, opts)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			jobLogf(jobID, models.LogLevelWarn, "Job cancelled: %v", ctx.Err())
+			return
+		}
+		delay *= 2
+	}
+}
+
+// processAttempt runs the pipeline once, completing the job on success.
+// It returns why the attempt failed, or nil once the job is complete or
+// cancelled.
+func processAttempt(ctx context.Context, jobID, filePath, filename string, opts jobOptions) *jobFailure {
+	extractPath := filepath.Join(opts.workDir, "extracted")
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		// Local runs document a directory where it is, read-only
+		extractPath = filePath
+		jobLogf(jobID, models.LogLevelInfo, "Local directory, skipping extraction")
+		jobs.Update(jobID, 10, "Directory ready")
+	} else if isSourceFile(filename) {
+		// A lone source file becomes a one-file project, no extraction needed
+		if err := stageSourceFile(filePath, extractPath); err != nil {
+			jobLogf(jobID, models.LogLevelError, "Failed to stage source file: %v", err)
+			return &jobFailure{err: err, message: failureMessage(err, "Failed to stage source file")}
+		}
+		jobLogf(jobID, models.LogLevelInfo, "Single source file upload, skipping extraction")
+		jobs.Update(jobID, 10, "Source file staged")
+	} else {
+		extractOpts := utils.ExtractOptions{Password: opts.Password}
+		if cfg.ExtractSourcesOnly {
+			extractOpts.Keep = extractFilter(opts)
+		}
+		skipped := 0
+		if cfg.ExtractSkipCorrupt {
+			extractOpts.SkipCorrupt = func(name string, err error) {
+				skipped++
+				jobLogf(jobID, models.LogLevelWarn, "Skipping unreadable archive entry %s: %v", name, err)
+				jobs.Modify(jobID, func(job *models.Job) {
+					job.SkippedEntries = append(job.SkippedEntries, models.SkippedEntry{Name: name, Error: err.Error()})
+				})
+			}
+		}
+		releaseExtraction, err := reserveExtraction(filePath, extractOpts)
+		if err == nil {
+			err = utils.ExtractArchive(filePath, extractPath, extractOpts)
+			releaseExtraction()
+		}
+		if err != nil {
+			jobLogf(jobID, models.LogLevelError, "Failed to extract archive: %v", err)
+			return &jobFailure{err: err, message: failureMessage(err, "Failed to extract archive")}
+		}
+		if skipped > 0 && !hasFiles(extractPath) {
+			jobLogf(jobID, models.LogLevelError, "No usable files extracted, %d entries skipped", skipped)
+			return &jobFailure{message: "Archive is corrupt: no usable files could be extracted"}
+		}
+		jobLogf(jobID, models.LogLevelInfo, "Extraction complete")
+		jobs.Update(jobID, 10, "Archive extracted")
+	}
+
+	opts.doneIntake()
+
+	// Everything below is relative to the requested subpath, if any
+	basePath := extractPath
+	if opts.Subpath != "" {
+		var err error
+		if basePath, err = services.ResolveSubpath(extractPath, opts.Subpath); err != nil {
+			jobLogf(jobID, models.LogLevelError, "Invalid subpath: %v", err)
+			return &jobFailure{err: err, message: err.Error()}
+		}
+	}
+
+	opts.basePath = basePath
+	opts.cache = services.NewDocCache(opts.templateKey())
+	if opts.PreviousCache != nil && !opts.PreviousCache.Matches(opts.templateKey()) {
+		jobLogf(jobID, models.LogLevelWarn, "Previous job used different sections; analyzing every file")
+		opts.PreviousCache = nil
+	}
+
+	// Language overrides are keyed by path relative to the base
+	opts.resolvedOverrides = map[string]string{}
+	for rel, lang := range opts.LanguageOverrides {
+		opts.resolvedOverrides[filepath.Join(basePath, filepath.FromSlash(rel))] = lang
+	}
+	opts.languageOf = services.WithOverrides(opts.resolvedOverrides)
+
+	// Explicit roots win over workspace and manifest based detection
+	var roots []string
+	var workspaces []services.Workspace
+	var err error
+	if len(opts.Roots) > 0 {
+		roots, err = services.ResolveRoots(basePath, opts.Roots)
+	} else {
+		roots, workspaces, err = services.DetectProjectRoots(basePath)
+	}
+	if err != nil {
+		jobLogf(jobID, models.LogLevelError, "Failed to resolve project roots: %v", err)
+		return &jobFailure{err: err, message: err.Error()}
+	}
+	for _, ws := range workspaces {
+		jobLogf(jobID, models.LogLevelInfo, "Using the %d packages declared by the %s workspace in %s", len(ws.Packages), ws.Tool, relPath(basePath, ws.Root))
+	}
+
+	// Sub-projects run side by side up to the configured limit; the first
+	// failure stops the rest
+	started := time.Now()
+	runs := make([]projectRun, len(roots))
+	projectCtx, cancelProjects := context.WithCancel(ctx)
+	defer cancelProjects()
+	var progressMu sync.Mutex
+	fractions := make([]float64, len(roots))
+	slots := services.NewSemaphore(max(cfg.ProjectConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, root := range roots {
+		if err := slots.Acquire(projectCtx); err != nil {
+			break
+		}
+		name := projectName(basePath, root)
+
+		// Each project gets an equal share of the analysis progress range
+		progress := func(done, total int) {
+			progressMu.Lock()
+			fractions[i] = float64(done) / float64(total)
+			sum := 0.0
+			for _, f := range fractions {
+				sum += f
+			}
+			pct := 10 + int(80*sum)/len(roots)
+			progressMu.Unlock()
+			jobs.Update(jobID, pct, fmt.Sprintf("Analyzed %d of %d files in %s", done, total, name))
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer slots.Release()
+			runs[i] = documentRoot(projectCtx, jobID, name, root, len(roots) > 1, started, opts, progress)
+			if runs[i].err != nil {
+				cancelProjects()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Job cancelled: %v", ctx.Err())
+		return nil
+	}
+	for _, run := range runs {
+		// Projects stopped because a sibling failed report cancellation
+		if run.err != nil && !errors.Is(run.err, context.Canceled) {
+			jobLogf(jobID, models.LogLevelError, "Failed to document %s: %v", run.output.Project, run.err)
+			return &jobFailure{err: run.err, message: failureMessage(run.err, run.err.Error())}
+		}
+	}
+
+	// Listed in detection order unless configured otherwise
+	if cfg.ProjectOrder == "name" {
+		sort.SliceStable(runs, func(i, j int) bool {
+			return runs[i].output.Project < runs[j].output.Project
+		})
+	}
+	var outputs []models.JobOutput
+	var languages []models.LanguageStat
+	var projectType string
+	fallback := false
+	for _, run := range runs {
+		outputs = append(outputs, run.output)
+		languages = services.MergeLanguageStats(languages, run.languages)
+		fallback = fallback || run.fallback
+	}
+	if len(runs) == 1 {
+		projectType = runs[0].output.Type
+	}
+
+	if err := opts.cache.Save(services.DocCachePath(jobID)); err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to save doc cache: %v", err)
+	}
+
+	jobs.Modify(jobID, func(job *models.Job) {
+		job.Outputs = outputs
+		job.Languages = languages
+		job.ProjectType = projectType
+	})
+	if fallback {
+		message := fmt.Sprintf("Documentation generated as markdown; %s generation failed", opts.Generator.Extension())
+		jobLogf(jobID, models.LogLevelWarn, "%s", message)
+		jobs.CompleteWithFallback(jobID, message)
+		return nil
+	}
+	jobLogf(jobID, models.LogLevelInfo, "Documentation generated successfully")
+	jobs.Complete(jobID, "Documentation generated successfully")
+	return nil
+}
+
+// projectRun is the outcome of documenting one sub-project.
+type projectRun struct {
+	output    models.JobOutput
+	languages []models.LanguageStat
+	fallback  bool
+	err       error
+}
+
+// documentRoot documents the project at root into its own output file,
+// named after the project when the job has several.
+func documentRoot(ctx context.Context, jobID, name, root string, multi bool, started time.Time, opts jobOptions, progress progressFunc) projectRun {
+	filename := outputFilename(jobID, opts.Generator.Extension())
+	if multi {
+		filename = fmt.Sprintf("%s_%s_documentation.%s", jobID, name, opts.Generator.Extension())
+	}
+	if dir := services.OutputDir(cfg.OutputDirTemplate, name, jobID, started); dir != "" {
+		filename = path.Join(dir, filename)
+	}
+	// Outputs are recorded under the name finally used, so status and
+	// downloads follow a suffixed one
+	resolved, err := services.ResolveCollision(filepath.Join("./output", filepath.FromSlash(filename)), cfg.OutputCollision)
+	if err != nil {
+		return projectRun{output: models.JobOutput{Project: name}, err: err}
+	}
+	filename = path.Join(path.Dir(filename), filepath.Base(resolved))
+
+	// A single document can be streamed to clients while it is assembled
+	if !multi {
+		opts.stream = docStream(jobID)
+	}
+
+	project := &models.Project{Name: name, Path: root, CreatedAt: time.Now()}
+	written, err := documentProject(ctx, jobID, project, filename, opts, progress)
+	if written != filename && cfg.OutputCollision != services.CollisionOverwrite {
+		// Free the name claimed above, which nothing was written to
+		os.Remove(resolved)
+	}
+	if err != nil {
+		return projectRun{output: models.JobOutput{Project: name}, err: err}
+	}
+	artifacts := extraFormats(jobID, filename, written, opts)
+	if spec := openAPISpec(jobID, project, filename, opts); spec != "" {
+		artifacts = append(artifacts, spec)
+	}
+	return projectRun{
+		output: models.JobOutput{
+			Project:   name,
+			Type:      project.Type,
+			Filename:  written,
+			Artifacts: artifacts,
+			Digests:   outputDigests(jobID, append([]string{written}, artifacts...)),
+			Files:     project.Files,
+		},
+		languages: project.Languages,
+		fallback:  written != filename,
+	}
+}
+
+// docSeparator sits between the documentation of two files.
+const docSeparator = "\n\n---\n\n"
+
+// documentProject analyzes the sources under project.Path and writes one
+// document to ./output/filename, filling in the project's type and
+// language statistics along the way. It returns the name of the file
+// written, which is a markdown fallback if the generator failed.
+func documentProject(ctx context.Context, jobID string, project *models.Project, filename string, opts jobOptions, progress progressFunc) (string, error) {
+	root := project.Path
+
+	// Collect code files with the configured extensions
+	exts := cfg.SourceExtensions
+	codeFiles, generated, err := CollectSourceFiles(root, exts, CollectOptions{
+		ModifiedSince:  opts.ModifiedSince,
+		SkipGenerated:  opts.SkipGenerated,
+		FollowSymlinks: cfg.FollowSymlinks,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to collect source files: %w", err)
+	}
+	if len(generated) > 0 {
+		jobLogf(jobID, models.LogLevelInfo, "Skipped %d generated files, e.g. %s", len(generated), relPath(root, generated[0]))
+	}
+	codeFiles = includeOverridden(root, codeFiles, exts, opts)
+
+	// SQL files fill the schema section; those declaring more than tables
+	// are documented by the agent too
+	var schemaSection string
+	if cfg.SchemaDocs {
+		var rest []string
+		schemaSection, rest = schemaDocs(jobID, project)
+		for _, file := range rest {
+			if !slices.Contains(codeFiles, file) {
+				codeFiles = append(codeFiles, file)
+			}
+		}
+	}
+
+	// Point out languages present in the tree but left out of analysis
+	unsupported, err := services.UnsupportedExtensions(root, exts)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to tally unsupported files: %v", err)
+	}
+	if len(codeFiles) == 0 {
+		if !opts.ModifiedSince.IsZero() {
+			return "", fmt.Errorf("no source files modified since %s", opts.ModifiedSince.Format(time.DateOnly))
+		}
+		if len(generated) > 0 {
+			return "", fmt.Errorf("no source files found; all %d analyzable files are generated", len(generated))
+		}
+		if len(unsupported) > 0 {
+			return "", fmt.Errorf("no source files found; found %s not in the analyzable set", services.DescribeExtensions(unsupported, 3))
+		}
+		return "", fmt.Errorf("no source files found")
+	}
+	if skipped := countFiles(unsupported); skipped > len(codeFiles) {
+		jobLogf(jobID, models.LogLevelWarn, "Most source files aren't analyzed: found %s not in the analyzable set", services.DescribeExtensions(unsupported, 3))
+	}
+
+	project.Type = services.ClassifyProject(root)
+	opts.projectType = project.Type
+	if opts.DocInputs {
+		opts.docContext = docContext(jobID, root)
+	}
+	project.Languages, err = services.ComputeLanguageStats(codeFiles, opts.languageOf)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to compute language stats: %v", err)
+	}
+
+	// Under the title, the project summary and the sections that don't
+	// depend on analysis lead the document
+	title := fmt.Sprintf("# %s Documentation\n\n", project.Name)
+	lead := services.RenderProjectHeader(project) + "\n---\n\n"
+	if diagrams := services.JobDiagrams(jobID); len(diagrams) > 0 {
+		jobLogf(jobID, models.LogLevelInfo, "Embedding %d architecture diagrams", len(diagrams))
+		lead += services.RenderDiagrams(diagrams, filepath.Dir(filepath.Join("./output", filename))) + "\n\n---\n\n"
+	}
+	if apiSection := apiEndpoints(jobID, project); apiSection != "" {
+		lead += apiSection + "\n---\n\n"
+	}
+	if schemaSection != "" {
+		lead += schemaSection + "\n---\n\n"
+	}
+
+	// Without an overview up front or grouping by package, the document
+	// only grows at its end while files are analyzed, so it can be
+	// streamed as it is assembled
+	streamed := opts.stream != nil && opts.Overview == services.OverviewOff && opts.Group != services.GroupPackage
+	if streamed {
+		opts.stream.Write(services.OffsetHeadings(title+lead, opts.HeadingOffset))
+	}
+
+	// Analyze files; results come back in document order regardless of
+	// the order they were dispatched in
+	services.OrderFiles(codeFiles, opts.Order, opts.languageOf)
+	var docs []string
+	var deadLetters []models.DeadLetter
+	var retried []models.RetriedFile
+	var overviews []services.FileOverview
+	var analyzed []string
+	var sections []services.FileSection
+	lowConfidence := 0
+	documented := false
+	var fatal error
+	analyzeFiles(ctx, jobID, codeFiles, opts, progress, func(result fileResult) {
+		if fatal != nil {
+			return
+		}
+		if result.Err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(result.Err, services.ErrAgentUnavailable) {
+				fatal = result.Err
+				return
+			}
+			rel, err := filepath.Rel(root, result.Path)
+			if err != nil {
+				rel = result.Path
+			}
+			jobLogf(jobID, models.LogLevelError, "Giving up on %s after %d attempts (%s): %v", filepath.ToSlash(rel), len(result.History), attemptKinds(result.History), result.Err)
+			deadLetters = append(deadLetters, models.DeadLetter{
+				Project:  project.Name,
+				Path:     filepath.ToSlash(rel),
+				Error:    result.Err.Error(),
+				Attempts: len(result.History),
+				TimedOut: errors.Is(result.Err, errAnalysisBudget),
+				History:  result.History,
+			})
+			return
+		}
+		analyzed = append(analyzed, result.Path)
+		if len(result.History) > 0 {
+			rel, err := filepath.Rel(root, result.Path)
+			if err != nil {
+				rel = result.Path
+			}
+			retried = append(retried, models.RetriedFile{
+				Project: project.Name,
+				Path:    filepath.ToSlash(rel),
+				History: result.History,
+			})
+		}
+		if result.Batched {
+			// Covered by an earlier file's batch document, which is already
+			// written; a low score of the file's own is still flagged
+			if result.Confidence != nil && *result.Confidence < cfg.MinConfidence {
+				rel, err := filepath.Rel(root, result.Path)
+				if err != nil {
+					rel = result.Path
+				}
+				jobLogf(jobID, models.LogLevelWarn, "Low confidence (%.2f) for %s, flagged for review", *result.Confidence, filepath.ToSlash(rel))
+				lowConfidence++
+			}
+			return
+		}
+		doc := result.Doc
+		if opts.PublicOnly {
+			// The agent may document private helpers regardless
+			doc = services.DropSymbolSections(doc, privateSymbols(result.Path, opts))
+		}
+		if strings.Trim(doc, " \t\r\n-*_") != "" {
+			documented = true
+			if opts.ErrorTable {
+				doc = services.InsertErrorTable(doc, errorTable(jobID, root, result.Path, opts))
+			}
+		}
+		if opts.Overview != services.OverviewOff {
+			if text := services.ExtractOverview(doc); text != "" {
+				rel, err := filepath.Rel(root, result.Path)
+				if err != nil {
+					rel = result.Path
+				}
+				overviews = append(overviews, services.FileOverview{File: filepath.ToSlash(rel), Text: text})
+			}
+		}
+		if doc == "" && !opts.IncludeSource {
+			return
+		}
+		if result.Confidence != nil && *result.Confidence < cfg.MinConfidence && doc != "" {
+			rel, err := filepath.Rel(root, result.Path)
+			if err != nil {
+				rel = result.Path
+			}
+			jobLogf(jobID, models.LogLevelWarn, "Low confidence (%.2f) for %s, flagged for review", *result.Confidence, filepath.ToSlash(rel))
+			doc = services.AnnotateLowConfidence(doc, *result.Confidence)
+			lowConfidence++
+		}
+		if opts.IncludeSource {
+			doc += sourceSection(root, result.Path, opts.languageOf(result.Path))
+		}
+		if streamed {
+			chunk := doc
+			if len(docs) > 0 {
+				chunk = docSeparator + doc
+			}
+			opts.stream.Write(services.OffsetHeadings(chunk, opts.HeadingOffset))
+		}
+		docs = append(docs, doc)
+		if opts.Group == services.GroupPackage {
+			sections = append(sections, services.FileSection{
+				Package: services.PackageOf(root, result.Path, opts.languageOf(result.Path)),
+				Doc:     doc,
+			})
+		}
+	})
+	if fatal != nil {
+		return "", fatal
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if len(deadLetters) > 0 || len(retried) > 0 || lowConfidence > 0 {
+		jobs.Modify(jobID, func(job *models.Job) {
+			job.DeadLetters = append(job.DeadLetters, deadLetters...)
+			job.RetriedFiles = append(job.RetriedFiles, retried...)
+			job.LowConfidence += lowConfidence
+		})
+	}
+	// Headers and tables alone would make a document that looks complete
+	if !documented {
+		return "", errNoDocumentation
+	}
+
+	// Combine all docs into one (simple join, or make a section per file)
+	// The project overview goes right under the title
+	combinedDoc := title
+	if overview := projectOverview(ctx, jobID, project, overviews, opts); overview != "" {
+		combinedDoc += overview + "\n---\n\n"
+	}
+	combinedDoc += lead
+	if opts.Group == services.GroupPackage {
+		combinedDoc += services.RenderPackageGroups(sections, docSeparator)
+	} else {
+		combinedDoc += strings.Join(docs, docSeparator)
+	}
+	assembled := len(combinedDoc)
+	if opts.ConfigFiles {
+		if configuration := configurationSection(ctx, jobID, root, opts); configuration != "" {
+			combinedDoc += "\n\n---\n\n" + configuration
+		}
+	}
+	if opts.Index {
+		combinedDoc += "\n\n---\n\n" + symbolIndex(jobID, root, codeFiles, opts)
+	}
+	if opts.FileTable {
+		project.Files = fileInfos(jobID, root, analyzed, opts)
+		combinedDoc += "\n\n---\n\n" + services.RenderFileTable(project.Files)
+	}
+	if opts.Changelog > 0 {
+		if changelog := recentChanges(ctx, jobID, root, opts); changelog != "" {
+			combinedDoc += "\n\n---\n\n" + changelog
+		}
+	}
+
+	switch {
+	case streamed:
+		opts.stream.Write(services.OffsetHeadings(combinedDoc[assembled:], opts.HeadingOffset))
+	case opts.stream != nil:
+		opts.stream.Write(services.OffsetHeadings(combinedDoc, opts.HeadingOffset))
+	}
+	combinedDoc = services.OffsetHeadings(combinedDoc, opts.HeadingOffset)
+
+	// Keep the markdown so the document can be served in other formats
+	if err := services.SaveMarkdown(filename, combinedDoc); err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to save markdown: %v", err)
+	}
+
+	// Generate documentation file in the requested format
+	outputPath := filepath.Join("./output", filename)
+	if err := utils.CreateDir(filepath.Dir(outputPath)); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	footer := documentFooter(jobID)
+	if err := services.GenerateWithFooter(opts.Generator, combinedDoc, outputPath, footer); err != nil {
+		if utils.IsDiskFull(err) || opts.Generator.Extension() == "md" {
+			return "", fmt.Errorf("failed to generate documentation: %w", err)
+		}
+
+		// Hand over the markdown rather than nothing at all
+		jobLogf(jobID, models.LogLevelWarn, "Failed to generate %s, falling back to markdown: %v", opts.Generator.Extension(), err)
+		fallback := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".md"
+		if ferr := services.GenerateWithFooter(services.NewMarkdownGenerator(), combinedDoc, filepath.Join("./output", fallback), footer); ferr != nil {
+			return "", fmt.Errorf("failed to generate documentation: %w", err)
+		}
+		return fallback, nil
+	}
+	if opts.stream != nil {
+		trailer := ""
+		if footer != "" {
+			trailer = services.FooterSection(footer)
+		}
+		opts.stream.Finish(trailer)
+	}
+	return filename, nil
+}
+
+// extraFormats renders the markdown saved for filename in each additional
+// format the job asked for, next to the primary output, and returns the
+// names of the files written. A format that fails is logged and left out.
+func extraFormats(jobID, filename, written string, opts jobOptions) []string {
+	if len(opts.ExtraGenerators) == 0 {
+		return nil
+	}
+	markdown, err := os.ReadFile(services.MarkdownPath(filename))
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to read markdown for additional formats: %v", err)
+		return nil
+	}
+
+	var artifacts []string
+	footer := documentFooter(jobID)
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	for _, generator := range opts.ExtraGenerators {
+		name := base + "." + generator.Extension()
+		if name == written {
+			// Already produced as the primary output's markdown fallback
+			continue
+		}
+		if err := services.GenerateWithFooter(generator, string(markdown), filepath.Join("./output", name), footer); err != nil {
+			jobLogf(jobID, models.LogLevelWarn, "Failed to generate %s: %v", generator.Extension(), err)
+			continue
+		}
+		artifacts = append(artifacts, name)
+	}
+	return artifacts
+}
+
+// outputDigests hashes the files a project produced so downloads can be
+// verified against them.
+func outputDigests(jobID string, filenames []string) map[string]string {
+	digests := map[string]string{}
+	for _, filename := range filenames {
+		digest, err := services.FileDigest(filepath.Join("./output", filename))
+		if err != nil {
+			jobLogf(jobID, models.LogLevelWarn, "Failed to hash %s: %v", filename, err)
+			continue
+		}
+		digests[filename] = digest
+	}
+	return digests
+}
+
+// documentFooter renders the generation metadata closing a job's
+// documents, or returns "" when DOC_FOOTER is off.
+func documentFooter(jobID string) string {
+	if !cfg.DocFooter {
+		return ""
+	}
+	created := time.Now()
+	if job, ok := jobs.Get(jobID); ok {
+		created = job.CreatedAt
+	}
+	return services.RenderFooter(cfg.DocFooterTemplate, jobID, created)
+}
+
+// fileInfos collects the metadata of files for the file table.
+func fileInfos(jobID, root string, files []string, opts jobOptions) []models.FileInfo {
+	infos := make([]models.FileInfo, 0, len(files))
+	for _, file := range files {
+		stat, err := os.Stat(file)
+		if err != nil {
+			jobLogf(jobID, models.LogLevelWarn, "Failed to stat %s: %v", file, err)
+			continue
+		}
+		lines, err := services.CountLines(file)
+		if err != nil {
+			jobLogf(jobID, models.LogLevelWarn, "Failed to count lines of %s: %v", file, err)
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			rel = file
+		}
+		infos = append(infos, models.FileInfo{
+			Name:      filepath.Base(file),
+			Path:      filepath.ToSlash(rel),
+			Extension: filepath.Ext(file),
+			Size:      stat.Size(),
+			Lines:     lines,
+			Language:  opts.languageOf(file),
+		})
+	}
+	return infos
+}
+
+func countFiles(counts []services.ExtensionCount) int {
+	total := 0
+	for _, c := range counts {
+		total += c.Files
+	}
+	return total
+}
+
+// includeOverridden adds files under root whose overridden language is one
+// of the analyzable languages, even if their extension isn't collected.
+func includeOverridden(root string, files, exts []string, opts jobOptions) []string {
+	analyzable := map[string]bool{}
+	for _, ext := range exts {
+		analyzable[services.DetectLanguage("x"+ext)] = true
+	}
+	seen := map[string]bool{}
+	for _, file := range files {
+		seen[file] = true
+	}
+
+	for path, lang := range opts.resolvedOverrides {
+		if seen[path] || !analyzable[lang] || !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && !info.ModTime().Before(opts.ModifiedSince) {
+			files = append(files, path)
+			seen[path] = true
+		}
+	}
+	return files
+}
+
+// docContext gathers the documentation inputs under root into the context
+// sent to the agent with each file.
+func docContext(jobID, root string) string {
+	files, err := docInputs.Find(root)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to look for documentation inputs: %v", err)
+		return ""
+	}
+	text, included := services.RenderDocContext(root, files, cfg.DocInputMaxBytes)
+	if len(included) == 0 {
+		jobLogf(jobID, models.LogLevelInfo, "No documentation inputs found")
+		return ""
+	}
+	jobLogf(jobID, models.LogLevelInfo, "Sending %d documentation files as context: %s", len(included), strings.Join(included, ", "))
+	return text
+}
+
+// projectOverview produces the project-level overview in the job's
+// overview mode and records it on project. A failed synthesis call falls
+// back to aggregating the per-file overviews.
+func projectOverview(ctx context.Context, jobID string, project *models.Project, overviews []services.FileOverview, opts jobOptions) string {
+	if opts.Overview == services.OverviewOff {
+		return ""
+	}
+	if len(overviews) == 0 {
+		jobLogf(jobID, models.LogLevelWarn, "No per-file overviews to build a project overview from")
+		return ""
+	}
+
+	overview := ""
+	if opts.Overview == services.OverviewAgent {
+		var err error
+		overview, _, err = withRetry(ctx, jobID, "project overview", func() (string, error) {
+			return services.SynthesizeOverview(ctx, analyzer, project.Name, overviews, opts.docContext)
+		})
+		if err != nil {
+			jobLogf(jobID, models.LogLevelWarn, "Failed to synthesize project overview, aggregating instead: %v", err)
+		}
+	}
+	if overview == "" {
+		overview = services.AggregateOverview(overviews)
+	}
+	project.Overview = overview
+	return overview
+}
+
+// recentChanges renders the last opts.Changelog commits from the .git
+// directory of the project or the upload as a whole, if it has one.
+func recentChanges(ctx context.Context, jobID, root string, opts jobOptions) string {
+	gitDir, ok := services.FindGitDir(root, opts.basePath)
+	if !ok {
+		jobLogf(jobID, models.LogLevelWarn, "No .git directory in the upload, leaving out the changelog")
+		return ""
+	}
+	commits, err := services.RecentCommits(ctx, gitDir, opts.Changelog)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to read commit history: %v", err)
+		return ""
+	}
+	if len(commits) == 0 {
+		return ""
+	}
+	return services.RenderChangelog(commits)
+}
+
+// apiEndpoints fills project.APIEndpoints from an OpenAPI/Swagger spec in
+// the project and renders them. Without a spec the APIs are left to the
+// agent's per-file documentation.
+func apiEndpoints(jobID string, project *models.Project) string {
+	spec, err := services.FindAPISpec(project.Path)
+	if err != nil || spec == "" {
+		return ""
+	}
+	endpoints, err := services.ParseAPISpec(spec)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Ignoring API spec: %v", err)
+		return ""
+	}
+	if len(endpoints) == 0 {
+		return ""
+	}
+	project.APIEndpoints = endpoints
+	jobLogf(jobID, models.LogLevelInfo, "Documented %d endpoints from %s", len(endpoints), filepath.Base(spec))
+	return services.RenderAPIEndpoints(endpoints)
+}
+
+// schemaDocs fills project.Schema from the SQL files under the project
+// and renders its section. It also returns the files declaring views,
+// functions and the like, which the section doesn't cover.
+func schemaDocs(jobID string, project *models.Project) (string, []string) {
+	files, err := services.FindSchemaFiles(project.Path)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to look for schema files: %v", err)
+		return "", nil
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	tables, rest := services.ReadSchema(project.Path, files)
+	if len(tables) == 0 {
+		return "", rest
+	}
+	project.Schema = tables
+	jobLogf(jobID, models.LogLevelInfo, "Documented %d tables from %d SQL files", len(tables), len(files))
+	return services.RenderSchema(tables), rest
+}
+
+// openAPISpec writes project.APIEndpoints as an OpenAPI spec beside
+// filename when the job asked for one and returns the spec's name.
+func openAPISpec(jobID string, project *models.Project, filename string, opts jobOptions) string {
+	if opts.OpenAPI == "" {
+		return ""
+	}
+	if len(project.APIEndpoints) == 0 {
+		jobLogf(jobID, models.LogLevelInfo, "No API endpoints found, skipping OpenAPI spec")
+		return ""
+	}
+	data, err := services.MarshalOpenAPISpec(services.BuildOpenAPISpec(project.Name, project.APIEndpoints), opts.OpenAPI)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to encode OpenAPI spec: %v", err)
+		return ""
+	}
+	name := services.OpenAPIFilename(filename, opts.OpenAPI)
+	if err := os.WriteFile(filepath.Join("./output", name), data, 0644); err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to write OpenAPI spec: %v", err)
+		return ""
+	}
+	jobLogf(jobID, models.LogLevelInfo, "Wrote OpenAPI spec for %d endpoints", len(project.APIEndpoints))
+	return name
+}
+
+// configurationSection asks the agent to explain each configuration file
+// under root and renders the answers as the Configuration section. Files
+// that fail are logged and left out.
+func configurationSection(ctx context.Context, jobID, root string, opts jobOptions) string {
+	files, err := services.FindConfigFiles(root)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to look for configuration files: %v", err)
+		return ""
+	}
+	if len(files) == 0 {
+		jobLogf(jobID, models.LogLevelInfo, "No configuration files found")
+		return ""
+	}
+
+	docs := make([]services.ConfigDoc, len(files))
+	slots := services.NewSemaphore(max(cfg.AnalyzeConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		if slots.Acquire(ctx) != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer slots.Release()
+			rel := relPath(root, file)
+			doc, _, err := withRetry(ctx, jobID, rel, func() (string, error) {
+				return analyzer.Analyze(ctx, file, services.AugmentTemplate(services.ConfigFormatTemplate, fileAugmentations(file, opts)))
+			})
+			if err != nil {
+				jobLogf(jobID, models.LogLevelWarn, "Failed to document configuration file %s: %v", rel, err)
+				return
+			}
+			docs[i] = services.ConfigDoc{Path: rel, Doc: doc}
+		}()
+	}
+	wg.Wait()
+
+	documented := docs[:0]
+	for _, doc := range docs {
+		if strings.TrimSpace(doc.Doc) != "" {
+			documented = append(documented, doc)
+		}
+	}
+	if len(documented) == 0 || ctx.Err() != nil {
+		return ""
+	}
+	jobLogf(jobID, models.LogLevelInfo, "Documented %d configuration files", len(documented))
+	return services.RenderConfiguration(documented)
+}
+
+// symbolIndex extracts the symbols declared in files and renders them as an
+// index referencing each symbol's file and line.
+func symbolIndex(jobID, root string, files []string, opts jobOptions) string {
+	var symbols []models.Symbol
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			rel = filepath.Base(file)
+		}
+		found, err := services.ExtractSymbols(file, filepath.ToSlash(rel), opts.languageOf(file))
+		if err != nil {
+			jobLogf(jobID, models.LogLevelWarn, "Failed to extract symbols from %s: %v", rel, err)
+			continue
+		}
+		symbols = append(symbols, found...)
+	}
+	if opts.PublicOnly {
+		symbols = services.PublicSymbols(symbols)
+	}
+	return services.RenderSymbolIndex(symbols)
+}
+
+// errorTable renders the errors declared in path and the HTTP error
+// statuses it uses, or "" when it has none.
+func errorTable(jobID, root, path string, opts jobOptions) string {
+	codes, err := services.ExtractErrorCodes(path, opts.languageOf(path))
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to extract errors from %s: %v", relPath(root, path), err)
+		return ""
+	}
+	return services.RenderErrorTable(codes)
+}
+
+// sourceSection renders the original source of path to sit under its
+// documentation, truncated at the configured line limit.
+func sourceSection(root, path, language string) string {
+	snippet, err := services.SourceSnippet(path, language, cfg.SourceSnippetMaxLines)
+	if err != nil {
+		log.Printf("Failed to read source snippet for %s: %v", path, err)
+		return ""
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	return fmt.Sprintf("\n\n### Source: %s\n\n%s", filepath.ToSlash(rel), snippet)
+}
+
+type fileResult struct {
+	Path string
+	Doc  string
+	Err  error
+
+	// History holds the failed attempts, including the last one when Err
+	// is set
+	History []models.AttemptError
+
+	// Confidence is the agent's score for Doc, nil when it gave none
+	Confidence *float64
+
+	// Batched is set on the files of a batch after its first, which are
+	// documented by the first file's Doc rather than their own
+	Batched bool
+}
+
+// analyzeFiles runs the agent over files with a bounded worker pool and
+// hands each file's result to collect in document order, as soon as it
+// and every file before it are done. Calls to collect don't overlap. With
+// a batch size above one, each batch's document is attached to its first
+// file and the rest are marked Batched.
+func analyzeFiles(ctx context.Context, jobID string, files []string, opts jobOptions, progress progressFunc, collect func(fileResult)) {
+	results := make([]fileResult, len(files))
+	finished := make([]bool, len(files))
+
+	// Large files get their note up front and never reach the agent
+	var pending []int
+	for i, file := range files {
+		results[i].Path = file
+		if note, ok := largeFileNote(jobID, file, opts); ok {
+			results[i].Doc = note
+			finished[i] = true
+			jobs.FileDone(jobID, relPath(opts.basePath, file), nil)
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	concurrency := cfg.AnalyzeConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	// Adaptively, there is a worker for the most calls the limiter may
+	// allow and it decides how many run at once
+	var limiter *services.AdaptiveLimiter
+	if cfg.AnalyzeAdaptive {
+		limiter = services.NewAdaptiveLimiter(cfg.AnalyzeConcurrencyMin, cfg.AnalyzeConcurrencyMax, concurrency, cfg.AnalyzeLatencyTarget)
+		concurrency = cfg.AnalyzeConcurrencyMax
+		ctx = withCallObserver(ctx, limiter.Observe)
+	}
+
+	var mu sync.Mutex
+	confidences := map[string]float64{}
+	ctx = services.WithConfidenceReporter(ctx, func(path string, confidence float64) {
+		mu.Lock()
+		confidences[path] = confidence
+		mu.Unlock()
+	})
+
+	// flush collects the finished results no earlier file is still
+	// waiting on; the caller holds mu
+	next := 0
+	flush := func() {
+		for ; next < len(files) && finished[next]; next++ {
+			if confidence, ok := confidences[files[next]]; ok {
+				results[next].Confidence = &confidence
+			}
+			collect(results[next])
+		}
+	}
+
+	done := len(files) - len(pending)
+	work := make(chan []int)
+	var wg sync.WaitGroup
+	startWorker := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for unit := range work {
+				if limiter != nil {
+					if limiter.Acquire(ctx) != nil {
+						continue
+					}
+				}
+				if len(unit) == 1 {
+					i := unit[0]
+					results[i].Doc, results[i].History, results[i].Err = analyzeCached(ctx, jobID, files[i], opts)
+				} else {
+					analyzeBatch(ctx, jobID, files, unit, opts, results)
+				}
+				if limiter != nil {
+					limiter.Release()
+				}
+
+				if ctx.Err() == nil {
+					for _, i := range unit {
+						jobs.FileDone(jobID, relPath(opts.basePath, files[i]), results[i].Err)
+					}
+				}
+
+				mu.Lock()
+				done += len(unit)
+				progress(done, len(files))
+				for _, i := range unit {
+					finished[i] = true
+				}
+				flush()
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// With a ramp-up, workers join one at a time over the interval rather
+	// than all calling the agent at once
+	initial := concurrency
+	if cfg.AnalyzeRampUp > 0 {
+		initial = min(concurrency, cfg.AnalyzeRampStart)
+	}
+	for w := 0; w < initial; w++ {
+		startWorker()
+	}
+	rampDone := make(chan struct{})
+	if initial < concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			step := cfg.AnalyzeRampUp / time.Duration(concurrency-initial)
+			for w := initial; w < concurrency; w++ {
+				select {
+				case <-time.After(step):
+					startWorker()
+				case <-rampDone:
+					return
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, unit := range analysisUnits(files, pending, opts.BatchSize) {
+		select {
+		case work <- unit:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(rampDone)
+	close(work)
+	wg.Wait()
+	if limiter != nil {
+		jobLogf(jobID, models.LogLevelInfo, "Analysis concurrency settled at %d", limiter.Limit())
+	}
+
+	// Files left unanalyzed by a cancellation come last, with empty results
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range finished {
+		finished[i] = true
+	}
+	flush()
+}
+
+// relPath is path relative to root with forward slashes, or path itself
+// when it isn't under root.
+func relPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// analysisUnits groups file indexes into analyzer calls. Single files go
+// out in dispatch order; batches keep document order so each one covers
+// neighbouring files.
+func analysisUnits(files []string, pending []int, batchSize int) [][]int {
+	var units [][]int
+	if batchSize <= 1 {
+		for _, i := range dispatchOrder(files, pending, cfg.AnalyzeSmallestFirst) {
+			units = append(units, []int{i})
+		}
+		return units
+	}
+
+	for start := 0; start < len(pending); start += batchSize {
+		units = append(units, pending[start:min(start+batchSize, len(pending))])
+	}
+	return units
+}
+
+// analyzeBatch documents several files with one analyzer call, filling in
+// their results. Batches bypass the per-file doc cache.
+func analyzeBatch(ctx context.Context, jobID string, files []string, batch []int, opts jobOptions, results []fileResult) {
+	batcher, ok := analyzer.(services.BatchAnalyzer)
+	if !ok {
+		for _, i := range batch {
+			results[i].Doc, results[i].History, results[i].Err = analyzeCached(ctx, jobID, files[i], opts)
+		}
+		return
+	}
+
+	// Files analyzed with different templates can't share a call
+	formatTemplate := fileTemplate(files[batch[0]], opts)
+	for _, i := range batch[1:] {
+		if fileTemplate(files[i], opts) != formatTemplate {
+			for _, i := range batch {
+				results[i].Doc, results[i].History, results[i].Err = analyzeCached(ctx, jobID, files[i], opts)
+			}
+			return
+		}
+	}
+
+	// A batch carries the instructions of every file in it, once each
+	paths := make([]string, len(batch))
+	var extra []string
+	seen := map[string]bool{}
+	for n, i := range batch {
+		paths[n] = files[i]
+		for _, text := range fileAugmentations(files[i], opts) {
+			if !seen[text] {
+				seen[text] = true
+				extra = append(extra, text)
+			}
+		}
+	}
+	formatTemplate = services.AugmentTemplate(formatTemplate, extra)
+	label := fmt.Sprintf("batch of %d files from %s", len(paths), paths[0])
+	doc, history, err := withRetry(ctx, jobID, label, func() (string, error) {
+		return batcher.AnalyzeBatch(ctx, paths, formatTemplate)
+	})
+	for n, i := range batch {
+		results[i].History = history
+		results[i].Err = err
+		results[i].Batched = n > 0
+	}
+	results[batch[0]].Doc = doc
+}
+
+// largeFileNote returns a short note standing in for the documentation of
+// a file over its language's size limit (or the global large file
+// threshold), which is usually a generated or minified bundle with little
+// to document.
+func largeFileNote(jobID, file string, opts jobOptions) (string, bool) {
+	threshold := cfg.LargeFileThreshold
+	if limit, ok := languageSizeLimits[opts.languageOf(file)]; ok {
+		threshold = limit
+	}
+	if opts.AnalyzeLargeFiles || threshold <= 0 {
+		return "", false
+	}
+	info, err := os.Stat(file)
+	if err != nil || info.Size() <= threshold {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(opts.basePath, file)
+	if err != nil {
+		rel = filepath.Base(file)
+	}
+	rel = filepath.ToSlash(rel)
+	jobLogf(jobID, models.LogLevelInfo, "Skipping detailed analysis of large file %s (%d bytes)", rel, info.Size())
+	return fmt.Sprintf("## %s\n\nLarge generated file (%d KB), skipped detailed analysis.\n", rel, info.Size()/1024), true
+}
+
+// analyzeCached reuses the previous job's doc for a file whose content is
+// unchanged, and otherwise analyzes it. Either way the doc is recorded in
+// this job's cache.
+func analyzeCached(ctx context.Context, jobID, file string, opts jobOptions) (string, []models.AttemptError, error) {
+	rel, err := filepath.Rel(opts.basePath, file)
+	if err != nil {
+		return analyzeWithRetry(ctx, jobID, file, opts)
+	}
+	rel = filepath.ToSlash(rel)
+	sum, err := services.HashFile(file)
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to hash %s, not caching: %v", rel, err)
+		return analyzeWithRetry(ctx, jobID, file, opts)
+	}
+
+	if opts.PreviousCache != nil {
+		if doc, ok := opts.PreviousCache.Lookup(rel, sum); ok {
+			jobLogf(jobID, models.LogLevelInfo, "Unchanged since previous job, reusing documentation: %s", rel)
+			opts.cache.Put(rel, sum, doc)
+			return doc, nil, nil
+		}
+	}
+
+	doc, history, err := analyzeWithRetry(ctx, jobID, file, opts)
+	if err == nil {
+		opts.cache.Put(rel, sum, doc)
+	}
+	return doc, history, err
+}
+
+// analyzeWithRetry calls the agent for one file, retrying failures.
+func analyzeWithRetry(ctx context.Context, jobID, file string, opts jobOptions) (string, []models.AttemptError, error) {
+	budget := cfg.FileAnalysisBudget
+	if budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+
+	formatTemplate := services.AugmentTemplate(fileTemplate(file, opts), fileAugmentations(file, opts))
+	doc, history, err := withRetry(ctx, jobID, file, func() (string, error) {
+		return analyzer.Analyze(ctx, file, formatTemplate)
+	})
+	if err != nil && budget > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: abandoned after %s", errAnalysisBudget, budget)
+	}
+	return doc, history, err
+}
+
+// errNoDocumentation fails a job whose files all came back without any
+// documentation.
+var errNoDocumentation = errors.New("analysis produced no documentation")
+
+// errAnalysisBudget marks a file given up on once FILE_ANALYSIS_BUDGET ran
+// out, so the rest of the job can move on.
+var errAnalysisBudget = errors.New("file analysis budget exceeded")
+
+// fileTemplate returns the format template for file: the one configured
+// for its language or its project's type, the job's otherwise.
+func fileTemplate(file string, opts jobOptions) string {
+	template, ok := opts.templates.For(opts.languageOf(file), opts.projectType)
+	if !ok {
+		template = opts.FormatTemplate
+	}
+	return services.WithDocContext(template, opts.docContext)
+}
+
+// fileAugmentations returns the configured extra instructions for file.
+func fileAugmentations(file string, opts jobOptions) []string {
+	rel, err := filepath.Rel(opts.basePath, file)
+	if err != nil {
+		rel = filepath.Base(file)
+	}
+	extra := augmentations.For(filepath.ToSlash(rel), opts.languageOf(file))
+	if cfg.SchemaDocs && (services.IsSchemaFile(file) || services.IsMigrationPath(filepath.ToSlash(rel))) {
+		extra = append(extra, services.SchemaInstruction)
+	}
+	if opts.PublicOnly {
+		extra = append(extra, services.PublicOnlyInstruction(privateSymbols(file, opts)))
+	}
+	return extra
+}
+
+// privateSymbols names the unexported symbols declared in file.
+func privateSymbols(file string, opts jobOptions) []string {
+	symbols, err := services.ExtractSymbols(file, file, opts.languageOf(file))
+	if err != nil {
+		return nil
+	}
+	return services.PrivateSymbolNames(symbols)
+}
+
+// withRetry runs an analyzer call, retrying failures with exponential
+// backoff, and returns the errors of the failed attempts. The global
+// analyze slot is only held while a call is in flight, so waiting retries
+// don't starve other jobs.
+func withRetry(ctx context.Context, jobID, label string, call func() (string, error)) (string, []models.AttemptError, error) {
+	delay := cfg.AnalyzeRetryDelay
+	var history []models.AttemptError
+	for attempt := 1; ; attempt++ {
+		if err := analyzeSlots.Acquire(ctx); err != nil {
+			return "", history, err
+		}
+		// No retrying while the breaker is open; the job fails as a whole
+		if err := agentBreaker.Allow(); err != nil {
+			analyzeSlots.Release()
+			return "", history, err
+		}
+		jobLogf(jobID, models.LogLevelInfo, "Analyzing %s", label)
+		started := time.Now()
+		doc, err := call()
+		observeCall(ctx, time.Since(started), err)
+		agentBreaker.Record(err)
+		analyzeSlots.Release()
+		if err == nil {
+			return doc, history, nil
+		}
+		kind := services.ClassifyAgentError(err)
+		history = append(history, models.AttemptError{
+			Attempt: attempt,
+			Kind:    kind,
+			Error:   err.Error(),
+			At:      time.Now(),
+		})
+		if ctx.Err() != nil || attempt > cfg.AnalyzeRetries {
+			return "", history, err
+		}
+
+		jobLogf(jobID, models.LogLevelWarn, "Analysis of %s failed (attempt %d, %s), retrying in %s: %v", label, attempt, kind, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", history, err
+		}
+		delay *= 2
+	}
+}
+
+type callObserverKey struct{}
+
+// withCallObserver attaches a callback to ctx that withRetry feeds the
+// duration and outcome of every agent call made under it.
+func withCallObserver(ctx context.Context, observe func(latency time.Duration, err error)) context.Context {
+	return context.WithValue(ctx, callObserverKey{}, observe)
+}
+
+func observeCall(ctx context.Context, latency time.Duration, err error) {
+	if observe, ok := ctx.Value(callObserverKey{}).(func(time.Duration, error)); ok {
+		observe(latency, err)
+	}
+}
+
+// attemptKinds summarises a failure history, e.g. "timeout, server_error".
+func attemptKinds(history []models.AttemptError) string {
+	kinds := make([]string, len(history))
+	for i, attempt := range history {
+		kinds[i] = attempt.Kind
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// dispatchOrder returns the given indexes of files in the order they should
+// be analyzed: smallest first when enabled, so early sections appear quickly.
+func dispatchOrder(files []string, indexes []int, smallestFirst bool) []int {
+	order := append([]int(nil), indexes...)
+	if !smallestFirst {
+		return order
+	}
+
+	sizes := make([]int64, len(files))
+	for _, i := range order {
+		if info, err := os.Stat(files[i]); err == nil {
+			sizes[i] = info.Size()
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return sizes[order[a]] < sizes[order[b]]
+	})
+	return order
+}
+
+// projectName derives a filename-safe name for a project root.
+func projectName(extractPath, root string) string {
+	rel, err := filepath.Rel(extractPath, root)
+	if err != nil || rel == "." {
+		return "project"
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "-")
+}
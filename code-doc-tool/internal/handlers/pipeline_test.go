@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code-doc-tool/internal/config"
+)
+
+func TestAnalyzeFilesSmallestFirst(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeConcurrency = 1
+		c.AnalyzeSmallestFirst = true
+	})
+	files, opts := analyzeTest(t, "job", []string{"big.go", "small.go", "mid.go"}, map[string]string{
+		"big.go":   strings.Repeat("x", 300),
+		"small.go": "x",
+		"mid.go":   strings.Repeat("x", 20),
+	})
+
+	var mu sync.Mutex
+	var calls []string
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		calls = append(calls, filepath.Base(path))
+		mu.Unlock()
+		return "doc of " + filepath.Base(path), nil
+	})
+
+	var collected []string
+	analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {}, func(r fileResult) {
+		collected = append(collected, r.Doc)
+	})
+	if want := []string{"small.go", "mid.go", "big.go"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("analyzed %v, want %v", calls, want)
+	}
+	if want := []string{"doc of big.go", "doc of small.go", "doc of mid.go"}; !reflect.DeepEqual(collected, want) {
+		t.Errorf("collected %v, want document order %v", collected, want)
+	}
+}
+
+func TestAnalyzeFilesConcurrency(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeConcurrency = 3
+	})
+	names := []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f.go", "g.go", "h.go"}
+	files, opts := analyzeTest(t, "job", names, map[string]string{})
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return "doc", nil
+	})
+
+	var progress []int
+	collected := 0
+	analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {
+		progress = append(progress, done)
+	}, func(r fileResult) {
+		collected++
+	})
+	if peak != 3 {
+		t.Errorf("peak concurrency %d, want 3", peak)
+	}
+	if collected != len(names) || progress[len(progress)-1] != len(names) {
+		t.Errorf("collected %d files, progress %v", collected, progress)
+	}
+}
+
+func TestExtractFilter(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.SourceExtensions = []string{".go", ".py"}
+	})
+	opts, _, err := newJobOptions(jobRequest{
+		Subpath:           "svc",
+		LanguageOverrides: map[string]string{"tool.inc": "php"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := extractFilter(opts)
+	for name, want := range map[string]bool{
+		"main.go":                true,
+		"pkg/App.PY":             true,
+		"go.mod":                 true,
+		"web/package.json":       true,
+		".gitignore":             true,
+		"bin/run":                true,
+		"logo.png":               false,
+		"dist/bundle.js":         false,
+		".env":                   false,
+		"svc/tool.inc":           true,
+		"wrapper/svc/tool.inc":   true,
+		"tool.inc":               false,
+		"../escape/../../x.png":  false,
+		"/abs/path/component.go": true,
+	} {
+		if got := keep(name); got != want {
+			t.Errorf("keep(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if keep(".git/objects/pack/p.pack") {
+		t.Error(".git is kept without a changelog")
+	}
+	opts.Changelog = 5
+	if !extractFilter(opts)(".git/objects/pack/p.pack") {
+		t.Error(".git isn't kept for a changelog")
+	}
+}
+
+func TestAnalyzeFilesSharedCaps(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeConcurrency = 2
+		c.AnalyzeGlobalConcurrency = 3
+	})
+	names := []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f.go"}
+	filesA, optsA := analyzeTest(t, "job-a", names, map[string]string{})
+	filesB, optsB := analyzeTest(t, "job-b", names, map[string]string{})
+
+	var mu sync.Mutex
+	inFlight := map[string]int{}
+	peak := map[string]int{}
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		job := "job-b"
+		if strings.HasPrefix(path, optsA.basePath) {
+			job = "job-a"
+		}
+		mu.Lock()
+		inFlight[job]++
+		inFlight["all"]++
+		peak[job] = max(peak[job], inFlight[job])
+		peak["all"] = max(peak["all"], inFlight["all"])
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight[job]--
+		inFlight["all"]--
+		mu.Unlock()
+		return "doc", nil
+	})
+
+	var wg sync.WaitGroup
+	for _, job := range []struct {
+		id    string
+		files []string
+		opts  jobOptions
+	}{{"job-a", filesA, optsA}, {"job-b", filesB, optsB}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			analyzeFiles(context.Background(), job.id, job.files, job.opts, func(done, total int) {}, func(r fileResult) {})
+		}()
+	}
+	wg.Wait()
+
+	if peak["job-a"] > 2 || peak["job-b"] > 2 {
+		t.Errorf("per-job peaks %d and %d, want at most 2", peak["job-a"], peak["job-b"])
+	}
+	if peak["all"] != 3 {
+		t.Errorf("global peak %d, want 3", peak["all"])
+	}
+}
+
+func TestAnalyzeFilesLargeFile(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.LargeFileThreshold = 100
+	})
+	contents := map[string]string{
+		"bundle.min.js": strings.Repeat("x", 2048),
+		"main.go":       "package main\n",
+	}
+
+	for _, force := range []bool{false, true} {
+		files, opts := analyzeTest(t, "job", []string{"bundle.min.js", "main.go"}, contents)
+		opts.AnalyzeLargeFiles = force
+
+		var mu sync.Mutex
+		var calls []string
+		analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+			mu.Lock()
+			calls = append(calls, filepath.Base(path))
+			mu.Unlock()
+			return "analyzed " + filepath.Base(path), nil
+		})
+		docs := map[string]string{}
+		analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {}, func(r fileResult) {
+			docs[filepath.Base(r.Path)] = r.Doc
+		})
+
+		if docs["main.go"] != "analyzed main.go" {
+			t.Errorf("force=%v: main.go doc %q", force, docs["main.go"])
+		}
+		bundle := docs["bundle.min.js"]
+		if force {
+			if bundle != "analyzed bundle.min.js" || len(calls) != 2 {
+				t.Errorf("forced: bundle doc %q, calls %v", bundle, calls)
+			}
+		} else if !strings.Contains(bundle, "Large generated file (2 KB), skipped detailed analysis") || len(calls) != 1 {
+			t.Errorf("bundle doc %q, calls %v", bundle, calls)
+		}
+	}
+}
+
+// batchAnalyzer documents a batch of files in one call.
+type batchAnalyzer struct {
+	funcAnalyzer
+	batch func(paths []string) (string, error)
+}
+
+func (b batchAnalyzer) AnalyzeBatch(ctx context.Context, paths []string, formatTemplate string) (string, error) {
+	return b.batch(paths)
+}
+
+func TestAnalyzeFilesBatches(t *testing.T) {
+	setupTest(t, nil)
+	names := []string{"a.go", "b.go", "c.go", "d.go", "e.go"}
+	files, opts := analyzeTest(t, "job", names, map[string]string{})
+	opts.BatchSize = 2
+
+	var mu sync.Mutex
+	var batches []string
+	batch := func(paths []string) (string, error) {
+		var bases []string
+		for _, path := range paths {
+			bases = append(bases, filepath.Base(path))
+		}
+		mu.Lock()
+		batches = append(batches, strings.Join(bases, "+"))
+		mu.Unlock()
+		return "doc of " + strings.Join(bases, "+"), nil
+	}
+	analyzer = batchAnalyzer{
+		funcAnalyzer: func(ctx context.Context, path, formatTemplate string) (string, error) {
+			return batch([]string{path})
+		},
+		batch: batch,
+	}
+
+	var docs []string
+	var batched []string
+	analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {}, func(r fileResult) {
+		if r.Batched {
+			batched = append(batched, filepath.Base(r.Path))
+		} else {
+			docs = append(docs, r.Doc)
+		}
+	})
+	sorted := append([]string(nil), batches...)
+	sort.Strings(sorted)
+	if want := []string{"a.go+b.go", "c.go+d.go", "e.go"}; !reflect.DeepEqual(sorted, want) {
+		t.Errorf("batches %v, want %v", batches, want)
+	}
+	if want := []string{"doc of a.go+b.go", "doc of c.go+d.go", "doc of e.go"}; !reflect.DeepEqual(docs, want) {
+		t.Errorf("docs %v, want %v", docs, want)
+	}
+	if want := []string{"b.go", "d.go"}; !reflect.DeepEqual(batched, want) {
+		t.Errorf("batched %v, want %v", batched, want)
+	}
+}
+
+func TestAnalyzeFilesLanguageSizeLimits(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.LargeFileThreshold = 10000
+		c.LanguageSizeLimits = map[string]int64{"javascript": 1000, "Go": 100000}
+	})
+	same := strings.Repeat("x", 5000)
+	files, opts := analyzeTest(t, "job", []string{"bundle.js", "server.go"}, map[string]string{
+		"bundle.js": same,
+		"server.go": same,
+	})
+
+	var mu sync.Mutex
+	var calls []string
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		calls = append(calls, filepath.Base(path))
+		mu.Unlock()
+		return "analyzed", nil
+	})
+	docs := map[string]string{}
+	analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {}, func(r fileResult) {
+		docs[filepath.Base(r.Path)] = r.Doc
+	})
+
+	if !reflect.DeepEqual(calls, []string{"server.go"}) {
+		t.Errorf("analyzed %v, want server.go only", calls)
+	}
+	if !strings.Contains(docs["bundle.js"], "skipped detailed analysis") {
+		t.Errorf("bundle.js doc %q, want the large file note", docs["bundle.js"])
+	}
+}
+
+// settledConcurrency returns the limit a job's adaptive analysis ended
+// at, from its log.
+func settledConcurrency(t *testing.T, jobID string) int {
+	t.Helper()
+	entries, _ := jobs.Logs(jobID)
+	for _, entry := range entries {
+		var limit int
+		if _, err := fmt.Sscanf(entry.Message, "Analysis concurrency settled at %d", &limit); err == nil {
+			return limit
+		}
+	}
+	t.Fatal("no settled concurrency in the job log")
+	return 0
+}
+
+func TestAnalyzeFilesAdaptiveBackoff(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeAdaptive = true
+		c.AnalyzeConcurrency = 6
+		c.AnalyzeConcurrencyMin = 1
+		c.AnalyzeConcurrencyMax = 6
+		c.AnalyzeLatencyTarget = 15 * time.Millisecond
+	})
+	var names []string
+	for i := range 40 {
+		names = append(names, fmt.Sprintf("f%02d.go", i))
+	}
+	files, opts := analyzeTest(t, "job", names, map[string]string{})
+
+	// The agent slows down with every call it serves at once
+	var mu sync.Mutex
+	inFlight, peak, late := 0, 0, 0
+	calls := 0
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		inFlight++
+		calls++
+		peak = max(peak, inFlight)
+		if calls > 30 {
+			late = max(late, inFlight)
+		}
+		delay := time.Duration(inFlight) * 10 * time.Millisecond
+		mu.Unlock()
+		time.Sleep(delay)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return "doc", nil
+	})
+
+	analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {}, func(r fileResult) {})
+	if peak != 6 {
+		t.Errorf("peak concurrency %d, want the initial 6", peak)
+	}
+	if late > 3 {
+		t.Errorf("concurrency %d late in the job, want it backed off", late)
+	}
+	if limit := settledConcurrency(t, "job"); limit > 3 {
+		t.Errorf("settled at %d, want it backed off", limit)
+	}
+}
+
+func TestAnalyzeFilesRampUp(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeConcurrency = 4
+		c.AnalyzeRampUp = 300 * time.Millisecond
+		c.AnalyzeRampStart = 1
+	})
+	var names []string
+	for i := range 60 {
+		names = append(names, fmt.Sprintf("f%02d.go", i))
+	}
+	files, opts := analyzeTest(t, "job", names, map[string]string{})
+
+	// Records the peak concurrency before each worker could have joined
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	var early, middle int
+	start := time.Now()
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		switch elapsed := time.Since(start); {
+		case elapsed < 90*time.Millisecond:
+			early = max(early, inFlight)
+		case elapsed < 190*time.Millisecond:
+			middle = max(middle, inFlight)
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return "doc", nil
+	})
+
+	analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {}, func(r fileResult) {})
+	if early != 1 || middle > 2 {
+		t.Errorf("concurrency %d then %d in the first two steps, want 1 then at most 2", early, middle)
+	}
+	if peak != 4 {
+		t.Errorf("peak concurrency %d, want all 4 workers once ramped up", peak)
+	}
+}
+
+func TestAnalyzeFilesRampUpStopsEarly(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeConcurrency = 4
+		c.AnalyzeRampUp = time.Minute
+		c.AnalyzeRampStart = 2
+	})
+	files, opts := analyzeTest(t, "job", []string{"a.go", "b.go", "c.go"}, map[string]string{})
+
+	var calls atomic.Int32
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		calls.Add(1)
+		return "doc", nil
+	})
+	start := time.Now()
+	analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {}, func(r fileResult) {})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("analysis took %s, want the ramp to stop once every file was handed out", elapsed)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d calls, want 3", calls.Load())
+	}
+}
+
+func TestAnalyzeFilesAdaptiveIgnoresRetryDelay(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeAdaptive = true
+		c.AnalyzeConcurrency = 2
+		c.AnalyzeConcurrencyMin = 1
+		c.AnalyzeConcurrencyMax = 4
+		c.AnalyzeLatencyTarget = 20 * time.Millisecond
+		c.AnalyzeRetries = 1
+		c.AnalyzeRetryDelay = 50 * time.Millisecond
+	})
+	names := []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f.go", "g.go", "h.go"}
+	files, opts := analyzeTest(t, "job", names, map[string]string{})
+
+	// Every call is fast, but each file fails once and waits out the
+	// retry delay, which says nothing about the agent's load
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !failed[path] {
+			failed[path] = true
+			return "", errors.New("malformed request")
+		}
+		return "doc", nil
+	})
+
+	analyzeFiles(context.Background(), "job", files, opts, func(done, total int) {}, func(r fileResult) {})
+	if limit := settledConcurrency(t, "job"); limit < 2 {
+		t.Errorf("settled at %d, want the retry delay not to count as latency", limit)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/utils"
+)
+
+// resumeInfo is what a job needs to be started again after a restart. It
+// sits in the job's working directory and goes away with it.
+type resumeInfo struct {
+	Request  jobRequest `json:"request"`
+	FilePath string     `json:"file_path"`
+	Filename string     `json:"filename"`
+
+	// Passwords aren't written to disk, so such jobs can't be resumed
+	HasPassword bool `json:"has_password"`
+}
+
+func resumeInfoPath(workDir string) string {
+	return filepath.Join(workDir, "resume.json")
+}
+
+// saveResumeInfo records how to restart a job whose upload is on disk.
+func saveResumeInfo(jobID, workDir string, req jobRequest, filePath, filename string) {
+	info := resumeInfo{Request: req, FilePath: filePath, Filename: filename, HasPassword: req.Password != ""}
+	info.Request.Password = ""
+	data, err := json.Marshal(info)
+	if err == nil {
+		err = os.WriteFile(resumeInfoPath(workDir), data, 0600)
+	}
+	if err != nil {
+		jobLogf(jobID, models.LogLevelWarn, "Failed to save resume information: %v", err)
+	}
+}
+
+// recoverJobs loads the persisted jobs and deals with the ones a restart
+// interrupted: resumed when their upload is still on disk, failed
+// otherwise so clients polling them get an answer.
+func recoverJobs() {
+	interrupted, err := jobs.Restore()
+	if err != nil {
+		log.Printf("Failed to restore jobs: %v", err)
+		return
+	}
+
+	for _, jobID := range interrupted {
+		if err := resumeJob(jobID); err != nil {
+			log.Printf("Cannot resume job %s: %v", jobID, err)
+			jobs.Fail(jobID, "Interrupted by restart")
+			utils.CleanupDir(jobWorkDir(jobID))
+			continue
+		}
+		log.Printf("Resumed job %s after restart", jobID)
+	}
+}
+
+func resumeJob(jobID string) error {
+	workDir := jobWorkDir(jobID)
+	data, err := os.ReadFile(resumeInfoPath(workDir))
+	if err != nil {
+		return fmt.Errorf("no resume information: %w", err)
+	}
+	var info resumeInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return fmt.Errorf("invalid resume information: %w", err)
+	}
+	if info.HasPassword {
+		return fmt.Errorf("upload was password protected")
+	}
+	if _, err := os.Stat(info.FilePath); err != nil {
+		return fmt.Errorf("upload is gone: %w", err)
+	}
+	opts, _, err := newJobOptions(info.Request)
+	if err != nil {
+		return err
+	}
+	opts.workDir = workDir
+
+	// Start extraction over rather than trusting a half-written tree
+	if err := os.RemoveAll(filepath.Join(workDir, "extracted")); err != nil {
+		return err
+	}
+
+	job, _ := jobs.Get(jobID)
+	opts.TraceID = job.TraceID
+	ctx, cancel := context.WithCancel(jobContext(jobID, opts))
+	jobs.Resume(jobID, cancel)
+	jobLogf(jobID, models.LogLevelInfo, "Resuming %s after restart", filepath.Base(info.FilePath))
+	go processCodebase(ctx, jobID, info.FilePath, info.Filename, opts)
+	return nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+)
+
+// interruptedJob leaves behind what a restart in the middle of a job
+// does: a persisted processing record and its working directory with the
+// upload, resume information unless noResume, and half-staged sources.
+func interruptedJob(t *testing.T, store, jobID, filename, content string, noResume bool) {
+	t.Helper()
+	previous := services.NewJobStore()
+	if err := previous.PersistTo(store); err != nil {
+		t.Fatal(err)
+	}
+	workDir, err := newWorkDir(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.Create(jobID, func() {})
+	previous.Modify(jobID, func(job *models.Job) { job.WorkDir = workDir })
+
+	filePath := filepath.Join(workDir, filename)
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !noResume {
+		saveResumeInfo(jobID, workDir, jobRequest{Format: "md"}, filePath, filename)
+	}
+	if err := stageSourceFile(filePath, filepath.Join(workDir, "extracted")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverJobs(t *testing.T) {
+	setupTest(t, nil)
+	store := "./output/.jobs"
+	const resumable = "11111111-1111-4111-8111-111111111111"
+	const lost = "22222222-2222-4222-8222-222222222222"
+	interruptedJob(t, store, resumable, "greet.go", "package main\n\nfunc Greet() {}\n", false)
+	interruptedJob(t, store, lost, "old.go", "package main\n", true)
+
+	// The restarted server reads the records back
+	jobs = services.NewJobStore()
+	if err := jobs.PersistTo(store); err != nil {
+		t.Fatal(err)
+	}
+	recoverJobs()
+
+	if job := waitJob(t, resumable); job.Status != models.JobStatusCompleted {
+		t.Errorf("resumed job %s: %s", job.Status, job.Message)
+	}
+	job := waitJob(t, lost)
+	if job.Status != models.JobStatusFailed || job.Message != "Interrupted by restart" {
+		t.Errorf("job without resume information %s: %s", job.Status, job.Message)
+	}
+	if _, err := os.Stat(job.WorkDir); !os.IsNotExist(err) {
+		t.Errorf("working directory of the failed job left behind: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import "github.com/gofiber/fiber/v2"
+
+// ServerConfig is the Fiber configuration the routes expect. Bodies past
+// JSONBodyLimit are streamed rather than read up front, so LimitBody can
+// hold each route to its own limit before the body is in memory.
+func ServerConfig() fiber.Config {
+	return fiber.Config{
+		BodyLimit:                    int(cfg.JSONBodyLimit),
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		ErrorHandler:                 ErrorHandler,
+	}
+}
+
+// Routes registers the API routes on app.
+func Routes(app *fiber.App) {
+	api := app.Group("/api", LimitBody)
+
+	api.Post("/upload", UploadCodebase)
+	api.Post("/upload-url", UploadFromURL)
+	api.Get("/download/*", DownloadDocumentation)
+	api.Get("/status/:jobId", RequireJobID, GetStatus)
+	api.Get("/jobs/:jobId/log", RequireJobID, GetJobLog)
+	api.Get("/jobs/:jobId/events", RequireJobID, StreamJobEvents)
+	api.Get("/jobs/:jobId/document", RequireJobID, GetJobDocument)
+	api.Get("/jobs/:jobId/document/stream", RequireJobID, StreamJobDocument)
+	api.Get("/jobs/:jobId/markdown", RequireJobID, GetJobMarkdown)
+	api.Get("/jobs/:jobId/artifacts", RequireJobID, GetJobArtifacts)
+	api.Get("/agent-check", AgentCheck)
+	api.Get("/diff", DiffDocuments)
+	api.Get("/metrics", RequireAdmin, GetMetrics)
+	api.Get("/stats", GetStats)
+	api.Post("/admin/pause", RequireAdmin, PauseProcessing)
+	api.Post("/admin/resume", RequireAdmin, ResumeProcessing)
+}
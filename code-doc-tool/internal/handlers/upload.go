@@ -1,160 +1,780 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
+	"code-doc-tool/internal/models"
 	"code-doc-tool/internal/services"
 	"code-doc-tool/internal/utils"
 )
 
+// jobOptions carries the per-request settings into processCodebase.
+type jobOptions struct {
+	FormatTemplate string
+	Generator      services.Generator
+
+	// ExtraGenerators render the same document in further formats when a
+	// job asks for several
+	ExtraGenerators []services.Generator
+	Roots           []string
+	Subpath         string
+	IncludeSource   bool
+	Password        string
+	Order           string
+	Group           string
+	Index           bool
+	BatchSize       int
+	HeadingOffset   int
+
+	// Overview selects how the project-level overview is produced, if at
+	// all; see services.OverviewOff and friends
+	Overview string
+
+	// FileTable appends a table of the analyzed files' path, language,
+	// size and line count
+	FileTable bool
+
+	// ConfigFiles adds a Configuration section explaining the project's
+	// configuration files, e.g. docker-compose.yml and .env.example
+	ConfigFiles bool
+
+	// DocInputs sends the project's READMEs and other documentation
+	// matching DOC_INPUT_GLOBS to the agent as context
+	DocInputs bool
+
+	// PublicOnly documents exported symbols only: the agent is told which
+	// symbols to leave out, sections about them are dropped from what it
+	// returns and the index lists exported symbols only
+	PublicOnly bool
+
+	// ErrorTable adds a table of the errors and HTTP error statuses found
+	// in each file's source to its Error Handling section
+	ErrorTable bool
+
+	// OpenAPI is the encoding of the OpenAPI spec written from the
+	// project's endpoints, "json" or "yaml"; "" for none
+	OpenAPI string
+
+	// Changelog lists this many recent commits when the upload carries
+	// its .git directory; 0 leaves the section out
+	Changelog int
+
+	// ModifiedSince limits analysis to files modified at or after it;
+	// extracted files keep the modification times stored in the archive
+	ModifiedSince time.Time
+
+	// AnalyzeLargeFiles sends files over LargeFileThreshold to the agent
+	// instead of documenting them with a note
+	AnalyzeLargeFiles bool
+
+	// SkipGenerated leaves out files carrying a generated-code marker
+	SkipGenerated bool
+
+	// LanguageOverrides maps paths relative to the analysis base to a
+	// canonical language name
+	LanguageOverrides map[string]string
+
+	// Filled in by processCodebase once the extraction root is known
+	resolvedOverrides map[string]string
+	languageOf        services.LanguageFunc
+
+	// TraceID correlates the upload request, the job and its agent calls
+	TraceID string
+
+	// PreviousCache holds a prior job's per-file docs; unchanged files are
+	// reused from it instead of being analyzed again
+	PreviousCache *services.DocCache
+
+	// Filled in by processCodebase: the analysis base and the cache this
+	// job records for later runs
+	basePath string
+	cache    *services.DocCache
+
+	// templates replace FormatTemplate for some languages or project types
+	// unless the job picked its own sections; projectType is the type of
+	// the project being documented
+	templates   *services.FormatTemplates
+	projectType string
+
+	// docContext is the text of the project's documentation inputs
+	docContext string
+
+	// stream receives a single-project text document while it is being
+	// assembled; nil when it isn't streamed
+	stream *services.DocStream
+
+	// workDir is the job's own directory for its upload and extraction
+	workDir string
+
+	// releaseIntake frees the job's intake slot once its upload is on disk
+	// and extracted, and releaseDisk the disk space reserved for it; both
+	// are safe to call more than once
+	releaseIntake func()
+	releaseDisk   func()
+}
+
+// jobRequest holds the raw per-job settings shared by every upload route.
+type jobRequest struct {
+	Sections      []string `json:"sections"`
+	Format        string   `json:"format"`
+	Roots         []string `json:"roots"`
+	Subpath       string   `json:"subpath"`
+	IncludeSource bool     `json:"include_source"`
+	Password      string   `json:"password"`
+	Order         string   `json:"order"`
+	Group         string   `json:"group"`
+	Index         bool     `json:"index"`
+	BatchSize     int      `json:"batch_size"`
+	HeadingOffset *int     `json:"heading_offset"`
+	Overview      string   `json:"overview"`
+	Detail        string   `json:"detail"`
+	Changelog     int      `json:"changelog"`
+	FileTable     bool     `json:"file_table"`
+	ConfigFiles   bool     `json:"config_files"`
+	DocInputs     bool     `json:"doc_inputs"`
+	PublicOnly    bool     `json:"public_only"`
+	OpenAPI       string   `json:"openapi"`
+
+	// ModifiedWithinDays keeps only files modified in the last N days
+	ModifiedWithinDays int `json:"modified_within_days"`
+
+	AnalyzeLargeFiles bool `json:"analyze_large_files"`
+
+	// IncludeGenerated analyzes generated files despite SKIP_GENERATED
+	IncludeGenerated bool `json:"include_generated"`
+
+	LanguageOverrides map[string]string `json:"language_overrides"`
+
+	// PreviousJobID enables incremental runs against that job's output
+	PreviousJobID string `json:"previous_job_id"`
+}
+
+// templateKey identifies the format templates the job analyzes files
+// with, and the instructions added to them, for its doc cache.
+func (o jobOptions) templateKey() string {
+	key := o.FormatTemplate + o.templates.Key() + "\x00" + augmentations.Key()
+	if o.DocInputs {
+		key += "\x00doc inputs"
+	}
+	return key
+}
+
+// formJobRequest reads the job settings from multipart form fields.
+// language_overrides is a JSON object of {"path": "language"}.
+func formJobRequest(c *fiber.Ctx) (jobRequest, error) {
+	var overrides map[string]string
+	if raw := c.FormValue("language_overrides"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+			return jobRequest{}, fmt.Errorf("language_overrides must be a JSON object of path to language")
+		}
+	}
+
+	var batchSize int
+	if raw := c.FormValue("batch_size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return jobRequest{}, fmt.Errorf("batch_size must be a number")
+		}
+		batchSize = n
+	}
+
+	var headingOffset *int
+	if raw := c.FormValue("heading_offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return jobRequest{}, fmt.Errorf("heading_offset must be a number")
+		}
+		headingOffset = &n
+	}
+
+	var changelog int
+	if raw := c.FormValue("changelog"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return jobRequest{}, fmt.Errorf("changelog must be a number")
+		}
+		changelog = n
+	}
+
+	var modifiedWithin int
+	if raw := c.FormValue("modified_within_days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return jobRequest{}, fmt.Errorf("modified_within_days must be a number")
+		}
+		modifiedWithin = n
+	}
+
+	return jobRequest{
+		// Optional comma separated list of sections, e.g. "overview,apis,8"
+		Sections:      splitList(c.FormValue("sections")),
+		Format:        c.FormValue("format"),
+		Roots:         splitList(c.FormValue("roots")),
+		Subpath:       strings.TrimSpace(c.FormValue("subpath")),
+		IncludeSource: c.FormValue("include_source") == "true",
+		Password:      c.FormValue("password"),
+		Order:         c.FormValue("order"),
+		Group:         c.FormValue("group"),
+		Index:         c.FormValue("index") == "true",
+		BatchSize:     batchSize,
+		HeadingOffset: headingOffset,
+		Overview:      c.FormValue("overview"),
+		Detail:        c.FormValue("detail"),
+		Changelog:     changelog,
+		FileTable:     c.FormValue("file_table") == "true",
+		ConfigFiles:   c.FormValue("config_files") == "true",
+		DocInputs:     c.FormValue("doc_inputs") == "true",
+		PublicOnly:    c.FormValue("public_only") == "true",
+		OpenAPI:       c.FormValue("openapi"),
+
+		LanguageOverrides: overrides,
+		PreviousJobID:     strings.TrimSpace(c.FormValue("previous_job_id")),
+		AnalyzeLargeFiles: c.FormValue("analyze_large_files") == "true",
+		IncludeGenerated:  c.FormValue("include_generated") == "true",
+
+		ModifiedWithinDays: modifiedWithin,
+	}, nil
+}
+
+// newJobOptions validates req. On failure it returns the error code to
+// report alongside the error.
+func newJobOptions(req jobRequest) (jobOptions, string, error) {
+	sections, err := services.SelectSections(req.Sections)
+	if err != nil {
+		return jobOptions{}, ErrCodeInvalidSections, err
+	}
+
+	// A comma separated list asks for every format from one analysis; the
+	// first is the job's primary output
+	var generators []services.Generator
+	for _, format := range strings.Split(req.Format, ",") {
+		if format = strings.TrimSpace(format); format == "" {
+			if len(generators) > 0 {
+				continue
+			}
+			format = services.DefaultFormat
+		}
+		generator, err := services.NewGenerator(format)
+		if err != nil {
+			return jobOptions{}, ErrCodeInvalidFormat, err
+		}
+		duplicate := false
+		for _, g := range generators {
+			duplicate = duplicate || g.Extension() == generator.Extension()
+		}
+		if !duplicate {
+			generators = append(generators, generator)
+		}
+	}
+	generator := generators[0]
+
+	order := req.Order
+	if order == "" {
+		order = cfg.DocumentOrder
+	}
+	if order, err = services.ValidateOrder(order); err != nil {
+		return jobOptions{}, ErrCodeInvalidOrder, err
+	}
+
+	group, err := services.ValidateGroup(req.Group)
+	if err != nil {
+		return jobOptions{}, ErrCodeBadRequest, err
+	}
+
+	overview := req.Overview
+	if overview == "" {
+		overview = cfg.ProjectOverview
+	}
+	if overview, err = services.ValidateOverviewMode(overview); err != nil {
+		return jobOptions{}, ErrCodeBadRequest, err
+	}
+
+	detail := req.Detail
+	if detail == "" {
+		detail = cfg.DocDetail
+	}
+	if detail, err = services.ValidateDetail(detail); err != nil {
+		return jobOptions{}, ErrCodeBadRequest, err
+	}
+
+	if req.Changelog < 0 || req.Changelog > services.MaxChangelogCommits {
+		return jobOptions{}, ErrCodeBadRequest, fmt.Errorf("changelog must be between 0 and %d", services.MaxChangelogCommits)
+	}
+
+	batchSize := req.BatchSize
+	if batchSize == 0 {
+		batchSize = cfg.AnalyzeBatchSize
+	}
+	if batchSize < 1 {
+		return jobOptions{}, ErrCodeBadRequest, fmt.Errorf("batch_size must be at least 1")
+	}
+
+	// Unlike batch_size, an explicit 0 is meaningful here
+	headingOffset := cfg.HeadingOffset
+	if req.HeadingOffset != nil {
+		headingOffset = *req.HeadingOffset
+	}
+	if headingOffset < 0 || headingOffset > 5 {
+		return jobOptions{}, ErrCodeBadRequest, fmt.Errorf("heading_offset must be between 0 and 5")
+	}
+
+	openAPI, err := services.ValidateOpenAPIFormat(req.OpenAPI)
+	if err != nil {
+		return jobOptions{}, ErrCodeBadRequest, err
+	}
+
+	if req.ModifiedWithinDays < 0 {
+		return jobOptions{}, ErrCodeBadRequest, fmt.Errorf("modified_within_days must not be negative")
+	}
+	var modifiedSince time.Time
+	if req.ModifiedWithinDays > 0 {
+		modifiedSince = time.Now().AddDate(0, 0, -req.ModifiedWithinDays)
+	}
+
+	for _, rel := range append([]string{req.Subpath}, req.Roots...) {
+		if err := services.ValidateSubpath(rel); err != nil {
+			return jobOptions{}, ErrCodeInvalidPath, err
+		}
+	}
+
+	overrides := map[string]string{}
+	for rel, lang := range req.LanguageOverrides {
+		if err := services.ValidateSubpath(rel); err != nil {
+			return jobOptions{}, ErrCodeInvalidPath, err
+		}
+		canonical, ok := services.NormalizeLanguage(lang)
+		if !ok {
+			return jobOptions{}, ErrCodeInvalidLanguage, fmt.Errorf("unknown language %q for %s", lang, rel)
+		}
+		overrides[filepath.ToSlash(filepath.Clean(rel))] = canonical
+	}
+
+	var previous *services.DocCache
+	if req.PreviousJobID != "" {
+		if !isJobID(req.PreviousJobID) {
+			return jobOptions{}, ErrCodeBadRequest, fmt.Errorf("previous_job_id must be a job ID")
+		}
+		if previous, err = services.LoadDocCache(services.DocCachePath(req.PreviousJobID)); err != nil {
+			return jobOptions{}, ErrCodeJobNotFound, fmt.Errorf("no cached documentation for job %s", req.PreviousJobID)
+		}
+	}
+
+	// Sections picked for the job apply to every file
+	var templates *services.FormatTemplates
+	if len(req.Sections) == 0 {
+		templates = formatTemplates.WithDetail(detail)
+	}
+
+	return jobOptions{
+		FormatTemplate:  services.WithDetail(services.BuildFormatTemplate(sections), detail),
+		templates:       templates,
+		Generator:       generator,
+		ExtraGenerators: generators[1:],
+		Roots:           req.Roots,
+		Subpath:         req.Subpath,
+		IncludeSource:   req.IncludeSource,
+		Password:        req.Password,
+		Order:           order,
+		Group:           group,
+		Index:           req.Index,
+		BatchSize:       batchSize,
+		HeadingOffset:   headingOffset,
+		Overview:        overview,
+		Changelog:       req.Changelog,
+		FileTable:       req.FileTable,
+		ConfigFiles:     req.ConfigFiles,
+		DocInputs:       req.DocInputs,
+		PublicOnly:      req.PublicOnly,
+		OpenAPI:         openAPI,
+		ModifiedSince:   modifiedSince,
+
+		LanguageOverrides: overrides,
+		PreviousCache:     previous,
+		AnalyzeLargeFiles: req.AnalyzeLargeFiles,
+		SkipGenerated:     cfg.SkipGenerated && !req.IncludeGenerated,
+		ErrorTable:        cfg.ErrorTable && slices.ContainsFunc(sections, func(s services.DocSection) bool { return s.Key == "errors" }),
+	}, "", nil
+}
+
+// startJob registers a job and runs it in the background, once
+// processing isn't paused, and returns the job's initial status. The
+// context is cancelled when the job finishes or the watchdog gives up on
+// it.
+func startJob(jobID string, opts jobOptions, run func(ctx context.Context)) string {
+	ctx := registerJob(jobID, opts)
+	// Queued before the response goes out, so its status never reads
+	// processing while it waits
+	queued := dispatch.IsClosed()
+	if queued {
+		jobs.Queue(jobID, "Queued while processing is paused")
+		jobLogf(jobID, models.LogLevelInfo, "Processing is paused; job queued")
+		// The upload is on disk and counted by the quota, so the intake
+		// slot isn't held while paused; otherwise a pause would soon
+		// turn new uploads away instead of queueing them
+		opts.doneIntake()
+	}
+	go func() {
+		// Jobs that fail before processing starts must not leave
+		// streaming clients waiting
+		defer closeDocStream(jobID)
+		if queued {
+			if err := dispatch.Wait(ctx); err != nil {
+				utils.CleanupDir(opts.workDir)
+				return
+			}
+			jobs.Dispatch(jobID, "Processing started")
+			jobLogf(jobID, models.LogLevelInfo, "Processing resumed; job dispatched")
+		}
+		run(ctx)
+	}()
+	if queued {
+		return models.JobStatusQueued
+	}
+	return models.JobStatusProcessing
+}
+
+// registerJob adds a job to the store and returns the context its
+// processing runs under.
+func registerJob(jobID string, opts jobOptions) context.Context {
+	ctx, cancel := context.WithCancel(jobContext(jobID, opts))
+	jobs.Create(jobID, cancel)
+	jobs.Modify(jobID, func(job *models.Job) {
+		job.Format = opts.Generator.Extension()
+		job.TraceID = opts.TraceID
+		job.WorkDir = opts.workDir
+	})
+	openDocStream(jobID, opts.Generator)
+	return ctx
+}
+
+// jobContext carries a job's trace ID and redaction reporting.
+func jobContext(jobID string, opts jobOptions) context.Context {
+	ctx := services.WithTraceID(context.Background(), opts.TraceID)
+	return services.WithRedactionReporter(ctx, func(path string, count int) {
+		jobLogf(jobID, models.LogLevelInfo, "Redacted %d secrets from %s", count, filepath.Base(path))
+		jobs.Modify(jobID, func(job *models.Job) {
+			job.Redactions += count
+		})
+	})
+}
+
+// acquireIntake reserves an intake slot for a new upload, reporting false
+// when the node is saturated.
+func acquireIntake(opts *jobOptions) bool {
+	if !intakeSlots.TryAcquire() {
+		return false
+	}
+	opts.releaseIntake = sync.OnceFunc(intakeSlots.Release)
+	return true
+}
+
+// reserveDisk reserves size bytes of the disk quota for an upload.
+func reserveDisk(opts *jobOptions, size int64) bool {
+	release, ok := reserveQuota(size)
+	if !ok {
+		return false
+	}
+	opts.releaseDisk = release
+	return true
+}
+
+// reserveExtraction reserves the disk quota for the files extracting
+// filePath with extractOpts writes. Once they are on disk the quota
+// counts them itself, so the reservation is released after extraction.
+func reserveExtraction(filePath string, extractOpts utils.ExtractOptions) (func(), error) {
+	size, err := utils.ExtractedSize(filePath, extractOpts)
+	if err != nil {
+		// Left for extraction to report
+		return func() {}, nil
+	}
+	release, ok := reserveQuota(size)
+	if !ok {
+		return nil, fmt.Errorf("%w: extracting the archive needs %d bytes beyond the disk quota", utils.ErrInsufficientDiskSpace, size)
+	}
+	return release, nil
+}
+
+// reserveQuota reserves size bytes of the disk quota, sweeping expired
+// outputs once before giving up.
+func reserveQuota(size int64) (func(), bool) {
+	release, ok := diskQuota.Reserve(size)
+	if !ok && cfg.OutputTTL > 0 && services.SweepOutputs("./output", cfg.OutputTTL) > 0 {
+		diskQuota.Invalidate()
+		release, ok = diskQuota.Reserve(size)
+	}
+	return release, ok
+}
+
+// quotaResponse turns an upload away with 507 while the disk quota is used
+// up.
+func quotaResponse(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(cfg.UploadRetryAfter.Seconds())))
+	return errorResponse(c, fiber.StatusInsufficientStorage, ErrCodeQuotaExceeded, "Disk quota exceeded, retry later")
+}
+
+// busyResponse turns an upload away with 503 and a Retry-After hint.
+func busyResponse(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(cfg.UploadRetryAfter.Seconds())))
+	return errorResponse(c, fiber.StatusServiceUnavailable, ErrCodeServerBusy, "Too many uploads in progress, retry later")
+}
+
+// doneIntake releases the intake slot and disk reservation, if taken. By
+// then the upload is on disk and counted by the quota itself.
+func (o jobOptions) doneIntake() {
+	if o.releaseIntake != nil {
+		o.releaseIntake()
+	}
+	if o.releaseDisk != nil {
+		o.releaseDisk()
+	}
+}
+
+// traceID returns the request's correlation ID, set by the requestid
+// middleware from X-Request-ID or freshly generated.
+func traceID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("requestid").(string); ok && validTraceID(id) {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// validTraceID rejects IDs we wouldn't want to forward as a header.
+func validTraceID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
 type UploadResponse struct {
 	JobID   string `json:"job_id"`
 	Message string `json:"message"`
 	Status  string `json:"status"`
 }
 
-func CollectSourceFiles(root string, exts []string) ([]string, error) {
-	var files []string
+// CollectOptions narrow down or widen what CollectSourceFiles lists.
+type CollectOptions struct {
+	// ModifiedSince, when non-zero, skips files last modified before it
+	ModifiedSince time.Time
+	// SkipGenerated leaves out files marked as generated, which are
+	// returned separately
+	SkipGenerated bool
+	// FollowSymlinks descends into symlinked directories under root
+	FollowSymlinks bool
+}
+
+// CollectSourceFiles lists files under root with one of exts.
+func CollectSourceFiles(root string, exts []string, opts CollectOptions) (files, generated []string, err error) {
 	extMap := map[string]bool{}
+	languages := map[string]bool{}
 	for _, e := range exts {
 		extMap[strings.ToLower(e)] = true
+		languages[services.DetectLanguage("x"+e)] = true
 	}
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	err = utils.Walk(root, opts.FollowSymlinks, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() {
-			if extMap[strings.ToLower(filepath.Ext(path))] {
-				files = append(files, path)
+		if info.IsDir() || info.ModTime().Before(opts.ModifiedSince) {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if !extMap[ext] {
+			// Extensionless scripts count when their interpreter's
+			// language is analyzable
+			if ext != "" || !info.Mode().IsRegular() {
+				return nil
 			}
+			if lang, ok := services.ShebangLanguage(path); !ok || !languages[lang] {
+				return nil
+			}
+		}
+		if opts.SkipGenerated && services.IsGenerated(path) {
+			generated = append(generated, path)
+			return nil
 		}
+		files = append(files, path)
 		return nil
 	})
-	return files, err
+	return files, generated, err
 }
 
+// UploadCodebase takes an archive or single source file as the "codebase"
+// field of a multipart form, or an archive as the raw request body.
 func UploadCodebase(c *fiber.Ctx) error {
+	if mediaType(string(c.Request().Header.ContentType())) != fiber.MIMEMultipartForm {
+		return uploadRawBody(c)
+	}
+
 	// Get uploaded file
 	file, err := c.FormFile("codebase")
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "No file uploaded",
-		})
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeNoFile, "No file uploaded")
 	}
 
 	ext := strings.ToLower(filepath.Ext(file.Filename))
-	if !isValidArchive(ext) {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid file type. Please upload .zip, .tar, or .tar.gz files",
-		})
+	if !isValidArchive(ext) && !isSourceFile(file.Filename) {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeInvalidFileType, "Invalid file type. Please upload .zip, .tar, or .tar.gz files, or a single source file")
+	}
+
+	return acceptUpload(c, file.Filename, file.Size, func(path string) error {
+		return c.SaveFile(file, path)
+	})
+}
+
+// acceptUpload stores an upload of size bytes as filename with save and
+// starts its job, using the job settings sent alongside it.
+func acceptUpload(c *fiber.Ctx, filename string, size int64, save func(path string) error) error {
+	req, err := formJobRequest(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, err.Error())
+	}
+	opts, code, err := newJobOptions(req)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, code, err.Error())
+	}
+	opts.TraceID = traceID(c)
+	diagrams, err := formDiagrams(c)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeInvalidDiagram, err.Error())
+	}
+	if !acquireIntake(&opts) {
+		return busyResponse(c)
+	}
+	if !reserveDisk(&opts, size) {
+		opts.doneIntake()
+		return quotaResponse(c)
 	}
 
 	jobID := uuid.New().String()
 
-	uploadPath := fmt.Sprintf("./uploads/%s", jobID)
-	if err := utils.CreateDir(uploadPath); err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to create upload directory",
-		})
+	if opts.workDir, err = newWorkDir(jobID); err != nil {
+		opts.doneIntake()
+		return errorResponse(c, fiber.StatusInternalServerError, ErrCodeInternal, "Failed to create upload directory")
 	}
 
 	// Save uploaded file
-	filePath := filepath.Join(uploadPath, file.Filename)
-	if err := c.SaveFile(file, filePath); err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to save uploaded file",
-		})
+	filePath := filepath.Join(opts.workDir, filename)
+	if err := save(filePath); err != nil {
+		utils.CleanupDir(opts.workDir)
+		opts.doneIntake()
+		return errorResponse(c, fiber.StatusInternalServerError, ErrCodeInternal, "Failed to save uploaded file")
+	}
+	if err := saveDiagrams(c, jobID, diagrams); err != nil {
+		utils.CleanupDir(opts.workDir)
+		opts.doneIntake()
+		return errorResponse(c, fiber.StatusInternalServerError, ErrCodeInternal, "Failed to save diagrams")
+	}
+	saveResumeInfo(jobID, opts.workDir, req, filePath, filename)
+
+	// Tiny single-file jobs can be answered with the document itself,
+	// unless processing is paused and the job has to wait its turn
+	if c.FormValue("sync") == "true" && isSourceFile(filename) && size <= cfg.SyncMaxBytes && !dispatch.IsClosed() {
+		processCodebase(registerJob(jobID, opts), jobID, filePath, filename, opts)
+		return syncResponse(c, jobID)
 	}
 
 	// Process asynchronously
-	go processCodebase(jobID, filePath, file.Filename)
+	status := startJob(jobID, opts, func(ctx context.Context) {
+		processCodebase(ctx, jobID, filePath, filename, opts)
+	})
 
+	message := "File uploaded successfully. Processing started."
+	if status == models.JobStatusQueued {
+		message = "File uploaded successfully. Queued until processing resumes."
+	}
 	return c.JSON(UploadResponse{
 		JobID:   jobID,
-		Message: "File uploaded successfully. Processing started.",
-		Status:  "processing",
+		Message: message,
+		Status:  status,
 	})
 }
-func processCodebase(jobID, filePath, filename string) {
-	log.Printf("Starting processing for job %s", jobID)
-
-	extractPath := fmt.Sprintf("./uploads/%s/extracted", jobID)
-	if err := utils.ExtractArchive(filePath, extractPath); err != nil {
-		log.Printf("Failed to extract archive for job %s: %v", jobID, err)
-		return
-	}
-	log.Printf("Extraction complete for job %s", extractPath)
 
-	// Collect code files (.py, .js, .ts, .php, .go, ... add others as needed)
-	exts := []string{".py", ".js", ".ts", ".php", ".go"}
-	codeFiles, err := CollectSourceFiles(extractPath, exts)
-	if err != nil || len(codeFiles) == 0 {
-		log.Printf("No source files found for job %s: %v", jobID, err)
-		return
-	}
-
-	// Analyze files (could aggregate, or select main if preferred)
-	var docs []string
-	for _, codeFile := range codeFiles {
-		log.Printf("Analyzing file: %s", codeFile)
-		doc, err := services.AnalyzeProject(codeFile)
-		if err != nil {
-			log.Printf("File analysis failed for %s: %v", codeFile, err)
-			continue
-		}
-		docs = append(docs, doc)
+// syncResponse sends the document of a job processed during the request,
+// with the job ID in a header so its status and log stay reachable.
+func syncResponse(c *fiber.Ctx, jobID string) error {
+	c.Set("X-Job-ID", jobID)
+	job, _ := jobs.Get(jobID)
+	if job.Status == models.JobStatusFailed || len(job.Outputs) == 0 {
+		return errorResponse(c, fiber.StatusUnprocessableEntity, ErrCodeJobFailed, job.Message)
 	}
 
-	// Combine all docs into one (simple join, or make a section per file)
-	combinedDoc := strings.Join(docs, "\n\n---\n\n")
-
-	// Generate documentation file (save as .docx, or markdown, as you wish)
-	generator := services.NewDocxGenerator()
-	outputPath := fmt.Sprintf("./output/%s_documentation.docx", jobID)
-	if err := generator.GenerateDocumentation(combinedDoc, outputPath); err != nil {
-		log.Printf("Failed to generate documentation for job %s: %v", jobID, err)
-		return
+	filename := job.Outputs[0].Filename
+	if err := c.SendFile(filepath.Join("./output", filename)); err != nil {
+		return err
 	}
-	log.Printf("Documentation generated successfully for job %s", jobID)
+	c.Set("Content-Type", services.ContentTypeFor(filename))
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(filename)))
+	return nil
+}
 
-	utils.CleanupDir(fmt.Sprintf("./uploads/%s", jobID))
+// isJobID reports whether id is a job ID as the server issues them: a
+// UUID in its canonical lowercase form.
+func isJobID(id string) bool {
+	parsed, err := uuid.Parse(id)
+	return err == nil && parsed.String() == id
 }
 
-func processCodebaseOld(jobID, filePath, filename string) {
-	log.Printf("Starting processing for job %s", jobID)
+func outputFilename(jobID, ext string) string {
+	return fmt.Sprintf("%s_documentation.%s", jobID, ext)
+}
 
-	extractPath := fmt.Sprintf("./uploads/%s/extracted", jobID)
-	if err := utils.ExtractArchive(filePath, extractPath); err != nil {
-		log.Printf("Failed to extract archive for job %s: %v", jobID, err)
-		return
+// splitList splits a comma separated form value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	log.Printf("Extraction complete for job %s", extractPath)
+	return items
+}
 
-	// Analyze codebase
-	project, err := services.AnalyzeProject(extractPath)
-	if err != nil {
-		log.Printf("Failed to analyze project for job %s: %v", jobID, err)
-		return
+// failureMessage picks the user-facing message for a failed job step.
+func failureMessage(err error, fallback string) string {
+	switch {
+	case utils.IsDiskFull(err):
+		return "Insufficient disk space"
+	case errors.Is(err, utils.ErrPasswordRequired):
+		return "Archive is password-protected; supply it in the password field"
+	case errors.Is(err, utils.ErrWrongPassword):
+		return "Incorrect archive password"
+	case errors.Is(err, utils.ErrUnsupportedCrypt):
+		return err.Error()
+	case errors.Is(err, services.ErrAgentUnavailable):
+		return "Agent unavailable; try again later"
 	}
-	log.Printf("Analysis complete for job %s: %+v", jobID, project)
+	return fallback
+}
 
-	// Generate documentation
-	generator := services.NewDocxGenerator()
-	outputPath := fmt.Sprintf("./output/%s_documentation.docx", jobID)
-	if err := generator.GenerateDocumentation(project, outputPath); err != nil {
-		log.Printf("Failed to generate documentation for job %s: %v", jobID, err)
-		return
+// isSourceFile reports whether name is a single analyzable source file
+// rather than an archive.
+func isSourceFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, sourceExt := range cfg.SourceExtensions {
+		if ext == strings.ToLower(sourceExt) {
+			return true
+		}
 	}
-	log.Printf("Documentation generated successfully for job %s", jobID)
-
-	utils.CleanupDir(fmt.Sprintf("./uploads/%s", jobID))
+	return false
 }
 
 func isValidArchive(ext string) bool {
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/utils"
+)
+
+// archiveTypes maps the media types and ?type= names a raw upload may
+// declare to the extension ExtractArchive expects.
+var archiveTypes = map[string]string{
+	"zip":                          ".zip",
+	"application/zip":              ".zip",
+	"application/x-zip-compressed": ".zip",
+	"tar":                          ".tar",
+	"application/x-tar":            ".tar",
+	"tar.gz":                       ".tar.gz",
+	"tgz":                          ".tar.gz",
+	"application/gzip":             ".tar.gz",
+	"application/x-gzip":           ".tar.gz",
+	"application/x-gtar":           ".tar.gz",
+	"application/x-tgz":            ".tar.gz",
+}
+
+// uploadRawBody treats the request body as the archive, for clients
+// posting it with e.g. curl --data-binary. The format comes from ?type=,
+// then Content-Type; with neither, or a generic type, it is sniffed from
+// the archive itself. Job settings are read from the query.
+func uploadRawBody(c *fiber.Ctx) error {
+	// The undecoded body: a .tar.gz is the archive, not a transfer encoding
+	body := c.Request().Body()
+	if len(body) == 0 {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeNoFile, "No file uploaded")
+	}
+
+	ext, err := rawArchiveExt(c.Query("type"), mediaType(string(c.Request().Header.ContentType())), body)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeInvalidFileType, err.Error())
+	}
+
+	return acceptUpload(c, "archive"+ext, int64(len(body)), func(path string) error {
+		return os.WriteFile(path, body, 0644)
+	})
+}
+
+func rawArchiveExt(declared, contentType string, body []byte) (string, error) {
+	if declared != "" {
+		if ext, ok := archiveTypes[strings.ToLower(declared)]; ok {
+			return ext, nil
+		}
+		return "", fmt.Errorf("unsupported type %q, expected zip, tar or tar.gz", declared)
+	}
+	if ext, ok := archiveTypes[contentType]; ok {
+		return ext, nil
+	}
+	// curl --data-binary sends form encoding unless told otherwise
+	switch contentType {
+	case "", fiber.MIMEOctetStream, fiber.MIMEApplicationForm:
+	default:
+		return "", fmt.Errorf("unsupported Content-Type %q; post a .zip, .tar or .tar.gz archive", contentType)
+	}
+	ext, err := utils.ArchiveFormatOf(body)
+	if err != nil {
+		return "", fmt.Errorf("%w; post a .zip, .tar or .tar.gz archive", err)
+	}
+	return ext, nil
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestUploadRawBody(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(testTar(t, testProject))
+	w.Close()
+
+	zipped := testZip(t, testProject)
+	tests := []struct {
+		name, query, contentType string
+		body                     []byte
+	}{
+		{"sniffed zip", "", "", zipped},
+		{"curl default", "", fiber.MIMEApplicationForm, zipped},
+		{"declared zip", "", "application/zip", zipped},
+		{"type parameter", "&type=zip", fiber.MIMEOctetStream, zipped},
+		{"gzipped tar", "", "application/gzip", gz.Bytes()},
+		{"sniffed tar.gz", "", "", gz.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, "/api/upload?format=md"+tt.query, bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			}
+			resp, body := doRequest(t, app, req)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+			}
+			var uploaded UploadResponse
+			json.Unmarshal(body, &uploaded)
+			job := waitJob(t, uploaded.JobID)
+			if job.Status != models.JobStatusCompleted {
+				t.Fatalf("job %s: %s", job.Status, job.Message)
+			}
+			if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "util.go") {
+				t.Errorf("document doesn't cover the archive:\n%s", doc)
+			}
+		})
+	}
+}
+
+func TestUploadRawBodyRejected(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	zipped := testZip(t, testProject)
+	tests := []struct {
+		name, query, contentType string
+		body                     []byte
+		code                     string
+	}{
+		{"empty body", "", fiber.MIMEOctetStream, nil, ErrCodeNoFile},
+		{"unknown type", "?type=rar", fiber.MIMEOctetStream, zipped, ErrCodeInvalidFileType},
+		{"unsupported content type", "", fiber.MIMETextPlain, zipped, ErrCodeInvalidFileType},
+		{"not an archive", "", fiber.MIMEOctetStream, []byte("package main\n"), ErrCodeInvalidFileType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, "/api/upload"+tt.query, bytes.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			resp, body := doRequest(t, app, req)
+			if resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != tt.code {
+				t.Errorf("got %d %s, want 400 %s", resp.StatusCode, body, tt.code)
+			}
+		})
+	}
+}
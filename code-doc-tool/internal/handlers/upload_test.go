@@ -0,0 +1,1740 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+	"code-doc-tool/internal/utils"
+)
+
+var testProject = map[string]string{
+	"go.mod":  "module example.com/app\n\ngo 1.22\n",
+	"main.go": "package main\n\nfunc main() {}\n",
+	"util.go": "package main\n\n// Add adds.\nfunc Add(a, b int) int { return a + b }\n",
+}
+
+func TestUploadSections(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"sections": "apis,8", "format": "md"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{"## 5. APIs", "## 8. Usage Example"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document is missing %q", want)
+		}
+	}
+	if strings.Contains(doc, "## 1. Overview") {
+		t.Error("document has an unselected section")
+	}
+}
+
+func TestUploadUnknownSection(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	req := uploadRequest(t, "project.zip", testZip(t, testProject), map[string]string{"sections": "overview,bogus"})
+	resp, body := doRequest(t, app, req)
+	if resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeInvalidSections {
+		t.Fatalf("got %d %s, want 400 %s", resp.StatusCode, body, ErrCodeInvalidSections)
+	}
+}
+
+func TestUploadDetail(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.DocDetail = "detailed" })
+	var templates sync.Map
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		templates.Store(filepath.Base(path), formatTemplate)
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+
+	for detail, want := range map[string]string{"": "Detail level: detailed.", "brief": "Detail level: brief."} {
+		job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md", "detail": detail}))
+		if job.Status != models.JobStatusCompleted {
+			t.Fatalf("job %s: %s", job.Status, job.Message)
+		}
+		if template, _ := templates.Load("main.go"); !strings.Contains(template.(string), want) {
+			t.Errorf("detail %q sent template without %q:\n%s", detail, want, template)
+		}
+	}
+
+	req := uploadRequest(t, "project.zip", testZip(t, testProject), map[string]string{"detail": "verbose"})
+	if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeBadRequest {
+		t.Errorf("got %d %s, want 400 %s", resp.StatusCode, body, ErrCodeBadRequest)
+	}
+}
+
+func TestUploadFormatTemplates(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "templates.json")
+	if err := os.WriteFile(file, []byte(`{"Go": "Document this Go file.\n", "library": ["usage"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setupTest(t, func(c *config.Config) { c.FormatTemplatesFile = file })
+	var templates sync.Map
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		templates.Store(filepath.Base(path), formatTemplate)
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+	files := map[string]string{
+		"greet.go": "package greet\n\nfunc Greet() {}\n",
+		"greet.js": "export function greet() {}\n",
+	}
+
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md", "detail": "brief"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if job.Outputs[0].Type != models.ProjectTypeLibrary {
+		t.Fatalf("project type %q, want library", job.Outputs[0].Type)
+	}
+	goTemplate, _ := templates.Load("greet.go")
+	if !strings.HasPrefix(goTemplate.(string), "Document this Go file.\n") || !strings.Contains(goTemplate.(string), "Detail level: brief.") {
+		t.Errorf("greet.go template:\n%s", goTemplate)
+	}
+	jsTemplate, _ := templates.Load("greet.js")
+	if !strings.Contains(jsTemplate.(string), "## 8. Usage Example") || strings.Contains(jsTemplate.(string), "## 1. Overview") {
+		t.Errorf("greet.js template isn't the library's:\n%s", jsTemplate)
+	}
+
+	// Sections picked for the job apply to every file
+	templates = sync.Map{}
+	job = waitJob(t, upload(t, app, files, map[string]string{"format": "md", "sections": "apis"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	for _, name := range []string{"greet.go", "greet.js"} {
+		if template, _ := templates.Load(name); !strings.Contains(template.(string), "## 5. APIs") || strings.Contains(template.(string), "Document this") {
+			t.Errorf("%s template isn't the job's:\n%s", name, template)
+		}
+	}
+}
+
+func TestUploadDocInputs(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.ExtractSourcesOnly = true })
+	var templates sync.Map
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		templates.Store(filepath.Base(path), formatTemplate)
+		if filepath.Base(path) == "overviews.md" {
+			return "# Demo\n\nA demo service.\n", nil
+		}
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+	// Wrapped in a folder, and extracted with only the sources kept
+	files := map[string]string{
+		"demo/main.go":                     "package main\n\nfunc main() {}\n",
+		"demo/README.md":                   "# Demo\n\nServes the greeting API.\n",
+		"demo/docs/adr/0001-storage.md":    "We store greetings in SQLite.\n",
+		"demo/notes.md":                    "Scratch notes.\n",
+		"demo/node_modules/left/README.md": "A dependency.\n",
+	}
+
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md", "doc_inputs": "true", "overview": "agent"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	for _, name := range []string{"main.go", "overviews.md"} {
+		template, _ := templates.Load(name)
+		for _, want := range []string{"--- demo/README.md ---\n# Demo\n\nServes the greeting API.", "--- demo/docs/adr/0001-storage.md ---\nWe store greetings in SQLite."} {
+			if !strings.Contains(template.(string), want) {
+				t.Errorf("%s template is missing %q:\n%s", name, want, template)
+			}
+		}
+		for _, unwanted := range []string{"Scratch notes.", "A dependency."} {
+			if strings.Contains(template.(string), unwanted) {
+				t.Errorf("%s template has %q", name, unwanted)
+			}
+		}
+	}
+
+	// Without the option the agent gets no documentation
+	templates = sync.Map{}
+	job = waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if template, _ := templates.Load("main.go"); strings.Contains(template.(string), "Serves the greeting API.") {
+		t.Errorf("template has the README without doc_inputs:\n%s", template)
+	}
+}
+
+func TestJobLanguageStats(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"}))
+	if len(job.Languages) != 1 || job.Languages[0].Language != "Go" || job.Languages[0].Files != 2 || job.Languages[0].Lines != 7 {
+		t.Errorf("job languages %+v, want 2 Go files of 7 lines", job.Languages)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "| Go | 2 | 7 |") {
+		t.Errorf("document has no language table:\n%s", doc)
+	}
+}
+
+func TestFailureMessage(t *testing.T) {
+	enospc := &fs.PathError{Op: "write", Path: "out.docx", Err: syscall.ENOSPC}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{enospc, "Insufficient disk space"},
+		{fmt.Errorf("extract: %w", utils.WrapDiskFull(enospc)), "Insufficient disk space"},
+		{utils.ErrPasswordRequired, "Archive is password-protected; supply it in the password field"},
+		{errors.New("boom"), "Failed to extract archive"},
+	}
+	for _, tt := range tests {
+		if got := failureMessage(tt.err, "Failed to extract archive"); got != tt.want {
+			t.Errorf("failureMessage(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestUploadTextFormat(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "txt"}))
+	if job.Format != "txt" || !strings.HasSuffix(job.Outputs[0].Filename, ".txt") {
+		t.Fatalf("job format %q, outputs %+v", job.Format, job.Outputs)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); strings.Contains(doc, "## ") {
+		t.Errorf("text output still has markdown headings:\n%s", doc)
+	}
+
+	req := uploadRequest(t, "project.zip", testZip(t, testProject), map[string]string{"format": "pdf"})
+	if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeInvalidFormat {
+		t.Errorf("format=pdf got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestUploadSeveralFormats(t *testing.T) {
+	setupTest(t, nil)
+	var calls atomic.Int32
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		calls.Add(1)
+		return "## " + filepath.Base(path) + "\nDocumented.\n", nil
+	})
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "docx, md,markdown"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("agent called %d times, want once per file", n)
+	}
+	output := job.Outputs[0]
+	if output.Filename != jobID+"_documentation.docx" || len(output.Artifacts) != 1 || output.Artifacts[0] != jobID+"_documentation.md" {
+		t.Fatalf("output %+v, want a docx with one md artifact", output)
+	}
+	if doc := readOutput(t, output.Artifacts[0]); !strings.Contains(doc, "## util.go") {
+		t.Errorf("markdown artifact:\n%s", doc)
+	}
+	if info, err := os.Stat(filepath.Join("./output", output.Filename)); err != nil || info.Size() == 0 {
+		t.Errorf("docx output: %v", err)
+	}
+
+	_, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/status/"+jobID, nil))
+	var status struct {
+		Outputs []struct {
+			Artifacts []struct {
+				Format      string `json:"format"`
+				DownloadURL string `json:"download_url"`
+			} `json:"artifacts"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil || len(status.Outputs) != 1 {
+		t.Fatalf("status %s", body)
+	}
+	artifacts := status.Outputs[0].Artifacts
+	if len(artifacts) != 2 || artifacts[0].Format != "docx" || artifacts[1].Format != "md" {
+		t.Fatalf("artifacts %+v, want docx and md", artifacts)
+	}
+	for _, artifact := range artifacts {
+		if resp, _ := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, artifact.DownloadURL, nil)); resp.StatusCode != fiber.StatusOK {
+			t.Errorf("download of %s: %d", artifact.DownloadURL, resp.StatusCode)
+		}
+	}
+
+	req := uploadRequest(t, "project.zip", testZip(t, testProject), map[string]string{"format": "md,pdf"})
+	if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeInvalidFormat {
+		t.Errorf("format=md,pdf got %d %s", resp.StatusCode, body)
+	}
+}
+
+var multiProject = map[string]string{
+	"api/go.mod":       "module api\n",
+	"api/main.go":      "package main\n\nfunc main() {}\n",
+	"web/package.json": "{\"name\": \"web\"}\n",
+	"web/index.js":     "console.log(1)\n",
+	"docs/notes.go":    "package docs\n",
+}
+
+func TestUploadMultiProject(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	job := waitJob(t, upload(t, app, multiProject, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted || len(job.Outputs) != 2 {
+		t.Fatalf("job %s with outputs %+v, want one per project", job.Status, job.Outputs)
+	}
+	if job.Outputs[0].Project != "api" || job.Outputs[1].Project != "web" {
+		t.Errorf("outputs %+v", job.Outputs)
+	}
+
+	// Explicit roots replace detection
+	job = waitJob(t, upload(t, app, multiProject, map[string]string{"format": "md", "roots": "docs"}))
+	if len(job.Outputs) != 1 || job.Outputs[0].Project != "docs" {
+		t.Errorf("roots=docs gave outputs %+v", job.Outputs)
+	}
+
+	req := uploadRequest(t, "project.zip", testZip(t, multiProject), map[string]string{"roots": "../etc"})
+	if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeInvalidPath {
+		t.Errorf("roots=../etc got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestUploadWorkspace(t *testing.T) {
+	// The workspace manifest survives extracting only the sources
+	setupTest(t, func(c *config.Config) { c.ExtractSourcesOnly = true })
+	app := newTestApp()
+	files := map[string]string{
+		"pnpm-workspace.yaml":          "packages:\n  - 'packages/*' # libraries\n  - '!packages/legacy'\n",
+		"package.json":                 "{}",
+		"packages/ui/package.json":     "{}",
+		"packages/ui/index.js":         "export const ui = 1\n",
+		"packages/legacy/package.json": "{}",
+		"packages/legacy/index.js":     "export const old = 1\n",
+		"tools/package.json":           "{}",
+		"tools/gen.js":                 "console.log(1)\n",
+	}
+
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted || len(job.Outputs) != 1 || job.Outputs[0].Project != "packages-ui" {
+		t.Fatalf("job %s with outputs %+v, want packages/ui only", job.Status, job.Outputs)
+	}
+	entries, _ := jobs.Logs(job.ID)
+	if !slices.ContainsFunc(entries, func(e models.JobLogEntry) bool {
+		return strings.HasPrefix(e.Message, "Using the 1 packages declared by the pnpm workspace")
+	}) {
+		t.Error("job log doesn't name the workspace")
+	}
+}
+
+func TestUploadProjectConcurrency(t *testing.T) {
+	files := map[string]string{
+		"web/go.mod": "module web\n",
+		"web/a.go":   "package web\n",
+		"web/b.go":   "package web\n",
+		"api/go.mod": "module api\n",
+		"api/a.go":   "package api\n",
+		"api/b.go":   "package api\n",
+		"lib/go.mod": "module lib\n",
+		"lib/a.go":   "package lib\n",
+		"lib/b.go":   "package lib\n",
+	}
+	for order, want := range map[string]string{"detected": "web api lib", "name": "api lib web"} {
+		t.Run(order, func(t *testing.T) {
+			setupTest(t, func(c *config.Config) {
+				c.ProjectConcurrency = 2
+				c.ProjectOrder = order
+			})
+			var mu sync.Mutex
+			active := map[string]int{}
+			peak := 0
+			analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+				project := filepath.Base(filepath.Dir(path))
+				mu.Lock()
+				active[project]++
+				peak = max(peak, len(active))
+				mu.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				mu.Lock()
+				if active[project]--; active[project] == 0 {
+					delete(active, project)
+				}
+				mu.Unlock()
+				return "# " + filepath.Base(path) + "\n", nil
+			})
+			app := newTestApp()
+
+			// Explicit roots are the detected order
+			job := waitJob(t, upload(t, app, files, map[string]string{"format": "md", "roots": "web,api,lib"}))
+			if job.Status != models.JobStatusCompleted {
+				t.Fatalf("job %s: %s", job.Status, job.Message)
+			}
+			var projects []string
+			for _, output := range job.Outputs {
+				projects = append(projects, output.Project)
+			}
+			if got := strings.Join(projects, " "); got != want {
+				t.Errorf("outputs listed as %s, want %s", got, want)
+			}
+			if peak != 2 {
+				t.Errorf("%d projects analyzed at once, want 2", peak)
+			}
+		})
+	}
+}
+
+func TestUploadSubpath(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	job := waitJob(t, upload(t, app, multiProject, map[string]string{"format": "md", "subpath": "api"}))
+	if job.Status != models.JobStatusCompleted || len(job.Outputs) != 1 {
+		t.Fatalf("job %s: %s, outputs %+v", job.Status, job.Message, job.Outputs)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "main.go") || strings.Contains(doc, "index.js") {
+		t.Errorf("document isn't limited to api/:\n%s", doc)
+	}
+
+	job = waitJob(t, upload(t, app, multiProject, map[string]string{"subpath": "missing"}))
+	if job.Status != models.JobStatusFailed {
+		t.Errorf("subpath=missing: job %s", job.Status)
+	}
+
+	req := uploadRequest(t, "project.zip", testZip(t, multiProject), map[string]string{"subpath": "../.."})
+	if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeInvalidPath {
+		t.Errorf("subpath=../.. got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestUploadIncludeSource(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.SourceSnippetMaxLines = 2
+	})
+	app := newTestApp()
+
+	job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md", "include_source": "true"}))
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{"### Source: util.go", "```go\npackage main\n\n```", "_Source truncated to the first 2 lines._"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document is missing %q:\n%s", want, doc)
+		}
+	}
+
+	job = waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"}))
+	if doc := readOutput(t, job.Outputs[0].Filename); strings.Contains(doc, "### Source:") {
+		t.Error("source included without include_source")
+	}
+}
+
+func TestUploadLanguageOverrides(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	files := map[string]string{
+		"main.go":      "package main\n",
+		"lib/tool.inc": "<?php echo 1;\n",
+	}
+
+	job := waitJob(t, upload(t, app, files, map[string]string{
+		"format":             "md",
+		"language_overrides": `{"lib/tool.inc": "php"}`,
+	}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	if !strings.Contains(doc, "# tool.inc") || !strings.Contains(doc, "| PHP | 1 | 1 |") {
+		t.Errorf("overridden file not analyzed as PHP:\n%s", doc)
+	}
+
+	for overrides, code := range map[string]string{
+		`{"lib/tool.inc": "klingon"}`: ErrCodeInvalidLanguage,
+		`{"../tool.inc": "php"}`:      ErrCodeInvalidPath,
+		`["php"]`:                     ErrCodeBadRequest,
+	} {
+		req := uploadRequest(t, "project.zip", testZip(t, files), map[string]string{"language_overrides": overrides})
+		if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != code {
+			t.Errorf("%s: got %d %s, want %s", overrides, resp.StatusCode, body, code)
+		}
+	}
+}
+
+func TestUploadTraceID(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp(requestid.New())
+
+	req := uploadRequest(t, "project.zip", testZip(t, testProject), nil)
+	req.Header.Set(fiber.HeaderXRequestID, "client-trace-1")
+	resp, body := doRequest(t, app, req)
+	var uploaded UploadResponse
+	json.Unmarshal(body, &uploaded)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("got %d %s", resp.StatusCode, body)
+	}
+	if job := waitJob(t, uploaded.JobID); job.TraceID != "client-trace-1" {
+		t.Errorf("job trace ID %q", job.TraceID)
+	}
+
+	for id, ok := range map[string]bool{"abc-123": true, "": false, "has space": false, strings.Repeat("x", 129): false} {
+		if validTraceID(id) != ok {
+			t.Errorf("validTraceID(%q) = %v", id, !ok)
+		}
+	}
+}
+
+func TestUploadDeadLetters(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeRetries = 1
+		c.AnalyzeRetryDelay = time.Millisecond
+	})
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		if strings.HasSuffix(path, "util.go") {
+			return "", errors.New("agent returned 500")
+		}
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if len(job.DeadLetters) != 1 {
+		t.Fatalf("dead letters %+v, want util.go", job.DeadLetters)
+	}
+	letter := job.DeadLetters[0]
+	if letter.Path != "util.go" || letter.Attempts != 2 || !strings.Contains(letter.Error, "agent returned 500") {
+		t.Errorf("dead letter %+v", letter)
+	}
+
+	_, body := doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/status/"+jobID, nil))
+	var status struct {
+		DeadLetters []models.DeadLetter `json:"dead_letters"`
+	}
+	json.Unmarshal(body, &status)
+	if len(status.DeadLetters) != 1 || status.DeadLetters[0].Path != "util.go" {
+		t.Errorf("status dead letters: %s", body)
+	}
+
+	_, body = doRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jobs/"+jobID+"/log", nil))
+	if !strings.Contains(string(body), "Giving up on util.go after 2 attempts") {
+		t.Errorf("job log doesn't record the dead letter: %s", body)
+	}
+}
+
+func TestUploadAnalysisBudget(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.FileAnalysisBudget = 100 * time.Millisecond
+		c.AnalyzeRetries = 2
+		c.AnalyzeRetryDelay = time.Millisecond
+	})
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		if strings.HasSuffix(path, "main.go") {
+			// An agent that never answers this file
+			<-ctx.Done()
+			return "", fmt.Errorf("analyze request: %w", ctx.Err())
+		}
+		return "## " + filepath.Base(path) + "\nDocumented.\n", nil
+	})
+	app := newTestApp()
+
+	started := time.Now()
+	job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"}))
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("job took %s with a budget of 100ms", elapsed)
+	}
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if len(job.DeadLetters) != 1 {
+		t.Fatalf("dead letters %+v, want main.go", job.DeadLetters)
+	}
+	letter := job.DeadLetters[0]
+	if letter.Path != "main.go" || !letter.TimedOut || !strings.Contains(letter.Error, "abandoned after 100ms") {
+		t.Errorf("dead letter %+v, want main.go timed out", letter)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "## util.go") {
+		t.Errorf("the other file wasn't documented:\n%s", doc)
+	}
+}
+
+func TestUploadAgentUnavailable(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AgentBreakerThreshold = 2
+		c.AgentBreakerCooldown = time.Hour
+		c.AnalyzeRetries = 3
+		c.AnalyzeRetryDelay = time.Millisecond
+		c.AnalyzeConcurrency = 1
+		c.JobRetries = 0
+	})
+	var calls atomic.Int32
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		calls.Add(1)
+		return "", &services.AgentStatusError{StatusCode: fiber.StatusServiceUnavailable, Body: "down"}
+	})
+	app := newTestApp()
+
+	job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusFailed || job.Message != "Agent unavailable; try again later" {
+		t.Fatalf("job %s: %s, want it failed for the agent", job.Status, job.Message)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("agent called %d times, want the breaker to stop it after 2", n)
+	}
+
+	// Later jobs fail fast without reaching the agent
+	job = waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusFailed || calls.Load() != 2 {
+		t.Errorf("job %s after %d calls, want it failed without calling the agent", job.Status, calls.Load())
+	}
+	if agentBreaker.State() != services.CircuitOpen {
+		t.Errorf("circuit %s, want open", agentBreaker.State())
+	}
+}
+
+func TestUploadAgentResponseTooLarge(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("code_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		doc := "# " + header.Filename
+		if header.Filename == "util.go" {
+			doc += strings.Repeat(" padding", 200)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"document": doc})
+	}))
+	defer agent.Close()
+
+	setupTest(t, func(c *config.Config) {
+		c.Analyzer = "http"
+		c.AgentURL = agent.URL + "/analyze"
+		c.AgentMaxResponseSize = 1000
+		c.AnalyzeRetries = 0
+	})
+	app := newTestApp()
+
+	job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if len(job.DeadLetters) != 1 {
+		t.Fatalf("dead letters %+v, want util.go", job.DeadLetters)
+	}
+	letter := job.DeadLetters[0]
+	if letter.Path != "util.go" || !strings.Contains(letter.Error, "agent response exceeds maximum size") {
+		t.Errorf("dead letter %+v", letter)
+	}
+	if len(letter.History) != 1 || letter.History[0].Kind != "response_too_large" {
+		t.Errorf("attempt history %+v, want response_too_large", letter.History)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "# main.go") || strings.Contains(doc, "padding") {
+		t.Errorf("document:\n%s", doc)
+	}
+}
+
+func TestUploadEmptyDocumentation(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.JobRetries = 0 })
+	docs := map[string]string{"main.go": "", "util.go": "\n---\n  \n***\n"}
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		return docs[filepath.Base(path)], nil
+	})
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "docx"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusFailed || !strings.Contains(job.Message, "analysis produced no documentation") {
+		t.Fatalf("job %s: %s, want it failed for lack of documentation", job.Status, job.Message)
+	}
+	if _, err := os.Stat(filepath.Join("./output", jobID+"_documentation.docx")); !os.IsNotExist(err) {
+		t.Errorf("a blank document was written: %v", err)
+	}
+
+	// One documented file is enough
+	docs["util.go"] = "## Add\nAdds.\n"
+	if job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"})); job.Status != models.JobStatusCompleted {
+		t.Errorf("job %s: %s", job.Status, job.Message)
+	}
+}
+
+func TestUploadRetriedFiles(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeRetries = 2
+		c.AnalyzeRetryDelay = time.Millisecond
+	})
+	failures := []error{
+		fmt.Errorf("analyze request timed out: %w", context.DeadlineExceeded),
+		&services.AgentStatusError{StatusCode: fiber.StatusBadGateway, Body: "bad gateway"},
+	}
+	var mu sync.Mutex
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasSuffix(path, "util.go") && len(failures) > 0 {
+			err := failures[0]
+			failures = failures[1:]
+			return "", err
+		}
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+
+	job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted || len(job.DeadLetters) != 0 {
+		t.Fatalf("job %s: %s, dead letters %+v", job.Status, job.Message, job.DeadLetters)
+	}
+	if len(job.RetriedFiles) != 1 || job.RetriedFiles[0].Path != "util.go" {
+		t.Fatalf("retried files %+v, want util.go", job.RetriedFiles)
+	}
+	history := job.RetriedFiles[0].History
+	if len(history) != 2 || history[0].Kind != "timeout" || history[1].Kind != "server_error" {
+		t.Errorf("history %+v, want a timeout then a server error", history)
+	}
+	if len(history) == 2 && (history[0].Attempt != 1 || history[1].Error != "agent error: bad gateway") {
+		t.Errorf("history %+v", history)
+	}
+}
+
+func TestUploadOverview(t *testing.T) {
+	for _, mode := range []string{"aggregate", "agent"} {
+		t.Run(mode, func(t *testing.T) {
+			setupTest(t, nil)
+			analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+				if filepath.Base(path) == "overviews.md" {
+					return "# Demo\n\nOne service in two files.\n", nil
+				}
+				return "## Overview\nThe " + filepath.Base(path) + " file.\n", nil
+			})
+			app := newTestApp()
+
+			job := waitJob(t, upload(t, app, testProject, map[string]string{"format": "md", "overview": mode}))
+			if job.Status != models.JobStatusCompleted {
+				t.Fatalf("job %s: %s", job.Status, job.Message)
+			}
+			doc := readOutput(t, job.Outputs[0].Filename)
+			title, rest, _ := strings.Cut(doc, "\n\n")
+			if !strings.HasPrefix(title, "# ") || !strings.HasPrefix(rest, "## Project Overview\n") {
+				t.Fatalf("overview isn't right under the title:\n%s", doc)
+			}
+			want := "One service in two files."
+			if mode == "aggregate" {
+				want = "- **main.go**: The main.go file."
+			}
+			if overview, _, _ := strings.Cut(rest, "---"); !strings.Contains(overview, want) {
+				t.Errorf("overview is missing %q:\n%s", want, overview)
+			}
+			if strings.Index(doc, "## Project Overview") > strings.Index(doc, "## Project Summary") {
+				t.Errorf("overview comes after the project summary:\n%s", doc)
+			}
+		})
+	}
+}
+
+func TestUploadDiskQuota(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.DiskQuota = 50 * 1024 })
+	release := make(chan struct{})
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		<-release
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+
+	// About 20KB uploaded, and as much again once extracted
+	files := map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+		"big.go":  "package main\n\n" + strings.Repeat("// padding\n", 1800),
+	}
+	archive := testTar(t, files)
+	extracted := int64(len(files["main.go"]) + len(files["big.go"]))
+	send := func() (*http.Response, []byte) {
+		return doRequest(t, app, uploadRequest(t, "project.tar", archive, map[string]string{"format": "md"}))
+	}
+
+	resp, body := send()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("first upload returned %d: %s", resp.StatusCode, body)
+	}
+	var first UploadResponse
+	json.Unmarshal(body, &first)
+	deadline := time.Now().Add(5 * time.Second)
+	for diskQuota.Usage().Used < int64(len(archive))+extracted {
+		if time.Now().After(deadline) {
+			t.Fatalf("first job never extracted: %+v", diskQuota.Usage())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, body = send()
+	if resp.StatusCode != fiber.StatusInsufficientStorage || errorCode(t, body) != ErrCodeQuotaExceeded {
+		t.Fatalf("second upload got %d %s, want 507 while the first holds the quota", resp.StatusCode, body)
+	}
+
+	close(release)
+	if job := waitJob(t, first.JobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	resp, body = send()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload after the first job returned %d: %s", resp.StatusCode, body)
+	}
+	var second UploadResponse
+	json.Unmarshal(body, &second)
+	if job := waitJob(t, second.JobID); job.Status != models.JobStatusCompleted {
+		t.Errorf("job %s: %s", job.Status, job.Message)
+	}
+}
+
+func TestUploadDiskQuotaExtraction(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.DiskQuota = 30 * 1024
+		c.JobRetries = 0
+	})
+	app := newTestApp()
+
+	// The archive fits in the quota, but not together with its contents
+	archive := testTar(t, map[string]string{"big.go": "package main\n\n" + strings.Repeat("// padding\n", 1800)})
+	resp, body := doRequest(t, app, uploadRequest(t, "project.tar", archive, map[string]string{"format": "md"}))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+	}
+	var uploaded UploadResponse
+	json.Unmarshal(body, &uploaded)
+	job := waitJob(t, uploaded.JobID)
+	if job.Status != models.JobStatusFailed || job.Message != "Insufficient disk space" {
+		t.Errorf("job %s: %s, want it failed for the quota", job.Status, job.Message)
+	}
+}
+
+func TestUploadSingleSourceFile(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	source := "package main\n\n// Greet says hello.\nfunc Greet() string { return \"hello\" }\n"
+	resp, body := doRequest(t, app, uploadRequest(t, "greet.go", []byte(source), map[string]string{"format": "md"}))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+	}
+	var uploaded UploadResponse
+	json.Unmarshal(body, &uploaded)
+	job := waitJob(t, uploaded.JobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "greet.go") {
+		t.Errorf("document doesn't cover greet.go:\n%s", doc)
+	}
+	if len(job.Languages) != 1 || job.Languages[0].Files != 1 {
+		t.Errorf("languages %+v, want one Go file", job.Languages)
+	}
+}
+
+func TestUploadJobRetry(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AnalyzeRetries = 0
+		c.JobRetries = 1
+		c.JobRetryDelay = time.Millisecond
+	})
+	var calls atomic.Int32
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		if calls.Add(1) == 1 {
+			return "", fmt.Errorf("dial agent: %w", services.ErrAgentUnavailable)
+		}
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+
+	// A lone source file, so the retry has to stage it a second time
+	source := "package main\n\nfunc Greet() string { return \"hello\" }\n"
+	resp, body := doRequest(t, app, uploadRequest(t, "greet.go", []byte(source), map[string]string{"format": "md"}))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+	}
+	var uploaded UploadResponse
+	json.Unmarshal(body, &uploaded)
+	job := waitJob(t, uploaded.JobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if job.Attempts != 2 {
+		t.Errorf("attempts %d, want 2", job.Attempts)
+	}
+}
+
+func TestUploadSync(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.SyncMaxBytes = 1024 })
+	app := newTestApp()
+
+	source := "package main\n\nfunc main() {}\n"
+	resp, body := doRequest(t, app, uploadRequest(t, "main.go", []byte(source), map[string]string{"format": "md", "sync": "true"}))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(ct, "text/markdown") {
+		t.Errorf("content type %q, want markdown", ct)
+	}
+	if !strings.Contains(string(body), "Placeholder documentation for main.go") {
+		t.Errorf("response isn't the document:\n%s", body)
+	}
+	jobID := resp.Header.Get("X-Job-ID")
+	if job, ok := jobs.Get(jobID); !ok || job.Status != models.JobStatusCompleted {
+		t.Errorf("job %q not completed: %+v", jobID, job)
+	}
+
+	// Over the threshold the upload falls back to a job
+	large := source + strings.Repeat("// padding\n", 200)
+	resp, body = doRequest(t, app, uploadRequest(t, "main.go", []byte(large), map[string]string{"format": "md", "sync": "true"}))
+	var uploaded UploadResponse
+	if err := json.Unmarshal(body, &uploaded); err != nil || uploaded.JobID == "" {
+		t.Fatalf("got %d %s, want an async job", resp.StatusCode, body)
+	}
+	waitJob(t, uploaded.JobID)
+}
+
+func TestUploadUnsupportedFile(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+	resp, body := doRequest(t, app, uploadRequest(t, "notes.docx", []byte("not source"), nil))
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("got %d %s, want 400", resp.StatusCode, body)
+	}
+}
+
+func TestUploadUnsupportedLanguage(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.JobRetries = 0 })
+	app := newTestApp()
+	jobID := upload(t, app, map[string]string{
+		"crate/src/main.rs": "fn main() {}\n",
+		"crate/src/lib.rs":  "pub fn lib() {}\n",
+		"crate/Cargo.toml":  "[package]\nname = \"crate\"\n",
+	}, map[string]string{"format": "md"})
+
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusFailed {
+		t.Fatalf("job %s, want it failed", job.Status)
+	}
+	want := "found 2 .rs files (Rust) not in the analyzable set"
+	if !strings.Contains(job.Message, want) {
+		t.Errorf("message %q doesn't say %q", job.Message, want)
+	}
+}
+
+func TestUploadIncremental(t *testing.T) {
+	setupTest(t, nil)
+	var mu sync.Mutex
+	var calls []string
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		calls = append(calls, filepath.Base(path))
+		mu.Unlock()
+		content, _ := os.ReadFile(path)
+		return "## " + filepath.Base(path) + "\n" + string(content), nil
+	})
+	app := newTestApp()
+
+	first := upload(t, app, testProject, map[string]string{"format": "md"})
+	if job := waitJob(t, first); job.Status != models.JobStatusCompleted {
+		t.Fatalf("first job %s: %s", job.Status, job.Message)
+	}
+
+	changed := map[string]string{}
+	for name, content := range testProject {
+		changed[name] = content
+	}
+	changed["util.go"] += "\n// Sub subtracts.\nfunc Sub(a, b int) int { return a - b }\n"
+	calls = nil
+	second := upload(t, app, changed, map[string]string{"format": "md", "previous_job_id": first})
+	job := waitJob(t, second)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("second job %s: %s", job.Status, job.Message)
+	}
+	if fmt.Sprint(calls) != "[util.go]" {
+		t.Errorf("re-analyzed %v, want only util.go", calls)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{"func main() {}", "func Sub(a, b int)"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("combined document is missing %q", want)
+		}
+	}
+
+	resp, body := doRequest(t, app, uploadRequest(t, "project.zip", testZip(t, testProject), map[string]string{
+		"previous_job_id": "00000000-0000-0000-0000-000000000000",
+	}))
+	if resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeJobNotFound {
+		t.Errorf("unknown previous job: got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestUploadIndex(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md", "index": "true"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{"## Index", "| Add | function | util.go | 4 |", "| main | function | main.go | 3 |"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document is missing %q", want)
+		}
+	}
+}
+
+func TestUploadPublicOnly(t *testing.T) {
+	setupTest(t, nil)
+	var templates sync.Map
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		templates.Store(filepath.Base(path), formatTemplate)
+		// An agent documenting everything regardless
+		return "## Functions\n\n### Exported()\nPublic.\n\n### helper()\nPrivate.\n", nil
+	})
+	app := newTestApp()
+
+	files := map[string]string{"api.go": "package api\n\nfunc Exported() {}\n\nfunc helper() {}\n"}
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md", "public_only": "true", "index": "true"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{"### Exported()", "| Exported | function | api.go | 3 |"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document is missing %q:\n%s", want, doc)
+		}
+	}
+	if strings.Contains(doc, "helper") {
+		t.Errorf("document covers the unexported helper:\n%s", doc)
+	}
+	if template, _ := templates.Load("api.go"); !strings.Contains(template.(string), "Do not document: helper.") {
+		t.Errorf("agent wasn't told to leave helper out:\n%s", template)
+	}
+
+	// Without the option everything stays
+	job = waitJob(t, upload(t, app, files, map[string]string{"format": "md", "index": "true"}))
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "### helper()") || !strings.Contains(doc, "| helper |") {
+		t.Errorf("helper missing without public_only:\n%s", doc)
+	}
+}
+
+func TestUploadErrorTable(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	files := map[string]string{"store.go": "package store\n\nvar ErrMissing = errors.New(\"missing\")\n"}
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	if _, section, _ := strings.Cut(doc, "Error Handling"); !strings.Contains(section, "| `ErrMissing` | error value | missing | 3 |") {
+		t.Errorf("Error Handling section has no error table:\n%s", doc)
+	}
+
+	// Not asked for without the section
+	job = waitJob(t, upload(t, app, files, map[string]string{"format": "md", "sections": "overview"}))
+	if doc := readOutput(t, job.Outputs[0].Filename); strings.Contains(doc, "ErrMissing") {
+		t.Errorf("error table added without the Error Handling section:\n%s", doc)
+	}
+}
+
+func TestUploadFileTable(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md", "file_table": "true"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{
+		"## Files",
+		fmt.Sprintf("| main.go | Go | %d | 3 |", len(testProject["main.go"])),
+		fmt.Sprintf("| util.go | Go | %d | 4 |", len(testProject["util.go"])),
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document is missing %q:\n%s", want, doc)
+		}
+	}
+	if strings.Contains(doc, "| go.mod |") {
+		t.Error("file table lists a file that wasn't analyzed")
+	}
+
+	files := job.Outputs[0].Files
+	if len(files) != 2 || files[0].Path != "main.go" || files[1].Size != int64(len(testProject["util.go"])) {
+		t.Errorf("output files %+v", files)
+	}
+
+	// Without the option there is no table
+	job = waitJob(t, upload(t, app, testProject, map[string]string{"format": "md"}))
+	if doc := readOutput(t, job.Outputs[0].Filename); strings.Contains(doc, "## Files") || job.Outputs[0].Files != nil {
+		t.Error("file table added without being asked for")
+	}
+}
+
+func TestUploadConfigFiles(t *testing.T) {
+	setupTest(t, nil)
+	var sent sync.Map
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		sent.Store(filepath.Base(path), formatTemplate)
+		return "# " + filepath.Base(path) + "\n\n## Purpose\nExplained.\n", nil
+	})
+	app := newTestApp()
+
+	files := map[string]string{
+		"docker-compose.yml": "services:\n  web:\n    image: nginx\n",
+		".env":               "SECRET=hunter2\n",
+		".env.example":       "SECRET=\n",
+	}
+	for name, content := range testProject {
+		files[name] = content
+	}
+	jobID := upload(t, app, files, map[string]string{"format": "md", "config_files": "true"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{"# Configuration", "## docker-compose.yml", "## .env.example", "#### Purpose"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document is missing %q:\n%s", want, doc)
+		}
+	}
+	if template, ok := sent.Load("docker-compose.yml"); !ok || !strings.Contains(template.(string), "configuration file, not source code") {
+		t.Errorf("docker-compose.yml sent with template %v", template)
+	}
+	if _, ok := sent.Load(".env"); ok {
+		t.Error(".env was sent to the agent")
+	}
+
+	// Without the option configuration files aren't analyzed
+	sent = sync.Map{}
+	job = waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	if _, ok := sent.Load("docker-compose.yml"); ok || strings.Contains(readOutput(t, job.Outputs[0].Filename), "# Configuration") {
+		t.Error("configuration documented without being asked for")
+	}
+}
+
+func TestUploadGroupByPackage(t *testing.T) {
+	setupTest(t, nil)
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		return "# " + filepath.Base(path) + "\n\nDocumented.\n", nil
+	})
+	app := newTestApp()
+	files := map[string]string{
+		"go.mod":                   "module example.com/app\n",
+		"main.go":                  "package main\n\nfunc main() {}\n",
+		"internal/store/store.go":  "package store\n",
+		"internal/store/cache.go":  "package store\n",
+		"internal/api/handlers.go": "package api\n",
+	}
+
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md", "group": "package"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	// Packages in name order, each holding its files' sections
+	var last int
+	for _, want := range []string{
+		"# Package internal/api", "## handlers.go",
+		"# Package internal/store", "## cache.go", "## store.go",
+		"# Package main", "## main.go",
+	} {
+		i := strings.Index(doc, want)
+		if i < last {
+			t.Fatalf("%q is missing or out of place:\n%s", want, doc)
+		}
+		last = i
+	}
+
+	req := uploadRequest(t, "project.zip", testZip(t, files), map[string]string{"group": "module"})
+	if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("group=module got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestUploadOffline(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	for _, format := range []string{"md", "docx"} {
+		jobID := upload(t, app, testProject, map[string]string{"format": format})
+		job := waitJob(t, jobID)
+		if job.Status != models.JobStatusCompleted || len(job.Outputs) != 1 {
+			t.Fatalf("%s job %s: %s", format, job.Status, job.Message)
+		}
+		if !strings.HasSuffix(job.Outputs[0].Filename, "."+format) {
+			t.Errorf("%s job wrote %s", format, job.Outputs[0].Filename)
+		}
+		if format == "md" && !strings.Contains(readOutput(t, job.Outputs[0].Filename), "generated offline") {
+			t.Error("document doesn't come from the stub analyzer")
+		}
+	}
+}
+
+func TestUploadBackpressure(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.MaxInflightUploads = 2
+		c.UploadRetryAfter = 7 * time.Second
+	})
+	app := newTestApp()
+
+	// Two uploads still being extracted saturate the node
+	intakeSlots.TryAcquire()
+	intakeSlots.TryAcquire()
+	resp, body := doRequest(t, app, uploadRequest(t, "project.zip", testZip(t, testProject), nil))
+	if resp.StatusCode != fiber.StatusServiceUnavailable || errorCode(t, body) != ErrCodeServerBusy {
+		t.Fatalf("got %d %s, want 503", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get(fiber.HeaderRetryAfter); got != "7" {
+		t.Errorf("Retry-After %q, want 7", got)
+	}
+
+	intakeSlots.Release()
+	jobID := upload(t, app, testProject, nil)
+	if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if got := len(intakeSlots); got != 1 {
+		t.Errorf("%d intake slots held after the job, want 1", got)
+	}
+}
+
+func TestUploadBatchIncludeSource(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md", "batch_size": "2", "include_source": "true"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	if n := strings.Count(doc, "### Source: "); n != 1 {
+		t.Errorf("%d source sections, want one for the batch:\n%s", n, doc)
+	}
+	if !strings.Contains(doc, "# main.go") || !strings.Contains(doc, "# util.go") {
+		t.Errorf("batch document doesn't cover both files:\n%s", doc)
+	}
+}
+
+func TestUploadHeadingOffset(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "md", "heading_offset": "1"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			t.Errorf("heading %q wasn't demoted", line)
+		}
+	}
+	if !strings.Contains(doc, "\n## main.go\n") {
+		t.Errorf("file heading isn't at level 2:\n%s", doc)
+	}
+
+	resp, body := doRequest(t, app, uploadRequest(t, "project.zip", testZip(t, testProject), map[string]string{"heading_offset": "6"}))
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("offset 6: got %d %s, want 400", resp.StatusCode, body)
+	}
+}
+
+func TestUploadDocxFallback(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		// A template that isn't a docx makes every docx generation fail
+		c.DocxTemplate = "broken.docx"
+	})
+	if err := os.WriteFile("broken.docx", []byte("not a zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp()
+
+	jobID := upload(t, app, testProject, map[string]string{"format": "docx"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompletedWithFallback {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if len(job.Outputs) != 1 || !strings.HasSuffix(job.Outputs[0].Filename, ".md") {
+		t.Fatalf("outputs %+v, want the markdown fallback", job.Outputs)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "main.go") {
+		t.Errorf("fallback document:\n%s", doc)
+	}
+	if _, err := os.Stat(filepath.Join("./output", jobID+"_documentation.docx")); err == nil {
+		t.Error("a broken docx was left behind")
+	}
+}
+
+func TestUploadPromptAugmentations(t *testing.T) {
+	var mu sync.Mutex
+	formats := map[string]string{}
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("code_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		formats[header.Filename] = r.FormValue("format")
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"document": "## " + header.Filename})
+	}))
+	defer agent.Close()
+
+	setupTest(t, func(c *config.Config) {
+		c.Analyzer = "http"
+		c.AgentURL = agent.URL + "/analyze"
+		c.SourceExtensions = append(c.SourceExtensions, ".sql")
+		c.PromptAugmentationsFile = "augment.json"
+		os.WriteFile("augment.json", []byte(`{"SQL": "Explain every table and index."}`), 0644)
+	})
+	app := newTestApp()
+
+	files := map[string]string{
+		"main.go":       "package main\n",
+		"db/schema.sql": "CREATE TABLE users (id INT);\n",
+	}
+	jobID := upload(t, app, files, map[string]string{"format": "md"})
+	if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if !strings.Contains(formats["schema.sql"], "- Explain every table and index.") {
+		t.Errorf("schema.sql format lacks its augmentation:\n%s", formats["schema.sql"])
+	}
+	if strings.Contains(formats["main.go"], "Explain every table") {
+		t.Errorf("main.go got the SQL augmentation:\n%s", formats["main.go"])
+	}
+}
+
+func TestUploadLowConfidence(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("code_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply := map[string]any{"document": "# " + header.Filename + "\n\nDocs."}
+		switch header.Filename {
+		case "main.go":
+			reply["confidence"] = 0.3
+		case "util.go":
+			reply["confidence"] = 0.9
+		}
+		json.NewEncoder(w).Encode(reply)
+	}))
+	defer agent.Close()
+
+	setupTest(t, func(c *config.Config) {
+		c.Analyzer = "http"
+		c.AgentURL = agent.URL + "/analyze"
+		c.MinConfidence = 0.5
+	})
+	app := newTestApp()
+
+	files := map[string]string{
+		"main.go":  "package main\n",
+		"util.go":  "package main\n",
+		"other.py": "print(1)\n",
+	}
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if job.LowConfidence != 1 {
+		t.Errorf("low confidence count %d, want 1", job.LowConfidence)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	if !strings.Contains(doc, "# main.go\n\n> **Low confidence (0.30), review needed.**\n\nDocs.") {
+		t.Errorf("main.go isn't flagged for review:\n%s", doc)
+	}
+	// A confident score and no score at all are left alone
+	if strings.Count(doc, "review needed") != 1 {
+		t.Errorf("more than main.go flagged:\n%s", doc)
+	}
+}
+
+func TestUploadLowConfidenceBatch(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var names []string
+		var doc strings.Builder
+		for _, header := range r.MultipartForm.File["code_file"] {
+			names = append(names, header.Filename)
+			fmt.Fprintf(&doc, "# %s\n\nDocs.\n\n", header.Filename)
+		}
+		reply := map[string]any{"document": doc.String()}
+		switch names[0] {
+		case "a.go":
+			// One score for the batch doesn't say which file it is about
+			reply["confidence"] = 0.1
+		case "c.go":
+			reply["confidence"] = map[string]any{"d.go": 0.2}
+		}
+		json.NewEncoder(w).Encode(reply)
+	}))
+	defer agent.Close()
+
+	setupTest(t, func(c *config.Config) {
+		c.Analyzer = "http"
+		c.AgentURL = agent.URL + "/analyze"
+		c.MinConfidence = 0.5
+	})
+	app := newTestApp()
+
+	files := map[string]string{
+		"a.go": "package demo\n",
+		"b.go": "package demo\n",
+		"c.go": "package demo\n",
+		"d.go": "package demo\n",
+	}
+	jobID := upload(t, app, files, map[string]string{"format": "md", "batch_size": "2"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if job.LowConfidence != 1 {
+		t.Errorf("low confidence count %d, want only d.go", job.LowConfidence)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); strings.Contains(doc, "review needed") {
+		t.Errorf("a batch document was flagged for a score that isn't its first file's:\n%s", doc)
+	}
+	logs, _ := jobs.Logs(jobID)
+	var flagged []string
+	for _, entry := range logs {
+		if strings.HasPrefix(entry.Message, "Low confidence") {
+			flagged = append(flagged, entry.Message)
+		}
+	}
+	if !slices.Equal(flagged, []string{"Low confidence (0.20) for d.go, flagged for review"}) {
+		t.Errorf("flagged %q, want only d.go", flagged)
+	}
+}
+
+func TestTemplateKeyAugmentations(t *testing.T) {
+	setupTest(t, nil)
+	opts, _, err := newJobOptions(jobRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := opts.templateKey()
+
+	os.WriteFile("augment.json", []byte(`{"SQL": "Explain every table."}`), 0644)
+	if augmentations, err = services.LoadPromptAugmentations("augment.json"); err != nil {
+		t.Fatal(err)
+	}
+	if opts.templateKey() == plain {
+		t.Error("cached docs would be reused after the augmentations changed")
+	}
+}
+
+func TestCollectSourceFilesModifiedSince(t *testing.T) {
+	setupTest(t, nil)
+	root := t.TempDir()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"fresh.go": time.Hour,
+		"week.go":  6 * 24 * time.Hour,
+		"old.go":   90 * 24 * time.Hour,
+	} {
+		path := filepath.Join(root, name)
+		os.WriteFile(path, []byte("package main\n"), 0644)
+		os.Chtimes(path, now.Add(-age), now.Add(-age))
+	}
+
+	files, _, err := CollectSourceFiles(root, []string{".go"}, CollectOptions{ModifiedSince: now.AddDate(0, 0, -7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	if fmt.Sprint(names) != "[fresh.go week.go]" {
+		t.Errorf("collected %v, want fresh.go and week.go", names)
+	}
+}
+
+func TestUploadShebangScript(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.ExtractSourcesOnly = true })
+	app := newTestApp()
+	files := map[string]string{
+		"app/main.go":    "package main\n",
+		"app/bin/deploy": "#!/usr/bin/env python3\n\ndef deploy():\n    pass\n",
+		"app/bin/run":    "#!/usr/bin/perl\nprint 1;\n",
+		"app/LICENSE":    "MIT\n",
+	}
+
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	if !strings.Contains(doc, "Placeholder documentation for deploy (Python") || !strings.Contains(doc, "| Python | 1 |") {
+		t.Errorf("the python script wasn't analyzed:\n%s", doc)
+	}
+	if strings.Contains(doc, "# run\n") || strings.Contains(doc, "# LICENSE\n") {
+		t.Errorf("a file of no analyzable language was analyzed:\n%s", doc)
+	}
+}
+
+func TestUploadSkipGenerated(t *testing.T) {
+	setupTest(t, nil)
+	var mu sync.Mutex
+	var analyzed []string
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		mu.Lock()
+		analyzed = append(analyzed, filepath.Base(path))
+		mu.Unlock()
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+	files := map[string]string{
+		"main.go":   "package main\n\nfunc main() {}\n",
+		"api.pb.go": "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage main\n",
+	}
+
+	for _, tt := range []struct {
+		include string
+		want    string
+	}{
+		{"", "[main.go]"},
+		{"true", "[api.pb.go main.go]"},
+	} {
+		analyzed = nil
+		jobID := upload(t, app, files, map[string]string{"format": "md", "include_generated": tt.include})
+		if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+			t.Fatalf("job %s: %s", job.Status, job.Message)
+		}
+		sort.Strings(analyzed)
+		if got := fmt.Sprint(analyzed); got != tt.want {
+			t.Errorf("include_generated=%q analyzed %s, want %s", tt.include, got, tt.want)
+		}
+	}
+}
+
+func TestUploadModifiedWithin(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	// Extraction keeps the modification times stored in the archive
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, modified := range map[string]time.Time{
+		"recent.go": time.Now().Add(-time.Hour),
+		"stale.go":  time.Now().AddDate(-1, 0, 0),
+	} {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte("package main\n"))
+	}
+	w.Close()
+
+	resp, body := doRequest(t, app, uploadRequest(t, "project.zip", buf.Bytes(), map[string]string{"format": "md", "modified_within_days": "30"}))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+	}
+	var uploaded UploadResponse
+	json.Unmarshal(body, &uploaded)
+	job := waitJob(t, uploaded.JobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	if !strings.Contains(doc, "recent.go") || strings.Contains(doc, "stale.go") {
+		t.Errorf("document should cover recent.go only:\n%s", doc)
+	}
+}
+
+func TestUploadCorruptEntry(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.ExtractSkipCorrupt = true
+	})
+	app := newTestApp()
+
+	// Stored entries, so flipping a byte of content breaks the checksum
+	corruptZip := func(files map[string]string, corrupt ...string) []byte {
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		for name, content := range files {
+			f, _ := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+			f.Write([]byte(content))
+		}
+		w.Close()
+		data := buf.Bytes()
+		for _, name := range corrupt {
+			data = bytes.Replace(data, []byte(files[name]), bytes.ToUpper([]byte(files[name])), 1)
+		}
+		return data
+	}
+	files := map[string]string{
+		"main.go":   "package main\n\nfunc main() {}\n",
+		"broken.go": "package main // damaged\n",
+	}
+
+	resp, body := doRequest(t, app, uploadRequest(t, "project.zip", corruptZip(files, "broken.go"), map[string]string{"format": "md"}))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("upload returned %d: %s", resp.StatusCode, body)
+	}
+	var uploaded UploadResponse
+	json.Unmarshal(body, &uploaded)
+	job := waitJob(t, uploaded.JobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if len(job.SkippedEntries) != 1 || job.SkippedEntries[0].Name != "broken.go" {
+		t.Errorf("skipped entries %+v, want broken.go", job.SkippedEntries)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "main.go") {
+		t.Errorf("document doesn't cover main.go:\n%s", doc)
+	}
+
+	_, body = doRequest(t, app, uploadRequest(t, "project.zip", corruptZip(files, "main.go", "broken.go"), nil))
+	json.Unmarshal(body, &uploaded)
+	if job := waitJob(t, uploaded.JobID); job.Status != models.JobStatusFailed || !strings.Contains(job.Message, "no usable files") {
+		t.Errorf("all entries corrupt: job %s: %s", job.Status, job.Message)
+	}
+}
+
+func TestUploadAPISpec(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	files := map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+		"openapi.json": `{"openapi": "3.0.0", "servers": [{"url": "https://api.example.com"}],
+			"paths": {"/users": {"get": {"summary": "List users"}}}}`,
+	}
+	jobID := upload(t, app, files, map[string]string{"format": "md"})
+	job := waitJob(t, jobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{"## API Endpoints", "| GET | /users | List users |", `curl -X GET "https://api.example.com/users"`} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document is missing %q", want)
+		}
+	}
+}
+
+func TestUploadSchemaDocs(t *testing.T) {
+	setupTest(t, nil)
+	var templates sync.Map
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		templates.Store(filepath.Base(path), formatTemplate)
+		return "## Overview\nDocumented.\n", nil
+	})
+	app := newTestApp()
+	files := map[string]string{
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"schema.sql": "CREATE TABLE [dbo].[Order Items] ([Id] INT NOT NULL, PRIMARY KEY ([Id]));\n",
+		"migrations/001_note.sql": "ALTER TABLE [dbo].[Order Items] ADD [Note] TEXT;\n" +
+			"CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN RETURN NEW; END $$ LANGUAGE plpgsql;\n",
+	}
+
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	doc := readOutput(t, job.Outputs[0].Filename)
+	for _, want := range []string{"## Data Model / Schema", "| dbo.Order Items | 2 | schema.sql |", "| Note | TEXT |  |"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document is missing %q:\n%s", want, doc)
+		}
+	}
+	// Only the migration declaring a function goes to the agent, told to
+	// focus on the schema
+	if _, ok := templates.Load("schema.sql"); ok {
+		t.Error("tables-only schema.sql was sent to the agent")
+	}
+	if template, _ := templates.Load("001_note.sql"); template == nil || !strings.Contains(template.(string), services.SchemaInstruction) {
+		t.Errorf("migration template without the schema instruction:\n%v", template)
+	}
+	if template, _ := templates.Load("main.go"); strings.Contains(template.(string), services.SchemaInstruction) {
+		t.Error("main.go got the schema instruction")
+	}
+
+	setupTest(t, func(c *config.Config) { c.SchemaDocs = false })
+	app = newTestApp()
+	job = waitJob(t, upload(t, app, files, map[string]string{"format": "md"}))
+	if doc := readOutput(t, job.Outputs[0].Filename); strings.Contains(doc, "## Data Model / Schema") {
+		t.Error("SCHEMA_DOCS=false still documented the schema")
+	}
+}
+
+func TestUploadOpenAPIOutput(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	files := map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+		"swagger.json": `{"swagger": "2.0", "host": "api.example.com", "schemes": ["https"],
+			"paths": {"/users/{id}": {"get": {"operationId": "getUser", "summary": "Get a user"}}}}`,
+	}
+	job := waitJob(t, upload(t, app, files, map[string]string{"format": "md", "openapi": "yaml"}))
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	output := job.Outputs[0]
+	name := strings.TrimSuffix(output.Filename, ".md") + ".openapi.yaml"
+	if !slices.Contains(output.Artifacts, name) {
+		t.Fatalf("artifacts %v, want %s", output.Artifacts, name)
+	}
+	spec := readOutput(t, name)
+	for _, want := range []string{"openapi: \"3.0.3\"", "  \"/users/{id}\":", "operationId: \"getUser\"", "url: \"https://api.example.com\""} {
+		if !strings.Contains(spec, want) {
+			t.Errorf("spec is missing %q:\n%s", want, spec)
+		}
+	}
+
+	// A project without endpoints gets no spec
+	job = waitJob(t, upload(t, app, testProject, map[string]string{"format": "md", "openapi": "json"}))
+	if len(job.Outputs[0].Artifacts) != 0 {
+		t.Errorf("artifacts %v for a project without endpoints", job.Outputs[0].Artifacts)
+	}
+	entries, _ := jobs.Logs(job.ID)
+	if !slices.ContainsFunc(entries, func(e models.JobLogEntry) bool { return strings.Contains(e.Message, "skipping OpenAPI spec") }) {
+		t.Error("job log doesn't say the spec was skipped")
+	}
+
+	req := uploadRequest(t, "project.zip", testZip(t, testProject), map[string]string{"openapi": "xml"})
+	if resp, body := doRequest(t, app, req); resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != ErrCodeBadRequest {
+		t.Errorf("got %d %s, want 400 %s", resp.StatusCode, body, ErrCodeBadRequest)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/services"
+	"code-doc-tool/internal/utils"
+)
+
+type uploadURLRequest struct {
+	jobRequest
+	ArchiveURL string `json:"archive_url"`
+}
+
+// UploadFromURL downloads an archive from a remote URL and runs the normal
+// documentation pipeline over it.
+func UploadFromURL(c *fiber.Ctx) error {
+	var req uploadURLRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, "Invalid request body")
+	}
+	if req.ArchiveURL == "" {
+		return errorResponse(c, fiber.StatusBadRequest, ErrCodeBadRequest, "archive_url is required")
+	}
+
+	opts, code, err := newJobOptions(req.jobRequest)
+	if err != nil {
+		return errorResponse(c, fiber.StatusBadRequest, code, err.Error())
+	}
+	opts.TraceID = traceID(c)
+
+	downloader := &services.ArchiveDownloader{
+		MaxSize:      cfg.MaxFileSize,
+		Timeout:      cfg.ArchiveDownloadTimeout,
+		AllowPrivate: cfg.AllowPrivateArchiveURLs,
+	}
+	if _, err := downloader.ValidateURL(c.Context(), req.ArchiveURL); err != nil {
+		code := ErrCodeInvalidURL
+		if errors.Is(err, services.ErrPrivateAddress) {
+			code = ErrCodeForbiddenURL
+		}
+		return errorResponse(c, fiber.StatusBadRequest, code, err.Error())
+	}
+
+	if !acquireIntake(&opts) {
+		return busyResponse(c)
+	}
+	// The archive's size is unknown until it is downloaded, so only check
+	// there is room left at all
+	if !reserveDisk(&opts, 0) {
+		opts.doneIntake()
+		return quotaResponse(c)
+	}
+
+	jobID := uuid.New().String()
+	if opts.workDir, err = newWorkDir(jobID); err != nil {
+		opts.doneIntake()
+		return errorResponse(c, fiber.StatusInternalServerError, ErrCodeInternal, "Failed to create upload directory")
+	}
+
+	status := startJob(jobID, opts, func(ctx context.Context) {
+		jobs.Update(jobID, 0, "Downloading archive")
+		filePath, err := downloader.Download(ctx, req.ArchiveURL, opts.workDir)
+		if err != nil {
+			jobLogf(jobID, models.LogLevelError, "Failed to download archive: %v", err)
+			jobs.Fail(jobID, failureMessage(err, err.Error()))
+			utils.CleanupDir(opts.workDir)
+			opts.doneIntake()
+			return
+		}
+		saveResumeInfo(jobID, opts.workDir, req.jobRequest, filePath, filepath.Base(filePath))
+		processCodebase(ctx, jobID, filePath, filepath.Base(filePath), opts)
+	})
+
+	message := "Archive download started. Processing will follow."
+	if status == models.JobStatusQueued {
+		message = "Archive queued until processing resumes."
+	}
+	return c.JSON(UploadResponse{
+		JobID:   jobID,
+		Message: message,
+		Status:  status,
+	})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+)
+
+// urlRequest posts body as JSON to /api/upload-url.
+func urlRequest(body string) *http.Request {
+	req := httptest.NewRequest(fiber.MethodPost, "/api/upload-url", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return req
+}
+
+func TestUploadFromURL(t *testing.T) {
+	setupTest(t, func(c *config.Config) {
+		c.AllowPrivateArchiveURLs = true
+	})
+	app := newTestApp()
+	archive := testZip(t, testProject)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	resp, body := doRequest(t, app, urlRequest(`{"archive_url": "`+server.URL+`/app.zip", "format": "md"}`))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("got %d: %s", resp.StatusCode, body)
+	}
+	var uploaded UploadResponse
+	json.Unmarshal(body, &uploaded)
+	job := waitJob(t, uploaded.JobID)
+	if job.Status != models.JobStatusCompleted {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if doc := readOutput(t, job.Outputs[0].Filename); !strings.Contains(doc, "util.go") {
+		t.Errorf("document doesn't cover the downloaded archive:\n%s", doc)
+	}
+}
+
+func TestUploadFromURLRejected(t *testing.T) {
+	setupTest(t, nil)
+	app := newTestApp()
+
+	tests := []struct {
+		body string
+		code string
+	}{
+		{`{}`, ErrCodeBadRequest},
+		{`{"archive_url": "file:///etc/passwd"}`, ErrCodeInvalidURL},
+		{`{"archive_url": "http://127.0.0.1:1/app.zip"}`, ErrCodeForbiddenURL},
+	}
+	for _, tt := range tests {
+		resp, body := doRequest(t, app, urlRequest(tt.body))
+		if resp.StatusCode != fiber.StatusBadRequest || errorCode(t, body) != tt.code {
+			t.Errorf("%s: got %d %s, want %s", tt.body, resp.StatusCode, body, tt.code)
+		}
+	}
+}
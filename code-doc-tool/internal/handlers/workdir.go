@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+
+	"code-doc-tool/internal/utils"
+)
+
+// newWorkDir creates a job's own directory under the upload path. Its
+// upload, extracted tree and intermediate files all live there, and the
+// random suffix keeps it apart from every other job's, so concurrent jobs
+// never touch each other's files. It goes away when the job ends.
+func newWorkDir(jobID string) (string, error) {
+	if err := utils.CreateDir(cfg.UploadPath); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(cfg.UploadPath, jobID+"-")
+}
+
+// jobWorkDir returns the working directory recorded for a job. Jobs saved
+// before directories were recorded used one named after the job.
+func jobWorkDir(jobID string) string {
+	if job, ok := jobs.Get(jobID); ok && job.WorkDir != "" {
+		return job.WorkDir
+	}
+	return filepath.Join(cfg.UploadPath, jobID)
+}
+
+// findWorkDir looks for a job's working directory on disk, for jobs the
+// store doesn't know.
+func findWorkDir(jobID string) (string, bool) {
+	matches, _ := filepath.Glob(filepath.Join(cfg.UploadPath, jobID+"-*"))
+	if len(matches) == 0 {
+		return "", false
+	}
+	return matches[0], true
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/models"
+)
+
+func TestConcurrentWorkDirs(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.UploadPath = "./scratch" })
+	var started sync.WaitGroup
+	started.Add(2)
+	release := make(chan struct{})
+	analyzer = funcAnalyzer(func(ctx context.Context, path, formatTemplate string) (string, error) {
+		if filepath.Base(path) == "main.go" {
+			started.Done()
+			<-release
+		}
+		return "# " + filepath.Base(path) + "\n", nil
+	})
+	app := newTestApp()
+
+	// Two jobs are in flight together
+	var ids [2]string
+	for i := range ids {
+		ids[i] = upload(t, app, testProject, map[string]string{"format": "md"})
+	}
+	started.Wait()
+
+	var dirs []string
+	for _, jobID := range ids {
+		job, _ := jobs.Get(jobID)
+		if filepath.Dir(job.WorkDir) != "scratch" {
+			t.Errorf("job %s works in %q, want a directory under UPLOAD_PATH", jobID, job.WorkDir)
+		}
+		if _, err := os.Stat(filepath.Join(job.WorkDir, "extracted", "main.go")); err != nil {
+			t.Errorf("job %s's sources aren't in its directory: %v", jobID, err)
+		}
+		if dir, ok := findWorkDir(jobID); !ok || dir != filepath.Clean(job.WorkDir) {
+			t.Errorf("findWorkDir(%s) = %q, %v", jobID, dir, ok)
+		}
+		dirs = append(dirs, job.WorkDir)
+	}
+	if dirs[0] == dirs[1] {
+		t.Fatalf("both jobs work in %s", dirs[0])
+	}
+
+	close(release)
+	for i, jobID := range ids {
+		if job := waitJob(t, jobID); job.Status != models.JobStatusCompleted {
+			t.Errorf("job %s: %s", job.Status, job.Message)
+		}
+		if _, err := os.Stat(dirs[i]); !os.IsNotExist(err) {
+			t.Errorf("working directory %s left behind: %v", dirs[i], err)
+		}
+	}
+}
+
+func TestNewWorkDir(t *testing.T) {
+	setupTest(t, func(c *config.Config) { c.UploadPath = filepath.Join("nested", "uploads") })
+	const jobID = "33333333-3333-4333-8333-333333333333"
+	first, err := newWorkDir(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newWorkDir(jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("the same directory %s twice", first)
+	}
+	for _, dir := range []string{first, second} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Errorf("%s not created: %v", dir, err)
+		}
+	}
+
+	// Records from before directories were recorded fall back to the ID
+	if got := jobWorkDir(jobID); got != filepath.Join("nested", "uploads", jobID) {
+		t.Errorf("jobWorkDir of an unknown job = %s", got)
+	}
+}
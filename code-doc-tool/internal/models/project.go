@@ -11,6 +11,30 @@ type APIEndpoint struct {
 	CurlExample string   `json:"curl_example"`
 }
 
+// SchemaTable is a table as a project's SQL schema and migrations leave
+// it.
+type SchemaTable struct {
+	Name        string         `json:"name"`
+	Columns     []SchemaColumn `json:"columns"`
+	Constraints []string       `json:"constraints,omitempty"`
+	Source      string         `json:"source"`
+}
+
+type SchemaColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Constraints string `json:"constraints,omitempty"`
+}
+
+// Project types assigned by the classifier.
+const (
+	ProjectTypeService  = "service"
+	ProjectTypeLibrary  = "library"
+	ProjectTypeCLI      = "cli"
+	ProjectTypeFrontend = "frontend"
+	ProjectTypeUnknown  = "unknown"
+)
+
 type Project struct {
 	Name              string            `json:"name"`
 	Type              string            `json:"type"`
@@ -21,6 +45,7 @@ type Project struct {
 	FolderStructure   map[string]string `json:"folder_structure"`
 	SetupInstructions []string          `json:"setup_instructions"`
 	APIEndpoints      []APIEndpoint     `json:"api_endpoints"`
+	Schema            []SchemaTable     `json:"schema,omitempty"`
 	ParsersInfo       map[string]string `json:"parsers_info"`
 	DataFlow          string            `json:"data_flow"`
 	ExternalServices  []string          `json:"external_services"`
@@ -28,6 +53,7 @@ type Project struct {
 	FutureRoadmap     []string          `json:"future_roadmap"`
 	CommonIssues      []string          `json:"common_issues"`
 	DeveloperNotes    []string          `json:"developer_notes"`
+	Languages         []LanguageStat    `json:"languages,omitempty"`
 
 	Dependencies map[string][]Dependency `json:"dependencies"`
 	Files        []FileInfo              `json:"files"`
@@ -46,9 +72,51 @@ type FileInfo struct {
 	Path      string `json:"path"`
 	Extension string `json:"extension"`
 	Size      int64  `json:"size"`
+	Lines     int    `json:"lines"`
 	Language  string `json:"language"`
 }
 
+// JobStats aggregates every job a node has seen. Rates and the average
+// duration cover finished jobs only.
+type JobStats struct {
+	Total                  int             `json:"total_jobs"`
+	Processing             int             `json:"processing"`
+	Queued                 int             `json:"queued"`
+	Completed              int             `json:"completed"`
+	CompletedWithFallback  int             `json:"completed_with_fallback"`
+	Failed                 int             `json:"failed"`
+	SuccessRate            float64         `json:"success_rate"`
+	FailureRate            float64         `json:"failure_rate"`
+	AverageDurationSeconds float64         `json:"average_duration_seconds"`
+	FilesAnalyzed          int             `json:"files_analyzed"`
+	Languages              []LanguageCount `json:"top_languages"`
+
+	// Paused is set while new jobs are held queued; see the admin
+	// pause endpoint
+	Paused bool `json:"paused"`
+}
+
+// LanguageCount is the number of files analyzed in one language.
+type LanguageCount struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+}
+
+type LanguageStat struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Lines    int    `json:"lines"`
+}
+
+// Symbol is a declaration listed in the document index.
+type Symbol struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Exported bool   `json:"exported"`
+}
+
 type DirectoryNode struct {
 	Name     string          `json:"name"`
 	Path     string          `json:"path"`
@@ -57,11 +125,139 @@ type DirectoryNode struct {
 	Children []DirectoryNode `json:"children,omitempty"`
 }
 
+const (
+	JobStatusProcessing = "processing"
+	JobStatusQueued     = "queued"
+	JobStatusCompleted  = "completed"
+	JobStatusFailed     = "failed"
+
+	// JobStatusCompletedWithFallback means the requested format couldn't be
+	// produced and markdown was delivered instead
+	JobStatusCompletedWithFallback = "completed_with_fallback"
+)
+
+// JobActive reports whether a job with status has yet to finish: it is
+// processing or queued waiting for processing to resume.
+func JobActive(status string) bool {
+	return status == JobStatusProcessing || status == JobStatusQueued
+}
+
+const (
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// Kinds of job event sent on the live stream.
+const (
+	JobEventProgress = "progress"
+	JobEventFile     = "file"
+	JobEventDone     = "done"
+)
+
+// JobEvent is one entry of a job's live stream: a progress update, a file
+// whose analysis finished, or the job finishing, which ends the stream.
+type JobEvent struct {
+	Seq      int       `json:"seq"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Progress int       `json:"progress,omitempty"`
+	Message  string    `json:"message,omitempty"`
+
+	// File events carry the path relative to the project root and whether
+	// it was documented; Status is the job's own on the done event
+	File   string `json:"file,omitempty"`
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type JobLogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+type JobOutput struct {
+	Project  string `json:"project"`
+	Type     string `json:"type"`
+	Filename string `json:"filename"`
+
+	// Artifacts are the same document in the job's additional formats
+	Artifacts []string `json:"artifacts,omitempty"`
+
+	// Digests maps Filename and each artifact to the hex SHA-256 of its
+	// contents, taken when it was generated
+	Digests map[string]string `json:"digests,omitempty"`
+
+	// Files lists the analyzed files when the job asked for a file table
+	Files []FileInfo `json:"files,omitempty"`
+}
+
+// DeadLetter records a file that could not be documented after every
+// retry was used up.
+type DeadLetter struct {
+	Project  string `json:"project"`
+	Path     string `json:"path"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+
+	// TimedOut is set when the file ran out of its analysis budget
+	TimedOut bool `json:"timed_out,omitempty"`
+
+	History []AttemptError `json:"history,omitempty"`
+}
+
+// AttemptError records one failed analysis attempt. Kind is a coarse
+// category such as timeout, server_error or parse_error.
+type AttemptError struct {
+	Attempt int       `json:"attempt"`
+	Kind    string    `json:"kind"`
+	Error   string    `json:"error"`
+	At      time.Time `json:"at"`
+}
+
+// RetriedFile is a file whose analysis succeeded after failed attempts.
+type RetriedFile struct {
+	Project string         `json:"project"`
+	Path    string         `json:"path"`
+	History []AttemptError `json:"history"`
+}
+
+// SkippedEntry is an archive entry that couldn't be extracted.
+type SkippedEntry struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
 type Job struct {
 	ID        string    `json:"id"`
 	Status    string    `json:"status"`
 	Progress  int       `json:"progress"`
 	Message   string    `json:"message"`
+	Format    string    `json:"format"`
+	TraceID   string    `json:"trace_id,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectType string         `json:"project_type,omitempty"`
+	Languages   []LanguageStat `json:"languages,omitempty"`
+	Outputs     []JobOutput    `json:"outputs,omitempty"`
+	DeadLetters []DeadLetter   `json:"dead_letters,omitempty"`
+	Redactions  int            `json:"redactions,omitempty"`
+
+	// LowConfidence counts sections flagged for review because the agent
+	// scored them below MIN_CONFIDENCE
+	LowConfidence int `json:"low_confidence,omitempty"`
+
+	RetriedFiles []RetriedFile `json:"retried_files,omitempty"`
+
+	SkippedEntries []SkippedEntry `json:"skipped_entries,omitempty"`
+
+	// WorkDir is the job's own directory for its upload, extracted tree
+	// and intermediate files
+	WorkDir string `json:"work_dir,omitempty"`
+
+	// Attempts counts the runs of the job, more than one when transient
+	// failures were retried
+	Attempts int `json:"attempts,omitempty"`
 }
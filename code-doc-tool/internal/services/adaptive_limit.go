@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// adaptiveErrorRate is the share of failed calls in a window above which
+// the limit backs off.
+const adaptiveErrorRate = 0.1
+
+// AdaptiveLimiter bounds concurrent agent calls with a limit that adjusts
+// itself: once per window of as many calls as the current limit, it grows
+// by one while calls stay under the latency target and rarely fail, and
+// shrinks by a quarter when they don't. It only grows when the window
+// actually used the whole limit, and stays within min and max.
+type AdaptiveLimiter struct {
+	min, max int
+	target   time.Duration
+
+	mu       sync.Mutex
+	limit    int
+	inflight int
+	wake     chan struct{}
+
+	// The current window
+	calls     int
+	failures  int
+	latency   time.Duration
+	saturated bool
+}
+
+// NewAdaptiveLimiter returns a limiter between lo and hi concurrent calls,
+// starting at initial, that aims to keep calls under target on average.
+func NewAdaptiveLimiter(lo, hi, initial int, target time.Duration) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		min:    lo,
+		max:    hi,
+		target: target,
+		limit:  max(lo, min(initial, hi)),
+		wake:   make(chan struct{}),
+	}
+}
+
+// Acquire waits for a call to be allowed under the current limit, giving
+// up if ctx is cancelled first. Every successful Acquire must be followed
+// by Release.
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inflight < l.limit {
+			l.inflight++
+			l.saturated = l.saturated || l.inflight == l.limit
+			l.mu.Unlock()
+			return nil
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release ends a unit of work started by Acquire.
+func (l *AdaptiveLimiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	l.notify()
+}
+
+// Observe records an agent call that took latency and ended with err. It
+// is fed each call on its own, not the retries, backoff and queueing
+// around it, so only the agent's own responsiveness moves the limit. Only
+// errors pointing at an overloaded or unreachable agent count as
+// failures, and a cancelled call isn't counted at all.
+func (l *AdaptiveLimiter) Observe(latency time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch ClassifyAgentError(err) {
+	case "cancelled":
+		return
+	case "timeout", "server_error", "rate_limited", "connection_error":
+		l.failures++
+	}
+	l.calls++
+	l.latency += latency
+	if l.calls < l.limit {
+		return
+	}
+
+	overloaded := float64(l.failures) > adaptiveErrorRate*float64(l.calls) ||
+		l.latency/time.Duration(l.calls) > l.target
+	limit := l.limit
+	if overloaded {
+		l.limit = max(l.min, min(l.limit-1, l.limit*3/4))
+	} else if l.saturated {
+		l.limit = min(l.max, l.limit+1)
+	}
+	l.calls, l.failures, l.latency, l.saturated = 0, 0, 0, l.inflight >= l.limit
+	if l.limit > limit {
+		l.notify()
+	}
+}
+
+// notify wakes callers waiting in Acquire; l.mu must be held.
+func (l *AdaptiveLimiter) notify() {
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// Limit returns the current concurrency limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
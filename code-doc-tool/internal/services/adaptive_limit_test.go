@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// runWindow acquires the limiter's whole limit and observes a call of
+// latency for each slot, like a window of saturated agent calls.
+func runWindow(t *testing.T, l *AdaptiveLimiter, latency time.Duration, err error) {
+	t.Helper()
+	n := l.Limit()
+	for range n {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range n {
+		l.Observe(latency, err)
+	}
+	for range n {
+		l.Release()
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := NewAdaptiveLimiter(1, 6, 4, 100*time.Millisecond)
+
+	// Slow calls back off by a quarter each window, down to the minimum
+	for _, want := range []int{3, 2, 1, 1} {
+		runWindow(t, l, time.Second, nil)
+		if got := l.Limit(); got != want {
+			t.Fatalf("limit %d after a slow window, want %d", got, want)
+		}
+	}
+
+	// Fast, saturated calls recover one at a time, up to the maximum
+	for _, want := range []int{2, 3, 4, 5, 6, 6} {
+		runWindow(t, l, time.Millisecond, nil)
+		if got := l.Limit(); got != want {
+			t.Fatalf("limit %d after a fast window, want %d", got, want)
+		}
+	}
+
+	// Fast calls that fail for an overloaded agent still back off
+	runWindow(t, l, time.Millisecond, &AgentStatusError{StatusCode: 503})
+	if got := l.Limit(); got != 4 {
+		t.Errorf("limit %d after failing calls, want 4", got)
+	}
+}
+
+func TestAdaptiveLimiterUnsaturated(t *testing.T) {
+	l := NewAdaptiveLimiter(1, 6, 2, 100*time.Millisecond)
+
+	// One call at a time never shows the limit is too low
+	for range 4 {
+		l.Acquire(context.Background())
+		l.Observe(time.Millisecond, nil)
+		l.Release()
+	}
+	if got := l.Limit(); got != 2 {
+		t.Errorf("limit %d, want 2 while the limit isn't used", got)
+	}
+
+	// Cancelled calls say nothing about the agent
+	for range 4 {
+		l.Observe(time.Second, context.Canceled)
+	}
+	if got := l.Limit(); got != 2 {
+		t.Errorf("limit %d after cancelled calls, want 2", got)
+	}
+}
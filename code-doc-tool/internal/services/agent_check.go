@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code-doc-tool/internal/config"
+)
+
+// agentProbe is the tiny known file sent by CheckAgent.
+const agentProbe = `def greet(name):
+    """Return a greeting for name."""
+    return "Hello, " + name
+`
+
+// CheckAgent sends agentProbe to the configured agent and verifies it
+// answers with a non-empty {"document": ...} body.
+func CheckAgent(ctx context.Context, cfg *config.Config) error {
+	dir, err := os.MkdirTemp("", "agent-check-")
+	if err != nil {
+		return fmt.Errorf("failed to create probe file: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	probe := filepath.Join(dir, "probe.py")
+	if err := os.WriteFile(probe, []byte(agentProbe), 0644); err != nil {
+		return fmt.Errorf("failed to create probe file: %w", err)
+	}
+
+	sections, _ := SelectSections([]string{"overview"})
+	doc, err := AnalyzeProject(ctx, cfg, probe, BuildFormatTemplate(sections))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(doc) == "" {
+		return fmt.Errorf("agent response has no document")
+	}
+	return nil
+}
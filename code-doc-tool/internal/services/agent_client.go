@@ -0,0 +1,42 @@
+package services
+
+import (
+	"net/http"
+	"time"
+)
+
+// AgentPool sizes the pool of keep-alive connections to the agent.
+type AgentPool struct {
+	// MaxIdleConns caps idle connections overall, MaxIdleConnsPerHost
+	// those kept to the agent; the latter should cover the number of
+	// concurrent calls or connections are closed and redialled under load
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout closes connections left idle this long
+	IdleConnTimeout time.Duration
+}
+
+// agentClient is shared by every agent call so connections are reused.
+var agentClient = NewAgentClient(AgentPool{MaxIdleConns: 100, MaxIdleConnsPerHost: 16, IdleConnTimeout: 90 * time.Second})
+
+// SetAgentPool replaces the client used for agent calls with one pooling
+// connections as configured. Connections of the previous client are
+// closed once idle.
+func SetAgentPool(pool AgentPool) {
+	previous := agentClient
+	agentClient = NewAgentClient(pool)
+	previous.CloseIdleConnections()
+}
+
+// NewAgentClient returns a client keeping connections alive per pool,
+// otherwise set up like http.DefaultTransport (proxies, dial and TLS
+// timeouts, HTTP/2).
+func NewAgentClient(pool AgentPool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = pool.MaxIdleConns
+	transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = pool.IdleConnTimeout
+	transport.DisableKeepAlives = false
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code-doc-tool/internal/config"
+)
+
+// countingAgent serves the analyze agent, holding each call until calls
+// of them are in flight, and counts the connections opened to it.
+func countingAgent(t *testing.T, calls int) (*config.Config, *atomic.Int32) {
+	t.Helper()
+	var opened atomic.Int32
+	var mu sync.Mutex
+	waiting, release := 0, make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if waiting++; waiting == calls {
+			close(release)
+			waiting, release = 0, make(chan struct{})
+			mu.Unlock()
+		} else {
+			wait := release
+			mu.Unlock()
+			<-wait
+		}
+		replyDocument(w, "doc")
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	cfg := config.New()
+	cfg.AgentURL = server.URL + "/analyze"
+	return cfg, &opened
+}
+
+// analyzeRounds makes rounds of n concurrent agent calls.
+func analyzeRounds(t *testing.T, cfg *config.Config, file string, rounds, n int) {
+	t.Helper()
+	for range rounds {
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := AnalyzeProject(context.Background(), cfg, file, "tpl"); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		// Let the finished calls hand their connections back
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestAgentConnectionReuse(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+	file := filepath.Join(dir, "main.go")
+	t.Cleanup(func() {
+		SetAgentPool(AgentPool{MaxIdleConns: 100, MaxIdleConnsPerHost: 16, IdleConnTimeout: 90 * time.Second})
+	})
+
+	SetAgentPool(AgentPool{MaxIdleConns: 100, MaxIdleConnsPerHost: 4, IdleConnTimeout: time.Minute})
+	cfg, opened := countingAgent(t, 4)
+	analyzeRounds(t, cfg, file, 5, 4)
+	if n := opened.Load(); n != 4 {
+		t.Errorf("%d connections for 5 rounds of 4 calls, want 4 reused", n)
+	}
+
+	// A pool smaller than the concurrency redials the difference each round
+	SetAgentPool(AgentPool{MaxIdleConns: 100, MaxIdleConnsPerHost: 1, IdleConnTimeout: time.Minute})
+	cfg, opened = countingAgent(t, 4)
+	analyzeRounds(t, cfg, file, 5, 4)
+	if n := opened.Load(); n != 4+4*3 {
+		t.Errorf("%d connections with one idle connection kept, want 16", n)
+	}
+}
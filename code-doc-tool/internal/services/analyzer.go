@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"code-doc-tool/internal/config"
+)
+
+// Analyzer produces the markdown documentation for one source file,
+// following the sections laid out in formatTemplate.
+type Analyzer interface {
+	Analyze(ctx context.Context, path, formatTemplate string) (string, error)
+}
+
+// BatchAnalyzer is implemented by analyzers that can document several
+// files in one call, producing a single document covering all of them.
+type BatchAnalyzer interface {
+	AnalyzeBatch(ctx context.Context, paths []string, formatTemplate string) (string, error)
+}
+
+// Analyzer implementations selectable through ANALYZER.
+const (
+	AnalyzerHTTP = "http"
+	AnalyzerStub = "stub"
+)
+
+// NewAnalyzer returns the analyzer named by cfg.Analyzer.
+func NewAnalyzer(cfg *config.Config) (Analyzer, error) {
+	switch strings.ToLower(cfg.Analyzer) {
+	case "", AnalyzerHTTP:
+		return &HTTPAnalyzer{cfg: cfg}, nil
+	case AnalyzerStub:
+		return StubAnalyzer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown analyzer: %s", cfg.Analyzer)
+	}
+}
+
+// HTTPAnalyzer calls the agent at cfg.AgentURL.
+type HTTPAnalyzer struct {
+	cfg *config.Config
+}
+
+func (a *HTTPAnalyzer) Analyze(ctx context.Context, path, formatTemplate string) (string, error) {
+	return AnalyzeProject(ctx, a.cfg, path, formatTemplate)
+}
+
+func (a *HTTPAnalyzer) AnalyzeBatch(ctx context.Context, paths []string, formatTemplate string) (string, error) {
+	return AnalyzeFiles(ctx, a.cfg, paths, formatTemplate)
+}
+
+// StubAnalyzer returns deterministic placeholder docs without any network
+// access, for offline demos and tests.
+type StubAnalyzer struct{}
+
+func (StubAnalyzer) Analyze(ctx context.Context, path, formatTemplate string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	lines, err := CountLines(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read code file: %w", err)
+	}
+
+	name := filepath.Base(path)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", name)
+	fmt.Fprintf(&b, "Placeholder documentation for %s (%s, %d lines), generated offline.\n", name, DetectLanguage(path), lines)
+
+	// Mirror the requested section headings so the layout matches a real run
+	for _, line := range strings.Split(formatTemplate, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			fmt.Fprintf(&b, "\n%s\n\nNot available in offline mode.\n", line)
+		}
+	}
+	return b.String(), nil
+}
+
+func (s StubAnalyzer) AnalyzeBatch(ctx context.Context, paths []string, formatTemplate string) (string, error) {
+	docs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		doc, err := s.Analyze(ctx, path, formatTemplate)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc)
+	}
+	return strings.Join(docs, "\n"), nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code-doc-tool/internal/config"
+)
+
+func TestNewAnalyzer(t *testing.T) {
+	cfg := config.New()
+	for name, want := range map[string]string{
+		"":     "*services.HTTPAnalyzer",
+		"HTTP": "*services.HTTPAnalyzer",
+		"stub": "services.StubAnalyzer",
+	} {
+		cfg.Analyzer = name
+		a, err := NewAnalyzer(cfg)
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if got := fmt.Sprintf("%T", a); got != want {
+			t.Errorf("%q: got %s, want %s", name, got, want)
+		}
+	}
+	cfg.Analyzer = "magic"
+	if _, err := NewAnalyzer(cfg); err == nil {
+		t.Error("unknown analyzer accepted")
+	}
+}
+
+func TestStubAnalyzer(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	path := filepath.Join(dir, "main.go")
+
+	var stub StubAnalyzer
+	doc, err := stub.Analyze(context.Background(), path, "# Docs\n## 1. Overview\n- purpose\n## 5. APIs\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# main.go", "(Go, 3 lines)", "## 1. Overview", "## 5. APIs"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("stub doc is missing %q:\n%s", want, doc)
+		}
+	}
+	again, _ := stub.Analyze(context.Background(), path, "# Docs\n## 1. Overview\n- purpose\n## 5. APIs\n")
+	if again != doc {
+		t.Error("stub output isn't deterministic")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := stub.Analyze(ctx, path, ""); err == nil {
+		t.Error("cancelled call succeeded")
+	}
+	if _, err := stub.Analyze(context.Background(), filepath.Join(dir, "missing.go"), ""); err == nil {
+		t.Error("missing file succeeded")
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"code-doc-tool/internal/utils"
+)
+
+var (
+	ErrPrivateAddress  = errors.New("archive URL resolves to a private or internal address")
+	ErrArchiveTooLarge = errors.New("archive exceeds the maximum upload size")
+)
+
+// Ranges that aren't covered by the net.IP helpers but are still internal.
+// The NAT64 prefixes reach IPv4 hosts, private ones included, through a
+// translator.
+var blockedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "64:ff9b::/96", "64:ff9b:1::/48"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+func isPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+type ArchiveDownloader struct {
+	MaxSize      int64
+	Timeout      time.Duration
+	AllowPrivate bool
+}
+
+// ValidateURL checks the scheme and that the host resolves only to public
+// addresses. The dialer re-checks at connect time to defeat DNS rebinding.
+func (d *ArchiveDownloader) ValidateURL(ctx context.Context, rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("archive URL must be an absolute http(s) URL")
+	}
+	if d.AllowPrivate {
+		return u, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve archive host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return nil, ErrPrivateAddress
+		}
+	}
+	return u, nil
+}
+
+func (d *ArchiveDownloader) client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if d.AllowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicIP(net.ParseIP(host)) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+	// Through a proxy the dialer would only see the proxy's address, so
+	// the check above holds only for direct connections
+	var proxy func(*http.Request) (*url.URL, error)
+	if d.AllowPrivate {
+		proxy = http.ProxyFromEnvironment
+	}
+	return &http.Client{
+		Timeout: d.Timeout,
+		Transport: &http.Transport{
+			Proxy:               proxy,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Download fetches rawURL into destDir, verifies it is an archive by its
+// magic bytes and returns the saved path with the matching extension.
+func (d *ArchiveDownloader) Download(ctx context.Context, rawURL, destDir string) (string, error) {
+	u, err := d.ValidateURL(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download archive: HTTP %d", resp.StatusCode)
+	}
+	if d.MaxSize > 0 && resp.ContentLength > d.MaxSize {
+		return "", ErrArchiveTooLarge
+	}
+
+	tmpPath := filepath.Join(destDir, "download.tmp")
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return "", err
+	}
+
+	var body io.Reader = resp.Body
+	if d.MaxSize > 0 {
+		body = io.LimitReader(resp.Body, d.MaxSize+1)
+	}
+	written, err := io.Copy(out, body)
+	out.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to download archive: %w", utils.WrapDiskFull(err))
+	}
+	if d.MaxSize > 0 && written > d.MaxSize {
+		os.Remove(tmpPath)
+		return "", ErrArchiveTooLarge
+	}
+
+	ext, err := utils.DetectArchiveFormat(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	archivePath := filepath.Join(destDir, "archive"+ext)
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		return "", err
+	}
+	return archivePath, nil
+}
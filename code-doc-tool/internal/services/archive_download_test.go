@@ -0,0 +1,91 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":            true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"10.1.2.3":           false,
+		"192.168.0.10":       false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"::1":                false,
+		"fe80::1":            false,
+		"64:ff9b::a9fe:a9fe": false,
+		"64:ff9b:1::a00:1":   false,
+	} {
+		if got := isPublicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func testZipBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, _ := w.Create("main.go")
+	f.Write([]byte("package main\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveDownload(t *testing.T) {
+	archive := testZipBytes(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.zip":
+			w.Write(archive)
+		case "/readme.txt":
+			w.Write([]byte("not an archive"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	d := &ArchiveDownloader{MaxSize: 1 << 20, AllowPrivate: true}
+	path, err := d.Download(ctx, server.URL+"/app.zip", t.TempDir())
+	if err != nil || filepath.Base(path) != "archive.zip" {
+		t.Fatalf("got %q, %v", path, err)
+	}
+
+	if _, err := d.Download(ctx, server.URL+"/readme.txt", t.TempDir()); err == nil {
+		t.Error("a non-archive was accepted")
+	}
+	if _, err := d.Download(ctx, server.URL+"/missing.zip", t.TempDir()); err == nil {
+		t.Error("a 404 was accepted")
+	}
+
+	small := &ArchiveDownloader{MaxSize: int64(len(archive) - 1), AllowPrivate: true}
+	if _, err := small.Download(ctx, server.URL+"/app.zip", t.TempDir()); !errors.Is(err, ErrArchiveTooLarge) {
+		t.Errorf("got %v, want ErrArchiveTooLarge", err)
+	}
+
+	strict := &ArchiveDownloader{MaxSize: 1 << 20}
+	if _, err := strict.Download(ctx, server.URL+"/app.zip", t.TempDir()); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("got %v, want ErrPrivateAddress for a loopback server", err)
+	}
+	if strict.client().Transport.(*http.Transport).Proxy != nil {
+		t.Error("checked downloads go through HTTP(S)_PROXY, where the dialer only sees the proxy")
+	}
+	if _, err := strict.ValidateURL(ctx, "ftp://example.com/app.zip"); err == nil {
+		t.Error("an ftp URL was accepted")
+	}
+}
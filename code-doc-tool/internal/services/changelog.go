@@ -0,0 +1,99 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxChangelogCommits bounds how many commits a changelog may list.
+const MaxChangelogCommits = 500
+
+// Commit is one entry of a project's history.
+type Commit struct {
+	Hash    string
+	Author  string
+	Date    time.Time
+	Subject string
+}
+
+// FindGitDir returns the .git directory of the first of dirs that has one.
+// Archives often wrap everything in one top-level folder, so a directory
+// holding nothing but a single subdirectory is looked through.
+func FindGitDir(dirs ...string) (string, bool) {
+	for _, dir := range dirs {
+		for {
+			gitDir := filepath.Join(dir, ".git")
+			if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+				return gitDir, true
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+				break
+			}
+			dir = filepath.Join(dir, entries[0].Name())
+		}
+	}
+	return "", false
+}
+
+// RecentCommits lists the last n commits of the repository at gitDir. The
+// directory is passed explicitly so git never discovers a repository
+// above the upload. Its config comes from the upload and can't be
+// trusted, so system and global config are ignored and every setting
+// that would make git log run a program (signature checks, pagers,
+// external diffs, fsmonitor hooks) is overridden or disabled.
+func RecentCommits(ctx context.Context, gitDir string, n int) ([]Commit, error) {
+	cmd := exec.CommandContext(ctx, "git", "--git-dir="+gitDir, "--no-pager",
+		"-c", "core.fsmonitor=false",
+		"-c", "core.pager=cat",
+		"-c", "gpg.program=true",
+		"-c", "gpg.ssh.program=true",
+		"-c", "gpg.x509.program=true",
+		"log", "--no-color", "--no-show-signature", "--no-ext-diff", "--no-textconv", "--no-mailmap",
+		fmt.Sprintf("--max-count=%d", n), "--format=%h%x1f%an%x1f%aI%x1f%s")
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_PAGER=cat",
+		"GIT_TERMINAL_PROMPT=0",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		commits = append(commits, Commit{Hash: fields[0], Author: fields[1], Date: date, Subject: fields[3]})
+	}
+	return commits, nil
+}
+
+// RenderChangelog renders commits as a "Recent Changes" table.
+func RenderChangelog(commits []Commit) string {
+	var b strings.Builder
+	b.WriteString("## Recent Changes\n\n")
+	b.WriteString("| Date | Author | Commit | Subject |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, c := range commits {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", c.Date.Format(time.DateOnly), escapeCell(c.Author), c.Hash, escapeCell(c.Subject))
+	}
+	return b.String()
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRepo creates a repository in a temporary directory and returns a
+// function running git commands in it.
+func gitRepo(t *testing.T) (string, func(env []string, args ...string)) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(env []string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull,
+			"GIT_COMMITTER_NAME=ci", "GIT_COMMITTER_EMAIL=ci@example.com")
+		cmd.Env = append(cmd.Env, env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git(nil, "init", "-q")
+	return dir, git
+}
+
+// commit records a commit by author on date that rewrites log.txt.
+func commit(t *testing.T, dir string, git func(env []string, args ...string), author, date, subject string, extra ...string) {
+	t.Helper()
+	writeFiles(t, dir, map[string]string{"log.txt": subject + "\n"})
+	git(nil, "add", "log.txt")
+	args := append([]string{"commit", "-q", "-m", subject}, extra...)
+	git([]string{"GIT_AUTHOR_NAME=" + author, "GIT_AUTHOR_EMAIL=dev@example.com", "GIT_AUTHOR_DATE=" + date}, args...)
+}
+
+func TestRecentCommits(t *testing.T) {
+	dir, git := gitRepo(t)
+	commit(t, dir, git, "Ann", "2024-01-02T10:00:00Z", "Initial commit")
+	commit(t, dir, git, "Bob", "2024-02-03T10:00:00Z", "Add parser")
+	commit(t, dir, git, "Cy", "2024-03-04T10:00:00Z", "Fix a|b handling")
+
+	commits, err := RecentCommits(context.Background(), filepath.Join(dir, ".git"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].Author != "Cy" || commits[1].Subject != "Add parser" {
+		t.Fatalf("commits %+v, want the last two newest first", commits)
+	}
+
+	changelog := RenderChangelog(commits)
+	for _, want := range []string{
+		"## Recent Changes",
+		"| 2024-03-04 | Cy | " + commits[0].Hash + " | Fix a\\|b handling |",
+		"| 2024-02-03 | Bob | " + commits[1].Hash + " | Add parser |",
+	} {
+		if !strings.Contains(changelog, want) {
+			t.Errorf("changelog is missing %q:\n%s", want, changelog)
+		}
+	}
+	if strings.Contains(changelog, "Initial commit") {
+		t.Errorf("changelog lists more than 2 commits:\n%s", changelog)
+	}
+}
+
+func TestRecentCommitsUntrustedConfig(t *testing.T) {
+	dir, git := gitRepo(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	script := filepath.Join(t.TempDir(), "tool.sh")
+	// Signs like gpg when asked to, and leaves a marker for anything else
+	writeFiles(t, filepath.Dir(script), map[string]string{filepath.Base(script): `#!/bin/sh
+case "$*" in
+*-bsau*)
+	cat >/dev/null
+	printf '\n[GNUPG:] SIG_CREATED ' >&2
+	printf -- '-----BEGIN PGP SIGNATURE-----\n\nfake\n-----END PGP SIGNATURE-----\n'
+	exit 0;;
+esac
+touch ` + marker + `
+cat >/dev/null
+`})
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git(nil, "config", "gpg.program", script)
+	commit(t, dir, git, "Ann", "2024-01-02T10:00:00Z", "Signed commit", "--gpg-sign=key")
+	for _, setting := range [][]string{
+		{"log.showSignature", "true"},
+		{"core.pager", script},
+		{"core.fsmonitor", script},
+		{"diff.external", script},
+	} {
+		git(nil, "config", setting[0], setting[1])
+	}
+
+	commits, err := RecentCommits(context.Background(), filepath.Join(dir, ".git"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "Signed commit" {
+		t.Errorf("commits %+v", commits)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("git log ran a program from the repository's config")
+	}
+}
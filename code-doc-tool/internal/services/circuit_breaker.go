@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrAgentUnavailable is returned without calling the agent while the
+// circuit breaker is open.
+var ErrAgentUnavailable = errors.New("agent unavailable")
+
+// Circuit breaker states, as reported by State.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops calls to the agent after a run of consecutive
+// failures. Once the cooldown has passed a single probe call is let
+// through: its success closes the circuit, its failure reopens it.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker returns a breaker opening after threshold consecutive
+// failures; a threshold below 1 never opens.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow reports whether a call may go ahead, returning ErrAgentUnavailable
+// when it may not. Every allowed call must be followed by Record.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state() {
+	case CircuitOpen:
+		return ErrAgentUnavailable
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrAgentUnavailable
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record feeds the outcome of an allowed call back into the breaker. Only
+// errors pointing at the agent being down count as failures; a cancelled
+// call says nothing either way.
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.probing
+	b.probing = false
+	switch ClassifyAgentError(err) {
+	case "timeout", "server_error", "rate_limited", "connection_error":
+		b.failures++
+		if probe || b.failures == b.threshold {
+			b.openedAt = time.Now()
+		}
+	case "cancelled":
+	default:
+		b.failures = 0
+	}
+}
+
+// State reports whether the breaker is closed, open or half-open.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state()
+}
+
+func (b *CircuitBreaker) state() string {
+	if b.threshold < 1 || b.failures < b.threshold {
+		return CircuitClosed
+	}
+	if time.Since(b.openedAt) < b.cooldown {
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := NewCircuitBreaker(3, 50*time.Millisecond)
+	down := &AgentStatusError{StatusCode: 503}
+
+	// Failures below the threshold, or broken by a success, keep it closed
+	for _, err := range []error{down, down, nil, down, down, context.Canceled, &AgentStatusError{StatusCode: 400}} {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("closed breaker refused a call: %v", err)
+		}
+		b.Record(err)
+	}
+	if b.State() != CircuitClosed {
+		t.Fatalf("state %s, want closed", b.State())
+	}
+
+	// A run of failures opens it and calls fail fast
+	for range 3 {
+		b.Allow()
+		b.Record(context.DeadlineExceeded)
+	}
+	if b.State() != CircuitOpen {
+		t.Fatalf("state %s after 3 failures, want open", b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrAgentUnavailable) {
+		t.Fatalf("open breaker allowed a call: %v", err)
+	}
+
+	// After the cooldown a single probe goes through; its failure reopens
+	time.Sleep(60 * time.Millisecond)
+	if b.State() != CircuitHalfOpen {
+		t.Fatalf("state %s after the cooldown, want half_open", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("half-open breaker refused the probe: %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrAgentUnavailable) {
+		t.Fatal("half-open breaker allowed a second call during the probe")
+	}
+	b.Record(down)
+	if b.State() != CircuitOpen {
+		t.Fatalf("state %s after a failed probe, want open", b.State())
+	}
+
+	// A successful probe closes it again
+	time.Sleep(60 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.Record(nil)
+	if b.State() != CircuitClosed {
+		t.Errorf("state %s after a successful probe, want closed", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("closed breaker refused a call: %v", err)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := NewCircuitBreaker(0, time.Hour)
+	for range 10 {
+		if err := b.Allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.Record(&AgentStatusError{StatusCode: 503})
+	}
+	if b.State() != CircuitClosed {
+		t.Errorf("state %s with no threshold, want closed", b.State())
+	}
+}
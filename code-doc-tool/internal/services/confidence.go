@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type confidenceKey struct{}
+
+// WithConfidenceReporter attaches a callback to ctx that agent calls use to
+// report the confidence score the agent gave the documentation of path.
+// Agents that don't score their output never trigger it.
+func WithConfidenceReporter(ctx context.Context, report func(path string, confidence float64)) context.Context {
+	return context.WithValue(ctx, confidenceKey{}, report)
+}
+
+func reportConfidence(ctx context.Context, path string, confidence float64) {
+	if report, ok := ctx.Value(confidenceKey{}).(func(string, float64)); ok {
+		report(path, confidence)
+	}
+}
+
+// AnnotateLowConfidence flags doc as needing review, placing the note
+// under its leading heading when it has one.
+func AnnotateLowConfidence(doc string, confidence float64) string {
+	note := fmt.Sprintf("> **Low confidence (%.2f), review needed.**\n", confidence)
+	if strings.HasPrefix(doc, "#") {
+		heading, rest, _ := strings.Cut(doc, "\n")
+		return heading + "\n\n" + note + "\n" + strings.TrimLeft(rest, "\n")
+	}
+	return note + "\n" + doc
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConfidenceReporter(t *testing.T) {
+	// Without a reporter the score goes nowhere
+	reportConfidence(context.Background(), "a.go", 0.5)
+
+	got := map[string]float64{}
+	ctx := WithConfidenceReporter(context.Background(), func(path string, confidence float64) {
+		got[path] = confidence
+	})
+	reportConfidence(ctx, "a.go", 0.25)
+	if got["a.go"] != 0.25 {
+		t.Errorf("reported %v", got)
+	}
+}
+
+func TestAnnotateLowConfidence(t *testing.T) {
+	tests := map[string]string{
+		"# main.go\n\nDocs.": "# main.go\n\n> **Low confidence (0.42), review needed.**\n\nDocs.",
+		"Docs.":              "> **Low confidence (0.42), review needed.**\n\nDocs.",
+	}
+	for doc, want := range tests {
+		if got := AnnotateLowConfidence(doc, 0.42); got != want {
+			t.Errorf("AnnotateLowConfidence(%q) = %q, want %q", doc, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configFileNames are documented as configuration whatever their
+// extension. A real .env is deliberately absent: only its checked-in
+// templates are sent to the agent.
+var configFileNames = map[string]bool{
+	"docker-compose.yml":  true,
+	"docker-compose.yaml": true,
+	"compose.yml":         true,
+	"compose.yaml":        true,
+	".env.example":        true,
+	".env.sample":         true,
+	".env.template":       true,
+	".env.dist":           true,
+	".editorconfig":       true,
+	"nginx.conf":          true,
+}
+
+// configExtensions mark configuration files by extension.
+var configExtensions = map[string]bool{
+	".yaml":       true,
+	".yml":        true,
+	".toml":       true,
+	".ini":        true,
+	".cfg":        true,
+	".conf":       true,
+	".properties": true,
+}
+
+// Bounds on what FindConfigFiles returns, so a tree full of fixtures
+// doesn't turn into hundreds of agent calls.
+const (
+	maxConfigFiles    = 50
+	maxConfigFileSize = 256 * 1024
+)
+
+// FindConfigFiles lists the configuration files under root, shallowest
+// first and then by path, leaving out dependency and VCS directories,
+// lock files and API specs documented elsewhere.
+func FindConfigFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && skippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && info.Size() <= maxConfigFileSize && IsConfigFile(info.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		di, dj := strings.Count(files[i], string(filepath.Separator)), strings.Count(files[j], string(filepath.Separator))
+		if di != dj {
+			return di < dj
+		}
+		return files[i] < files[j]
+	})
+	if len(files) > maxConfigFiles {
+		files = files[:maxConfigFiles]
+	}
+	return files, nil
+}
+
+// IsConfigFile reports whether a file name is one FindConfigFiles picks up.
+func IsConfigFile(name string) bool {
+	lower := strings.ToLower(name)
+	if configFileNames[lower] {
+		return true
+	}
+	if strings.Contains(lower, ".lock") || strings.HasPrefix(lower, "openapi.") || strings.HasPrefix(lower, "swagger.") {
+		return false
+	}
+	return configExtensions[filepath.Ext(lower)]
+}
+
+// ConfigFormatTemplate asks the agent to explain a configuration file
+// rather than document code.
+const ConfigFormatTemplate = `# Configuration File
+This is a configuration file, not source code. Explain it for someone
+deploying or operating the project.
+
+## Purpose
+- What the file configures and when it is read
+
+## Settings
+- Each setting or key: what it controls, its value here and the values it accepts
+
+## Services and Environment
+- Services, ports, volumes and environment variables it defines, if any
+
+## Notes
+- Settings to change per environment and values that must be kept secret
+`
+
+// ConfigDoc is one configuration file's documentation.
+type ConfigDoc struct {
+	Path string
+	Doc  string
+}
+
+// RenderConfiguration renders the Configuration section with a
+// subsection per file, the file's own headings nested beneath.
+func RenderConfiguration(docs []ConfigDoc) string {
+	var b strings.Builder
+	b.WriteString("# Configuration\n")
+	for _, doc := range docs {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", doc.Path, strings.TrimSpace(OffsetHeadings(doc.Doc, 2)))
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":                   "package main\n",
+		"docker-compose.yml":        "services:\n  web:\n    image: nginx\n",
+		".env":                      "SECRET=1\n",
+		".env.example":              "SECRET=\n",
+		"deploy/values.yaml":        "replicas: 2\n",
+		"deploy/Cargo.lock.toml":    "[[package]]\n",
+		"api/openapi.yaml":          "openapi: 3.0.0\n",
+		"node_modules/pkg/app.yaml": "name: pkg\n",
+		"config/nginx.conf":         "server {}\n",
+	})
+
+	files, err := FindConfigFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, file := range files {
+		rel, _ := filepath.Rel(dir, file)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{".env.example", "docker-compose.yml", "config/nginx.conf", "deploy/values.yaml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config files %v, want %v", got, want)
+	}
+}
+
+func TestIsConfigFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"docker-compose.yaml": true,
+		"Compose.yml":         true,
+		"settings.ini":        true,
+		"app.properties":      true,
+		".editorconfig":       true,
+		".env":                false,
+		"poetry.lock":         false,
+		"swagger.yml":         false,
+		"main.go":             false,
+	} {
+		if got := IsConfigFile(name); got != want {
+			t.Errorf("IsConfigFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRenderConfiguration(t *testing.T) {
+	got := RenderConfiguration([]ConfigDoc{
+		{Path: "docker-compose.yml", Doc: "# Configuration File\n\n## Purpose\nRuns the stack.\n"},
+	})
+	for _, want := range []string{"# Configuration\n", "\n## docker-compose.yml\n", "### Configuration File", "#### Purpose"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("section is missing %q:\n%s", want, got)
+		}
+	}
+}
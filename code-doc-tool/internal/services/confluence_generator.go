@@ -0,0 +1,166 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"code-doc-tool/internal/utils"
+)
+
+// ConfluenceGenerator writes Confluence wiki markup, which can be pasted
+// into the wiki markup editor or inserted with the {wiki} macro.
+type ConfluenceGenerator struct {
+	// LineEnding terminates every line of the output
+	LineEnding string
+}
+
+func NewConfluenceGenerator() *ConfluenceGenerator {
+	return &ConfluenceGenerator{LineEnding: lineEnding}
+}
+
+func (g *ConfluenceGenerator) Extension() string { return "wiki" }
+
+func (g *ConfluenceGenerator) ContentType() string {
+	return "text/x-confluence-wiki; charset=utf-8"
+}
+
+var (
+	mdNumbered   = regexp.MustCompile(`^\d+[.)]\s+`)
+	mdInlineCode = regexp.MustCompile("`([^`]+)`")
+
+	// wikiLink is a markdown link once wikiInline has escaped its bracket
+	wikiLink = regexp.MustCompile(`\\\[([^\]]+)\]\(([^)]+)\)`)
+
+	// wikiCodeTag and wikiNoformatTag would end a macro body of their kind
+	wikiCodeTag     = regexp.MustCompile(`(?i)\{code[:}]`)
+	wikiNoformatTag = regexp.MustCompile(`(?i)\{noformat[:}]`)
+)
+
+// GenerateDocumentation writes docText converted to wiki markup.
+func (g *ConfluenceGenerator) GenerateDocumentation(docText string, outputPath string) error {
+	text := NormalizeText(RenderConfluence(docText), g.LineEnding)
+	if err := os.WriteFile(outputPath, []byte(text), 0644); err != nil {
+		if utils.IsDiskFull(err) {
+			os.Remove(outputPath)
+			return fmt.Errorf("failed to save wiki markup: %w", utils.WrapDiskFull(err))
+		}
+		return fmt.Errorf("failed to save wiki markup: %w", err)
+	}
+	return nil
+}
+
+// RenderConfluence converts markdown into Confluence wiki markup: headings
+// to h1. to h6., fenced code to {code} macros, bullet and numbered lists
+// (nested by indentation), tables with a || header row, quotes and rules.
+func RenderConfluence(docText string) string {
+	var b strings.Builder
+	lines := strings.Split(docText, "\n")
+	inCodeBlock := false
+	var lang string
+	var code []string
+
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			if !inCodeBlock {
+				lang = strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+				code = code[:0]
+			} else {
+				writeWikiCode(&b, lang, code)
+			}
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			code = append(code, line)
+			continue
+		}
+
+		if m := mdHeading.FindStringSubmatch(trimmed); m != nil {
+			fmt.Fprintf(&b, "h%d. %s\n", len(m[1]), wikiInline(m[2]))
+			continue
+		}
+
+		// Nested list items are indented by two or more spaces per level
+		depth := 1 + (len(line)-len(strings.TrimLeft(line, " \t")))/2
+		switch {
+		case trimmed == "---" || trimmed == "***":
+			b.WriteString("----\n")
+		case strings.HasPrefix(trimmed, "|"):
+			cells := parseTableRow(trimmed)
+			if isTableSeparator(cells) {
+				continue
+			}
+			// A row followed by a separator is the header
+			sep := "|"
+			if i+1 < len(lines) && isTableSeparator(parseTableRow(strings.TrimSpace(lines[i+1]))) {
+				sep = "||"
+			}
+			for n, cell := range cells {
+				cells[n] = wikiInline(cell)
+				if cells[n] == "" {
+					cells[n] = " "
+				}
+			}
+			b.WriteString(sep + strings.Join(cells, sep) + sep + "\n")
+		case mdImage.MatchString(trimmed):
+			// The image file isn't attached to the page, so keep the caption
+			b.WriteString("_" + wikiInline(mdImage.FindStringSubmatch(trimmed)[1]) + "_\n")
+		case mdBullet.MatchString(trimmed):
+			b.WriteString(strings.Repeat("*", depth) + " " + wikiInline(mdBullet.ReplaceAllString(trimmed, "")) + "\n")
+		case mdNumbered.MatchString(trimmed):
+			b.WriteString(strings.Repeat("#", depth) + " " + wikiInline(mdNumbered.ReplaceAllString(trimmed, "")) + "\n")
+		case strings.HasPrefix(trimmed, ">"):
+			b.WriteString("bq. " + wikiInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))) + "\n")
+		default:
+			b.WriteString(wikiInline(trimmed) + "\n")
+		}
+	}
+	if inCodeBlock {
+		writeWikiCode(&b, lang, code)
+	}
+	return b.String()
+}
+
+// writeWikiCode writes a fenced code block as a {code} macro. Macro bodies
+// end at the first closing tag whatever surrounds it, so a body holding a
+// {code} tag goes in {noformat} instead, and one holding both has their
+// braces escaped.
+func writeWikiCode(b *strings.Builder, lang string, lines []string) {
+	body := ""
+	if len(lines) > 0 {
+		body = strings.Join(lines, "\n") + "\n"
+	}
+	switch {
+	case !wikiCodeTag.MatchString(body):
+		if lang != "" {
+			b.WriteString("{code:language=" + lang + "}\n")
+		} else {
+			b.WriteString("{code}\n")
+		}
+		b.WriteString(body + "{code}\n")
+	case !wikiNoformatTag.MatchString(body):
+		b.WriteString("{noformat}\n" + body + "{noformat}\n")
+	default:
+		escape := func(tag string) string { return `\` + tag }
+		body = wikiCodeTag.ReplaceAllStringFunc(body, escape)
+		body = wikiNoformatTag.ReplaceAllStringFunc(body, escape)
+		b.WriteString("{code}\n" + body + "{code}\n")
+	}
+}
+
+// wikiInline converts inline markdown in a single line. Braces and
+// brackets are escaped first so text can't open a macro or a link; only
+// markdown links become wiki links.
+func wikiInline(s string) string {
+	s = strings.ReplaceAll(s, "{", `\{`)
+	s = strings.ReplaceAll(s, "[", `\[`)
+	s = mdInlineCode.ReplaceAllString(s, "{{$1}}")
+	s = wikiLink.ReplaceAllString(s, "[$1|$2]")
+	s = mdEmphasis.ReplaceAllString(s, "${1}_${2}_$3")
+	return mdStrong.ReplaceAllString(s, "*$2*")
+}
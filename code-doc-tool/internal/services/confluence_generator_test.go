@@ -0,0 +1,100 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderConfluence(t *testing.T) {
+	doc := strings.Join([]string{
+		"# Title",
+		"### Usage",
+		"Call **Run** with a [config](https://example.com), `ctx` and *care*.",
+		"- first",
+		"  - nested",
+		"1. one",
+		"2. two",
+		"```go",
+		"m := map[string]int{\"a\": 1} // **not bold**",
+		"```",
+		"```",
+		"plain",
+		"```",
+		"| Name | Type |",
+		"| --- | --- |",
+		"| id | `int` |",
+		"| note |  |",
+		"> Quoted {macro}",
+		"---",
+	}, "\n")
+	want := strings.Join([]string{
+		"h1. Title",
+		"h3. Usage",
+		"Call *Run* with a [config|https://example.com], {{ctx}} and _care_.",
+		"* first",
+		"** nested",
+		"# one",
+		"# two",
+		"{code:language=go}",
+		"m := map[string]int{\"a\": 1} // **not bold**",
+		"{code}",
+		"{code}",
+		"plain",
+		"{code}",
+		"||Name||Type||",
+		"|id|{{int}}|",
+		"|note| |",
+		`bq. Quoted \{macro}`,
+		"----",
+		"",
+	}, "\n")
+	if got := RenderConfluence(doc); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenderConfluenceEscapes(t *testing.T) {
+	tests := []struct{ name, doc, want string }{
+		{"brackets", "See [docs](https://example.com) or arr[0] and [not a link]",
+			`See [docs|https://example.com] or arr\[0] and \[not a link]` + "\n"},
+		{"macro in text", "Use {code} or {{x}}", `Use \{code} or \{\{x}}` + "\n"},
+		{"code tag in code", "```go\ns := \"{code}\"\n```",
+			"{noformat}\ns := \"{code}\"\n{noformat}\n"},
+		{"both tags in code", "```\n{CODE:title=x}\n{noformat}\n```",
+			"{code}\n\\{CODE:title=x}\n\\{noformat}\n{code}\n"},
+		{"noformat tag only", "```\n{noformat}\n```", "{code}\n{noformat}\n{code}\n"},
+		{"unterminated", "```sh\nmake {code}", "{noformat}\nmake {code}\n{noformat}\n"},
+	}
+	for _, tt := range tests {
+		if got := RenderConfluence(tt.doc); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfluenceGenerator(t *testing.T) {
+	for _, format := range []string{"wiki", "confluence"} {
+		generator, err := NewGenerator(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if generator.Extension() != "wiki" || !strings.HasPrefix(generator.ContentType(), "text/x-confluence-wiki") {
+			t.Errorf("%s: extension %q, content type %q", format, generator.Extension(), generator.ContentType())
+		}
+	}
+	if got := ContentTypeFor("out.wiki"); !strings.HasPrefix(got, "text/x-confluence-wiki") {
+		t.Errorf("ContentTypeFor(out.wiki) = %q", got)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.wiki")
+	g := &ConfluenceGenerator{LineEnding: "\r\n"}
+	if err := g.GenerateDocumentation("# Title\n\nBody\n", out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || !strings.HasPrefix(string(data), "h1. Title\r\n") {
+		t.Errorf("wrote %q, %v", data, err)
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// diagramTypes are the image formats accepted as architecture diagrams,
+// by sniffed content type, with the extension they are stored under.
+var diagramTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+var ErrInvalidDiagram = errors.New("diagram must be a PNG, JPEG or GIF image")
+
+// DiagramExtension sniffs the image in r and returns the extension it
+// should be stored under, or ErrInvalidDiagram for anything else.
+func DiagramExtension(r io.Reader) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", ErrInvalidDiagram
+	}
+	ext, ok := diagramTypes[http.DetectContentType(head[:n])]
+	if !ok {
+		return "", ErrInvalidDiagram
+	}
+	return ext, nil
+}
+
+// DiagramDir is where the diagrams uploaded with a job are kept, outside
+// the download route's reach.
+func DiagramDir(jobID string) string {
+	return filepath.Join("./output", ".cache", "diagrams", jobID)
+}
+
+// DiagramFilename names the nth (from 1) diagram of a job, keeping its
+// order and the uploaded name for the caption.
+func DiagramFilename(n int, uploaded, ext string) string {
+	base := strings.TrimSuffix(filepath.Base(uploaded), filepath.Ext(uploaded))
+	base = regexp.MustCompile(`[^A-Za-z0-9._-]+`).ReplaceAllString(base, "-")
+	if base = strings.Trim(base, "-."); base == "" {
+		base = "diagram"
+	}
+	return fmt.Sprintf("%02d-%s%s", n, base, ext)
+}
+
+// Diagram is an image embedded in the Architecture section.
+type Diagram struct {
+	Path    string
+	Caption string
+}
+
+// JobDiagrams lists the diagrams stored for a job in upload order, with
+// captions taken from their uploaded names.
+func JobDiagrams(jobID string) []Diagram {
+	dir := DiagramDir(jobID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	diagrams := make([]Diagram, 0, len(names))
+	for _, name := range names {
+		diagrams = append(diagrams, Diagram{Path: filepath.Join(dir, name), Caption: diagramCaption(name)})
+	}
+	return diagrams
+}
+
+// diagramCaption turns "01-data-flow.png" into "data flow".
+func diagramCaption(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if i := strings.IndexByte(name, '-'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSpace(strings.NewReplacer("-", " ", "_", " ").Replace(name))
+}
+
+// RenderDiagrams renders an "Architecture" section embedding each diagram
+// as a numbered figure. Image paths are made relative to dir, the
+// directory of the document they end up in.
+func RenderDiagrams(diagrams []Diagram, dir string) string {
+	if len(diagrams) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("# Architecture\n\n")
+	for i, d := range diagrams {
+		target := d.Path
+		if rel, err := filepath.Rel(dir, d.Path); err == nil {
+			target = rel
+		}
+		fmt.Fprintf(&b, "![Figure %d: %s](%s)\n\n", i+1, d.Caption, filepath.ToSlash(target))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// mdImage matches a markdown image on a line of its own.
+var mdImage = regexp.MustCompile(`^!\[([^\]]*)\]\(([^)\s]+)\)$`)
+
+// parseImage returns the caption and target of an image line, resolving a
+// relative target against dir.
+func parseImage(line, dir string) (caption, target string, ok bool) {
+	m := mdImage.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	target = filepath.FromSlash(m[2])
+	if !filepath.IsAbs(target) && !strings.Contains(m[2], "://") {
+		target = filepath.Join(dir, target)
+	}
+	return m[1], target, true
+}
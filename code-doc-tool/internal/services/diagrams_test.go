@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePNG writes a width x height PNG image to path.
+func writePNG(t *testing.T, path string, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiagramExtension(t *testing.T) {
+	data := writePNG(t, filepath.Join(t.TempDir(), "a.png"), 4, 3)
+	if ext, err := DiagramExtension(bytes.NewReader(data)); err != nil || ext != ".png" {
+		t.Errorf("PNG: got %q, %v", ext, err)
+	}
+	if ext, err := DiagramExtension(strings.NewReader("GIF89a\x01\x00\x01\x00")); err != nil || ext != ".gif" {
+		t.Errorf("GIF: got %q, %v", ext, err)
+	}
+	for _, data := range []string{"", "<svg xmlns=\"http://www.w3.org/2000/svg\"/>", "%PDF-1.7"} {
+		if _, err := DiagramExtension(strings.NewReader(data)); !errors.Is(err, ErrInvalidDiagram) {
+			t.Errorf("%q: got %v, want ErrInvalidDiagram", data, err)
+		}
+	}
+}
+
+func TestDiagramFilename(t *testing.T) {
+	for _, tt := range []struct {
+		n                   int
+		uploaded, ext, want string
+	}{
+		{1, "data flow.PNG", ".png", "01-data-flow.png"},
+		{2, "../../etc/passwd", ".gif", "02-passwd.gif"},
+		{12, "...", ".jpg", "12-diagram.jpg"},
+	} {
+		if got := DiagramFilename(tt.n, tt.uploaded, tt.ext); got != tt.want {
+			t.Errorf("DiagramFilename(%d, %q) = %q, want %q", tt.n, tt.uploaded, got, tt.want)
+		}
+	}
+	if got := diagramCaption("01-data-flow_v2.png"); got != "data flow v2" {
+		t.Errorf("caption %q", got)
+	}
+}
+
+func TestRenderDiagrams(t *testing.T) {
+	if got := RenderDiagrams(nil, "output"); got != "" {
+		t.Errorf("section without diagrams: %q", got)
+	}
+	got := RenderDiagrams([]Diagram{
+		{Path: filepath.Join("output", ".cache", "diagrams", "job", "01-flow.png"), Caption: "flow"},
+		{Path: filepath.Join("output", ".cache", "diagrams", "job", "02-deploy.gif"), Caption: "deploy"},
+	}, "output")
+	want := "# Architecture\n\n" +
+		"![Figure 1: flow](.cache/diagrams/job/01-flow.png)\n\n" +
+		"![Figure 2: deploy](.cache/diagrams/job/02-deploy.gif)"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDocxDiagram(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "flow.png"), 2000, 1000)
+	out := filepath.Join(dir, "out.docx")
+	doc := "# Architecture\n\n![Figure 1: flow](flow.png)\n\n![Figure 2: missing](missing.png)\n"
+	if err := (&DocxGenerator{Validate: true}).GenerateDocumentation(doc, out); err != nil {
+		t.Fatal(err)
+	}
+
+	parts, _, err := readDocxParts(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parts["word/media/docgen_image1.png"]; !ok {
+		t.Error("image part missing")
+	}
+	if _, ok := parts["word/media/docgen_image2.png"]; ok {
+		t.Error("a missing image was embedded")
+	}
+	document := string(parts["word/document.xml"])
+	for _, want := range []string{
+		`r:embed="rIdDocgenImage1"`,
+		// 2000px scaled down to the 6 inch page width
+		`<wp:extent cx="5486400" cy="2743200"/>`,
+		"Figure 1: flow",
+		"Figure 2: missing",
+	} {
+		if !strings.Contains(document, want) {
+			t.Errorf("document.xml is missing %q", want)
+		}
+	}
+	if strings.Contains(document, imagePlaceholder(1)) {
+		t.Error("placeholder paragraph left in the document")
+	}
+	if !strings.Contains(string(parts["[Content_Types].xml"]), `Extension="png"`) {
+		t.Error("png content type not registered")
+	}
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// FileDigest returns the hex SHA-256 of the file at path.
+func FileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DigestHeader formats a hex SHA-256 as the value of an RFC 3230 Digest
+// header, which carries the hash in base64.
+func DigestHeader(digest string) (string, bool) {
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size {
+		return "", false
+	}
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum), true
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// diskUsageTTL is how long a measurement of the directories is reused.
+// Walking them is too slow to do for every upload.
+const diskUsageTTL = 5 * time.Second
+
+// DiskQuota caps the combined size of the working directories. Space for
+// uploads still being written, and for archives being extracted, is
+// reserved up front so concurrent jobs can't overshoot the quota together.
+type DiskQuota struct {
+	mu       sync.Mutex
+	limit    int64
+	dirs     []string
+	reserved int64
+
+	// used is the size of dirs as of measured. Invalidate, which also
+	// runs when a reservation is released since the space has usually
+	// turned into files on disk by then, forces a new walk and bumps
+	// changes so a walk already under way doesn't overwrite it
+	used     int64
+	measured time.Time
+	changes  int
+}
+
+// DiskUsage is a snapshot of the quota's accounting.
+type DiskUsage struct {
+	Used     int64 `json:"used_bytes"`
+	Reserved int64 `json:"reserved_bytes"`
+	Limit    int64 `json:"quota_bytes"`
+}
+
+// NewDiskQuota tracks dirs against limit bytes; a limit of 0 disables it.
+func NewDiskQuota(limit int64, dirs ...string) *DiskQuota {
+	return &DiskQuota{limit: limit, dirs: dirs}
+}
+
+// Reserve sets aside n bytes if they fit in the quota. The returned
+// release gives them back and is safe to call more than once.
+func (q *DiskQuota) Reserve(n int64) (func(), bool) {
+	if q.limit <= 0 {
+		return func() {}, true
+	}
+
+	used := q.measure()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if used+q.reserved+n > q.limit {
+		return nil, false
+	}
+	q.reserved += n
+	return sync.OnceFunc(func() {
+		q.mu.Lock()
+		q.reserved -= n
+		q.mu.Unlock()
+		q.Invalidate()
+	}), true
+}
+
+// Invalidate drops the cached measurement after files under the
+// directories were deleted, so the freed space counts right away.
+func (q *DiskQuota) Invalidate() {
+	q.mu.Lock()
+	q.measured = time.Time{}
+	q.changes++
+	q.mu.Unlock()
+}
+
+// Usage reports current usage, reservations and the limit.
+func (q *DiskQuota) Usage() DiskUsage {
+	used := q.measure()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return DiskUsage{Used: used, Reserved: q.reserved, Limit: q.limit}
+}
+
+// measure returns the size of the directories, walking them outside the
+// lock when the last measurement is stale.
+func (q *DiskQuota) measure() int64 {
+	q.mu.Lock()
+	if !q.measured.IsZero() && time.Since(q.measured) < diskUsageTTL {
+		defer q.mu.Unlock()
+		return q.used
+	}
+	changes := q.changes
+	q.mu.Unlock()
+
+	started := time.Now()
+	used := dirSize(q.dirs)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// A release during the walk may have left it short already
+	if q.changes == changes {
+		q.used, q.measured = used, started
+	}
+	return used
+}
+
+// dirSize sums the sizes of the regular files under dirs.
+func dirSize(dirs []string) int64 {
+	var total int64
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+				total += info.Size()
+			}
+			return nil
+		})
+	}
+	return total
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiskQuota(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"old.md": strings.Repeat("x", 40)})
+	quota := NewDiskQuota(100, dir)
+
+	release, ok := quota.Reserve(50)
+	if !ok {
+		t.Fatal("50 bytes don't fit beside 40 used")
+	}
+	if _, ok := quota.Reserve(20); ok {
+		t.Error("reservation beyond the quota accepted")
+	}
+
+	// The upload lands on disk, then its reservation is released
+	writeFiles(t, dir, map[string]string{"upload.zip": strings.Repeat("x", 50)})
+	release()
+	release()
+	if usage := quota.Usage(); usage != (DiskUsage{Used: 90, Reserved: 0, Limit: 100}) {
+		t.Errorf("usage %+v", usage)
+	}
+	if _, ok := quota.Reserve(20); ok {
+		t.Error("reservation beyond the quota accepted after release")
+	}
+
+	// Deleted files count as freed once invalidated
+	os.Remove(filepath.Join(dir, "upload.zip"))
+	quota.Invalidate()
+	if _, ok := quota.Reserve(60); !ok {
+		t.Error("freed space not available")
+	}
+}
+
+func TestDiskQuotaDisabled(t *testing.T) {
+	quota := NewDiskQuota(0, t.TempDir())
+	if _, ok := quota.Reserve(1 << 40); !ok {
+		t.Error("disabled quota refused a reservation")
+	}
+}
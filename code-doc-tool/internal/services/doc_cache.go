@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"code-doc-tool/internal/utils"
+)
+
+// CachedDoc is the documentation produced for one version of a file.
+type CachedDoc struct {
+	SHA256 string `json:"sha256"`
+	Doc    string `json:"doc"`
+}
+
+// DocCache maps file paths, relative to the analysis base, to their
+// documentation so a later job can skip files that haven't changed.
+// Template is the hash of the format template the docs were produced with.
+type DocCache struct {
+	mu       sync.Mutex
+	Template string               `json:"template"`
+	Files    map[string]CachedDoc `json:"files"`
+}
+
+func NewDocCache(formatTemplate string) *DocCache {
+	return &DocCache{Template: hashString(formatTemplate), Files: map[string]CachedDoc{}}
+}
+
+// DocCachePath is where a job's cache is kept, outside the download route's
+// reach.
+func DocCachePath(jobID string) string {
+	return filepath.Join("./output", ".cache", jobID+".json")
+}
+
+func LoadDocCache(path string) (*DocCache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cache DocCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("invalid doc cache: %w", err)
+	}
+	if cache.Files == nil {
+		cache.Files = map[string]CachedDoc{}
+	}
+	return &cache, nil
+}
+
+// Matches reports whether docs in the cache were produced with
+// formatTemplate and can be reused.
+func (c *DocCache) Matches(formatTemplate string) bool {
+	return c.Template == hashString(formatTemplate)
+}
+
+// Lookup returns the cached doc for rel if its content hash still matches.
+func (c *DocCache) Lookup(rel, sum string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.Files[rel]
+	if !ok || cached.SHA256 != sum {
+		return "", false
+	}
+	return cached.Doc, true
+}
+
+func (c *DocCache) Put(rel, sum, doc string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Files[rel] = CachedDoc{SHA256: sum, Doc: doc}
+}
+
+func (c *DocCache) Save(path string) error {
+	c.mu.Lock()
+	data, err := json.Marshal(c)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := utils.CreateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// HashFile returns the hex SHA-256 of a file's content.
+func HashFile(path string) (string, error) {
+	f, err := utils.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells bounds the LCS table, about 8MB of ints; larger changes
+// are reported as a single replacement instead of a minimal diff.
+const maxDiffCells = 1024 * 1024
+
+// DocDiff describes how one combined document differs from another.
+type DocDiff struct {
+	Added   []string `json:"added_sections"`
+	Removed []string `json:"removed_sections"`
+	Changed []string `json:"changed_sections"`
+	Unified string   `json:"unified"`
+}
+
+// DiffDocuments compares two markdown documents section by section and
+// line by line. Sections are named by their heading path, e.g.
+// "handlers.go > 6. Functions / Classes".
+func DiffDocuments(fromName, from, toName, to string) DocDiff {
+	fromSections, fromOrder := splitSections(from)
+	toSections, toOrder := splitSections(to)
+
+	diff := DocDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for _, name := range fromOrder {
+		body, ok := toSections[name]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, name)
+		case body != fromSections[name]:
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for _, name := range toOrder {
+		if _, ok := fromSections[name]; !ok {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+
+	diff.Unified = unifiedDiff(fromName, toName, strings.Split(from, "\n"), strings.Split(to, "\n"), 3)
+	return diff
+}
+
+// splitSections maps each heading path to the text beneath it. Repeated
+// paths get a "#n" suffix so every section keeps a distinct name.
+func splitSections(doc string) (map[string]string, []string) {
+	sections := map[string]string{}
+	var order []string
+	seen := map[string]int{}
+	var path []string
+	name := ""
+	var body strings.Builder
+
+	flush := func() {
+		if name != "" || strings.TrimSpace(body.String()) != "" {
+			key := name
+			if key == "" {
+				key = "(preamble)"
+			}
+			if seen[key]++; seen[key] > 1 {
+				key = fmt.Sprintf("%s #%d", key, seen[key])
+			}
+			// The separator between per-file docs isn't part of a section
+			sections[key] = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body.String()), "---"))
+			order = append(order, key)
+		}
+		body.Reset()
+	}
+
+	inCode := false
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+		if m := mdHeading.FindStringSubmatch(trimmed); m != nil && !inCode {
+			flush()
+			level := len(m[1])
+			if level > len(path) {
+				path = append(path, make([]string, level-len(path))...)
+			}
+			path = append(path[:level-1], m[2])
+			name = strings.Join(nonEmpty(path), " > ")
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	flush()
+	return sections, order
+}
+
+func nonEmpty(items []string) []string {
+	var out []string
+	for _, item := range items {
+		if item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff renders a unified diff of a and b with the given number of
+// context lines. It returns "" when they are equal.
+func unifiedDiff(aName, bName string, a, b []string, context int) string {
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change and the run of changes close enough to share
+		// a hunk with it
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			} else if i-last > 2*context {
+				break
+			}
+		}
+
+		from := max(first-context, start)
+		to := min(last+context+1, len(ops))
+
+		aStart, bStart := 1, 1
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				aStart++
+			}
+			if op.kind != '-' {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, op := range ops[from:to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// diffLines returns the edit script turning a into b, using a longest
+// common subsequence over the lines between the common prefix and suffix.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(ma)+1)*(len(mb)+1) > maxDiffCells {
+		for _, line := range ma {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range mb {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		ops = append(ops, lcsOps(ma, mb)...)
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+func lcsOps(a, b []string) []diffOp {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	from := "# main.go\n\n## Overview\nStarts the server.\n\n## Usage\nRun it.\n\n---\n\n# old.go\n\nGone soon.\n"
+	to := "# main.go\n\n## Overview\nStarts the HTTP server.\n\n## Usage\nRun it.\n\n---\n\n# new.go\n\nJust added.\n"
+
+	diff := DiffDocuments("a", from, "b", to)
+	if want := []string{"main.go > Overview"}; !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("changed %v, want %v", diff.Changed, want)
+	}
+	if want := []string{"old.go"}; !reflect.DeepEqual(diff.Removed, want) {
+		t.Errorf("removed %v, want %v", diff.Removed, want)
+	}
+	if want := []string{"new.go"}; !reflect.DeepEqual(diff.Added, want) {
+		t.Errorf("added %v, want %v", diff.Added, want)
+	}
+	for _, want := range []string{"--- a\n+++ b\n", "-Starts the server.\n+Starts the HTTP server.\n", "-# old.go\n", "+# new.go\n"} {
+		if !strings.Contains(diff.Unified, want) {
+			t.Errorf("unified diff is missing %q:\n%s", want, diff.Unified)
+		}
+	}
+
+	if same := DiffDocuments("a", from, "b", from); same.Unified != "" || len(same.Changed) != 0 {
+		t.Errorf("identical documents differ: %+v", same)
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	// Past maxDiffCells the changed middle becomes one replacement
+	var a, b []string
+	for i := 0; i < 1100; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = append([]string{"same"}, a...)
+	b = append([]string{"same"}, b...)
+	ops := diffLines(a, b)
+	if len(ops) != 1+2*1100 || ops[0].kind != ' ' || ops[1].kind != '-' || ops[len(ops)-1].kind != '+' {
+		t.Errorf("got %d ops, want the prefix then all removals then all additions", len(ops))
+	}
+}
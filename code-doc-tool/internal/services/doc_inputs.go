@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DocInputs picks the documentation files of a project, such as READMEs,
+// changelogs and ADRs, whose text is given to the agent as context. A
+// glob without a slash is matched against file names ("README*"), one
+// with slashes against the path relative to the project, where "**"
+// stands for any number of directories ("docs/**/*.md"). Matching
+// ignores case.
+type DocInputs struct {
+	globs []string
+}
+
+// NewDocInputs checks globs and returns a matcher for them.
+func NewDocInputs(globs []string) (*DocInputs, error) {
+	d := &DocInputs{}
+	for _, glob := range globs {
+		glob = strings.ToLower(strings.Trim(strings.TrimSpace(glob), "/"))
+		if glob == "" {
+			continue
+		}
+		for _, segment := range strings.Split(glob, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid documentation input pattern %q: %w", glob, err)
+			}
+		}
+		d.globs = append(d.globs, glob)
+	}
+	return d, nil
+}
+
+// Match reports whether the slash separated path rel names a
+// documentation input.
+func (d *DocInputs) Match(rel string) bool {
+	if d == nil {
+		return false
+	}
+	rel = strings.ToLower(rel)
+	for _, glob := range d.globs {
+		if !strings.Contains(glob, "/") {
+			if ok, _ := path.Match(glob, path.Base(rel)); ok {
+				return true
+			}
+			continue
+		}
+		if matchSegments(strings.Split(glob, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchSegments(pattern[1:], name[1:])
+}
+
+// MatchNested reports whether rel, or its part below any of its
+// directories, names a documentation input; for archives that wrap a
+// project in a top-level folder.
+func (d *DocInputs) MatchNested(rel string) bool {
+	for {
+		if d.Match(rel) {
+			return true
+		}
+		i := strings.Index(rel, "/")
+		if i < 0 {
+			return false
+		}
+		rel = rel[i+1:]
+	}
+}
+
+// Find lists the documentation inputs under root, shallowest first and
+// then by path, leaving out dependency and VCS directories.
+func (d *DocInputs) Find(root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && skippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil && info.Mode().IsRegular() && d.MatchNested(filepath.ToSlash(rel)) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		di, dj := strings.Count(files[i], string(filepath.Separator)), strings.Count(files[j], string(filepath.Separator))
+		if di != dj {
+			return di < dj
+		}
+		return files[i] < files[j]
+	})
+	return files, nil
+}
+
+// RenderDocContext joins the text of files, labelled by their path
+// relative to root, into at most maxBytes. The last file that fits only
+// in part is cut short; files that aren't text are skipped. It returns
+// the context and the relative paths of the files it includes.
+func RenderDocContext(root string, files []string, maxBytes int) (string, []string) {
+	var b strings.Builder
+	var included []string
+	for _, file := range files {
+		room := maxBytes - b.Len()
+		if room <= 0 {
+			break
+		}
+		data, err := os.ReadFile(file)
+		if err != nil || !utf8.Valid(data) {
+			continue
+		}
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			continue
+		}
+		rel, _ := filepath.Rel(root, file)
+		header := fmt.Sprintf("--- %s ---\n", filepath.ToSlash(rel))
+		if len(header)+len(text)+2 > room {
+			// Cut on a rune boundary, leaving room for the header
+			text = strings.ToValidUTF8(text[:max(0, room-len(header)-2)], "")
+			if text == "" {
+				break
+			}
+		}
+		b.WriteString(header + text + "\n\n")
+		included = append(included, filepath.ToSlash(rel))
+	}
+	return strings.TrimSpace(b.String()), included
+}
+
+// WithDocContext appends a project's documentation to a format template
+// for the agent to draw on.
+func WithDocContext(formatTemplate, docContext string) string {
+	if docContext == "" {
+		return formatTemplate
+	}
+	return formatTemplate + "\nProject documentation, for context only; use it to understand the code but document the code itself:\n\n" + docContext + "\n"
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocInputsMatch(t *testing.T) {
+	inputs, err := NewDocInputs([]string{"README*", " CHANGELOG* ", "/docs/**/*.md/", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rel, want := range map[string]bool{
+		"README.md":            true,
+		"readme.rst":           true,
+		"pkg/api/README":       true,
+		"CHANGELOG.md":         true,
+		"docs/intro.md":        true,
+		"docs/adr/0001-db.MD":  true,
+		"notes.md":             false,
+		"src/docs/intro.md":    false,
+		"docs/diagram.png":     false,
+		"main.go":              false,
+		"project/docs/x/y.md":  false,
+		"project/README.md":    true,
+		"my-readme.md":         false,
+		"docs":                 false,
+		"documentation/a.md":   false,
+		"docs/adr/sub/deep.md": true,
+	} {
+		if got := inputs.Match(rel); got != want {
+			t.Errorf("Match(%q) = %v, want %v", rel, got, want)
+		}
+	}
+
+	// Below a wrapping top-level folder
+	if !inputs.MatchNested("project/docs/adr/0001-db.md") || inputs.MatchNested("project/notes.md") {
+		t.Error("MatchNested doesn't look below the top-level folder")
+	}
+
+	var none *DocInputs
+	if none.Match("README.md") {
+		t.Error("nil DocInputs matched")
+	}
+	if _, err := NewDocInputs([]string{"docs/[/*.md"}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestDocInputsFind(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"README.md":                     "# Demo",
+		"docs/adr/0002-queue.md":        "Queue",
+		"docs/adr/0001-db.md":           "DB",
+		"docs/guide.md":                 "Guide",
+		"CHANGELOG.md":                  "Changes",
+		"notes.md":                      "Notes",
+		"node_modules/left/README.md":   "dependency",
+		".git/README":                   "vcs",
+		"main.go":                       "package main",
+		"internal/server/README.md":     "Server",
+		"internal/server/server.go":     "package server",
+		"internal/server/docs/notes.md": "Server notes",
+	})
+	inputs, err := NewDocInputs([]string{"README*", "CHANGELOG*", "docs/**/*.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := inputs.Find(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, file := range files {
+		rel, _ := filepath.Rel(root, file)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	// Shallowest first, then by path
+	want := []string{
+		"CHANGELOG.md", "README.md",
+		"docs/guide.md",
+		"docs/adr/0001-db.md", "docs/adr/0002-queue.md", "internal/server/README.md",
+		"internal/server/docs/notes.md",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find = %q, want %q", got, want)
+	}
+}
+
+func TestRenderDocContext(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"README.md":     "# Demo\n\nA demo service.\n",
+		"empty.md":      "  \n",
+		"logo.md":       "\xff\xfe binary",
+		"docs/guide.md": "Start it with make run, then open the dashboard.",
+	})
+	files := []string{
+		filepath.Join(root, "README.md"),
+		filepath.Join(root, "empty.md"),
+		filepath.Join(root, "logo.md"),
+		filepath.Join(root, "missing.md"),
+		filepath.Join(root, "docs", "guide.md"),
+	}
+
+	text, included := RenderDocContext(root, files, 1000)
+	if want := "--- README.md ---\n# Demo\n\nA demo service.\n\n--- docs/guide.md ---\nStart it with make run, then open the dashboard."; text != want {
+		t.Errorf("context %q, want %q", text, want)
+	}
+	if !reflect.DeepEqual(included, []string{"README.md", "docs/guide.md"}) {
+		t.Errorf("included %v", included)
+	}
+
+	// The file that doesn't fit is cut short, and nothing follows it
+	limit := len("--- README.md ---\n# Demo\n\nA demo service.\n\n") + len("--- docs/guide.md ---\n") + 2 + len("Start it")
+	text, included = RenderDocContext(root, files, limit)
+	if !strings.HasSuffix(text, "--- docs/guide.md ---\nStart it") || len(included) != 2 {
+		t.Errorf("context %q, included %v; want the guide cut after \"Start it\"", text, included)
+	}
+	text, included = RenderDocContext(root, files, 20)
+	if text != "" && !strings.HasPrefix(text, "--- README.md ---\n") || len(included) > 1 {
+		t.Errorf("context %q, included %v with room for little more than a header", text, included)
+	}
+}
+
+func TestWithDocContext(t *testing.T) {
+	if got := WithDocContext("template\n", ""); got != "template\n" {
+		t.Errorf("empty context changed the template: %q", got)
+	}
+	got := WithDocContext("template\n", "--- README.md ---\nA demo.")
+	if !strings.HasPrefix(got, "template\n") || !strings.Contains(got, "for context only") || !strings.HasSuffix(got, "--- README.md ---\nA demo.\n") {
+		t.Errorf("template with context: %q", got)
+	}
+}
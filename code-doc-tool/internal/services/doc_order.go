@@ -0,0 +1,65 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Orderings for the per-file sections of the combined document.
+const (
+	OrderPath      = "path"
+	OrderDirectory = "directory"
+	OrderLanguage  = "language"
+	OrderSize      = "size"
+)
+
+const DefaultOrder = OrderPath
+
+// ValidateOrder normalizes an ordering name, treating "" as DefaultOrder.
+func ValidateOrder(order string) (string, error) {
+	switch order = strings.ToLower(strings.TrimSpace(order)); order {
+	case "":
+		return DefaultOrder, nil
+	case OrderPath, OrderDirectory, OrderLanguage, OrderSize:
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown order %q, expected one of path, directory, language, size", order)
+}
+
+// OrderFiles sorts files in place for the given ordering. Ties always fall
+// back to path order so the result is deterministic.
+//   - path: alphabetical by full path
+//   - directory: each directory's own files before its subdirectories
+//   - language: grouped by detected language
+//   - size: smallest file first
+func OrderFiles(files []string, order string, languageOf LanguageFunc) {
+	sort.Strings(files)
+
+	switch order {
+	case OrderDirectory:
+		sort.SliceStable(files, func(i, j int) bool {
+			return filepath.Dir(files[i]) < filepath.Dir(files[j])
+		})
+	case OrderLanguage:
+		languages := make(map[string]string, len(files))
+		for _, file := range files {
+			languages[file] = languageOf(file)
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			return languages[files[i]] < languages[files[j]]
+		})
+	case OrderSize:
+		sizes := make(map[string]int64, len(files))
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil {
+				sizes[file] = info.Size()
+			}
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			return sizes[files[i]] < sizes[files[j]]
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrderFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"z.go":         strings.Repeat("x", 50),
+		"a.py":         strings.Repeat("x", 10),
+		"lib/b.go":     strings.Repeat("x", 30),
+		"lib/sub/c.py": strings.Repeat("x", 20),
+		"lib/d.js":     strings.Repeat("x", 40),
+	})
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{OrderPath, []string{"a.py", "lib/b.go", "lib/d.js", "lib/sub/c.py", "z.go"}},
+		{OrderDirectory, []string{"a.py", "z.go", "lib/b.go", "lib/d.js", "lib/sub/c.py"}},
+		{OrderLanguage, []string{"lib/b.go", "z.go", "lib/d.js", "a.py", "lib/sub/c.py"}},
+		{OrderSize, []string{"a.py", "lib/sub/c.py", "lib/b.go", "lib/d.js", "z.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			files := make([]string, len(tt.want))
+			for i, name := range tt.want {
+				files[len(files)-1-i] = filepath.Join(dir, filepath.FromSlash(name))
+			}
+			OrderFiles(files, tt.order, DetectLanguage)
+			if got := relPaths(t, dir, files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	if order, err := ValidateOrder(""); err != nil || order != DefaultOrder {
+		t.Errorf("ValidateOrder(\"\") = %q, %v", order, err)
+	}
+	if order, err := ValidateOrder(" Size "); err != nil || order != OrderSize {
+		t.Errorf("ValidateOrder(\" Size \") = %q, %v", order, err)
+	}
+	if _, err := ValidateOrder("random"); err == nil {
+		t.Error("ValidateOrder accepted an unknown order")
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"strings"
+	"sync"
+)
+
+// DocStream holds a text document while it is being assembled so it can
+// be sent to clients before it is complete. Markdown written to it is
+// rendered into the output format a block at a time, so what clients
+// receive matches the file the format's generator writes.
+type DocStream struct {
+	mu      sync.Mutex
+	data    []byte
+	pending string // markdown not rendered yet
+	closed  bool
+	changed chan struct{}
+
+	// render converts whole lines of markdown, ending each with a newline
+	render func(string) string
+	eol    string
+}
+
+// NewDocStream returns a stream rendering markdown the way g renders
+// whole documents, or false when g's output can't be produced piecemeal,
+// as with Word documents.
+func NewDocStream(g Generator) (*DocStream, bool) {
+	s := &DocStream{changed: make(chan struct{})}
+	switch g := g.(type) {
+	case *MarkdownGenerator:
+		s.render, s.eol = func(md string) string { return md + "\n" }, g.LineEnding
+	case *TextGenerator:
+		s.render, s.eol = RenderPlainText, g.LineEnding
+	case *ConfluenceGenerator:
+		s.render, s.eol = RenderConfluence, g.LineEnding
+	default:
+		return nil, false
+	}
+	return s, true
+}
+
+// Write appends markdown to the document. Everything up to the last blank
+// line outside a code block that has more text after it is rendered and
+// sent; the rest waits for more markdown or Finish.
+func (s *DocStream) Write(markdown string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.pending += markdown
+
+	cut, blank, offset := -1, -1, 0
+	inCodeBlock := false
+	for _, line := range strings.SplitAfter(s.pending, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			if !inCodeBlock && blank < 0 && offset > 0 {
+				blank = offset - 1 // the newline ending the line before
+			}
+		} else {
+			if blank >= 0 {
+				cut, blank = blank, -1
+			}
+			if strings.HasPrefix(trimmed, "```") {
+				inCodeBlock = !inCodeBlock
+			}
+		}
+		offset += len(line)
+	}
+	if cut < 0 {
+		return
+	}
+	s.emit(s.render(s.pending[:cut]))
+	s.pending = s.pending[cut+1:]
+}
+
+// Finish renders what is left of the document followed by trailer, ends
+// it with exactly one line ending and closes the stream.
+func (s *DocStream) Finish(trailer string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	rest := s.pending
+	if trailer != "" {
+		rest = strings.TrimRight(rest, "\n") + trailer
+	}
+	s.pending = ""
+	if text := strings.TrimRight(s.render(rest), "\n"); text != "" {
+		s.emit(text + "\n")
+	}
+	s.closed = true
+	s.notify()
+}
+
+// Close ends the stream, leaving the document as it is; used when its job
+// finishes without a document.
+func (s *DocStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.notify()
+	}
+}
+
+// Read returns the rendered document from offset onwards, whether it is
+// complete, and a channel closed when either changes.
+func (s *DocStream) Read(offset int) ([]byte, bool, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset = max(0, min(offset, len(s.data)))
+	return s.data[offset:len(s.data):len(s.data)], s.closed, s.changed
+}
+
+// emit appends rendered text with the generators' normalization and
+// wakes readers; the caller holds s.mu.
+func (s *DocStream) emit(text string) {
+	text = strings.ToValidUTF8(text, "\uFFFD")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	if s.eol != "\n" {
+		text = strings.ReplaceAll(text, "\n", s.eol)
+	}
+	s.data = append(s.data, text...)
+	s.notify()
+}
+
+// notify wakes readers; the caller holds s.mu.
+func (s *DocStream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const streamedDoc = "# Project\n\n**Language:** Go\n\n---\n\n" +
+	"## main.go\n\nStarts the *server*.\n\n```go\nfunc main() {\n\n\tserve()\n}\n```\n\n" +
+	"| Name | Kind |\n| --- | --- |\n| main | function |\n\n- first\n- second\n\n---\n\n" +
+	"## util.go\n\nHelpers.\n\n\n"
+
+func TestDocStream(t *testing.T) {
+	dir := t.TempDir()
+	generators := map[string]Generator{
+		"md":        &MarkdownGenerator{LineEnding: "\n"},
+		"md crlf":   &MarkdownGenerator{LineEnding: "\r\n"},
+		"txt":       &TextGenerator{LineEnding: "\n"},
+		"wiki crlf": &ConfluenceGenerator{LineEnding: "\r\n"},
+	}
+	for name, g := range generators {
+		for _, footer := range []string{"", "Generated by code-doc-tool"} {
+			for _, piece := range []int{1, 7, len(streamedDoc)} {
+				stream, ok := NewDocStream(g)
+				if !ok {
+					t.Fatalf("%s can't be streamed", name)
+				}
+				for i := 0; i < len(streamedDoc); i += piece {
+					stream.Write(streamedDoc[i:min(i+piece, len(streamedDoc))])
+				}
+				trailer := ""
+				if footer != "" {
+					trailer = FooterSection(footer)
+				}
+				stream.Finish(trailer)
+
+				path := filepath.Join(dir, "doc")
+				if err := GenerateWithFooter(g, streamedDoc, path, footer); err != nil {
+					t.Fatal(err)
+				}
+				want, _ := os.ReadFile(path)
+				got, closed, _ := stream.Read(0)
+				if !closed || string(got) != string(want) {
+					t.Errorf("%s, footer %q, pieces of %d: streamed\n%q\nwant\n%q", name, footer, piece, got, want)
+				}
+			}
+		}
+	}
+
+	if _, ok := NewDocStream(&DocxGenerator{}); ok {
+		t.Error("Word documents can't be streamed")
+	}
+}
+
+func TestDocStreamRead(t *testing.T) {
+	stream, _ := NewDocStream(&MarkdownGenerator{LineEnding: "\n"})
+	_, closed, changed := stream.Read(0)
+	if closed {
+		t.Fatal("new stream is closed")
+	}
+
+	// A paragraph is held back until the next one starts
+	stream.Write("# Title\n\nFirst para")
+	select {
+	case <-changed:
+	default:
+		t.Fatal("readers weren't woken")
+	}
+	data, _, _ := stream.Read(0)
+	if string(data) != "# Title\n" {
+		t.Errorf("sent %q, want the title only", data)
+	}
+	stream.Write("graph.\n\nSecond.")
+	data, _, _ = stream.Read(len("# Title\n"))
+	if string(data) != "\nFirst paragraph.\n" {
+		t.Errorf("sent %q from the offset", data)
+	}
+
+	// Closing keeps what was sent and drops the rest
+	stream.Close()
+	stream.Write("\n\nMore.\n\n")
+	data, closed, _ = stream.Read(0)
+	if !closed || string(data) != "# Title\n\nFirst paragraph.\n" {
+		t.Errorf("closed stream %q, %v", data, closed)
+	}
+}
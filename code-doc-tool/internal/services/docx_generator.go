@@ -2,30 +2,90 @@ package services
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/gomutex/godocx"
+	"github.com/gomutex/godocx/docx"
+
+	"code-doc-tool/internal/utils"
 )
 
-type DocxGenerator struct{}
+type DocxGenerator struct {
+	Style DocxStyle
+
+	// Validate checks the written file with ValidateDocx, so a broken
+	// document fails generation rather than reaching the user
+	Validate bool
+}
 
 func NewDocxGenerator() *DocxGenerator {
-	return &DocxGenerator{}
+	return &DocxGenerator{Style: docxStyle, Validate: docxValidate}
+}
+
+func (g *DocxGenerator) Extension() string { return "docx" }
+
+func (g *DocxGenerator) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
 }
 
 // Generate formatted .docx from structured text input
-func (g *DocxGenerator) GenerateDocumentation(docText string, outputPath string) error {
-	doc, err := godocx.NewDocument()
+func (g *DocxGenerator) GenerateDocumentation(docText string, outputPath string) (err error) {
+	// Treat a panic inside godocx like any other generation failure
+	defer func() {
+		if r := recover(); r != nil {
+			os.Remove(outputPath)
+			err = fmt.Errorf("docx generation panicked: %v", r)
+		}
+	}()
+
+	// A template brings its own styles, page setup and header/footer
+	var doc *docx.RootDoc
+	if g.Style.Template != "" {
+		doc, err = godocx.OpenDocument(g.Style.Template)
+	} else {
+		doc, err = godocx.NewDocument()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create document: %w", err)
 	}
 
-	lines := strings.Split(docText, "\n")
+	// Agent text goes into the XML as-is, so anything XML can't carry
+	// would leave a document Word refuses to open
+	lines := strings.Split(sanitizeXMLText(docText), "\n")
 	inCodeBlock := false
 
+	// Images are inserted after saving, in place of a placeholder paragraph
+	var images []docxImage
+
+	// Markdown table rows are buffered until the table ends
+	var tableRows [][]string
+	flushTable := func() {
+		if len(tableRows) == 0 {
+			return
+		}
+		table := doc.AddTable()
+		for _, cells := range tableRows {
+			row := table.AddRow()
+			for _, cell := range cells {
+				row.AddCell().AddParagraph(cell)
+			}
+		}
+		tableRows = nil
+	}
+
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
 
+		if !inCodeBlock && strings.HasPrefix(trimmed, "|") {
+			if cells := parseTableRow(trimmed); !isTableSeparator(cells) {
+				tableRows = append(tableRows, cells)
+			}
+			continue
+		}
+		flushTable()
+
 		switch {
 		case trimmed == "":
 			doc.AddEmptyParagraph()
@@ -40,8 +100,9 @@ func (g *DocxGenerator) GenerateDocumentation(docText string, outputPath string)
 			}
 
 		case inCodeBlock:
+			// Keep indentation inside code blocks
 			p := doc.AddParagraph("")
-			p.AddText(trimmed)
+			p.AddText(strings.TrimRight(line, "\r"))
 
 		case strings.HasPrefix(trimmed, "# "):
 			title := strings.TrimPrefix(trimmed, "# ")
@@ -53,6 +114,26 @@ func (g *DocxGenerator) GenerateDocumentation(docText string, outputPath string)
 			p := doc.AddParagraph(subtitle)
 			p.Style("Heading 2")
 
+		case strings.HasPrefix(trimmed, "### "):
+			p := doc.AddParagraph(strings.TrimPrefix(trimmed, "### "))
+			p.Style("Heading 3")
+
+		case mdHeading.MatchString(trimmed):
+			// Deeper headings, e.g. from a heading offset
+			m := mdHeading.FindStringSubmatch(trimmed)
+			p := doc.AddParagraph(m[2])
+			p.Style(fmt.Sprintf("Heading %d", len(m[1])))
+
+		case mdImage.MatchString(trimmed):
+			caption, target, _ := parseImage(trimmed, filepath.Dir(outputPath))
+			if readableImage(target) {
+				images = append(images, docxImage{Path: target, Caption: caption})
+				doc.AddParagraph(imagePlaceholder(len(images)))
+			}
+			if caption != "" {
+				doc.AddParagraph(caption).Style("Caption")
+			}
+
 		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
 			content := trimmed[2:]
 			p := doc.AddParagraph(content)
@@ -63,9 +144,73 @@ func (g *DocxGenerator) GenerateDocumentation(docText string, outputPath string)
 		}
 	}
 
+	flushTable()
+
 	if err := doc.SaveTo(outputPath); err != nil {
+		if utils.IsDiskFull(err) {
+			// Remove the partially written file
+			os.Remove(outputPath)
+			return fmt.Errorf("failed to save docx: %w", utils.WrapDiskFull(err))
+		}
 		return fmt.Errorf("failed to save docx: %w", err)
 	}
 
+	if err := addHeaderFooter(outputPath, g.Style.Header, g.Style.Footer); err != nil {
+		os.Remove(outputPath)
+		if utils.IsDiskFull(err) {
+			err = utils.WrapDiskFull(err)
+		}
+		return fmt.Errorf("failed to add header/footer: %w", err)
+	}
+
+	if err := addImages(outputPath, images); err != nil {
+		os.Remove(outputPath)
+		if utils.IsDiskFull(err) {
+			err = utils.WrapDiskFull(err)
+		}
+		return fmt.Errorf("failed to add images: %w", err)
+	}
+
+	if g.Validate {
+		if err := ValidateDocx(outputPath); err != nil {
+			os.Remove(outputPath)
+			return fmt.Errorf("generated docx is invalid: %w", err)
+		}
+	}
+
 	return nil
 }
+
+// sanitizeXMLText drops the characters XML 1.0 can't represent, such as
+// null bytes and most control characters, and replaces invalid UTF-8.
+func sanitizeXMLText(s string) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20, r == 0xFFFE, r == 0xFFFF:
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func parseTableRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
+}
+
+// isTableSeparator reports whether cells form a "| --- | :---: |" row.
+func isTableSeparator(cells []string) bool {
+	for _, cell := range cells {
+		if strings.Trim(cell, "-: ") != "" || !strings.Contains(cell, "-") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomutex/godocx"
+)
+
+// checkDocxXML parses every XML part of the docx at path.
+func checkDocxXML(t *testing.T, path string) string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var body string
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".xml") && !strings.HasSuffix(f.Name, ".rels") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		decoder := xml.NewDecoder(strings.NewReader(string(data)))
+		for {
+			if _, err := decoder.Token(); err != nil {
+				if !errors.Is(err, io.EOF) {
+					t.Errorf("%s isn't well-formed XML: %v", f.Name, err)
+				}
+				break
+			}
+		}
+		if f.Name == "word/document.xml" {
+			body = string(data)
+		}
+	}
+	return body
+}
+
+func TestDocxControlCharacters(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.docx")
+	text := "# Ti\x00tle\n\nBell\x07 and \x1b[31mescape\x0b, tab\tkept.\n\nBad \xff\xfe bytes.\n\n| A\x01 | B |\n| --- | --- |\n| c\x02 | d |\n"
+	if err := NewDocxGenerator().GenerateDocumentation(text, out); err != nil {
+		t.Fatal(err)
+	}
+
+	body := checkDocxXML(t, out)
+	for _, want := range []string{"Title", "Bell and [31mescape, tab", "Bad \uFFFD bytes."} {
+		if !strings.Contains(body, want) {
+			t.Errorf("document.xml is missing %q", want)
+		}
+	}
+	if _, err := godocx.OpenDocument(out); err != nil {
+		t.Errorf("generated docx doesn't open: %v", err)
+	}
+}
+
+func TestSanitizeXMLText(t *testing.T) {
+	tests := map[string]string{
+		"plain":             "plain",
+		"a\x00b\x1fc":       "abc",
+		"keep\t\r\n":        "keep\t\r\n",
+		"\uFFFE\uFFFFnon":   "non",
+		"bad\xc3":           "bad\uFFFD",
+		"<tag> & \"quote\"": "<tag> & \"quote\"",
+	}
+	for in, want := range tests {
+		if got := sanitizeXMLText(in); got != want {
+			t.Errorf("sanitizeXMLText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// docxImage is an image file placed in a generated .docx where its
+// placeholder paragraph stands.
+type docxImage struct {
+	Path    string
+	Caption string
+}
+
+const (
+	// Images wider than this are scaled down to fit the page
+	maxImageWidthEMU = 6 * 914400
+	// EMU per pixel at 96 DPI
+	emuPerPixel = 9525
+)
+
+// imagePlaceholder is the text of the paragraph the nth image replaces.
+func imagePlaceholder(n int) string {
+	return fmt.Sprintf("docgen-image-%d", n)
+}
+
+// readableImage reports whether path is an image addImages can embed.
+func readableImage(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, _, err = image.DecodeConfig(f)
+	return err == nil
+}
+
+// addImages rewrites the .docx at path, replacing the placeholder
+// paragraph of each image with the image itself.
+func addImages(path string, images []docxImage) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	parts, order, err := readDocxParts(path)
+	if err != nil {
+		return err
+	}
+	document, rels, types := string(parts["word/document.xml"]), string(parts["word/_rels/document.xml.rels"]), string(parts["[Content_Types].xml"])
+	if document == "" || rels == "" || types == "" {
+		return fmt.Errorf("not a word document: missing main parts")
+	}
+
+	for i, img := range images {
+		n := i + 1
+		data, err := os.ReadFile(img.Path)
+		if err != nil {
+			return err
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(img.Path), err)
+		}
+		ext := format
+		if ext == "jpeg" {
+			ext = "jpg"
+		}
+
+		part := fmt.Sprintf("word/media/docgen_image%d.%s", n, ext)
+		rid := fmt.Sprintf("rIdDocgenImage%d", n)
+		parts[part] = data
+		order = append(order, part)
+		rels = strings.Replace(rels, "</Relationships>", fmt.Sprintf(
+			`<Relationship Id="%s" Type="%s/image" Target="%s"/></Relationships>`,
+			rid, docxRelNS, strings.TrimPrefix(part, "word/")), 1)
+		if !strings.Contains(types, `Extension="`+ext+`"`) {
+			types = strings.Replace(types, "</Types>", fmt.Sprintf(
+				`<Default Extension="%s" ContentType="image/%s"/></Types>`, ext, format), 1)
+		}
+
+		start, end, ok := placeholderParagraph(document, imagePlaceholder(n))
+		if !ok {
+			return fmt.Errorf("placeholder for image %d not found", n)
+		}
+		cx, cy := imageExtent(config.Width, config.Height)
+		document = document[:start] + drawingXML(n, rid, img.Caption, cx, cy) + document[end:]
+	}
+
+	parts["word/document.xml"] = []byte(document)
+	parts["word/_rels/document.xml.rels"] = []byte(rels)
+	parts["[Content_Types].xml"] = []byte(types)
+	return writeDocxParts(path, parts, order)
+}
+
+// placeholderParagraph finds the bounds of the <w:p> element containing
+// text.
+func placeholderParagraph(document, text string) (start, end int, ok bool) {
+	at := strings.Index(document, ">"+text+"<")
+	if at < 0 {
+		return 0, 0, false
+	}
+	start = max(strings.LastIndex(document[:at], "<w:p>"), strings.LastIndex(document[:at], "<w:p "))
+	closing := strings.Index(document[at:], "</w:p>")
+	if start < 0 || closing < 0 {
+		return 0, 0, false
+	}
+	return start, at + closing + len("</w:p>"), true
+}
+
+// imageExtent converts a size in pixels to EMU, scaled down to fit the
+// page width.
+func imageExtent(width, height int) (cx, cy int64) {
+	cx, cy = int64(width)*emuPerPixel, int64(height)*emuPerPixel
+	if cx > maxImageWidthEMU {
+		cy = cy * maxImageWidthEMU / cx
+		cx = maxImageWidthEMU
+	}
+	return cx, cy
+}
+
+func drawingXML(n int, rid, caption string, cx, cy int64) string {
+	var descr strings.Builder
+	xml.EscapeText(&descr, []byte(caption))
+	return fmt.Sprintf(`<w:p><w:pPr><w:jc w:val="center"/></w:pPr><w:r><w:drawing>`+
+		`<wp:inline xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%[4]d" cy="%[5]d"/><wp:docPr id="%[1]d" name="Image %[1]d" descr="%[3]s"/>`+
+		`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">`+
+		`<a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:nvPicPr><pic:cNvPr id="%[1]d" name="Image %[1]d"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip xmlns:r="%[6]s" r:embed="%[2]s"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%[4]d" cy="%[5]d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>`,
+		1000+n, rid, descr.String(), cx, cy, docxRelNS)
+}
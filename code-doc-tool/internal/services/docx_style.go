@@ -0,0 +1,169 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DocxStyle customises generated .docx files. Template is an existing
+// .docx whose styles, page setup and header/footer are reused;
+// Header and Footer, when set, replace the default header and footer text.
+type DocxStyle struct {
+	Template string
+	Header   string
+	Footer   string
+}
+
+var docxStyle DocxStyle
+
+// SetDocxStyle sets the styling applied by every DocxGenerator created
+// afterwards.
+func SetDocxStyle(style DocxStyle) {
+	docxStyle = style
+}
+
+const (
+	docxMainNS = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	docxRelNS  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+	// Part names and relationship IDs unlikely to clash with a template's own
+	headerPart = "word/docgen_header.xml"
+	footerPart = "word/docgen_footer.xml"
+	headerRID  = "rIdDocgenHeader"
+	footerRID  = "rIdDocgenFooter"
+)
+
+var (
+	selfClosingSectPr = regexp.MustCompile(`<w:sectPr(\s[^>]*)?/>`)
+	sectPrOpen        = regexp.MustCompile(`<w:sectPr(\s[^>]*)?>`)
+)
+
+// addHeaderFooter rewrites the .docx at path so its body section uses the
+// given header and footer text. Empty strings leave that part untouched.
+func addHeaderFooter(path, header, footer string) error {
+	if header == "" && footer == "" {
+		return nil
+	}
+
+	parts, order, err := readDocxParts(path)
+	if err != nil {
+		return err
+	}
+
+	document, rels, types := string(parts["word/document.xml"]), string(parts["word/_rels/document.xml.rels"]), string(parts["[Content_Types].xml"])
+	if document == "" || rels == "" || types == "" {
+		return fmt.Errorf("not a word document: missing main parts")
+	}
+
+	var refs strings.Builder
+	add := func(kind, part, rid, text string) {
+		if text == "" {
+			return
+		}
+		tag := "hdr"
+		if kind == "footer" {
+			tag = "ftr"
+		}
+		parts[part] = []byte(hdrFtrXML(tag, text))
+		order = append(order, part)
+
+		// Only one default header/footer per section, so drop the template's
+		document = regexp.MustCompile(`<w:`+kind+`Reference\s[^>]*w:type="default"[^>]*/>`).ReplaceAllString(document, "")
+		rels = strings.Replace(rels, "</Relationships>", fmt.Sprintf(
+			`<Relationship Id="%s" Type="%s/%s" Target="%s"/></Relationships>`,
+			rid, docxRelNS, kind, strings.TrimPrefix(part, "word/")), 1)
+		types = strings.Replace(types, "</Types>", fmt.Sprintf(
+			`<Override PartName="/%s" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.%s+xml"/></Types>`,
+			part, kind), 1)
+		fmt.Fprintf(&refs, `<w:%sReference w:type="default" r:id="%s"/>`, kind, rid)
+	}
+	add("header", headerPart, headerRID, header)
+	add("footer", footerPart, footerRID, footer)
+
+	// Attach the references to the body's section properties, which are
+	// the last sectPr in the document
+	document = selfClosingSectPr.ReplaceAllString(document, "<w:sectPr$1></w:sectPr>")
+	if locs := sectPrOpen.FindAllStringIndex(document, -1); len(locs) > 0 {
+		end := locs[len(locs)-1][1]
+		document = document[:end] + refs.String() + document[end:]
+	} else {
+		document = strings.Replace(document, "</w:body>", "<w:sectPr>"+refs.String()+"</w:sectPr></w:body>", 1)
+	}
+	if !strings.Contains(document, `xmlns:r="`) {
+		document = strings.Replace(document, "<w:document ", `<w:document xmlns:r="`+docxRelNS+`" `, 1)
+	}
+	parts["word/document.xml"] = []byte(document)
+	parts["word/_rels/document.xml.rels"] = []byte(rels)
+	parts["[Content_Types].xml"] = []byte(types)
+	return writeDocxParts(path, parts, order)
+}
+
+// readDocxParts reads every part of the .docx at path, returning them by
+// name along with the order they are stored in.
+func readDocxParts(path string) (map[string][]byte, []string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	parts := map[string][]byte{}
+	var order []string
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		parts[f.Name] = data
+		order = append(order, f.Name)
+	}
+	return parts, order, nil
+}
+
+// writeDocxParts replaces the .docx at path with parts, stored in order.
+func writeDocxParts(path string, parts map[string][]byte, order []string) error {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range order {
+		fw, err := w.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(parts[name]); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func hdrFtrXML(tag, text string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<w:%s xmlns:w="%s" xmlns:r="%s">`, tag, docxMainNS, docxRelNS)
+	style := "Header"
+	if tag == "ftr" {
+		style = "Footer"
+	}
+	// One paragraph per line of text
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(&b, `<w:p><w:pPr><w:pStyle w:val="%s"/></w:pPr><w:r><w:t xml:space="preserve">`, style)
+		xml.EscapeText(&b, []byte(line))
+		b.WriteString(`</w:t></w:r></w:p>`)
+	}
+	fmt.Fprintf(&b, `</w:%s>`, tag)
+	return b.String()
+}
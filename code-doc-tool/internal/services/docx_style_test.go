@@ -0,0 +1,77 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomutex/godocx"
+)
+
+// brandTemplate writes a .docx template carrying a style of its own.
+func brandTemplate(t *testing.T, path string) {
+	t.Helper()
+	doc, err := godocx.NewDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+	parts, order, err := readDocxParts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts["word/styles.xml"] = []byte(strings.Replace(string(parts["word/styles.xml"]), "</w:styles>",
+		`<w:style w:type="paragraph" w:customStyle="1" w:styleId="BrandBody"><w:name w:val="Brand Body"/>`+
+			`<w:rPr><w:rFonts w:ascii="Brand Sans" w:hAnsi="Brand Sans"/></w:rPr></w:style></w:styles>`, 1))
+	if err := writeDocxParts(path, parts, order); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDocxTemplateStyle(t *testing.T) {
+	dir := t.TempDir()
+	template := filepath.Join(dir, "brand.docx")
+	brandTemplate(t, template)
+
+	out := filepath.Join(dir, "out.docx")
+	g := &DocxGenerator{Style: DocxStyle{Template: template, Header: "ACME Corp", Footer: "Confidential"}, Validate: true}
+	if err := g.GenerateDocumentation("# Title\n\nBody text.\n", out); err != nil {
+		t.Fatal(err)
+	}
+
+	parts, _, err := readDocxParts(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if styles := string(parts["word/styles.xml"]); !strings.Contains(styles, "Brand Sans") {
+		t.Error("the template's styles weren't carried over")
+	}
+	if !strings.Contains(string(parts[headerPart]), "ACME Corp") || !strings.Contains(string(parts[footerPart]), "Confidential") {
+		t.Error("header or footer text missing")
+	}
+	document := string(parts["word/document.xml"])
+	for _, want := range []string{headerRID, footerRID, "Body text."} {
+		if !strings.Contains(document, want) {
+			t.Errorf("document.xml is missing %q", want)
+		}
+	}
+}
+
+func TestDocxDefaultStyle(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.docx")
+	if err := (&DocxGenerator{}).GenerateDocumentation("# Title\n", out); err != nil {
+		t.Fatal(err)
+	}
+	parts, _, err := readDocxParts(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parts[headerPart]; ok {
+		t.Error("a header was added without one configured")
+	}
+	if strings.Contains(string(parts["word/styles.xml"]), "Brand Sans") {
+		t.Error("default document has template styles")
+	}
+}
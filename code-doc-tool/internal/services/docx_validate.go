@@ -0,0 +1,85 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+)
+
+var docxValidate bool
+
+// SetDocxValidation turns on ValidateDocx for every DocxGenerator created
+// afterwards.
+func SetDocxValidation(on bool) {
+	docxValidate = on
+}
+
+// docxRequiredParts must be present in every Word document.
+var docxRequiredParts = []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml"}
+
+// ValidateDocx checks that the named file is a well-formed OOXML word
+// document: a readable zip holding the required parts, every XML part
+// parsing cleanly, and word/document.xml rooted at w:document. It checks
+// structure only, not the full schema.
+func ValidateDocx(name string) error {
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return fmt.Errorf("not a zip archive: %w", err)
+	}
+	defer r.Close()
+
+	present := map[string]bool{}
+	for _, f := range r.File {
+		present[f.Name] = true
+	}
+	for _, name := range docxRequiredParts {
+		if !present[name] {
+			return fmt.Errorf("missing part %s", name)
+		}
+	}
+
+	for _, f := range r.File {
+		if ext := path.Ext(f.Name); ext != ".xml" && ext != ".rels" {
+			continue
+		}
+		root, err := checkXMLPart(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if f.Name == "word/document.xml" && (root.Space != docxMainNS || root.Local != "document") {
+			return fmt.Errorf("%s: root element is %s, expected w:document", f.Name, root.Local)
+		}
+	}
+	return nil
+}
+
+// checkXMLPart parses a zip entry to the end and returns its root element.
+func checkXMLPart(f *zip.File) (xml.Name, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return xml.Name{}, err
+	}
+	defer rc.Close()
+
+	var root xml.Name
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok && root.Local == "" {
+			root = start.Name
+		}
+	}
+	if root.Local == "" {
+		return xml.Name{}, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateDocx(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.docx")
+	if err := NewDocxGenerator().GenerateDocumentation("# Title\n\nBody.\n", valid); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateDocx(valid); err != nil {
+		t.Fatalf("generated document rejected: %v", err)
+	}
+
+	// corrupt rewrites one part of the valid document, or drops it when
+	// change returns nil
+	corrupt := func(name, part string, change func(data string) []byte) string {
+		parts, order, err := readDocxParts(valid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data := change(string(parts[part])); data != nil {
+			parts[part] = data
+		} else {
+			order = slices.DeleteFunc(order, func(name string) bool { return name == part })
+		}
+		path := filepath.Join(dir, name)
+		if err := writeDocxParts(path, parts, order); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	notZip := filepath.Join(dir, "notzip.docx")
+	os.WriteFile(notZip, []byte("# Title\n"), 0644)
+
+	tests := map[string]struct {
+		path string
+		want string
+	}{
+		"not a zip": {notZip, "not a zip archive"},
+		"truncated part": {corrupt("truncated.docx", "word/document.xml", func(data string) []byte {
+			return []byte(data[:len(data)/2])
+		}), "word/document.xml"},
+		"wrong root": {corrupt("root.docx", "word/document.xml", func(data string) []byte {
+			return []byte(strings.ReplaceAll(data, "w:document", "w:body2"))
+		}), "expected w:document"},
+		"missing part": {corrupt("missing.docx", "[Content_Types].xml", func(string) []byte { return nil }),
+			"missing part [Content_Types].xml"},
+	}
+	for name, tt := range tests {
+		if err := ValidateDocx(tt.path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error mentioning %q", name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of entries in an error-handling table.
+const (
+	ErrorKindValue  = "error value"
+	ErrorKindType   = "error type"
+	ErrorKindCode   = "error code"
+	ErrorKindStatus = "HTTP status"
+)
+
+// ErrorCode is an error a source file defines or an HTTP error status it
+// responds with.
+type ErrorCode struct {
+	Name   string
+	Kind   string
+	Detail string
+	Line   int
+}
+
+var (
+	// var ErrNotFound = errors.New("not found")
+	goErrorValue = regexp.MustCompile(`^\s*(?:var\s+)?([Ee]rr\w*)\s*=\s*(?:errors\.New|fmt\.Errorf)\(\s*"((?:[^"\\]|\\.)*)"`)
+	// type ValidationError struct
+	goErrorType = regexp.MustCompile(`^\s*type\s+(\w+Error)\s+struct\b`)
+	// ErrCodeNotFound = "not_found"
+	goErrorCode = regexp.MustCompile(`^\s*(?:const\s+)?(Err\w*)\s*(?:\w+\s*)?=\s*"([^"]*)"`)
+
+	// class NotFoundError(Exception), class NotFound extends Error,
+	// class NotFoundException extends \RuntimeException
+	pyErrorClass  = regexp.MustCompile(`^\s*class\s+(\w+)\s*\(\s*([\w.]*(?:Error|Exception))\s*\)`)
+	jsErrorClass  = regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?class\s+(\w+)\s+extends\s+([\w.]*Error)\b`)
+	phpErrorClass = regexp.MustCompile(`^\s*(?:final\s+|abstract\s+)?class\s+(\w+)\s+extends\s+\\?([\w\\]*(?:Exception|Error))\b`)
+
+	// http.StatusNotFound, fiber.StatusNotFound
+	namedStatus = regexp.MustCompile(`\b(?:http|fiber)\.(Status\w+)`)
+	// WriteHeader(404), res.status(404), abort(404), status_code=404, ...
+	literalStatus = regexp.MustCompile(`(?:\b(?:WriteHeader|SendStatus|Status|status|sendStatus|abort|http_response_code)\(\s*|\b(?:status_code|statusCode|status)\s*[=:]\s*)([45]\d\d)\b`)
+)
+
+// statusByName maps net/http constant names such as StatusNotFound to
+// their code, derived from the status texts.
+var statusByName = func() map[string]int {
+	names := map[string]int{}
+	clean := regexp.MustCompile(`[^A-Za-z0-9]+`)
+	for code := 400; code < 600; code++ {
+		if text := http.StatusText(code); text != "" {
+			names["Status"+clean.ReplaceAllString(text, "")] = code
+		}
+	}
+	return names
+}()
+
+// ExtractErrorCodes lists the errors path declares and the HTTP error
+// statuses it uses, by line. Statuses are listed once each, at their
+// first use.
+func ExtractErrorCodes(path, language string) ([]ErrorCode, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var codes []ErrorCode
+	statuses := map[int]bool{}
+	addStatus := func(code, line int) {
+		if code < 400 || code > 599 || statuses[code] {
+			return
+		}
+		statuses[code] = true
+		codes = append(codes, ErrorCode{Name: strconv.Itoa(code), Kind: ErrorKindStatus, Detail: http.StatusText(code), Line: line})
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		switch language {
+		case "Go":
+			if m := goErrorValue.FindStringSubmatch(line); m != nil {
+				codes = append(codes, ErrorCode{Name: m[1], Kind: ErrorKindValue, Detail: m[2], Line: n})
+			} else if m := goErrorType.FindStringSubmatch(line); m != nil {
+				codes = append(codes, ErrorCode{Name: m[1], Kind: ErrorKindType, Line: n})
+			} else if m := goErrorCode.FindStringSubmatch(line); m != nil {
+				codes = append(codes, ErrorCode{Name: m[1], Kind: ErrorKindCode, Detail: m[2], Line: n})
+			}
+		case "Python":
+			if m := pyErrorClass.FindStringSubmatch(line); m != nil {
+				codes = append(codes, ErrorCode{Name: m[1], Kind: ErrorKindType, Detail: "extends " + m[2], Line: n})
+			}
+		case "JavaScript", "TypeScript":
+			if m := jsErrorClass.FindStringSubmatch(line); m != nil {
+				codes = append(codes, ErrorCode{Name: m[1], Kind: ErrorKindType, Detail: "extends " + m[2], Line: n})
+			}
+		case "PHP":
+			if m := phpErrorClass.FindStringSubmatch(line); m != nil {
+				codes = append(codes, ErrorCode{Name: m[1], Kind: ErrorKindType, Detail: "extends " + m[2], Line: n})
+			}
+		}
+
+		for _, m := range namedStatus.FindAllStringSubmatch(line, -1) {
+			addStatus(statusByName[m[1]], n)
+		}
+		for _, m := range literalStatus.FindAllStringSubmatch(line, -1) {
+			code, _ := strconv.Atoi(m[1])
+			addStatus(code, n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Declared errors first, then statuses by code
+	sort.SliceStable(codes, func(i, j int) bool {
+		if (codes[i].Kind == ErrorKindStatus) != (codes[j].Kind == ErrorKindStatus) {
+			return codes[j].Kind == ErrorKindStatus
+		}
+		if codes[i].Kind == ErrorKindStatus {
+			return codes[i].Name < codes[j].Name
+		}
+		return false
+	})
+	return codes, nil
+}
+
+// RenderErrorTable renders codes as a markdown table, or "" when there
+// are none.
+func RenderErrorTable(codes []ErrorCode) string {
+	if len(codes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("| Error | Kind | Details | Line |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, c := range codes {
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %d |\n", c.Name, c.Kind, escapeCell(c.Detail), c.Line)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+var errorHandlingHeading = regexp.MustCompile(`(?i)^(?:\d+\.\s*)?error handling\b`)
+
+// InsertErrorTable adds table at the end of the first Error Handling
+// section of doc, after the agent's prose. A doc without that section is
+// returned unchanged.
+func InsertErrorTable(doc, table string) string {
+	if table == "" {
+		return doc
+	}
+	lines := strings.Split(doc, "\n")
+	inCodeBlock := false
+	section := 0 // level of the Error Handling heading once found
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		m := mdHeading.FindStringSubmatch(trimmed)
+		if m == nil || inCodeBlock {
+			continue
+		}
+		if section > 0 && len(m[1]) <= section {
+			return insertLines(lines, i, table)
+		}
+		if section == 0 && errorHandlingHeading.MatchString(strings.Trim(m[2], "*_ ")) {
+			section = len(m[1])
+		}
+	}
+	if section == 0 {
+		return doc
+	}
+	return insertLines(lines, len(lines), table)
+}
+
+// insertLines puts table before lines[at], separated by blank lines and
+// ahead of any trailing blank lines or rules closing the section.
+func insertLines(lines []string, at int, table string) string {
+	for at > 0 {
+		if t := strings.TrimSpace(lines[at-1]); t != "" && t != "---" {
+			break
+		}
+		at--
+	}
+	block := append([]string{"", "Errors found in the source:", ""}, strings.Split(table, "\n")...)
+	out := append(append(append([]string{}, lines[:at]...), block...), lines[at:]...)
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractErrorCodes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"api.go": `package api
+
+var ErrNotFound = errors.New("not found")
+var errQuota = fmt.Errorf("quota \"exceeded\"")
+
+type ValidationError struct{ Field string }
+
+const ErrCodeConflict = "conflict"
+
+func handle(w http.ResponseWriter, c *fiber.Ctx) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+	c.Status(fiber.StatusNotFound)
+	w.WriteHeader(404)
+	w.WriteHeader(200)
+	c.Status(429)
+}
+`,
+		"errors.py":  "class NotFoundError(Exception):\n    pass\n\nclass Plain(object):\n    pass\n\nabort(403)\n",
+		"errors.ts":  "export class ApiError extends Error {}\nres.status(500).send()\n",
+		"errors.php": "<?php\nfinal class MissingException extends \\RuntimeException {}\nhttp_response_code(410);\n",
+	})
+
+	tests := []struct {
+		file, language string
+		want           []string
+	}{
+		{"api.go", "Go", []string{
+			"ErrNotFound/error value/not found/3",
+			`errQuota/error value/quota \"exceeded\"/4`,
+			"ValidationError/error type//6",
+			"ErrCodeConflict/error code/conflict/8",
+			"404/HTTP status/Not Found/12",
+			"429/HTTP status/Too Many Requests/15",
+			"503/HTTP status/Service Unavailable/11",
+		}},
+		{"errors.py", "Python", []string{"NotFoundError/error type/extends Exception/1", "403/HTTP status/Forbidden/7"}},
+		{"errors.ts", "TypeScript", []string{"ApiError/error type/extends Error/1", "500/HTTP status/Internal Server Error/2"}},
+		{"errors.php", "PHP", []string{"MissingException/error type/extends RuntimeException/2", "410/HTTP status/Gone/3"}},
+	}
+	for _, tt := range tests {
+		codes, err := ExtractErrorCodes(filepath.Join(dir, tt.file), tt.language)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range codes {
+			got = append(got, fmt.Sprintf("%s/%s/%s/%d", c.Name, c.Kind, c.Detail, c.Line))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestRenderErrorTable(t *testing.T) {
+	if got := RenderErrorTable(nil); got != "" {
+		t.Errorf("table without errors: %q", got)
+	}
+	got := RenderErrorTable([]ErrorCode{
+		{Name: "ErrPipe", Kind: ErrorKindValue, Detail: "a|b", Line: 3},
+		{Name: "404", Kind: ErrorKindStatus, Detail: "Not Found", Line: 9},
+	})
+	want := "| Error | Kind | Details | Line |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| `ErrPipe` | error value | a\\|b | 3 |\n" +
+		"| `404` | HTTP status | Not Found | 9 |"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInsertErrorTable(t *testing.T) {
+	table := "| Error | Kind | Details | Line |"
+	doc := "# api.go\n\n## 7. Error Handling\nReturns errors.\n```\n## not a heading\n```\n### Retries\nNone.\n\n---\n\n## 8. Usage Example\nCall it.\n"
+	want := "# api.go\n\n## 7. Error Handling\nReturns errors.\n```\n## not a heading\n```\n### Retries\nNone.\n\n" +
+		"Errors found in the source:\n\n" + table + "\n\n---\n\n## 8. Usage Example\nCall it.\n"
+	if got := InsertErrorTable(doc, table); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The last section takes the table at the end
+	if got := InsertErrorTable("## **Error handling**\nText.\n", table); got != "## **Error handling**\nText.\n\nErrors found in the source:\n\n"+table+"\n" {
+		t.Errorf("trailing section: %q", got)
+	}
+	if got := InsertErrorTable("## Overview\nText.\n", table); got != "## Overview\nText.\n" {
+		t.Errorf("doc without the section changed: %q", got)
+	}
+}
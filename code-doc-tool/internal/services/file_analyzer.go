@@ -2,102 +2,242 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
-	"os"
-)
-
-func AnalyzeProject(codeFilePath string) (string, error) {
-	fmt.Printf("codeFilePath: %s\n", codeFilePath)
-	file, err := os.Open(codeFilePath)
-	if err != nil {
-		return "", fmt.Errorf("cannot open code file: %w", err)
-	}
-	defer file.Close()
-
-	formatTemplate := `
-		# Project Technical Documentation
-
-		## 1. Overview
-		- Purpose of the project
-		- High-level description of what it does
+	"path/filepath"
+	"strings"
 
-		## 2. Technology Stack
-		- Languages used
-		- Frameworks / Libraries
-		- External Services (APIs, DBs, etc.)
-
-		## 3. Architecture
-		- High-level description (monolith, microservices, etc.)
-		- Folder / module structure
-		- Data flow or sequence diagram (if applicable)
-
-		## 4. Setup & Installation
-		- Prerequisites
-		- Installation steps
-		- How to run locally / deploy
-
-		## 5. APIs
-		- Endpoint details (method, path, description, parameters, response)
+	"code-doc-tool/internal/config"
+	"code-doc-tool/internal/utils"
+)
 
-		## 6. Functions / Classes
-		- Function name, inputs, outputs, purpose
+// AgentStatusError is returned when the agent answers with a non-200
+// status.
+type AgentStatusError struct {
+	StatusCode int
+	Body       string
+}
 
-		## 7. Error Handling
-		- Common error codes
-		- Known failure scenarios
+func (e *AgentStatusError) Error() string {
+	return "agent error: " + e.Body
+}
 
-		## 8. Usage Example
-		- Sample request (curl / Python snippet)
-		- Sample response
+// ErrInvalidAgentResponse marks agent replies that couldn't be decoded.
+var ErrInvalidAgentResponse = errors.New("invalid response from agent")
+
+// ErrAgentResponseTooLarge is returned when the agent's reply is bigger
+// than AGENT_MAX_RESPONSE_SIZE; the rest of it is never read.
+var ErrAgentResponseTooLarge = errors.New("agent response exceeds maximum size")
+
+// ClassifyAgentError sorts an analysis error into a coarse category for
+// diagnostics.
+func ClassifyAgentError(err error) string {
+	var statusErr *AgentStatusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests:
+		return "rate_limited"
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 500:
+		return "server_error"
+	case errors.As(err, &statusErr):
+		return "client_error"
+	case errors.Is(err, ErrInvalidAgentResponse):
+		return "parse_error"
+	case errors.Is(err, ErrAgentResponseTooLarge):
+		return "response_too_large"
+	case errors.As(err, &netErr):
+		return "connection_error"
+	default:
+		return "error"
+	}
+}
 
-		## 9. Limitations
-		- Known limitations
-		- Model restrictions
+func AnalyzeProject(ctx context.Context, cfg *config.Config, codeFilePath, formatTemplate string) (string, error) {
+	return AnalyzeFiles(ctx, cfg, []string{codeFilePath}, formatTemplate)
+}
 
-		## 10. Future Improvements
-		- Planned features
-		- Possible optimizations
-`
+// AnalyzeFiles sends one or more files to the agent in a single request,
+// repeating the file field, and returns the one document it produces.
+// Requests carrying several files get the longer batch timeout.
+func AnalyzeFiles(ctx context.Context, cfg *config.Config, codeFilePaths []string, formatTemplate string) (string, error) {
+	timeout := cfg.AnalyzeTimeout
+	if len(codeFilePaths) > 1 {
+		timeout = cfg.AnalyzeBatchTimeout
+	}
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	var b bytes.Buffer
 	w := multipart.NewWriter(&b)
-	fw, _ := w.CreateFormFile("code_file", codeFilePath)
-	if _, err := io.Copy(fw, file); err != nil {
-		return "", fmt.Errorf("failed to copy code file: %w", err)
+	redactions := make([]int, len(codeFilePaths))
+	for i, codeFilePath := range codeFilePaths {
+		redacted, err := addFormFile(w, cfg.AgentFileField, codeFilePath, cfg.RedactSecrets)
+		if err != nil {
+			return "", err
+		}
+		redactions[i] = redacted
 	}
-	_ = w.WriteField("format", formatTemplate)
+	_ = w.WriteField(cfg.AgentFormatField, formatTemplate)
 	w.Close()
 
-	url := "http://localhost:8000/analyze"
-	req, err := http.NewRequest("POST", url, &b)
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.AgentURL, &b)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", w.FormDataContentType())
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		req.Header.Set(TraceHeader, traceID)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := agentClient.Do(req)
 	if err != nil {
+		// A caller's deadline, such as the per-file budget, reports itself
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+			return "", fmt.Errorf("analyze request timed out after %s: %w", timeout, err)
+		}
 		return "", fmt.Errorf("could not call analyze endpoint: %w", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, _ := io.ReadAll(resp.Body)
+	// A runaway agent must not be able to exhaust memory
+	if resp.ContentLength > cfg.AgentMaxResponseSize {
+		return "", fmt.Errorf("%w: %d bytes, limit %d", ErrAgentResponseTooLarge, resp.ContentLength, cfg.AgentMaxResponseSize)
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, cfg.AgentMaxResponseSize+1))
+	if int64(len(respBody)) > cfg.AgentMaxResponseSize {
+		return "", fmt.Errorf("%w: limit %d bytes", ErrAgentResponseTooLarge, cfg.AgentMaxResponseSize)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("agent error: %s", respBody)
+		return "", &AgentStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	doc, confidence, err := parseAgentResponse(respBody, cfg.AgentResponsePath)
+	if err != nil {
+		return "", err
+	}
+
+	// Reported once the call succeeds so retries don't count twice
+	for i, codeFilePath := range codeFilePaths {
+		reportRedactions(ctx, codeFilePath, redactions[i])
+		if score, ok := confidence.forFile(codeFilePath, len(codeFilePaths)); ok {
+			reportConfidence(ctx, codeFilePath, score)
+		}
 	}
 
-	doc := struct {
-		Document string `json:"document"`
-	}{}
-	if err := json.Unmarshal(respBody, &doc); err != nil {
-		return "", fmt.Errorf("invalid response from agent: %w", err)
+	return doc, nil
+}
+
+// agentConfidence is the score an agent sent beside a document: one for
+// the whole document, or one per file keyed by the file's name as sent.
+type agentConfidence struct {
+	document *float64
+	files    map[string]float64
+}
+
+// forFile returns the score for path in a call documenting count files. A
+// whole-document score only speaks for a file documented on its own; in a
+// batch, a file the agent gave no score of its own has none.
+func (c agentConfidence) forFile(path string, count int) (float64, bool) {
+	if score, ok := c.files[path]; ok {
+		return score, true
+	}
+	if score, ok := c.files[filepath.Base(path)]; ok {
+		return score, true
+	}
+	if c.document != nil && count == 1 {
+		return *c.document, true
+	}
+	return 0, false
+}
+
+// parseAgentResponse finds the document at the dotted path in the agent's
+// JSON reply, e.g. "result.document", along with the confidence beside it
+// if the agent sent any: a number, or an object of numbers by file name.
+func parseAgentResponse(body []byte, path string) (string, agentConfidence, error) {
+	var confidence agentConfidence
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", confidence, fmt.Errorf("%w: %w", ErrInvalidAgentResponse, err)
+	}
+
+	keys := strings.Split(path, ".")
+	parent, ok := value.(map[string]any)
+	for _, key := range keys[:len(keys)-1] {
+		if !ok {
+			break
+		}
+		parent, ok = parent[key].(map[string]any)
+	}
+	if !ok {
+		return "", confidence, fmt.Errorf("%w: no object at %q", ErrInvalidAgentResponse, path)
+	}
+	doc, ok := parent[keys[len(keys)-1]].(string)
+	if !ok {
+		return "", confidence, fmt.Errorf("%w: no document at %q", ErrInvalidAgentResponse, path)
 	}
-	fmt.Println("Document: ", doc.Document)
-	fmt.Println(doc.Document)
+	switch score := parent["confidence"].(type) {
+	case float64:
+		confidence.document = &score
+	case map[string]any:
+		confidence.files = map[string]float64{}
+		for file, value := range score {
+			if score, ok := value.(float64); ok {
+				confidence.files[file] = score
+			}
+		}
+	}
+	return doc, confidence, nil
+}
 
-	return doc.Document, nil
+// addFormFile attaches the file at path, with notebooks converted to
+// source and secrets replaced when redact is set, and returns the number
+// of redactions made.
+func addFormFile(w *multipart.Writer, field, path string, redact bool) (int, error) {
+	file, err := utils.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open code file: %w", err)
+	}
+	defer file.Close()
+
+	fw, _ := w.CreateFormFile(field, path)
+	if !redact && !IsNotebook(path) {
+		if _, err := io.Copy(fw, file); err != nil {
+			return 0, fmt.Errorf("failed to copy code file: %w", err)
+		}
+		return 0, nil
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read code file: %w", err)
+	}
+	if IsNotebook(path) {
+		source, err := NotebookSource(content)
+		if err != nil {
+			return 0, err
+		}
+		content = []byte(source)
+	}
+	count := 0
+	if redact {
+		content, count = RedactSecrets(content)
+	}
+	if _, err := fw.Write(content); err != nil {
+		return 0, fmt.Errorf("failed to copy code file: %w", err)
+	}
+	return count, nil
 }
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"code-doc-tool/internal/config"
+)
+
+// testAgent serves handler as the analyze agent and returns a config
+// pointing at it.
+func testAgent(t *testing.T, handler http.HandlerFunc) *config.Config {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cfg := config.New()
+	cfg.AgentURL = server.URL + "/analyze"
+	return cfg
+}
+
+// replyDocument answers an agent call with doc in the default envelope.
+func replyDocument(w http.ResponseWriter, doc string) {
+	json.NewEncoder(w).Encode(map[string]string{"document": doc})
+}
+
+func TestAnalyzeFieldNames(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+
+	cfg := testAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("source")
+		if err != nil {
+			http.Error(w, "no source field", http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		if string(content) != "package main\n" || r.FormValue("template") != "tpl" {
+			http.Error(w, "unexpected fields", http.StatusBadRequest)
+			return
+		}
+		replyDocument(w, "# main.go")
+	})
+	cfg.AgentFileField = "source"
+	cfg.AgentFormatField = "template"
+
+	doc, err := AnalyzeProject(context.Background(), cfg, filepath.Join(dir, "main.go"), "tpl")
+	if err != nil || doc != "# main.go" {
+		t.Fatalf("got %q, %v", doc, err)
+	}
+
+	cfg.AgentFileField = "code_file"
+	_, err = AnalyzeProject(context.Background(), cfg, filepath.Join(dir, "main.go"), "tpl")
+	var statusErr *AgentStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got %v, want the agent's 400", err)
+	}
+}
+
+func TestAnalyzeTraceHeader(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+
+	var got []string
+	cfg := testAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(TraceHeader))
+		replyDocument(w, "doc")
+	})
+
+	ctx := WithTraceID(context.Background(), "trace-123")
+	if _, err := AnalyzeProject(ctx, cfg, filepath.Join(dir, "main.go"), "tpl"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AnalyzeProject(context.Background(), cfg, filepath.Join(dir, "main.go"), "tpl"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "trace-123" || got[1] != "" {
+		t.Errorf("agent saw trace headers %q", got)
+	}
+}
+
+func TestAnalyzeBatchTimeout(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.go": "package a\n", "b.go": "package a\n"})
+
+	cfg := testAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		replyDocument(w, "doc")
+	})
+	cfg.AnalyzeTimeout = 20 * time.Millisecond
+	cfg.AnalyzeBatchTimeout = 2 * time.Second
+
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	if _, err := AnalyzeFiles(context.Background(), cfg, []string{a, b}, "tpl"); err != nil {
+		t.Errorf("batch failed within the batch timeout: %v", err)
+	}
+	_, err := AnalyzeProject(context.Background(), cfg, a, "tpl")
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out after 20ms") {
+		t.Errorf("got %v, want the single file timeout", err)
+	}
+}
+
+func TestParseAgentResponse(t *testing.T) {
+	tests := []struct {
+		body, path, want string
+		confidence       float64
+	}{
+		{`{"document": "# a.go"}`, "document", "# a.go", -1},
+		{`{"result": {"document": "# a.go", "confidence": 0.5}}`, "result.document", "# a.go", 0.5},
+		{`{"data": {"output": {"text": "# b.go"}}, "confidence": 0.1}`, "data.output.text", "# b.go", -1},
+		{`{"document": "# a.go", "confidence": {"a.go": 0.25}}`, "document", "# a.go", 0.25},
+		{`{"document": "# a.go", "confidence": "high"}`, "document", "# a.go", -1},
+	}
+	for _, tt := range tests {
+		doc, confidence, err := parseAgentResponse([]byte(tt.body), tt.path)
+		if err != nil || doc != tt.want {
+			t.Errorf("%s at %s: got %q, %v, want %q", tt.body, tt.path, doc, err, tt.want)
+			continue
+		}
+		if score, ok := confidence.forFile("src/a.go", 1); ok != (tt.confidence >= 0) || ok && score != tt.confidence {
+			t.Errorf("%s at %s: confidence %v, %v, want %v", tt.body, tt.path, score, ok, tt.confidence)
+		}
+	}
+
+	for _, tt := range []struct{ body, path string }{
+		{`not json`, "document"},
+		{`{"document": "# a.go"}`, "result.document"},
+		{`{"result": "# a.go"}`, "result.document"},
+		{`{"result": {"document": 42}}`, "result.document"},
+		{`["# a.go"]`, "document"},
+	} {
+		if _, _, err := parseAgentResponse([]byte(tt.body), tt.path); !errors.Is(err, ErrInvalidAgentResponse) {
+			t.Errorf("%s at %s: error %v, want an invalid agent response", tt.body, tt.path, err)
+		}
+	}
+}
+
+func TestAnalyzeResponsePath(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+	cfg := testAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"result": map[string]string{"document": "# main.go"}})
+	})
+
+	cfg.AgentResponsePath = "result.document"
+	doc, err := AnalyzeProject(context.Background(), cfg, filepath.Join(dir, "main.go"), "tpl")
+	if err != nil || doc != "# main.go" {
+		t.Fatalf("got %q, %v", doc, err)
+	}
+
+	cfg.AgentResponsePath = "document"
+	if _, err := AnalyzeProject(context.Background(), cfg, filepath.Join(dir, "main.go"), "tpl"); !errors.Is(err, ErrInvalidAgentResponse) {
+		t.Errorf("default path on a nested reply: %v", err)
+	}
+}
+
+func TestAnalyzeResponseTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+	doc := strings.Repeat("documentation ", 100)
+	cfg := testAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("status") == "error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		if r.URL.Query().Get("chunked") != "" {
+			// Flushing before the end leaves the length undeclared
+			io.WriteString(w, `{"document": "`)
+			w.(http.Flusher).Flush()
+			io.WriteString(w, doc+`"}`)
+			return
+		}
+		replyDocument(w, doc)
+	})
+	agentURL := cfg.AgentURL
+	cfg.AgentMaxResponseSize = 1000
+
+	// A declared length fails before reading, a chunked body once past
+	// the limit, and the limit applies to error replies too
+	for query, want := range map[string]string{
+		"":              "bytes, limit 1000",
+		"?chunked=1":    "limit 1000 bytes",
+		"?status=error": "bytes, limit 1000",
+	} {
+		cfg.AgentURL = agentURL + query
+		_, err := AnalyzeProject(context.Background(), cfg, filepath.Join(dir, "main.go"), "tpl")
+		if !errors.Is(err, ErrAgentResponseTooLarge) || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want ErrAgentResponseTooLarge with %q", query, err, want)
+		}
+		if class := ClassifyAgentError(err); class != "response_too_large" {
+			t.Errorf("%q: classified as %s", query, class)
+		}
+	}
+
+	cfg.AgentMaxResponseSize = 100 * 1024
+	for _, query := range []string{"", "?chunked=1"} {
+		cfg.AgentURL = agentURL + query
+		if got, err := AnalyzeProject(context.Background(), cfg, filepath.Join(dir, "main.go"), "tpl"); err != nil || got != doc {
+			t.Errorf("%q under the limit: got %d bytes, %v", query, len(got), err)
+		}
+	}
+}
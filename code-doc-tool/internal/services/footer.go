@@ -0,0 +1,49 @@
+package services
+
+import (
+	"strings"
+	"time"
+)
+
+// Version is the tool version named in document footers. Release builds
+// set it with -ldflags "-X code-doc-tool/internal/services.Version=v1.2.3".
+var Version = "dev"
+
+// RenderFooter fills in a footer template. Supported placeholders are
+// {version}, {job}, {time} (RFC 3339, UTC) and {date} (YYYY-MM-DD), the
+// times being those of the job's creation so re-rendering a document
+// later doesn't change its footer.
+func RenderFooter(template, jobID string, created time.Time) string {
+	created = created.UTC()
+	return strings.NewReplacer(
+		"{version}", Version,
+		"{job}", jobID,
+		"{time}", created.Format(time.RFC3339),
+		"{date}", created.Format(time.DateOnly),
+	).Replace(template)
+}
+
+// GenerateWithFooter writes docText with g, ending it with footer. Word
+// documents carry the footer on every page, below any DOCX_FOOTER text;
+// every other format gets it as a trailing section. An empty footer
+// generates the document unchanged.
+func GenerateWithFooter(g Generator, docText, outputPath, footer string) error {
+	if footer == "" {
+		return g.GenerateDocumentation(docText, outputPath)
+	}
+	if docx, ok := g.(*DocxGenerator); ok {
+		styled := *docx
+		if styled.Style.Footer != "" {
+			footer = styled.Style.Footer + "\n" + footer
+		}
+		styled.Style.Footer = footer
+		return styled.GenerateDocumentation(docText, outputPath)
+	}
+	return g.GenerateDocumentation(strings.TrimRight(docText, "\n")+FooterSection(footer), outputPath)
+}
+
+// FooterSection is the trailing section carrying footer in formats other
+// than Word.
+func FooterSection(footer string) string {
+	return "\n\n---\n\n*" + footer + "*\n"
+}
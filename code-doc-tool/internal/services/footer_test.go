@@ -0,0 +1,65 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderFooter(t *testing.T) {
+	created := time.Date(2024, 3, 4, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	got := RenderFooter("{version} {job} {time} {date} {other}", "job-1", created)
+	if want := Version + " job-1 2024-03-04T11:30:00Z 2024-03-04 {other}"; got != want {
+		t.Errorf("RenderFooter = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateWithFooter(t *testing.T) {
+	dir := t.TempDir()
+	footer := "Generated on 2024-03-04 (job job-1)"
+
+	md := filepath.Join(dir, "out.md")
+	if err := GenerateWithFooter(NewMarkdownGenerator(), "# Title\n\nBody.\n\n", md, footer); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Title\n\nBody.\n\n---\n\n*" + footer + "*\n"; string(data) != want {
+		t.Errorf("markdown %q, want %q", data, want)
+	}
+
+	// Word documents put it in the page footer, below the configured text
+	docx := filepath.Join(dir, "out.docx")
+	g := &DocxGenerator{Style: DocxStyle{Footer: "Confidential"}}
+	if err := GenerateWithFooter(g, "# Title\n", docx, footer); err != nil {
+		t.Fatal(err)
+	}
+	parts, _, err := readDocxParts(docx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := string(parts[footerPart])
+	confidential, generated := strings.Index(part, "Confidential"), strings.Index(part, footer)
+	if confidential < 0 || generated < confidential {
+		t.Errorf("footer part doesn't end with the metadata:\n%s", part)
+	}
+	if strings.Contains(string(parts["word/document.xml"]), footer) {
+		t.Error("the metadata ended up in the document body")
+	}
+	if g.Style.Footer != "Confidential" {
+		t.Errorf("the generator's style was changed to %q", g.Style.Footer)
+	}
+
+	// No footer leaves the document alone
+	plain := filepath.Join(dir, "plain.md")
+	if err := GenerateWithFooter(NewMarkdownGenerator(), "# Title\n", plain, ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(plain); string(data) != "# Title\n" {
+		t.Errorf("markdown without footer %q", data)
+	}
+}
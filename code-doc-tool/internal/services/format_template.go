@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type DocSection struct {
+	Number int
+	Key    string
+	Name   string
+	Items  []string
+}
+
+// DocSections is the full set of sections the agent can be asked to produce.
+var DocSections = []DocSection{
+	{1, "overview", "Overview", []string{
+		"Purpose of the project",
+		"High-level description of what it does",
+	}},
+	{2, "tech-stack", "Technology Stack", []string{
+		"Languages used",
+		"Frameworks / Libraries",
+		"External Services (APIs, DBs, etc.)",
+	}},
+	{3, "architecture", "Architecture", []string{
+		"High-level description (monolith, microservices, etc.)",
+		"Folder / module structure",
+		"Data flow or sequence diagram (if applicable)",
+	}},
+	{4, "setup", "Setup & Installation", []string{
+		"Prerequisites",
+		"Installation steps",
+		"How to run locally / deploy",
+	}},
+	{5, "apis", "APIs", []string{
+		"Endpoint details (method, path, description, parameters, response)",
+	}},
+	{6, "functions", "Functions / Classes", []string{
+		"Function name, inputs, outputs, purpose",
+	}},
+	{7, "errors", "Error Handling", []string{
+		"Common error codes",
+		"Known failure scenarios",
+	}},
+	{8, "usage", "Usage Example", []string{
+		"Sample request (curl / Python snippet)",
+		"Sample response",
+	}},
+	{9, "limitations", "Limitations", []string{
+		"Known limitations",
+		"Model restrictions",
+	}},
+	{10, "future", "Future Improvements", []string{
+		"Planned features",
+		"Possible optimizations",
+	}},
+}
+
+// SelectSections resolves user supplied section names, keys or numbers
+// against DocSections. An empty selection means every section.
+func SelectSections(names []string) ([]DocSection, error) {
+	wanted := map[int]bool{}
+	var unknown []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		section, ok := findSection(name)
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		wanted[section.Number] = true
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown sections: %s", strings.Join(unknown, ", "))
+	}
+	if len(wanted) == 0 {
+		return DocSections, nil
+	}
+
+	// Keep the template order regardless of the order requested
+	var selected []DocSection
+	for _, section := range DocSections {
+		if wanted[section.Number] {
+			selected = append(selected, section)
+		}
+	}
+	return selected, nil
+}
+
+func findSection(name string) (DocSection, bool) {
+	if n, err := strconv.Atoi(name); err == nil {
+		for _, section := range DocSections {
+			if section.Number == n {
+				return section, true
+			}
+		}
+		return DocSection{}, false
+	}
+
+	for _, section := range DocSections {
+		if strings.EqualFold(section.Key, name) || strings.EqualFold(section.Name, name) {
+			return section, true
+		}
+	}
+	return DocSection{}, false
+}
+
+// BuildFormatTemplate renders the markdown skeleton sent to the agent.
+func BuildFormatTemplate(sections []DocSection) string {
+	var b strings.Builder
+	b.WriteString("# Project Technical Documentation\n")
+	for _, section := range sections {
+		fmt.Fprintf(&b, "\n## %d. %s\n", section.Number, section.Name)
+		for _, item := range section.Items {
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+	}
+	return b.String()
+}
+
+// Detail levels for the documentation the agent writes.
+const (
+	DetailBrief    = "brief"
+	DetailStandard = "standard"
+	DetailDetailed = "detailed"
+)
+
+// detailInstructions are appended to the format template per detail
+// level; standard leaves the template as it is.
+var detailInstructions = map[string]string{
+	DetailBrief: "Be brief: at most a few sentences or bullet points per section, " +
+		"leave out sections that don't apply and skip examples.",
+	DetailDetailed: "Be exhaustive: cover every public and internal function, type and " +
+		"constant, explain parameters, return values, errors and edge cases, and " +
+		"include usage examples.",
+}
+
+// ValidateDetail normalizes a detail level, treating "" as standard.
+func ValidateDetail(detail string) (string, error) {
+	switch detail = strings.ToLower(strings.TrimSpace(detail)); detail {
+	case "":
+		return DetailStandard, nil
+	case DetailBrief, DetailStandard, DetailDetailed:
+		return detail, nil
+	}
+	return "", fmt.Errorf("unknown detail level %q, expected one of brief, standard, detailed", detail)
+}
+
+// WithDetail appends the instruction for a detail level to formatTemplate.
+func WithDetail(formatTemplate, detail string) string {
+	instruction, ok := detailInstructions[detail]
+	if !ok {
+		return formatTemplate
+	}
+	return formatTemplate + "\nDetail level: " + detail + ". " + instruction + "\n"
+}
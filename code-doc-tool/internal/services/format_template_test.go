@@ -0,0 +1,85 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func sectionKeys(sections []DocSection) []string {
+	var keys []string
+	for _, section := range sections {
+		keys = append(keys, section.Key)
+	}
+	return keys
+}
+
+func TestSelectSections(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  []string
+	}{
+		{"empty selects all", nil, sectionKeys(DocSections)},
+		{"blank names ignored", []string{" ", ""}, sectionKeys(DocSections)},
+		{"by key", []string{"apis"}, []string{"apis"}},
+		{"by name", []string{"setup & installation"}, []string{"setup"}},
+		{"by number", []string{"7"}, []string{"errors"}},
+		{"template order", []string{"future", "overview", "3"}, []string{"overview", "architecture", "future"}},
+		{"duplicates", []string{"apis", "APIs", "5"}, []string{"apis"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections, err := SelectSections(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sectionKeys(sections); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectSectionsUnknown(t *testing.T) {
+	_, err := SelectSections([]string{"apis", "nope", "42"})
+	if err == nil || !strings.Contains(err.Error(), "nope, 42") {
+		t.Fatalf("got %v, want an error naming nope and 42", err)
+	}
+}
+
+func TestBuildFormatTemplate(t *testing.T) {
+	sections, _ := SelectSections([]string{"overview", "usage"})
+	template := BuildFormatTemplate(sections)
+	for _, want := range []string{"## 1. Overview", "- Purpose of the project", "## 8. Usage Example"} {
+		if !strings.Contains(template, want) {
+			t.Errorf("template is missing %q:\n%s", want, template)
+		}
+	}
+	if strings.Contains(template, "## 5. APIs") {
+		t.Errorf("template has an unselected section:\n%s", template)
+	}
+}
+
+func TestWithDetail(t *testing.T) {
+	template := BuildFormatTemplate(DocSections)
+	if got := WithDetail(template, DetailStandard); got != template {
+		t.Errorf("standard changed the template:\n%s", got)
+	}
+	if got := WithDetail(template, DetailBrief); !strings.HasPrefix(got, template) || !strings.Contains(got, "Detail level: brief. Be brief") {
+		t.Errorf("brief template:\n%s", got)
+	}
+	if got := WithDetail(template, DetailDetailed); !strings.Contains(got, "Detail level: detailed. Be exhaustive") {
+		t.Errorf("detailed template:\n%s", got)
+	}
+}
+
+func TestValidateDetail(t *testing.T) {
+	for in, want := range map[string]string{"": DetailStandard, " Brief ": DetailBrief, "detailed": DetailDetailed} {
+		if got, err := ValidateDetail(in); err != nil || got != want {
+			t.Errorf("ValidateDetail(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ValidateDetail("verbose"); err == nil || !strings.Contains(err.Error(), `"verbose"`) {
+		t.Errorf("got %v, want an error naming verbose", err)
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"code-doc-tool/internal/models"
+)
+
+// FormatTemplates holds format templates that replace the default one for
+// files of a language or projects of a type, such as a Go-specific
+// template or one for frontends.
+type FormatTemplates struct {
+	byLanguage map[string]string
+	byType     map[string]string
+}
+
+// projectTypes are the project types a format template can be keyed by.
+var projectTypes = []string{
+	models.ProjectTypeService,
+	models.ProjectTypeLibrary,
+	models.ProjectTypeCLI,
+	models.ProjectTypeFrontend,
+}
+
+// LoadFormatTemplates reads a JSON object mapping languages ("Go") or
+// project types ("frontend") to a format template: either the template
+// text or a list of section names or numbers to build it from.
+func LoadFormatTemplates(file string) (*FormatTemplates, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid format templates: %w", err)
+	}
+
+	t := &FormatTemplates{byLanguage: map[string]string{}, byType: map[string]string{}}
+	for key, value := range raw {
+		template, err := formatTemplateValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("format template for %q: %w", key, err)
+		}
+		if lang, ok := NormalizeLanguage(key); ok {
+			t.byLanguage[lang] = template
+			continue
+		}
+		projectType := strings.ToLower(strings.TrimSpace(key))
+		if !slices.Contains(projectTypes, projectType) {
+			return nil, fmt.Errorf("format template key %q is neither a language nor a project type (%s)", key, strings.Join(projectTypes, ", "))
+		}
+		t.byType[projectType] = template
+	}
+	return t, nil
+}
+
+// formatTemplateValue reads a template given as text or as sections.
+func formatTemplateValue(value json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		if strings.TrimSpace(text) == "" {
+			return "", fmt.Errorf("template is empty")
+		}
+		return text, nil
+	}
+	var names []string
+	if err := json.Unmarshal(value, &names); err != nil {
+		return "", fmt.Errorf("expected template text or a list of sections")
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no sections listed")
+	}
+	sections, err := SelectSections(names)
+	if err != nil {
+		return "", err
+	}
+	return BuildFormatTemplate(sections), nil
+}
+
+// For returns the template for a file of language in a project of
+// projectType, preferring a language's template over its project type's.
+// It reports false when neither has one and the default applies.
+func (t *FormatTemplates) For(language, projectType string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	if template, ok := t.byLanguage[language]; ok {
+		return template, true
+	}
+	template, ok := t.byType[projectType]
+	return template, ok
+}
+
+// WithDetail returns a copy with the instruction for a detail level
+// appended to every template.
+func (t *FormatTemplates) WithDetail(detail string) *FormatTemplates {
+	if t == nil {
+		return nil
+	}
+	c := &FormatTemplates{byLanguage: map[string]string{}, byType: map[string]string{}}
+	for lang, template := range t.byLanguage {
+		c.byLanguage[lang] = WithDetail(template, detail)
+	}
+	for projectType, template := range t.byType {
+		c.byType[projectType] = WithDetail(template, detail)
+	}
+	return c
+}
+
+// Key identifies the templates, so cached documentation produced with
+// other ones isn't reused.
+func (t *FormatTemplates) Key() string {
+	if t == nil {
+		return ""
+	}
+	var keys []string
+	for lang, template := range t.byLanguage {
+		keys = append(keys, "language:"+lang+"\x00"+template)
+	}
+	for projectType, template := range t.byType {
+		keys = append(keys, "type:"+projectType+"\x00"+template)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\x00")
+}
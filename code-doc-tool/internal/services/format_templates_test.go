@@ -0,0 +1,92 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadTemplates writes content as a format templates file and loads it.
+func loadTemplates(t *testing.T, content string) (*FormatTemplates, error) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"templates.json": content})
+	return LoadFormatTemplates(filepath.Join(dir, "templates.json"))
+}
+
+func TestLoadFormatTemplates(t *testing.T) {
+	templates, err := loadTemplates(t, `{
+		"go": "Document this Go file.\n",
+		"Frontend": ["overview", "8"],
+		"library": "Document this library.\n"
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		language, projectType string
+		want                  string
+	}{
+		// A language's template wins over its project type's
+		{"Go", "library", "Document this Go file.\n"},
+		{"Python", "library", "Document this library.\n"},
+		{"JavaScript", "frontend", "## 8. Usage Example"},
+		{"Python", "service", ""},
+	}
+	for _, tt := range tests {
+		template, ok := templates.For(tt.language, tt.projectType)
+		if ok != (tt.want != "") || !strings.Contains(template, tt.want) {
+			t.Errorf("For(%q, %q) = %q, %v, want %q", tt.language, tt.projectType, template, ok, tt.want)
+		}
+	}
+	if template, _ := templates.For("JavaScript", "frontend"); !strings.Contains(template, "## 1. Overview") || strings.Contains(template, "## 5. APIs") {
+		t.Errorf("sections template:\n%s", template)
+	}
+
+	var none *FormatTemplates
+	if _, ok := none.For("Go", "library"); ok || none.Key() != "" || none.WithDetail(DetailBrief) != nil {
+		t.Error("no configured templates should leave the default in place")
+	}
+}
+
+func TestLoadFormatTemplatesInvalid(t *testing.T) {
+	for content, want := range map[string]string{
+		`{"gui": "Document it."}`: `"gui" is neither a language nor a project type`,
+		`{"Go": ""}`:              "template is empty",
+		`{"Go": []}`:              "no sections listed",
+		`{"Go": ["nope"]}`:        "nope",
+		`{"Go": 7}`:               "expected template text or a list of sections",
+		`["Go"]`:                  "invalid format templates",
+	} {
+		if _, err := loadTemplates(t, content); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error containing %q", content, err, want)
+		}
+	}
+}
+
+func TestFormatTemplatesWithDetail(t *testing.T) {
+	templates, err := loadTemplates(t, `{"Go": "Document this Go file.\n", "cli": ["usage"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	brief := templates.WithDetail(DetailBrief)
+	for _, key := range [][2]string{{"Go", ""}, {"Python", "cli"}} {
+		template, _ := brief.For(key[0], key[1])
+		if !strings.HasSuffix(template, "Detail level: brief. "+detailInstructions[DetailBrief]+"\n") {
+			t.Errorf("%s template without the detail level:\n%s", key, template)
+		}
+	}
+	if template, _ := templates.For("Go", ""); strings.Contains(template, "Detail level") {
+		t.Error("WithDetail changed the loaded templates")
+	}
+
+	// The cache key follows the templates' content
+	if templates.Key() == brief.Key() {
+		t.Error("templates with another detail level share a key")
+	}
+	again, _ := loadTemplates(t, `{"cli": ["usage"], "Go": "Document this Go file.\n"}`)
+	if templates.Key() != again.Key() {
+		t.Error("the same templates got different keys")
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate holds work back while it is closed, such as new jobs while an
+// operator has paused processing.
+type Gate struct {
+	mu     sync.Mutex
+	closed bool
+	opened chan struct{} // closed when the gate next opens
+}
+
+func NewGate() *Gate {
+	return &Gate{}
+}
+
+// Close holds back Wait until Open. It reports whether the gate was open.
+func (g *Gate) Close() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.closed = true
+	g.opened = make(chan struct{})
+	return true
+}
+
+// Open lets everything waiting through. It reports whether the gate was
+// closed.
+func (g *Gate) Open() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.closed {
+		return false
+	}
+	g.closed = false
+	close(g.opened)
+	return true
+}
+
+func (g *Gate) IsClosed() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closed
+}
+
+// Wait returns once the gate is open, or with ctx's error if ctx is
+// cancelled first.
+func (g *Gate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	closed, opened := g.closed, g.opened
+	g.mu.Unlock()
+	if !closed {
+		return nil
+	}
+	select {
+	case <-opened:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	"code-doc-tool/internal/utils"
+)
+
+// generatedReadLimit bounds how much of the start of a file is searched
+// for a generated-code marker.
+const generatedReadLimit = 1024
+
+// goGenerated is Go's convention for generated files, matched on a whole
+// line: https://go.dev/s/generatedcode
+var goGenerated = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// generatedComment matches a comment line that opens with one of the
+// other common markers, e.g. "# @generated by tool" or
+// " * This file is autogenerated". Markers only mentioned further into a
+// comment, or outside comments, don't count.
+var generatedComment = regexp.MustCompile(`(?i)^\s*(//|#|/?\*+|--|;+|<!--)\s*(@generated\b|(this (file|code) (is|was|has been) )?auto-?generated\b)`)
+
+// IsGenerated reports whether the named file declares itself generated in
+// its first lines.
+func IsGenerated(name string) bool {
+	file, err := utils.Open(name)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	buf := make([]byte, generatedReadLimit)
+	n, _ := io.ReadFull(file, buf)
+	lines := bytes.Split(buf[:n], []byte("\n"))
+	if n == generatedReadLimit && len(lines) > 1 {
+		// The last line may be cut short
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if goGenerated.Match(line) || generatedComment.Match(line) {
+			return true
+		}
+	}
+	return false
+}
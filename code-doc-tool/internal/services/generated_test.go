@@ -0,0 +1,37 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsGenerated(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          bool
+	}{
+		{"go.pb.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage pb\n", true},
+		{"crlf.go", "// Code generated by mockgen. DO NOT EDIT.\r\npackage mocks\r\n", true},
+		{"after_license.go", "// Copyright 2024 Example.\n\n// Code generated by stringer -type=Kind; DO NOT EDIT.\n\npackage kind\n", true},
+		{"schema.js", "/**\n * @generated SignedSource<<abc>>\n */\n", true},
+		{"client.py", "# This file is autogenerated by the OpenAPI generator\n", true},
+		{"types.ts", "// Auto-generated, do not modify\nexport type Id = string\n", true},
+
+		{"mention.go", "package lint\n\n// The check skips files marked DO NOT EDIT.\nfunc Check() {}\n", false},
+		{"literal.go", "package gen\n\nconst header = \"// Code generated by gen. DO NOT EDIT.\"\n", false},
+		{"doc.go", "// Package gen writes files marked @generated and autogenerated.\npackage gen\n", false},
+		{"lower.go", "// code generated by hand. do not edit.\npackage x\n", false},
+		{"late.go", "package x\n\n" + strings.Repeat("// padding\n", 100) + "// Code generated by x. DO NOT EDIT.\n", false},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		writeFiles(t, dir, map[string]string{tt.name: tt.content})
+		if got := IsGenerated(filepath.Join(dir, tt.name)); got != tt.want {
+			t.Errorf("IsGenerated(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if IsGenerated(filepath.Join(dir, "missing.go")) {
+		t.Error("a missing file reported generated")
+	}
+}
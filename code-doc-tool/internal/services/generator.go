@@ -0,0 +1,51 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Generator renders the combined markdown documentation into an output file.
+type Generator interface {
+	GenerateDocumentation(docText string, outputPath string) error
+	Extension() string
+	ContentType() string
+}
+
+const DefaultFormat = "docx"
+
+// Formats lists the output formats NewGenerator accepts, by extension.
+var Formats = []string{"docx", "txt", "md", "wiki"}
+
+// NewGenerator returns the generator for an output format name.
+func NewGenerator(format string) (Generator, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "docx":
+		return NewDocxGenerator(), nil
+	case "txt", "text":
+		return NewTextGenerator(), nil
+	case "md", "markdown":
+		return NewMarkdownGenerator(), nil
+	case "wiki", "confluence":
+		return NewConfluenceGenerator(), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// ContentTypeFor returns the content type of a generated file by extension.
+func ContentTypeFor(filename string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if generator, err := NewGenerator(ext); err == nil {
+		return generator.ContentType()
+	}
+	// OpenAPI specs written beside the documents
+	switch ext {
+	case "json":
+		return "application/json"
+	case "yaml":
+		return "application/yaml"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,33 @@
+package services
+
+import "strings"
+
+// maxHeadingLevel is the deepest markdown heading.
+const maxHeadingLevel = 6
+
+// OffsetHeadings demotes every markdown heading outside code blocks by
+// offset levels, so the document nests under an external heading. Levels
+// are clamped at maxHeadingLevel.
+func OffsetHeadings(doc string, offset int) string {
+	if offset <= 0 {
+		return doc
+	}
+
+	lines := strings.Split(doc, "\n")
+	inCodeBlock := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(trimmed); m != nil {
+			level := min(len(m[1])+offset, maxHeadingLevel)
+			lines[i] = strings.Repeat("#", level) + " " + m[2]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,20 @@
+package services
+
+import "testing"
+
+func TestOffsetHeadings(t *testing.T) {
+	doc := "# main.go\n\n## Overview\ntext\n\n```sh\n# not a heading\n```\n\n##### Deep\n###### Deepest\n"
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, doc},
+		{1, "## main.go\n\n### Overview\ntext\n\n```sh\n# not a heading\n```\n\n###### Deep\n###### Deepest\n"},
+		{9, "###### main.go\n\n###### Overview\ntext\n\n```sh\n# not a heading\n```\n\n###### Deep\n###### Deepest\n"},
+	}
+	for _, tt := range tests {
+		if got := OffsetHeadings(doc, tt.offset); got != tt.want {
+			t.Errorf("offset %d:\n%s\nwant:\n%s", tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"time"
+
+	"code-doc-tool/internal/models"
+)
+
+// File event statuses.
+const (
+	FileStatusOK     = "ok"
+	FileStatusFailed = "failed"
+)
+
+// FileDone publishes the outcome of one file's analysis; err is nil when
+// it was documented.
+func (s *JobStore) FileDone(id, file string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Status != models.JobStatusProcessing {
+		return
+	}
+	event := models.JobEvent{Type: models.JobEventFile, File: file, Status: FileStatusOK}
+	if err != nil {
+		event.Status = FileStatusFailed
+		event.Error = err.Error()
+	}
+	s.publish(id, event)
+}
+
+// Events returns a job's events from seq onwards, whether the stream is
+// over once they are sent, and a channel closed when more arrive. Jobs
+// restored after a restart have no history, only their done event.
+func (s *JobStore) Events(id string, seq int) ([]models.JobEvent, bool, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, false, nil, false
+	}
+	events := s.events[id]
+	finished := !models.JobActive(job.Status)
+	if finished && len(events) == 0 {
+		event := doneEvent(job)
+		event.Time = job.UpdatedAt
+		return []models.JobEvent{event}, true, nil, true
+	}
+
+	seq = max(0, min(seq, len(events)))
+	if s.changed[id] == nil {
+		s.changed[id] = make(chan struct{})
+	}
+	return append([]models.JobEvent(nil), events[seq:]...), finished, s.changed[id], true
+}
+
+// publish appends an event and wakes the job's listeners; the caller
+// holds s.mu.
+func (s *JobStore) publish(id string, event models.JobEvent) {
+	event.Seq = len(s.events[id])
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	s.events[id] = append(s.events[id], event)
+	if ch := s.changed[id]; ch != nil {
+		close(ch)
+		delete(s.changed, id)
+	}
+}
+
+func doneEvent(job *models.Job) models.JobEvent {
+	return models.JobEvent{
+		Type:     models.JobEventDone,
+		Progress: job.Progress,
+		Message:  job.Message,
+		Status:   job.Status,
+	}
+}
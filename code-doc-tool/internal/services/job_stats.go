@@ -0,0 +1,81 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"code-doc-tool/internal/models"
+)
+
+// jobCounters aggregates jobs as they start and finish, so stats never
+// need a scan of the store.
+type jobCounters struct {
+	total      int
+	queued     int
+	byStatus   map[string]int
+	duration   time.Duration
+	files      int
+	byLanguage map[string]int
+}
+
+func (c *jobCounters) started() {
+	c.total++
+}
+
+func (c *jobCounters) finished(job *models.Job) {
+	if c.byStatus == nil {
+		c.byStatus = map[string]int{}
+		c.byLanguage = map[string]int{}
+	}
+	c.byStatus[job.Status]++
+	c.duration += job.UpdatedAt.Sub(job.CreatedAt)
+	for _, lang := range job.Languages {
+		c.files += lang.Files
+		c.byLanguage[lang.Language] += lang.Files
+	}
+	c.files -= len(job.DeadLetters)
+}
+
+// Stats reports aggregates over every job the store has seen, with the
+// topLanguages most analyzed languages.
+func (s *JobStore) Stats(topLanguages int) models.JobStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c := &s.counters
+
+	finished := 0
+	for _, n := range c.byStatus {
+		finished += n
+	}
+	succeeded := c.byStatus[models.JobStatusCompleted] + c.byStatus[models.JobStatusCompletedWithFallback]
+	stats := models.JobStats{
+		Total:                 c.total,
+		Processing:            c.total - finished - c.queued,
+		Queued:                c.queued,
+		Completed:             c.byStatus[models.JobStatusCompleted],
+		CompletedWithFallback: c.byStatus[models.JobStatusCompletedWithFallback],
+		Failed:                c.byStatus[models.JobStatusFailed],
+		FilesAnalyzed:         c.files,
+		Languages:             []models.LanguageCount{},
+	}
+	if finished > 0 {
+		stats.SuccessRate = float64(succeeded) / float64(finished)
+		stats.FailureRate = float64(stats.Failed) / float64(finished)
+		stats.AverageDurationSeconds = (c.duration / time.Duration(finished)).Seconds()
+	}
+
+	for lang, files := range c.byLanguage {
+		stats.Languages = append(stats.Languages, models.LanguageCount{Language: lang, Files: files})
+	}
+	sort.Slice(stats.Languages, func(i, j int) bool {
+		a, b := stats.Languages[i], stats.Languages[j]
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		return a.Language < b.Language
+	})
+	if len(stats.Languages) > topLanguages {
+		stats.Languages = stats.Languages[:topLanguages]
+	}
+	return stats
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestJobStats(t *testing.T) {
+	s := NewJobStore()
+	for id, languages := range map[string][]models.LanguageStat{
+		"a": {{Language: "Go", Files: 3}, {Language: "Python", Files: 1}},
+		"b": {{Language: "Go", Files: 2}},
+		"c": {{Language: "PHP", Files: 4}},
+		"d": nil,
+	} {
+		s.Create(id, func() {})
+		s.Modify(id, func(job *models.Job) {
+			job.CreatedAt = job.CreatedAt.Add(-2 * time.Second)
+			job.Languages = languages
+		})
+	}
+	// A file of c was dead-lettered rather than analyzed
+	s.Modify("c", func(job *models.Job) { job.DeadLetters = []models.DeadLetter{{Path: "x.php"}} })
+	s.Complete("a", "done")
+	s.CompleteWithFallback("b", "done as markdown")
+	s.Fail("c", "boom")
+
+	stats := s.Stats(2)
+	want := models.JobStats{
+		Total:                 4,
+		Processing:            1,
+		Completed:             1,
+		CompletedWithFallback: 1,
+		Failed:                1,
+		SuccessRate:           2.0 / 3,
+		FailureRate:           1.0 / 3,
+		FilesAnalyzed:         9,
+		Languages:             []models.LanguageCount{{Language: "Go", Files: 5}, {Language: "PHP", Files: 4}},
+	}
+	if stats.AverageDurationSeconds < 2 || stats.AverageDurationSeconds > 3 {
+		t.Errorf("average duration %gs, want about 2s", stats.AverageDurationSeconds)
+	}
+	stats.AverageDurationSeconds = 0
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v\nwant %+v", stats, want)
+	}
+}
+
+func TestJobStatsEmpty(t *testing.T) {
+	stats := NewJobStore().Stats(10)
+	if stats.Total != 0 || stats.SuccessRate != 0 || stats.Languages == nil {
+		t.Errorf("stats of an empty store %+v", stats)
+	}
+}
+
+func TestJobStatsRestored(t *testing.T) {
+	dir := t.TempDir()
+	previous := NewJobStore()
+	previous.PersistTo(dir)
+	previous.Create("done", func() {})
+	previous.Modify("done", func(job *models.Job) { job.Languages = []models.LanguageStat{{Language: "Go", Files: 2}} })
+	previous.Complete("done", "done")
+	previous.Create("running", func() {})
+
+	s := NewJobStore()
+	s.PersistTo(dir)
+	if _, err := s.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	stats := s.Stats(10)
+	if stats.Total != 2 || stats.Completed != 1 || stats.Processing != 1 || stats.FilesAnalyzed != 2 {
+		t.Errorf("stats after a restore %+v", stats)
+	}
+}
+
+func TestJobStatsRestoredQueued(t *testing.T) {
+	dir := t.TempDir()
+	previous := NewJobStore()
+	previous.PersistTo(dir)
+	for _, id := range []string{"failed", "resumed"} {
+		previous.Create(id, func() {})
+		previous.Queue(id, "Waiting for a slot")
+	}
+
+	s := NewJobStore()
+	s.PersistTo(dir)
+	if _, err := s.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(10); stats.Queued != 2 || stats.Processing != 0 {
+		t.Errorf("stats after restoring queued jobs %+v", stats)
+	}
+
+	// Recovery fails one and resumes the other
+	s.Fail("failed", "Interrupted by restart")
+	s.Resume("resumed", func() {})
+	if stats := s.Stats(10); stats.Queued != 0 || stats.Processing != 1 || stats.Failed != 1 {
+		t.Errorf("stats after recovery %+v", stats)
+	}
+	s.Complete("resumed", "done")
+	if stats := s.Stats(10); stats.Queued != 0 || stats.Processing != 0 || stats.Completed != 1 {
+		t.Errorf("stats after the resumed job finished %+v", stats)
+	}
+}
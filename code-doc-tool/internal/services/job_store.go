@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code-doc-tool/internal/models"
+)
+
+type JobStore struct {
+	mu      sync.RWMutex
+	jobs    map[string]*models.Job
+	cancels map[string]context.CancelFunc
+	logs    map[string][]models.JobLogEntry
+
+	// events feed the live stream of each job; see Events
+	events  map[string][]models.JobEvent
+	changed map[string]chan struct{}
+
+	// dir, when set, keeps a JSON record of every job so they survive a
+	// restart; see PersistTo
+	dir string
+
+	// counters behind Stats, kept up to date as jobs start and finish
+	counters jobCounters
+}
+
+func NewJobStore() *JobStore {
+	return &JobStore{
+		jobs:    make(map[string]*models.Job),
+		cancels: make(map[string]context.CancelFunc),
+		logs:    make(map[string][]models.JobLogEntry),
+		events:  make(map[string][]models.JobEvent),
+		changed: make(map[string]chan struct{}),
+	}
+}
+
+// Create registers a new processing job. cancel is called if the job is
+// failed from outside its own goroutine (e.g. by the watchdog).
+func (s *JobStore) Create(id string, cancel context.CancelFunc) models.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	job := &models.Job{
+		ID:        id,
+		Status:    models.JobStatusProcessing,
+		Message:   "Processing started",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.jobs[id] = job
+	s.cancels[id] = cancel
+	s.counters.started()
+	s.save(job)
+	return *job
+}
+
+// PersistTo keeps job records in dir from now on. Records are written on
+// creation, metadata changes and completion; progress updates in between
+// aren't persisted.
+func (s *JobStore) PersistTo(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.dir = dir
+	s.mu.Unlock()
+	return nil
+}
+
+// save writes job's record; the caller holds s.mu.
+func (s *JobStore) save(job *models.Job) {
+	if s.dir == "" {
+		return
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("Failed to encode job %s: %v", job.ID, err)
+		return
+	}
+	// Write then rename so a crash never leaves a torn record
+	path := filepath.Join(s.dir, job.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		log.Printf("Failed to save job %s: %v", job.ID, err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Printf("Failed to save job %s: %v", job.ID, err)
+	}
+}
+
+// Restore loads the records persisted in the store's directory and
+// returns the IDs of jobs that were still processing or queued, i.e.
+// interrupted by a restart. Those have no context until Resume is called.
+func (s *JobStore) Restore() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		return nil, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var interrupted []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Failed to read job record %s: %v", path, err)
+			continue
+		}
+		var job models.Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			log.Printf("Ignoring corrupt job record %s", path)
+			continue
+		}
+		if _, ok := s.jobs[job.ID]; ok {
+			continue
+		}
+		s.jobs[job.ID] = &job
+		s.counters.started()
+		if models.JobActive(job.Status) {
+			if job.Status == models.JobStatusQueued {
+				s.counters.queued++
+			}
+			interrupted = append(interrupted, job.ID)
+		} else {
+			s.counters.finished(&job)
+		}
+	}
+	return interrupted, nil
+}
+
+// Resume restarts an interrupted job under a new context; one that was
+// queued goes straight to processing.
+func (s *JobStore) Resume(id string, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || !models.JobActive(job.Status) {
+		return
+	}
+	if job.Status == models.JobStatusQueued {
+		s.counters.queued--
+	}
+	job.Status = models.JobStatusProcessing
+	job.Progress = 0
+	job.Message = "Resumed after restart"
+	job.UpdatedAt = time.Now()
+	s.cancels[id] = cancel
+	s.save(job)
+}
+
+func (s *JobStore) Get(id string) (models.Job, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return models.Job{}, false
+	}
+	return *job, true
+}
+
+// Update records progress on a job that is still processing.
+func (s *JobStore) Update(id string, progress int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Status != models.JobStatusProcessing {
+		return
+	}
+	job.Progress = progress
+	job.Message = message
+	job.UpdatedAt = time.Now()
+	s.publish(id, models.JobEvent{Type: models.JobEventProgress, Progress: progress, Message: message})
+}
+
+// Queue marks a processing job as queued, held until Dispatch.
+func (s *JobStore) Queue(id, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Status != models.JobStatusProcessing {
+		return
+	}
+	job.Status = models.JobStatusQueued
+	job.Message = message
+	job.UpdatedAt = time.Now()
+	s.counters.queued++
+	s.save(job)
+}
+
+// Dispatch moves a queued job back to processing.
+func (s *JobStore) Dispatch(id, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Status != models.JobStatusQueued {
+		return
+	}
+	job.Status = models.JobStatusProcessing
+	job.Message = message
+	job.UpdatedAt = time.Now()
+	s.counters.queued--
+	s.save(job)
+}
+
+// AppendLog adds an entry to a job's own log. Logging counts as activity
+// on a running job, so the watchdog leaves it alone.
+func (s *JobStore) AppendLog(id, level, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	s.logs[id] = append(s.logs[id], models.JobLogEntry{
+		Time:    now,
+		Level:   level,
+		Message: message,
+	})
+	if models.JobActive(job.Status) {
+		job.UpdatedAt = now
+	}
+}
+
+// Logs returns a copy of a job's log, oldest first.
+func (s *JobStore) Logs(id string) ([]models.JobLogEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return nil, false
+	}
+	return append([]models.JobLogEntry(nil), s.logs[id]...), true
+}
+
+// Modify applies fn to the stored job, e.g. to attach metadata. Like a
+// log entry, it counts as activity on a running job.
+func (s *JobStore) Modify(id string, fn func(job *models.Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		if models.JobActive(job.Status) {
+			job.UpdatedAt = time.Now()
+		}
+		fn(job)
+		s.save(job)
+	}
+}
+
+func (s *JobStore) Complete(id, message string) {
+	s.finish(id, models.JobStatusCompleted, 100, message)
+}
+
+func (s *JobStore) CompleteWithFallback(id, message string) {
+	s.finish(id, models.JobStatusCompletedWithFallback, 100, message)
+}
+
+func (s *JobStore) Fail(id, message string) {
+	s.finish(id, models.JobStatusFailed, -1, message)
+}
+
+// finish moves a processing or queued job to a terminal status and
+// releases its context. A negative progress leaves the last reported
+// value untouched.
+func (s *JobStore) finish(id, status string, progress int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || !models.JobActive(job.Status) {
+		return
+	}
+	if job.Status == models.JobStatusQueued {
+		s.counters.queued--
+	}
+	job.Status = status
+	job.Message = message
+	job.UpdatedAt = time.Now()
+	if progress >= 0 {
+		job.Progress = progress
+	}
+	s.counters.finished(job)
+
+	level := models.LogLevelInfo
+	if status == models.JobStatusFailed {
+		level = models.LogLevelError
+	}
+	s.logs[id] = append(s.logs[id], models.JobLogEntry{
+		Time:    job.UpdatedAt,
+		Level:   level,
+		Message: fmt.Sprintf("Job %s: %s", status, message),
+	})
+	s.publish(id, doneEvent(job))
+
+	s.save(job)
+
+	if cancel := s.cancels[id]; cancel != nil {
+		cancel()
+	}
+	delete(s.cancels, id)
+}
+
+// FailStalled fails every processing job whose UpdatedAt is older than
+// stallTimeout and returns their IDs. UpdatedAt moves with progress, log
+// entries and metadata changes, so a job still retrying or waiting out a
+// backoff isn't taken for a hung one.
+func (s *JobStore) FailStalled(stallTimeout time.Duration) []string {
+	s.mu.RLock()
+	var stalled []string
+	for id, job := range s.jobs {
+		if job.Status == models.JobStatusProcessing && time.Since(job.UpdatedAt) > stallTimeout {
+			stalled = append(stalled, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, id := range stalled {
+		s.Fail(id, fmt.Sprintf("Job stalled: no activity for %s", stallTimeout))
+	}
+	return stalled
+}
+
+// Watchdog periodically fails stalled jobs until ctx is cancelled.
+func (s *JobStore) Watchdog(ctx context.Context, stallTimeout time.Duration) {
+	if stallTimeout <= 0 {
+		return
+	}
+
+	interval := stallTimeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, id := range s.FailStalled(stallTimeout) {
+				log.Printf("Watchdog failed stalled job %s", id)
+			}
+		}
+	}
+}
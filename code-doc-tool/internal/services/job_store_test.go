@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestFailStalled(t *testing.T) {
+	s := NewJobStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Create("stalled", cancel)
+	s.Create("moving", func() {})
+	s.Create("queued", func() {})
+	s.Queue("queued", "Queued")
+
+	time.Sleep(30 * time.Millisecond)
+	s.Update("moving", 50, "Halfway")
+
+	failed := s.FailStalled(20 * time.Millisecond)
+	if len(failed) != 1 || failed[0] != "stalled" {
+		t.Fatalf("FailStalled failed %v, want [stalled]", failed)
+	}
+	job, _ := s.Get("stalled")
+	if job.Status != models.JobStatusFailed || !strings.Contains(job.Message, "stalled") {
+		t.Errorf("stalled job is %s: %s", job.Status, job.Message)
+	}
+	if ctx.Err() == nil {
+		t.Error("the stalled job's context wasn't cancelled")
+	}
+	for id, status := range map[string]string{"moving": models.JobStatusProcessing, "queued": models.JobStatusQueued} {
+		if job, _ := s.Get(id); job.Status != status {
+			t.Errorf("%s job is %s, want %s", id, job.Status, status)
+		}
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	s := NewJobStore()
+	s.Create("job", func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watchdog(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := s.Get("job"); job.Status == models.JobStatusFailed {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("watchdog didn't fail the stalled job")
+}
+
+func TestFailStalledActivity(t *testing.T) {
+	s := NewJobStore()
+	for _, id := range []string{"logging", "modified", "silent"} {
+		s.Create(id, func() {})
+	}
+	time.Sleep(30 * time.Millisecond)
+	// Retries log without moving progress; metadata changes count too
+	s.AppendLog("logging", models.LogLevelWarn, "Analysis of main.go failed (attempt 1), retrying in 1s")
+	s.Modify("modified", func(job *models.Job) { job.WorkDir = "work" })
+
+	if failed := s.FailStalled(20 * time.Millisecond); len(failed) != 1 || failed[0] != "silent" {
+		t.Errorf("FailStalled failed %v, want [silent]", failed)
+	}
+
+	// Logging after a job finished doesn't touch it
+	s.Complete("logging", "done")
+	finished, _ := s.Get("logging")
+	time.Sleep(5 * time.Millisecond)
+	s.AppendLog("logging", models.LogLevelInfo, "Cleaned up")
+	if job, _ := s.Get("logging"); !job.UpdatedAt.Equal(finished.UpdatedAt) {
+		t.Error("a log entry moved a finished job's UpdatedAt")
+	}
+}
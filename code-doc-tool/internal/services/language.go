@@ -0,0 +1,268 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/utils"
+)
+
+var languageByExt = map[string]string{
+	".go":    "Go",
+	".py":    "Python",
+	".js":    "JavaScript",
+	".jsx":   "JavaScript",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".php":   "PHP",
+	".java":  "Java",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".c":     "C",
+	".h":     "C",
+	".cpp":   "C++",
+	".cc":    "C++",
+	".hpp":   "C++",
+	".cs":    "C#",
+	".kt":    "Kotlin",
+	".swift": "Swift",
+	".sql":   "SQL",
+	".sh":    "Shell",
+	".ipynb": "Jupyter Notebook",
+}
+
+// DetectLanguage maps a file path to a language name by its extension.
+func DetectLanguage(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if lang, ok := languageByExt[ext]; ok {
+		return lang
+	}
+	// Scripts often have no extension, only an interpreter line
+	if ext == "" {
+		if lang, ok := ShebangLanguage(path); ok {
+			return lang
+		}
+	}
+	return "Other"
+}
+
+// NormalizeLanguage matches a user supplied language name against the
+// known languages case-insensitively and returns its canonical spelling.
+func NormalizeLanguage(name string) (string, bool) {
+	for _, lang := range languageByExt {
+		if strings.EqualFold(lang, strings.TrimSpace(name)) {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// LanguageFunc resolves the language of a file path.
+type LanguageFunc func(path string) string
+
+// WithOverrides returns a LanguageFunc that consults overrides, keyed by
+// file path, before falling back to DetectLanguage.
+func WithOverrides(overrides map[string]string) LanguageFunc {
+	return func(path string) string {
+		if lang, ok := overrides[path]; ok {
+			return lang
+		}
+		return DetectLanguage(path)
+	}
+}
+
+// CountLines counts the lines in a file without reading it into memory.
+func CountLines(path string) (int, error) {
+	file, err := utils.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, 32*1024)
+	lines := 0
+	var last byte
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Count a trailing line that has no newline
+	if last != 0 && last != '\n' {
+		lines++
+	}
+	return lines, nil
+}
+
+// ExtensionCount is how many files with an extension a tree holds.
+type ExtensionCount struct {
+	Ext      string
+	Language string
+	Files    int
+}
+
+// UnsupportedExtensions tallies files under root written in a known
+// language whose extension isn't in exts, most common first. Files of no
+// known language (images, docs, config) aren't counted.
+func UnsupportedExtensions(root string, exts []string) ([]ExtensionCount, error) {
+	analyzable := map[string]bool{}
+	for _, ext := range exts {
+		analyzable[strings.ToLower(ext)] = true
+	}
+
+	counts := map[string]int{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if _, known := languageByExt[ext]; known && !analyzable[ext] {
+			counts[ext]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tally := make([]ExtensionCount, 0, len(counts))
+	for ext, n := range counts {
+		tally = append(tally, ExtensionCount{Ext: ext, Language: languageByExt[ext], Files: n})
+	}
+	sort.Slice(tally, func(i, j int) bool {
+		if tally[i].Files != tally[j].Files {
+			return tally[i].Files > tally[j].Files
+		}
+		return tally[i].Ext < tally[j].Ext
+	})
+	return tally, nil
+}
+
+// DescribeExtensions renders the first few counts as e.g.
+// "42 .rs files (Rust), 3 .java files (Java)".
+func DescribeExtensions(counts []ExtensionCount, limit int) string {
+	parts := make([]string, 0, limit)
+	for _, c := range counts[:min(limit, len(counts))] {
+		noun := "files"
+		if c.Files == 1 {
+			noun = "file"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s %s (%s)", c.Files, c.Ext, noun, c.Language))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ComputeLanguageStats tallies files and lines per language, largest first.
+func ComputeLanguageStats(files []string, detect LanguageFunc) ([]models.LanguageStat, error) {
+	byLang := map[string]*models.LanguageStat{}
+	for _, file := range files {
+		lines, err := CountLines(file)
+		if err != nil {
+			return nil, err
+		}
+
+		lang := detect(file)
+		stat, ok := byLang[lang]
+		if !ok {
+			stat = &models.LanguageStat{Language: lang}
+			byLang[lang] = stat
+		}
+		stat.Files++
+		stat.Lines += lines
+	}
+
+	stats := make([]models.LanguageStat, 0, len(byLang))
+	for _, stat := range byLang {
+		stats = append(stats, *stat)
+	}
+	sortLanguageStats(stats)
+	return stats, nil
+}
+
+// MergeLanguageStats combines two sets of statistics, largest first.
+func MergeLanguageStats(a, b []models.LanguageStat) []models.LanguageStat {
+	byLang := map[string]models.LanguageStat{}
+	for _, stat := range append(append([]models.LanguageStat{}, a...), b...) {
+		merged := byLang[stat.Language]
+		merged.Language = stat.Language
+		merged.Files += stat.Files
+		merged.Lines += stat.Lines
+		byLang[stat.Language] = merged
+	}
+
+	stats := make([]models.LanguageStat, 0, len(byLang))
+	for _, stat := range byLang {
+		stats = append(stats, stat)
+	}
+	sortLanguageStats(stats)
+	return stats
+}
+
+func sortLanguageStats(stats []models.LanguageStat) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Lines != stats[j].Lines {
+			return stats[i].Lines > stats[j].Lines
+		}
+		return stats[i].Language < stats[j].Language
+	})
+}
+
+// RenderProjectHeader renders the summary section placed at the top of a
+// project's document.
+func RenderProjectHeader(project *models.Project) string {
+	var b strings.Builder
+	b.WriteString("## Project Summary\n\n")
+	fmt.Fprintf(&b, "- Name: %s\n", project.Name)
+	if project.Type != "" {
+		fmt.Fprintf(&b, "- Type: %s\n", project.Type)
+	}
+	if len(project.Languages) > 0 {
+		b.WriteString("\n" + RenderLanguageTable(project.Languages))
+	}
+	return b.String()
+}
+
+// RenderFileTable renders per-file metadata as a markdown section.
+func RenderFileTable(files []models.FileInfo) string {
+	var b strings.Builder
+	b.WriteString("## Files\n\n")
+	b.WriteString("| Path | Language | Size | Lines |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "| %s | %s | %d | %d |\n", f.Path, f.Language, f.Size, f.Lines)
+	}
+	return b.String()
+}
+
+// RenderLanguageTable renders the language breakdown as a markdown section.
+func RenderLanguageTable(stats []models.LanguageStat) string {
+	var b strings.Builder
+	b.WriteString("## Languages\n\n")
+	b.WriteString("| Language | Files | Lines |\n")
+	b.WriteString("| --- | --- | --- |\n")
+	for _, stat := range stats {
+		fmt.Fprintf(&b, "| %s | %d | %d |\n", stat.Language, stat.Files, stat.Lines)
+	}
+	return b.String()
+}
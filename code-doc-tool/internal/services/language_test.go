@@ -0,0 +1,135 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code-doc-tool/internal/models"
+)
+
+// writeFiles creates files under dir, keyed by slash separated path.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]int{
+		"":              0,
+		"one":           1,
+		"one\n":         1,
+		"one\ntwo":      2,
+		"one\ntwo\n\n":  3,
+		"\n\n\nfour\n":  4,
+		"a\r\nb\r\nc":   3,
+		"no newline at": 1,
+	}
+	i := 0
+	for content, want := range tests {
+		i++
+		path := filepath.Join(dir, "f"+string(rune('a'+i)))
+		os.WriteFile(path, []byte(content), 0644)
+		if got, err := CountLines(path); err != nil || got != want {
+			t.Errorf("CountLines(%q) = %d, %v, want %d", content, got, err, want)
+		}
+	}
+}
+
+func TestComputeLanguageStats(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.go":     "package a\n\nfunc A() {}\n",
+		"b.go":     "package a\n",
+		"app.py":   "print(1)\nprint(2)\nprint(3)\nprint(4)\nprint(5)\n",
+		"tool.inc": "x\ny\n",
+	})
+	files := []string{
+		filepath.Join(dir, "a.go"),
+		filepath.Join(dir, "b.go"),
+		filepath.Join(dir, "app.py"),
+		filepath.Join(dir, "tool.inc"),
+	}
+	detect := WithOverrides(map[string]string{filepath.Join(dir, "tool.inc"): "PHP"})
+
+	stats, err := ComputeLanguageStats(files, detect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.LanguageStat{
+		{Language: "Python", Files: 1, Lines: 5},
+		{Language: "Go", Files: 2, Lines: 4},
+		{Language: "PHP", Files: 1, Lines: 2},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+
+	merged := MergeLanguageStats(stats, []models.LanguageStat{{Language: "Go", Files: 3, Lines: 10}})
+	if merged[0] != (models.LanguageStat{Language: "Go", Files: 5, Lines: 14}) || len(merged) != 3 {
+		t.Errorf("merged stats %+v", merged)
+	}
+
+	table := RenderLanguageTable(stats)
+	if !strings.Contains(table, "| Python | 1 | 5 |") || !strings.Contains(table, "| Go | 2 | 4 |") {
+		t.Errorf("language table:\n%s", table)
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	for name, want := range map[string]string{"php": "PHP", " javascript ": "JavaScript", "c++": "C++", "GO": "Go"} {
+		if got, ok := NormalizeLanguage(name); !ok || got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	if _, ok := NormalizeLanguage("klingon"); ok {
+		t.Error("NormalizeLanguage accepted an unknown language")
+	}
+
+	detect := WithOverrides(map[string]string{"a/x.txt": "Python"})
+	if detect("a/x.txt") != "Python" || detect("a/y.go") != "Go" || detect("a/z.txt") != "Other" {
+		t.Error("WithOverrides didn't prefer overrides and fall back to detection")
+	}
+}
+
+func TestUnsupportedExtensions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/main.rs":               "fn main() {}\n",
+		"src/lib.rs":                "pub fn lib() {}\n",
+		"src/util.rs":               "pub fn util() {}\n",
+		"App.java":                  "class App {}\n",
+		"build.go":                  "package build\n",
+		"README.md":                 "# Crate\n",
+		"node_modules/dep/index.rb": "puts 1\n",
+	})
+
+	counts, err := UnsupportedExtensions(dir, []string{".go", ".PY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ExtensionCount{
+		{Ext: ".rs", Language: "Rust", Files: 3},
+		{Ext: ".java", Language: "Java", Files: 1},
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("got %+v, want %+v", counts, want)
+	}
+	if got := DescribeExtensions(counts, 3); got != "3 .rs files (Rust), 1 .java file (Java)" {
+		t.Errorf("DescribeExtensions = %q", got)
+	}
+	if got := DescribeExtensions(counts, 1); got != "3 .rs files (Rust)" {
+		t.Errorf("DescribeExtensions with limit 1 = %q", got)
+	}
+}
@@ -0,0 +1,34 @@
+package services
+
+import "strings"
+
+// Line endings text outputs can be written with.
+const (
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+)
+
+var lineEnding = "\n"
+
+// SetLineEnding sets the line ending used by every text-based generator
+// created afterwards. Anything but "crlf" means LF.
+func SetLineEnding(name string) {
+	lineEnding = "\n"
+	if strings.EqualFold(name, LineEndingCRLF) {
+		lineEnding = "\r\n"
+	}
+}
+
+// NormalizeText makes text valid UTF-8 without a byte order mark, with eol
+// as every line ending and exactly one trailing line ending.
+func NormalizeText(text, eol string) string {
+	text = strings.ToValidUTF8(text, "\uFFFD")
+	text = strings.TrimPrefix(text, "\uFEFF")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.TrimRight(text, "\n") + "\n"
+	if eol != "\n" {
+		text = strings.ReplaceAll(text, "\n", eol)
+	}
+	return text
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name, text, eol, want string
+	}{
+		{"mixed to lf", "a\r\nb\rc\n", "\n", "a\nb\nc\n"},
+		{"mixed to crlf", "a\r\nb\rc\n", "\r\n", "a\r\nb\r\nc\r\n"},
+		{"trailing newline added", "a", "\n", "a\n"},
+		{"extra trailing newlines dropped", "a\n\n\n", "\r\n", "a\r\n"},
+		{"bom and invalid utf-8", "\uFEFFa\xffb", "\n", "a\uFFFDb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeText(tt.text, tt.eol); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownGeneratorLineEnding(t *testing.T) {
+	SetLineEnding(LineEndingCRLF)
+	t.Cleanup(func() { SetLineEnding(LineEndingLF) })
+
+	path := filepath.Join(t.TempDir(), "doc.md")
+	if err := NewMarkdownGenerator().GenerateDocumentation("# Doc\n\nfirst\r\nsecond\rthird", path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "# Doc\r\n\r\nfirst\r\nsecond\r\nthird\r\n" {
+		t.Errorf("wrote %q", data)
+	}
+	if strings.Count(string(data), "\n") != strings.Count(string(data), "\r\n") {
+		t.Errorf("bare line feeds in %q", data)
+	}
+}
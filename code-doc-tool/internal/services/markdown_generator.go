@@ -0,0 +1,52 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code-doc-tool/internal/utils"
+)
+
+type MarkdownGenerator struct {
+	// LineEnding terminates every line of the output
+	LineEnding string
+}
+
+func NewMarkdownGenerator() *MarkdownGenerator {
+	return &MarkdownGenerator{LineEnding: lineEnding}
+}
+
+func (g *MarkdownGenerator) Extension() string { return "md" }
+
+func (g *MarkdownGenerator) ContentType() string { return "text/markdown; charset=utf-8" }
+
+// GenerateDocumentation writes docText with normalized line endings.
+func (g *MarkdownGenerator) GenerateDocumentation(docText string, outputPath string) error {
+	if err := os.WriteFile(outputPath, []byte(NormalizeText(docText, g.LineEnding)), 0644); err != nil {
+		if utils.IsDiskFull(err) {
+			os.Remove(outputPath)
+			return fmt.Errorf("failed to save markdown: %w", utils.WrapDiskFull(err))
+		}
+		return fmt.Errorf("failed to save markdown: %w", err)
+	}
+	return nil
+}
+
+// MarkdownPath is where the combined markdown behind a generated output
+// file is kept, so it can be rendered into other formats later.
+func MarkdownPath(outputFilename string) string {
+	base := strings.TrimSuffix(outputFilename, filepath.Ext(outputFilename))
+	return filepath.Join("./output", ".cache", base+".md")
+}
+
+// SaveMarkdown stores the combined markdown for outputFilename. It is
+// always kept with LF line endings; generators apply their own.
+func SaveMarkdown(outputFilename, docText string) error {
+	path := MarkdownPath(outputFilename)
+	if err := utils.CreateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return (&MarkdownGenerator{LineEnding: "\n"}).GenerateDocumentation(docText, path)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// IsNotebook reports whether path is a Jupyter notebook.
+func IsNotebook(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// notebookCell is the part of a notebook cell worth analyzing; outputs,
+// execution counts and metadata are dropped.
+type notebookCell struct {
+	CellType string          `json:"cell_type"`
+	Source   json.RawMessage `json:"source"`
+}
+
+type notebook struct {
+	Cells    []notebookCell `json:"cells"`
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+// slashCommentLanguages are notebook kernels whose comments start with //.
+var slashCommentLanguages = map[string]bool{
+	"javascript": true,
+	"typescript": true,
+	"java":       true,
+	"scala":      true,
+	"kotlin":     true,
+	"go":         true,
+	"rust":       true,
+	"c++":        true,
+	"csharp":     true,
+}
+
+// NotebookSource converts notebook JSON into a percent-format script: code
+// cells as-is and markdown cells as comments, each after a "%%" marker.
+func NotebookSource(data []byte) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return "", fmt.Errorf("invalid notebook: %w", err)
+	}
+
+	language := strings.ToLower(nb.Metadata.LanguageInfo.Name)
+	if language == "" {
+		language = strings.ToLower(nb.Metadata.Kernelspec.Language)
+	}
+	comment := "#"
+	if slashCommentLanguages[language] {
+		comment = "//"
+	}
+
+	var b strings.Builder
+	if language != "" {
+		fmt.Fprintf(&b, "%s Jupyter notebook (%s)\n", comment, language)
+	}
+	for _, cell := range nb.Cells {
+		source := cellSource(cell.Source)
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		switch cell.CellType {
+		case "code":
+			fmt.Fprintf(&b, "\n%s %%%%\n%s\n", comment, strings.TrimRight(source, "\n"))
+		case "markdown":
+			fmt.Fprintf(&b, "\n%s %%%% [markdown]\n", comment)
+			for _, line := range strings.Split(strings.TrimRight(source, "\n"), "\n") {
+				fmt.Fprintf(&b, "%s %s\n", comment, line)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// cellSource joins a cell source, stored either as one string or as a
+// list of lines.
+func cellSource(raw json.RawMessage) string {
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		return strings.Join(lines, "")
+	}
+	var text string
+	json.Unmarshal(raw, &text)
+	return text
+}
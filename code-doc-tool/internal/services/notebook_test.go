@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+  "metadata": {"kernelspec": {"language": "python", "name": "python3"}, "language_info": {"name": "python"}},
+  "nbformat": 4,
+  "cells": [
+    {"cell_type": "markdown", "metadata": {}, "source": ["# Load data\n", "Reads the CSV."]},
+    {"cell_type": "code", "execution_count": 1, "metadata": {"tags": ["secret-tag"]}, "outputs": [{"output_type": "stream", "text": ["noisy output"]}], "source": "import pandas as pd\ndf = pd.read_csv('data.csv')\n"},
+    {"cell_type": "code", "metadata": {}, "outputs": [], "source": []}
+  ]
+}`
+
+func TestNotebookSource(t *testing.T) {
+	got, err := NotebookSource([]byte(testNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Jupyter notebook (python)\n" +
+		"\n# %% [markdown]\n# # Load data\n# Reads the CSV.\n" +
+		"\n# %%\nimport pandas as pd\ndf = pd.read_csv('data.csv')\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := NotebookSource([]byte("not json")); err == nil {
+		t.Error("no error for invalid notebook")
+	}
+}
+
+func TestAnalyzeNotebook(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"analysis.ipynb": testNotebook})
+
+	var sent string
+	cfg := testAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("code_file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		sent = string(content)
+		replyDocument(w, "doc")
+	})
+	if _, err := AnalyzeProject(context.Background(), cfg, filepath.Join(dir, "analysis.ipynb"), "tpl"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sent, "df = pd.read_csv('data.csv')") {
+		t.Errorf("code cell not sent: %q", sent)
+	}
+	for _, metadata := range []string{"kernelspec", "noisy output", "secret-tag", "nbformat"} {
+		if strings.Contains(sent, metadata) {
+			t.Errorf("notebook metadata %q sent to the agent: %q", metadata, sent)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"code-doc-tool/internal/models"
+)
+
+// apiSpecNames are the file names recognised as OpenAPI/Swagger specs.
+var apiSpecNames = map[string]bool{
+	"openapi.json": true,
+	"openapi.yaml": true,
+	"openapi.yml":  true,
+	"swagger.json": true,
+	"swagger.yaml": true,
+	"swagger.yml":  true,
+}
+
+// httpMethods are the operation keys of an OpenAPI path item, in the
+// order endpoints are listed.
+var httpMethods = []string{"get", "post", "put", "patch", "delete", "head", "options", "trace"}
+
+// FindAPISpec returns the OpenAPI/Swagger spec closest to root, or "" when
+// the tree has none.
+func FindAPISpec(root string) (string, error) {
+	var found string
+	depth := -1
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && skippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !apiSpecNames[strings.ToLower(info.Name())] {
+			return nil
+		}
+		if d := strings.Count(filepath.ToSlash(path), "/"); depth < 0 || d < depth {
+			found, depth = path, d
+		}
+		return nil
+	})
+	return found, err
+}
+
+// ParseAPISpec reads the endpoints declared by an OpenAPI 3 or Swagger 2
+// spec in JSON or YAML.
+func ParseAPISpec(path string) ([]models.APIEndpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &spec)
+	default:
+		spec, err = parseYAMLLite(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+
+	doc, ok := spec.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an API spec", filepath.Base(path))
+	}
+	paths, ok := doc["paths"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s declares no paths", filepath.Base(path))
+	}
+
+	baseURL := specBaseURL(doc)
+	routes := make([]string, 0, len(paths))
+	for route := range paths {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	var endpoints []models.APIEndpoint
+	for _, route := range routes {
+		item, _ := paths[route].(map[string]any)
+		for _, method := range httpMethods {
+			op, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			endpoints = append(endpoints, models.APIEndpoint{
+				Method:      strings.ToUpper(method),
+				Path:        route,
+				Handler:     specString(op["operationId"]),
+				Description: operationDescription(op),
+				CurlExample: curlExample(method, baseURL, route, op),
+			})
+		}
+	}
+	return endpoints, nil
+}
+
+// specBaseURL picks the server URL from servers (OpenAPI 3) or
+// schemes/host/basePath (Swagger 2).
+func specBaseURL(doc map[string]any) string {
+	if servers, ok := doc["servers"].([]any); ok && len(servers) > 0 {
+		if server, ok := servers[0].(map[string]any); ok {
+			if url := specString(server["url"]); url != "" {
+				return strings.TrimSuffix(url, "/")
+			}
+		}
+	}
+
+	host := specString(doc["host"])
+	if host == "" {
+		host = "localhost"
+	}
+	scheme := "http"
+	if schemes, ok := doc["schemes"].([]any); ok && len(schemes) > 0 {
+		if s := specString(schemes[0]); s != "" {
+			scheme = s
+		}
+	}
+	return scheme + "://" + host + strings.TrimSuffix(specString(doc["basePath"]), "/")
+}
+
+func operationDescription(op map[string]any) string {
+	summary := specString(op["summary"])
+	description := specString(op["description"])
+	switch {
+	case summary == "":
+		return description
+	case description == "" || description == summary:
+		return summary
+	default:
+		return summary + ". " + description
+	}
+}
+
+// curlExample builds a request for the operation, adding a JSON body for
+// operations that take one.
+func curlExample(method, baseURL, route string, op map[string]any) string {
+	cmd := fmt.Sprintf("curl -X %s \"%s%s\"", strings.ToUpper(method), baseURL, route)
+	if _, ok := op["requestBody"]; ok || hasBodyParameter(op) {
+		cmd += " \\\n  -H \"Content-Type: application/json\" \\\n  -d '{}'"
+	}
+	return cmd
+}
+
+// hasBodyParameter reports whether a Swagger 2 operation takes a body.
+func hasBodyParameter(op map[string]any) bool {
+	params, _ := op["parameters"].([]any)
+	for _, p := range params {
+		if param, ok := p.(map[string]any); ok && specString(param["in"]) == "body" {
+			return true
+		}
+	}
+	return false
+}
+
+func specString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return strings.TrimSpace(s)
+	case nil:
+		return ""
+	default:
+		return strings.TrimSpace(fmt.Sprint(s))
+	}
+}
+
+// RenderAPIEndpoints renders endpoints as a summary table followed by a
+// section per endpoint with its curl example.
+func RenderAPIEndpoints(endpoints []models.APIEndpoint) string {
+	var b strings.Builder
+	b.WriteString("## API Endpoints\n\n")
+	b.WriteString("| Method | Path | Description |\n")
+	b.WriteString("| --- | --- | --- |\n")
+	for _, e := range endpoints {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", e.Method, e.Path, strings.ReplaceAll(firstLine(e.Description), "|", "\\|"))
+	}
+
+	for _, e := range endpoints {
+		fmt.Fprintf(&b, "\n### %s %s\n\n", e.Method, e.Path)
+		if e.Description != "" {
+			b.WriteString(e.Description + "\n\n")
+		}
+		if e.Handler != "" {
+			fmt.Fprintf(&b, "Operation: %s\n\n", e.Handler)
+		}
+		b.WriteString("```bash\n" + e.CurlExample + "\n```\n")
+	}
+	return b.String()
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
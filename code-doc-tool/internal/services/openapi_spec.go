@@ -0,0 +1,242 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"code-doc-tool/internal/models"
+)
+
+// OpenAPIFormats lists the encodings an OpenAPI spec can be written in.
+var OpenAPIFormats = []string{"json", "yaml"}
+
+// ValidateOpenAPIFormat normalizes a requested spec encoding; "" means no
+// spec is wanted.
+func ValidateOpenAPIFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "":
+		return "", nil
+	case "json":
+		return "json", nil
+	case "yaml", "yml":
+		return "yaml", nil
+	default:
+		return "", fmt.Errorf("openapi must be one of %s", strings.Join(OpenAPIFormats, ", "))
+	}
+}
+
+// OpenAPIFilename names the spec written beside the document filename.
+func OpenAPIFilename(filename, format string) string {
+	return strings.TrimSuffix(filename, path.Ext(filename)) + ".openapi." + format
+}
+
+var (
+	// :id, <id>, <int:id> and {id:[0-9]+} all become {id}
+	colonParam = regexp.MustCompile(`:(\w+)`)
+	angleParam = regexp.MustCompile(`<(?:\w+:)?(\w+)>`)
+	braceParam = regexp.MustCompile(`\{(\w+)(?::[^}]*)?\}`)
+
+	curlURL = regexp.MustCompile(`^curl -X \w+ "([^"]*)"`)
+)
+
+// openAPIPath rewrites a route in the common router notations to an
+// OpenAPI path template and returns its parameters in order.
+func openAPIPath(route string) (string, []string) {
+	route = angleParam.ReplaceAllString(route, "{$1}")
+	route = braceParam.ReplaceAllString(route, "{$1}")
+	route = colonParam.ReplaceAllString(route, "{$1}")
+	var params []string
+	for _, m := range braceParam.FindAllStringSubmatch(route, -1) {
+		params = append(params, m[1])
+	}
+	return route, params
+}
+
+// BuildOpenAPISpec assembles an OpenAPI 3.0 document describing
+// endpoints. The spec carries what the endpoints record: paths, methods,
+// path parameters, summaries and whether a request takes a body; request
+// and response schemas are left open.
+func BuildOpenAPISpec(title string, endpoints []models.APIEndpoint) map[string]any {
+	paths := map[string]any{}
+	operationIDs := map[string]int{}
+	for _, e := range endpoints {
+		if e.Handler != "" {
+			operationIDs[e.Handler]++
+		}
+	}
+	servers := map[string]bool{}
+
+	for _, e := range endpoints {
+		method := strings.ToLower(e.Method)
+		route, params := openAPIPath(e.Path)
+		item, _ := paths[route].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[route] = item
+		}
+		if _, ok := item[method]; ok {
+			continue
+		}
+
+		op := map[string]any{
+			"responses": map[string]any{
+				"default": map[string]any{"description": "Response"},
+			},
+		}
+		// Operation IDs must be unique across the spec
+		if e.Handler != "" && operationIDs[e.Handler] == 1 {
+			op["operationId"] = e.Handler
+		}
+		if summary := firstLine(e.Description); summary != "" {
+			op["summary"] = summary
+			if e.Description != summary {
+				op["description"] = e.Description
+			}
+		}
+		if len(params) > 0 {
+			parameters := make([]any, 0, len(params))
+			for _, name := range params {
+				parameters = append(parameters, map[string]any{
+					"name":     name,
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]any{"type": "string"},
+				})
+			}
+			op["parameters"] = parameters
+		}
+		if strings.Contains(e.CurlExample, "\n  -d ") {
+			op["requestBody"] = map[string]any{
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": map[string]any{"type": "object"},
+					},
+				},
+			}
+		}
+		if len(e.Middleware) > 0 {
+			middleware := make([]any, len(e.Middleware))
+			for i, m := range e.Middleware {
+				middleware[i] = m
+			}
+			op["x-middleware"] = middleware
+		}
+		item[method] = op
+
+		if m := curlURL.FindStringSubmatch(e.CurlExample); m != nil && strings.HasSuffix(m[1], e.Path) {
+			servers[strings.TrimSuffix(m[1], e.Path)] = true
+		}
+	}
+
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title": title,
+			// Required by OpenAPI; the project's own version isn't known
+			"version": "1.0.0",
+		},
+		"paths": paths,
+	}
+	// Only a server every example agrees on is stated
+	if len(servers) == 1 {
+		for url := range servers {
+			if url != "" {
+				spec["servers"] = []any{map[string]any{"url": url}}
+			}
+		}
+	}
+	return spec
+}
+
+// MarshalOpenAPISpec encodes spec as JSON or YAML.
+func MarshalOpenAPISpec(spec map[string]any, format string) ([]byte, error) {
+	if format == "yaml" {
+		var b strings.Builder
+		writeYAML(&b, spec, 0)
+		return []byte(b.String()), nil
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(spec); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+var plainYAMLKey = regexp.MustCompile(`^[A-Za-z_][\w.-]*$`)
+
+// writeYAML writes the maps, lists and scalars of a spec as block YAML,
+// keys sorted as encoding/json sorts them.
+func writeYAML(b *strings.Builder, v any, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString(pad + yamlKey(key) + ":")
+			writeYAMLValue(b, v[key], indent+2)
+		}
+	case []any:
+		for _, item := range v {
+			if m, ok := item.(map[string]any); ok && len(m) > 0 {
+				// The item's first key shares the dash's line
+				var nested strings.Builder
+				writeYAML(&nested, m, indent+2)
+				b.WriteString(pad + "- " + nested.String()[indent+2:])
+				continue
+			}
+			b.WriteString(pad + "-")
+			writeYAMLValue(b, item, indent+2)
+		}
+	}
+}
+
+// writeYAMLValue writes what follows a key or dash: a scalar on the same
+// line or a nested block on the lines below.
+func writeYAMLValue(b *strings.Builder, v any, indent int) {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, v, indent)
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, v, indent)
+	default:
+		b.WriteString(" " + yamlScalarText(v) + "\n")
+	}
+}
+
+func yamlKey(key string) string {
+	if plainYAMLKey.MatchString(key) {
+		return key
+	}
+	return yamlScalarText(key)
+}
+
+// yamlScalarText encodes v as a JSON scalar, which YAML reads the same.
+func yamlScalarText(v any) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestValidateOpenAPIFormat(t *testing.T) {
+	for in, want := range map[string]string{"": "", "json": "json", " YAML ": "yaml", "yml": "yaml"} {
+		if got, err := ValidateOpenAPIFormat(in); err != nil || got != want {
+			t.Errorf("ValidateOpenAPIFormat(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ValidateOpenAPIFormat("xml"); err == nil {
+		t.Error("xml accepted")
+	}
+	if got := OpenAPIFilename("job_documentation.md", "yaml"); got != "job_documentation.openapi.yaml" {
+		t.Errorf("OpenAPIFilename = %q", got)
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	for route, want := range map[string]string{
+		"/users/:id":                   "/users/{id}",
+		"/items/<int:id>/tags/<name>":  "/items/{id}/tags/{name}",
+		"/orders/{id:[0-9]+}/{line}":   "/orders/{id}/{line}",
+		"/static":                      "/static",
+		"/teams/:team/members/:member": "/teams/{team}/members/{member}",
+	} {
+		if got, _ := openAPIPath(route); got != want {
+			t.Errorf("openAPIPath(%q) = %q, want %q", route, got, want)
+		}
+	}
+	if _, params := openAPIPath("/teams/:team/members/<int:member>"); !reflect.DeepEqual(params, []string{"team", "member"}) {
+		t.Errorf("params %v, want [team member]", params)
+	}
+}
+
+// specEndpoints are routes as a Go, Flask and Express project declare
+// them, with the curl examples the endpoint extraction writes.
+var specEndpoints = []models.APIEndpoint{
+	{
+		Method: "GET", Path: "/users/:id", Handler: "getUser",
+		Description: "Get a user\nLooks the user up by ID.",
+		CurlExample: `curl -X GET "https://api.example.com/users/:id"`,
+	},
+	{
+		Method: "POST", Path: "/items/<int:id>", Handler: "save",
+		Middleware:  []string{"auth"},
+		CurlExample: "curl -X POST \"https://api.example.com/items/<int:id>\" \\\n  -H \"Content-Type: application/json\" \\\n  -d '{}'",
+	},
+	{Method: "DELETE", Path: "/items/<int:id>", Handler: "save"},
+	// The same route again adds nothing
+	{Method: "GET", Path: "/users/:id", Handler: "other"},
+}
+
+func TestBuildOpenAPISpec(t *testing.T) {
+	spec := BuildOpenAPISpec("shop", specEndpoints)
+
+	if spec["openapi"] != "3.0.3" || spec["info"].(map[string]any)["title"] != "shop" {
+		t.Errorf("spec header %v %v", spec["openapi"], spec["info"])
+	}
+	if servers, _ := spec["servers"].([]any); len(servers) != 1 || servers[0].(map[string]any)["url"] != "https://api.example.com" {
+		t.Errorf("servers %v, want the examples' https://api.example.com", spec["servers"])
+	}
+
+	paths := spec["paths"].(map[string]any)
+	if len(paths) != 2 {
+		t.Fatalf("paths %v, want /users/{id} and /items/{id}", paths)
+	}
+	get := paths["/users/{id}"].(map[string]any)["get"].(map[string]any)
+	if get["operationId"] != "getUser" || get["summary"] != "Get a user" || get["description"] != specEndpoints[0].Description {
+		t.Errorf("GET operation %v", get)
+	}
+	if params := get["parameters"].([]any); len(params) != 1 || params[0].(map[string]any)["name"] != "id" {
+		t.Errorf("GET parameters %v", params)
+	}
+
+	item := paths["/items/{id}"].(map[string]any)
+	post := item["post"].(map[string]any)
+	// save handles two operations, so neither gets it as its ID
+	if _, ok := post["operationId"]; ok {
+		t.Errorf("shared handler used as operationId: %v", post)
+	}
+	if _, ok := post["requestBody"]; !ok {
+		t.Error("POST with a -d example has no requestBody")
+	}
+	if !reflect.DeepEqual(post["x-middleware"], []any{"auth"}) {
+		t.Errorf("x-middleware %v", post["x-middleware"])
+	}
+	if _, ok := item["delete"].(map[string]any)["requestBody"]; ok {
+		t.Error("DELETE without a body has a requestBody")
+	}
+}
+
+func TestBuildOpenAPISpecServers(t *testing.T) {
+	endpoints := []models.APIEndpoint{
+		{Method: "GET", Path: "/a", CurlExample: `curl -X GET "https://one.example.com/a"`},
+		{Method: "GET", Path: "/b", CurlExample: `curl -X GET "https://two.example.com/b"`},
+	}
+	if servers, ok := BuildOpenAPISpec("p", endpoints)["servers"]; ok {
+		t.Errorf("servers %v from examples that disagree", servers)
+	}
+	if servers, ok := BuildOpenAPISpec("p", endpoints[:1])["servers"]; !ok {
+		t.Error("no server from a single example")
+	} else if url := servers.([]any)[0].(map[string]any)["url"]; url != "https://one.example.com" {
+		t.Errorf("server %v", url)
+	}
+}
+
+// The written spec reads back as the same endpoints in either encoding
+func TestMarshalOpenAPISpec(t *testing.T) {
+	dir := t.TempDir()
+	for _, format := range OpenAPIFormats {
+		data, err := MarshalOpenAPISpec(BuildOpenAPISpec("shop", specEndpoints), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "openapi."+format)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		endpoints, err := ParseAPISpec(path)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, data)
+		}
+
+		var got []string
+		for _, e := range endpoints {
+			got = append(got, e.Method+" "+e.Path+" "+e.Handler)
+		}
+		want := []string{"POST /items/{id} ", "DELETE /items/{id} ", "GET /users/{id} getUser"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s endpoints %q, want %q\n%s", format, got, want, data)
+		}
+		if len(endpoints) == 3 {
+			if d := endpoints[2].Description; !strings.HasPrefix(d, "Get a user") || !strings.HasSuffix(d, "Looks the user up by ID.") {
+				t.Errorf("%s description %q", format, endpoints[2].Description)
+			}
+			if !strings.HasPrefix(endpoints[0].CurlExample, `curl -X POST "https://api.example.com/items/{id}"`) ||
+				!strings.Contains(endpoints[0].CurlExample, "-d '{}'") {
+				t.Errorf("%s curl example %q", format, endpoints[0].CurlExample)
+			}
+		}
+	}
+}
+
+func TestOpenAPIContentType(t *testing.T) {
+	for name, want := range map[string]string{"a.openapi.json": "application/json", "a.openapi.yaml": "application/yaml"} {
+		if got := ContentTypeFor(name); got != want {
+			t.Errorf("ContentTypeFor(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
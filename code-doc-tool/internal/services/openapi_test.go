@@ -0,0 +1,104 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code-doc-tool/internal/models"
+)
+
+const petstoreYAML = `openapi: 3.0.0
+info:
+  title: Petstore
+servers:
+  - url: https://api.example.com/v1/
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List pets
+    post:
+      summary: Create a pet
+      requestBody:
+        content:
+          application/json: {}
+  /pets/{id}:
+    delete:
+      description: Delete a pet
+`
+
+func TestParseAPISpec(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"api/openapi.yaml": petstoreYAML,
+		"swagger.json": `{"swagger": "2.0", "host": "pets.local", "basePath": "/api", "schemes": ["https"],
+			"paths": {"/pets": {"put": {"summary": "Replace", "parameters": [{"in": "body", "name": "pet"}]}}}}`,
+	})
+
+	endpoints, err := ParseAPISpec(filepath.Join(dir, "api", "openapi.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.APIEndpoint{
+		{Method: "GET", Path: "/pets", Handler: "listPets", Description: "List pets",
+			CurlExample: `curl -X GET "https://api.example.com/v1/pets"`},
+		{Method: "POST", Path: "/pets", Description: "Create a pet",
+			CurlExample: "curl -X POST \"https://api.example.com/v1/pets\" \\\n  -H \"Content-Type: application/json\" \\\n  -d '{}'"},
+		{Method: "DELETE", Path: "/pets/{id}", Description: "Delete a pet",
+			CurlExample: `curl -X DELETE "https://api.example.com/v1/pets/{id}"`},
+	}
+	if len(endpoints) != len(want) {
+		t.Fatalf("got %+v", endpoints)
+	}
+	for i := range want {
+		got := endpoints[i]
+		if got.Method != want[i].Method || got.Path != want[i].Path || got.Handler != want[i].Handler ||
+			got.Description != want[i].Description || got.CurlExample != want[i].CurlExample {
+			t.Errorf("endpoint %d:\n got %+v\nwant %+v", i, got, want[i])
+		}
+	}
+
+	swagger, err := ParseAPISpec(filepath.Join(dir, "swagger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swagger) != 1 || !strings.HasPrefix(swagger[0].CurlExample, `curl -X PUT "https://pets.local/api/pets" \`) {
+		t.Errorf("swagger endpoints %+v", swagger)
+	}
+
+	if found, _ := FindAPISpec(dir); filepath.Base(found) != "swagger.json" {
+		t.Errorf("FindAPISpec = %s, want the shallowest spec", found)
+	}
+}
+
+func TestParseAPISpecInvalid(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"openapi.json": `{"openapi": "3.0.0"}`,
+		"swagger.json": `not json`,
+	})
+	for _, name := range []string{"openapi.json", "swagger.json"} {
+		if _, err := ParseAPISpec(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s parsed", name)
+		}
+	}
+	if found, _ := FindAPISpec(t.TempDir()); found != "" {
+		t.Errorf("FindAPISpec found %s in an empty tree", found)
+	}
+}
+
+func TestRenderAPIEndpoints(t *testing.T) {
+	out := RenderAPIEndpoints([]models.APIEndpoint{{
+		Method: "GET", Path: "/a", Description: "Reads a | b\nMore detail", CurlExample: `curl -X GET "http://localhost/a"`,
+	}})
+	for _, want := range []string{
+		"| GET | /a | Reads a \\| b |",
+		"### GET /a",
+		"```bash\ncurl -X GET \"http://localhost/a\"\n```",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Output collision strategies, for when a document's file name is taken.
+const (
+	CollisionOverwrite = "overwrite"
+	CollisionSuffix    = "suffix"
+	CollisionFail      = "fail"
+)
+
+// ErrOutputExists reports a taken output name under CollisionFail.
+var ErrOutputExists = errors.New("output file already exists")
+
+// OutputDir expands an output directory template such as
+// "{project}/{date}/" for one job's document. Supported placeholders are
+// {project}, {job} and {date} (YYYY-MM-DD). The result is a clean
+// slash-separated path relative to the output root, or "" for an empty
+// template.
+func OutputDir(template, project, jobID string, date time.Time) string {
+	if strings.TrimSpace(template) == "" {
+		return ""
+	}
+	dir := strings.NewReplacer(
+		"{project}", safePathSegment(project),
+		"{job}", jobID,
+		"{date}", date.Format(time.DateOnly),
+	).Replace(template)
+
+	// Values never introduce separators, but guard against an escaping
+	// template all the same
+	dir = strings.TrimPrefix(path.Clean("/"+dir), "/")
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// ResolveCollision returns the file to write a document to when name may
+// already exist: CollisionOverwrite keeps name, CollisionSuffix appends
+// -1, -2 and so on before the extension until a name is free, and
+// CollisionFail returns ErrOutputExists. Under the last two the name
+// returned is claimed by creating it, empty, with O_EXCL, so concurrent
+// jobs never both get it; the caller writes over it, or removes it if
+// nothing is written after all.
+func ResolveCollision(name, strategy string) (string, error) {
+	if strategy == CollisionOverwrite {
+		return name, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return "", err
+	}
+	err := claimFile(name)
+	if strategy == CollisionFail && errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("%w: %s", ErrOutputExists, filepath.Base(name))
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for n := 1; errors.Is(err, fs.ErrExist); n++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, n, ext)
+		err = claimFile(candidate)
+	}
+	if err != nil {
+		return "", err
+	}
+	return candidate, nil
+}
+
+// claimFile creates name empty, failing with fs.ErrExist if it exists.
+func claimFile(name string) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// safePathSegment keeps a placeholder value within one path segment.
+func safePathSegment(s string) string {
+	s = strings.NewReplacer("/", "-", "\\", "-").Replace(s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
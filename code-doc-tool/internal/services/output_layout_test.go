@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResolveCollision(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     string
+		err      error
+	}{
+		{CollisionOverwrite, "doc.md", nil},
+		{CollisionSuffix, "doc-2.md", nil},
+		{CollisionFail, "", ErrOutputExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"doc.md": "old", "doc-1.md": "old"})
+			got, err := ResolveCollision(filepath.Join(dir, "doc.md"), tt.strategy)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if tt.want == "" {
+				return
+			}
+			if filepath.Base(got) != tt.want {
+				t.Errorf("resolved %s, want %s", filepath.Base(got), tt.want)
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, "doc.md")); string(data) != "old" {
+				t.Error("the existing output was changed")
+			}
+		})
+	}
+}
+
+func TestResolveCollisionFreeName(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "nested", "doc.md")
+	for _, strategy := range []string{CollisionSuffix, CollisionFail} {
+		got, err := ResolveCollision(name, strategy)
+		if err != nil || got != name {
+			t.Fatalf("%s: resolved %s, %v, want %s", strategy, got, err, name)
+		}
+		// The name is claimed until the caller writes or removes it
+		if _, err := os.Stat(got); err != nil {
+			t.Errorf("%s: name not claimed: %v", strategy, err)
+		}
+		os.Remove(got)
+	}
+}
+
+func TestResolveCollisionConcurrent(t *testing.T) {
+	const callers = 8
+	resolve := func(strategy string) ([]string, int) {
+		name := filepath.Join(t.TempDir(), "doc.md")
+		var mu sync.Mutex
+		var names []string
+		failed := 0
+		var wg sync.WaitGroup
+		for range callers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := ResolveCollision(name, strategy)
+				mu.Lock()
+				defer mu.Unlock()
+				if errors.Is(err, ErrOutputExists) {
+					failed++
+				} else if err != nil {
+					t.Error(err)
+				} else {
+					names = append(names, filepath.Base(got))
+				}
+			}()
+		}
+		wg.Wait()
+		sort.Strings(names)
+		return names, failed
+	}
+
+	names, _ := resolve(CollisionSuffix)
+	for i := 1; i < len(names); i++ {
+		if names[i] == names[i-1] {
+			t.Fatalf("two callers got %s: %v", names[i], names)
+		}
+	}
+	if len(names) != callers {
+		t.Errorf("resolved %v, want %d names", names, callers)
+	}
+
+	names, failed := resolve(CollisionFail)
+	if len(names) != 1 || failed != callers-1 {
+		t.Errorf("fail strategy: %v resolved and %d failed, want exactly one through", names, failed)
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	date := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct{ template, project, want string }{
+		{"", "app", ""},
+		{"{project}/{date}/", "app", "app/2024-05-06"},
+		{"docs/{job}", "app", "docs/job-1"},
+		{"{project}", "../etc", "..-etc"},
+		{"{project}", "..", "_"},
+		{"../../{project}", "app", "app"},
+	} {
+		if got := OutputDir(tt.template, tt.project, "job-1", date); got != tt.want {
+			t.Errorf("OutputDir(%q, %q) = %q, want %q", tt.template, tt.project, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code-doc-tool/internal/models"
+)
+
+// SweepOutputs removes files under dir last modified more than ttl ago and
+// returns how many were removed. Dot-directories hold internal state (job
+// records, doc caches, diagrams) that must outlive its own mtime; a job's
+// share of it goes once its outputs are gone, see sweepJobState.
+func SweepOutputs(dir string, ttl time.Duration) int {
+	cutoff := time.Now().Add(-ttl)
+	removed := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("Failed to remove expired output %s: %v", path, err)
+			return nil
+		}
+		removed++
+		return nil
+	})
+	return removed + sweepJobState(dir, cutoff)
+}
+
+// sweepJobState removes the record, doc cache, markdown and diagrams of
+// every finished job under dir whose outputs have all been swept, once its
+// record was last written before cutoff. It returns how many files went.
+func sweepJobState(dir string, cutoff time.Time) int {
+	records, _ := filepath.Glob(filepath.Join(dir, ".jobs", "*.json"))
+	removed := 0
+	for _, record := range records {
+		info, err := os.Stat(record)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		data, err := os.ReadFile(record)
+		if err != nil {
+			continue
+		}
+		var job models.Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" || models.JobActive(job.Status) {
+			continue
+		}
+		if outputsLeft(dir, job) {
+			continue
+		}
+
+		paths := []string{filepath.Join(dir, ".cache", job.ID+".json")}
+		for _, output := range job.Outputs {
+			for _, filename := range append([]string{output.Filename}, output.Artifacts...) {
+				base := strings.TrimSuffix(filename, filepath.Ext(filename))
+				paths = append(paths, filepath.Join(dir, ".cache", base+".md"))
+			}
+		}
+		for _, path := range paths {
+			if os.Remove(path) == nil {
+				removed++
+			}
+		}
+		diagrams := filepath.Join(dir, ".cache", "diagrams", job.ID)
+		if entries, err := os.ReadDir(diagrams); err == nil && os.RemoveAll(diagrams) == nil {
+			removed += len(entries)
+		}
+		// The record goes last, so a failed sweep is picked up again
+		if err := os.Remove(record); err != nil {
+			log.Printf("Failed to remove expired job record %s: %v", record, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// outputsLeft reports whether any of job's generated files are still
+// under dir.
+func outputsLeft(dir string, job models.Job) bool {
+	for _, output := range job.Outputs {
+		for _, filename := range append([]string{output.Filename}, output.Artifacts...) {
+			if _, err := os.Stat(filepath.Join(dir, filename)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OutputSweeper periodically removes expired outputs until ctx is
+// cancelled. A zero ttl keeps outputs forever.
+func OutputSweeper(ctx context.Context, dir string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	interval := ttl / 4
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := SweepOutputs(dir, ttl); n > 0 {
+				log.Printf("Output sweeper removed %d expired files", n)
+			}
+		}
+	}
+}
+
+// OutputExpiry returns when the output file at path will be swept, or
+// false if outputs don't expire or the file is gone.
+func OutputExpiry(path string, ttl time.Duration) (time.Time, bool) {
+	if ttl <= 0 {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime().Add(ttl), true
+}
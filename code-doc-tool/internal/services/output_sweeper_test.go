@@ -0,0 +1,59 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestSweepOutputsKeepsJobState(t *testing.T) {
+	dir := t.TempDir()
+	record := func(job models.Job) string {
+		data, err := json.Marshal(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	writeFiles(t, dir, map[string]string{
+		// done's outputs have expired, kept's are still fresh
+		"done_documentation.md":           "old",
+		"kept_documentation.md":           "new",
+		".jobs/done.json":                 record(models.Job{ID: "done", Status: models.JobStatusCompleted, Outputs: []models.JobOutput{{Filename: "done_documentation.md"}}}),
+		".jobs/kept.json":                 record(models.Job{ID: "kept", Status: models.JobStatusCompleted, Outputs: []models.JobOutput{{Filename: "kept_documentation.md"}}}),
+		".jobs/failed.json":               record(models.Job{ID: "failed", Status: models.JobStatusFailed}),
+		".jobs/running.json":              record(models.Job{ID: "running", Status: models.JobStatusProcessing}),
+		".cache/done.json":                "{}",
+		".cache/done_documentation.md":    "# Done",
+		".cache/diagrams/done/1-flow.png": "png",
+		".cache/kept.json":                "{}",
+		".cache/kept_documentation.md":    "# Kept",
+		".cache/diagrams/kept/1-flow.png": "png",
+	})
+	old := time.Now().Add(-2 * time.Hour)
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Base(path) != "kept_documentation.md" {
+			os.Chtimes(path, old, old)
+		}
+		return nil
+	})
+
+	if n := SweepOutputs(dir, time.Hour); n != 6 {
+		t.Errorf("removed %d files, want done's output, record, caches and diagram and failed's record", n)
+	}
+	for _, gone := range []string{"done_documentation.md", ".jobs/done.json", ".jobs/failed.json", ".cache/done.json", ".cache/done_documentation.md", ".cache/diagrams/done"} {
+		if _, err := os.Stat(filepath.Join(dir, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s was kept", gone)
+		}
+	}
+	// State of jobs with outputs left, or still running, stays however old
+	for _, kept := range []string{"kept_documentation.md", ".jobs/kept.json", ".jobs/running.json", ".cache/kept.json", ".cache/kept_documentation.md", ".cache/diagrams/kept/1-flow.png"} {
+		if _, err := os.Stat(filepath.Join(dir, kept)); err != nil {
+			t.Errorf("%s was swept: %v", kept, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ways of producing the project-level overview placed at the top of the
+// combined document.
+const (
+	OverviewOff       = "off"
+	OverviewAggregate = "aggregate"
+	OverviewAgent     = "agent"
+)
+
+// ValidateOverviewMode normalizes an overview mode, treating "" as off.
+func ValidateOverviewMode(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		return OverviewOff, nil
+	case OverviewOff, OverviewAggregate, OverviewAgent:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown overview mode %q, expected one of off, aggregate, agent", mode)
+}
+
+// FileOverview is the overview section of one per-file document.
+type FileOverview struct {
+	File string
+	Text string
+}
+
+// overviewTemplate asks the agent to merge per-file overviews.
+const overviewTemplate = `# Project Overview
+- Purpose of the project as a whole
+- Main components and how they fit together
+- Write it from the per-file overviews in the attached file; don't describe files one by one
+`
+
+var numberedHeading = regexp.MustCompile(`^\d+\.\s*`)
+
+// ExtractOverview returns the text under doc's first "Overview" heading
+// (numbered or not), up to the next heading of the same or a higher level.
+func ExtractOverview(doc string) string {
+	level := 0
+	var body []string
+	inCode := false
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+		if m := mdHeading.FindStringSubmatch(trimmed); m != nil && !inCode {
+			if level > 0 && len(m[1]) <= level {
+				break
+			}
+			if level == 0 && strings.EqualFold(numberedHeading.ReplaceAllString(m[2], ""), "Overview") {
+				level = len(m[1])
+				continue
+			}
+		}
+		if level > 0 {
+			body = append(body, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(body, "\n"))
+}
+
+// AggregateOverview builds a project overview from the per-file ones,
+// keeping the first paragraph of each.
+func AggregateOverview(overviews []FileOverview) string {
+	var b strings.Builder
+	b.WriteString("## Project Overview\n\n")
+	for _, o := range overviews {
+		paragraph, _, _ := strings.Cut(o.Text, "\n\n")
+		fmt.Fprintf(&b, "- **%s**: %s\n", o.File, strings.Join(strings.Fields(paragraph), " "))
+	}
+	return b.String()
+}
+
+// SynthesizeOverview asks analyzer for a unified project overview written
+// from the per-file overviews, which are sent as one markdown file, and
+// the project's documentation in docContext, if any.
+func SynthesizeOverview(ctx context.Context, analyzer Analyzer, projectName string, overviews []FileOverview, docContext string) (string, error) {
+	dir, err := os.MkdirTemp("", "overview-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Per-file overviews of %s\n", projectName)
+	for _, o := range overviews {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", o.File, o.Text)
+	}
+	path := filepath.Join(dir, "overviews.md")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", err
+	}
+
+	doc, err := analyzer.Analyze(ctx, path, WithDocContext(overviewTemplate, docContext))
+	if err != nil {
+		return "", err
+	}
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return "", fmt.Errorf("agent returned an empty overview")
+	}
+
+	// The agent's own title is replaced by ours
+	if first, rest, _ := strings.Cut(doc, "\n"); strings.HasPrefix(first, "# ") {
+		doc = strings.TrimSpace(rest)
+	}
+	return "## Project Overview\n\n" + doc + "\n", nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// synthesisAnalyzer answers a synthesis call with doc, recording the
+// per-file overviews and the template it was sent.
+type synthesisAnalyzer struct {
+	doc      string
+	err      error
+	sent     string
+	template string
+}
+
+func (a *synthesisAnalyzer) Analyze(ctx context.Context, path, formatTemplate string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	a.sent, a.template = string(content), formatTemplate
+	return a.doc, a.err
+}
+
+func TestExtractOverview(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"numbered", "# main.go\n\n## 1. Overview\nStarts the server.\n\n## 2. Setup\nRun it.\n", "Starts the server."},
+		{"nested kept", "## Overview\nIntro.\n### Details\nMore.\n## APIs\n", "Intro.\n### Details\nMore."},
+		{"heading in code", "## Overview\n```\n## APIs\n```\nDone.\n", "```\n## APIs\n```\nDone."},
+		{"none", "# main.go\n\n## Setup\nRun it.\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractOverview(tt.doc); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateOverview(t *testing.T) {
+	got := AggregateOverview([]FileOverview{
+		{File: "main.go", Text: "Starts\nthe server.\n\nSecond paragraph."},
+		{File: "util.go", Text: "Helpers."},
+	})
+	want := "## Project Overview\n\n- **main.go**: Starts the server.\n- **util.go**: Helpers.\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSynthesizeOverview(t *testing.T) {
+	overviews := []FileOverview{{File: "main.go", Text: "Starts the server."}}
+	analyzer := &synthesisAnalyzer{doc: "# Demo\n\nA demo service.\n"}
+	got, err := SynthesizeOverview(context.Background(), analyzer, "demo", overviews, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "## Project Overview\n\nA demo service.\n" {
+		t.Errorf("got %q", got)
+	}
+	if !strings.Contains(analyzer.sent, "## main.go\n\nStarts the server.") {
+		t.Errorf("agent was sent %q", analyzer.sent)
+	}
+
+	if analyzer.template != overviewTemplate {
+		t.Errorf("template without project documentation changed: %q", analyzer.template)
+	}
+	if _, err := SynthesizeOverview(context.Background(), analyzer, "demo", overviews, "--- README.md ---\nA demo."); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(analyzer.template, overviewTemplate) || !strings.Contains(analyzer.template, "--- README.md ---\nA demo.") {
+		t.Errorf("template without the project's documentation:\n%s", analyzer.template)
+	}
+
+	for _, failing := range []*synthesisAnalyzer{{doc: "  \n"}, {err: errors.New("agent down")}} {
+		if _, err := SynthesizeOverview(context.Background(), failing, "demo", overviews, ""); err == nil {
+			t.Errorf("no error for %+v", failing)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"code-doc-tool/internal/utils"
+)
+
+// Ways of grouping the per-file sections of the combined document.
+const (
+	GroupNone    = ""
+	GroupPackage = "package"
+)
+
+// ValidateGroup normalizes a grouping name.
+func ValidateGroup(group string) (string, error) {
+	switch group = strings.ToLower(strings.TrimSpace(group)); group {
+	case GroupNone, "none":
+		return GroupNone, nil
+	case GroupPackage:
+		return group, nil
+	}
+	return "", fmt.Errorf("unknown group %q, expected package or none", group)
+}
+
+// packageDecls match the declaration naming a file's package or namespace,
+// by language.
+var packageDecls = map[string]*regexp.Regexp{
+	"Go":     regexp.MustCompile(`^package\s+(\w+)`),
+	"Java":   regexp.MustCompile(`^package\s+([\w.]+)\s*;`),
+	"Kotlin": regexp.MustCompile(`^package\s+([\w.]+)`),
+	"C#":     regexp.MustCompile(`^namespace\s+([\w.]+)`),
+	"PHP":    regexp.MustCompile(`^namespace\s+([\w\\]+)\s*;`),
+}
+
+// packageScanLines bounds how far into a file its declaration is looked
+// for.
+const packageScanLines = 200
+
+// PackageOf names the package or namespace path belongs to. Declared names
+// win for Java, Kotlin, C# and PHP; Go packages are named by directory
+// (the declared name only for the root), Python modules by their dotted
+// directory, and anything else by its directory relative to root.
+func PackageOf(root, path, language string) string {
+	dir, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil {
+		dir = filepath.Dir(path)
+	}
+	dir = filepath.ToSlash(dir)
+
+	declared := declaredPackage(path, language)
+	switch language {
+	case "Go":
+		if dir == "." && declared != "" {
+			return declared
+		}
+	case "Java", "Kotlin", "C#", "PHP":
+		if declared != "" {
+			return declared
+		}
+	case "Python":
+		if dir != "." {
+			return strings.ReplaceAll(dir, "/", ".")
+		}
+	}
+	if dir == "." {
+		return "(root)"
+	}
+	return dir
+}
+
+func declaredPackage(path, language string) string {
+	decl, ok := packageDecls[language]
+	if !ok {
+		return ""
+	}
+	file, err := utils.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for n := 0; n < packageScanLines && scanner.Scan(); n++ {
+		if m := decl.FindStringSubmatch(strings.TrimSpace(scanner.Text())); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// FileSection is one file's documentation on its way into the combined
+// document.
+type FileSection struct {
+	Package string
+	Doc     string
+}
+
+// RenderPackageGroups nests sections under a heading per package, packages
+// in name order and sections in their original order within each. Section
+// headings are demoted one level to sit under the package's.
+func RenderPackageGroups(sections []FileSection, separator string) string {
+	var names []string
+	groups := map[string][]string{}
+	for _, section := range sections {
+		if _, ok := groups[section.Package]; !ok {
+			names = append(names, section.Package)
+		}
+		groups[section.Package] = append(groups[section.Package], OffsetHeadings(section.Doc, 1))
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("# Package %s\n\n%s", name, strings.Join(groups[name], separator)))
+	}
+	return strings.Join(parts, separator)
+}
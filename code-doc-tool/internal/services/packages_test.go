@@ -0,0 +1,66 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackageOf(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go":                      "// Command app.\npackage main\n",
+		"internal/store/store.go":      "package store\n",
+		"internal/store/store_test.go": "package store_test\n",
+		"src/com/acme/App.java":        "// header\npackage com.acme.app;\n\nclass App {}\n",
+		"src/Other.java":               "class Other {}\n",
+		"lib/Service.php":              "<?php\nnamespace Acme\\Billing;\n",
+		"app/models/user.py":           "class User: pass\n",
+		"setup.py":                     "print(1)\n",
+		"web/index.js":                 "export {}\n",
+		"Program.cs":                   "namespace Acme.Tools\n{\n}\n",
+	})
+	tests := []struct {
+		path, language, want string
+	}{
+		{"main.go", "Go", "main"},
+		{"internal/store/store.go", "Go", "internal/store"},
+		{"internal/store/store_test.go", "Go", "internal/store"},
+		{"src/com/acme/App.java", "Java", "com.acme.app"},
+		{"src/Other.java", "Java", "src"},
+		{"lib/Service.php", "PHP", `Acme\Billing`},
+		{"app/models/user.py", "Python", "app.models"},
+		{"setup.py", "Python", "(root)"},
+		{"web/index.js", "JavaScript", "web"},
+		{"Program.cs", "C#", "Acme.Tools"},
+	}
+	for _, tt := range tests {
+		if got := PackageOf(root, filepath.Join(root, filepath.FromSlash(tt.path)), tt.language); got != tt.want {
+			t.Errorf("PackageOf(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRenderPackageGroups(t *testing.T) {
+	got := RenderPackageGroups([]FileSection{
+		{Package: "internal/store", Doc: "# store.go\n\nStores."},
+		{Package: "main", Doc: "# main.go\n\nStarts."},
+		{Package: "internal/store", Doc: "# cache.go\n\nCaches."},
+	}, "\n---\n")
+	want := "# Package internal/store\n\n## store.go\n\nStores.\n---\n## cache.go\n\nCaches." +
+		"\n---\n# Package main\n\n## main.go\n\nStarts."
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestValidateGroup(t *testing.T) {
+	for in, want := range map[string]string{"": GroupNone, "none": GroupNone, " Package ": GroupPackage} {
+		if got, err := ValidateGroup(in); err != nil || got != want {
+			t.Errorf("ValidateGroup(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ValidateGroup("module"); err == nil || !strings.Contains(err.Error(), "unknown group") {
+		t.Errorf("ValidateGroup(module) = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectManifests mark the root directory of an independent project.
+var projectManifests = []string{
+	"go.mod",
+	"package.json",
+	"composer.json",
+	"requirements.txt",
+	"pyproject.toml",
+	"setup.py",
+	"Cargo.toml",
+	"pom.xml",
+	"build.gradle",
+	"Gemfile",
+}
+
+// ignoreFiles hold exclusion rules that later stages may honour.
+var ignoreFiles = map[string]bool{
+	".gitignore":    true,
+	".dockerignore": true,
+	".docignore":    true,
+}
+
+func IsProjectManifest(name string) bool {
+	for _, manifest := range projectManifests {
+		if name == manifest {
+			return true
+		}
+	}
+	return false
+}
+
+func IsIgnoreFile(name string) bool {
+	return ignoreFiles[name]
+}
+
+// skippedDirs are never searched for manifests.
+var skippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
+
+func hasManifest(dir string) bool {
+	for _, manifest := range projectManifests {
+		if _, err := os.Stat(filepath.Join(dir, manifest)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectProjectRoots returns the top-most directories under root that carry
+// a project manifest. A workspace root (see DetectWorkspace) contributes
+// the packages it declares instead, and the workspaces found are returned
+// too. Manifests nested inside an already detected project belong to that
+// project. If none are found, root itself is the project.
+func DetectProjectRoots(root string) ([]string, []Workspace, error) {
+	var roots []string
+	var workspaces []Workspace
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && skippedDirs[info.Name()] {
+			return filepath.SkipDir
+		}
+		if ws := DetectWorkspace(path); ws != nil {
+			roots = append(roots, ws.Packages...)
+			workspaces = append(workspaces, *ws)
+			return filepath.SkipDir
+		}
+		if hasManifest(path) {
+			roots = append(roots, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(roots) == 0 {
+		return []string{root}, nil, nil
+	}
+	return roots, workspaces, nil
+}
+
+// ResolveRoots joins user supplied relative paths onto base, rejecting any
+// that escape it or aren't directories.
+func ResolveRoots(base string, rels []string) ([]string, error) {
+	var roots []string
+	for _, rel := range rels {
+		root, err := ResolveSubpath(base, rel)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+// ValidateSubpath lexically checks that rel is a relative path that stays
+// inside whatever directory it is later joined onto.
+func ValidateSubpath(rel string) error {
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if filepath.IsAbs(clean) || strings.HasPrefix(rel, "/") ||
+		clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("path %q escapes the archive root", rel)
+	}
+	return nil
+}
+
+// ResolveSubpath joins rel onto base and ensures the result stays inside
+// base and is an existing directory.
+func ResolveSubpath(base, rel string) (string, error) {
+	if err := ValidateSubpath(rel); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(base, filepath.FromSlash(rel))
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("path %q is not a directory in the archive", rel)
+	}
+	return path, nil
+}
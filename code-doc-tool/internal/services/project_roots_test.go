@@ -0,0 +1,80 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// relPaths makes paths relative to dir, slash separated.
+func relPaths(t *testing.T, dir string, paths []string) []string {
+	t.Helper()
+	rels := []string{}
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	return rels
+}
+
+func TestDetectProjectRoots(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"api/go.mod":                        "module api\n",
+		"api/tools/go.mod":                  "module tools\n",
+		"web/package.json":                  "{}\n",
+		"web/node_modules/dep/package.json": "{}\n",
+		"scripts/run.sh":                    "echo\n",
+		"vendor/lib/go.mod":                 "module lib\n",
+	})
+	roots, workspaces, err := DetectProjectRoots(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relPaths(t, dir, roots); !reflect.DeepEqual(got, []string{"api", "web"}) {
+		t.Errorf("roots %v, want [api web]", got)
+	}
+	if len(workspaces) != 0 {
+		t.Errorf("workspaces %+v", workspaces)
+	}
+}
+
+func TestDetectProjectRootsNone(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n"})
+	roots, _, err := DetectProjectRoots(dir)
+	if err != nil || !reflect.DeepEqual(roots, []string{dir}) {
+		t.Errorf("got %v, %v, want the root itself", roots, err)
+	}
+}
+
+func TestResolveRoots(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"svc/a/main.go": "package main\n", "svc/b.go": "package svc\n"})
+
+	roots, err := ResolveRoots(dir, []string{"svc/a", "./svc"})
+	if err != nil || !reflect.DeepEqual(relPaths(t, dir, roots), []string{"svc/a", "svc"}) {
+		t.Errorf("got %v, %v", roots, err)
+	}
+	for _, bad := range []string{"../x", "/etc", "svc/b.go", "missing", "svc/../../x"} {
+		if _, err := ResolveRoots(dir, []string{bad}); err == nil {
+			t.Errorf("ResolveRoots accepted %q", bad)
+		}
+	}
+}
+
+func TestValidateSubpath(t *testing.T) {
+	for _, ok := range []string{"", ".", "src", "src/app", "a/../b", "..foo"} {
+		if err := ValidateSubpath(ok); err != nil {
+			t.Errorf("ValidateSubpath(%q) = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"..", "../src", "/abs", "a/../../b"} {
+		if err := ValidateSubpath(bad); err == nil {
+			t.Errorf("ValidateSubpath(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/utils"
+)
+
+// Import/dependency markers that identify a project's role.
+var (
+	frontendMarkers = []string{"react", "vue", "@angular/core", "svelte", "next", "nuxt"}
+	serviceMarkers  = []string{
+		"express", "fastify", "koa", "@nestjs/core", "hapi",
+		"net/http", "gin-gonic/gin", "gofiber/fiber", "labstack/echo", "go-chi/chi", "gorilla/mux",
+		"flask", "django", "fastapi", "tornado", "aiohttp",
+		"laravel/framework", "symfony/",
+	}
+	cliMarkers = []string{
+		"flag", "spf13/cobra", "urfave/cli",
+		"argparse", "click", "typer", "__main__",
+		"commander", "yargs",
+	}
+)
+
+// ClassifyProject inspects the manifests and sources under root and returns
+// one of the models.ProjectType* values.
+func ClassifyProject(root string) string {
+	var paths []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != root && skippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel, err := filepath.Rel(root, path); err == nil {
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	return classifyFiles(paths, func(rel string) string {
+		return readHead(filepath.Join(root, rel), 16*1024)
+	})
+}
+
+// classifyFiles decides the project type from relative paths and a reader
+// for their leading content.
+func classifyFiles(paths []string, read func(rel string) string) string {
+	has := map[string]bool{}
+	for _, p := range paths {
+		has[p] = true
+	}
+
+	// package.json declares most of what we need for JS projects
+	if has["package.json"] {
+		var pkg struct {
+			Bin             json.RawMessage   `json:"bin"`
+			Main            string            `json:"main"`
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+		}
+		if json.Unmarshal([]byte(read("package.json")), &pkg) == nil {
+			switch {
+			case len(pkg.Bin) > 0:
+				return models.ProjectTypeCLI
+			case hasDependency(pkg.Dependencies, frontendMarkers) || hasDependency(pkg.DevDependencies, frontendMarkers):
+				return models.ProjectTypeFrontend
+			case hasDependency(pkg.Dependencies, serviceMarkers):
+				return models.ProjectTypeService
+			}
+		}
+	}
+	if has["index.html"] || has["public/index.html"] || has["src/index.html"] {
+		return models.ProjectTypeFrontend
+	}
+
+	// Otherwise look at what the entrypoints import
+	var entrypoints, sources []string
+	for _, p := range paths {
+		base := filepath.Base(p)
+		switch {
+		case base == "main.go" || strings.HasPrefix(p, "cmd/") && strings.HasSuffix(p, ".go"),
+			base == "__main__.py" || base == "manage.py" || base == "app.py" || base == "main.py",
+			base == "server.js" || base == "index.js" || base == "app.js":
+			entrypoints = append(entrypoints, p)
+		case DetectLanguage(p) != "Other":
+			sources = append(sources, p)
+		}
+	}
+
+	switch {
+	case len(entrypoints) > 0:
+		// An entrypoint that parses flags is a CLI even if it also talks
+		// HTTP; one that only delegates is judged by the rest of the code
+		switch {
+		case containsMarker(entrypoints, read, serviceMarkers):
+			return models.ProjectTypeService
+		case containsMarker(entrypoints, read, cliMarkers):
+			return models.ProjectTypeCLI
+		case containsMarker(sources, read, serviceMarkers):
+			return models.ProjectTypeService
+		}
+		return models.ProjectTypeCLI
+	case len(sources) > 0:
+		return models.ProjectTypeLibrary
+	}
+	return models.ProjectTypeUnknown
+}
+
+func hasDependency(deps map[string]string, markers []string) bool {
+	for name := range deps {
+		for _, marker := range markers {
+			if name == marker || strings.HasPrefix(name, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsMarker reports whether any file mentions one of the markers in a
+// quoted import or module reference. Markers naming a repository path,
+// such as "gin-gonic/gin", also match behind their host, as Go imports
+// them.
+func containsMarker(paths []string, read func(string) string, markers []string) bool {
+	for _, p := range paths {
+		content := read(p)
+		for _, marker := range markers {
+			if strings.Contains(content, `"`+marker) || strings.Contains(content, `'`+marker) ||
+				strings.Contains(content, "import "+marker) || strings.Contains(content, "from "+marker) ||
+				strings.Contains(marker, "/") && strings.Contains(content, "/"+marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readHead returns up to limit bytes from the start of a file.
+func readHead(path string, limit int64) string {
+	file, err := utils.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(file, limit))
+	return string(data)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestClassifyProject(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"go service", map[string]string{
+			"go.mod":  "module svc\n",
+			"main.go": "package main\n\nimport \"net/http\"\n",
+		}, models.ProjectTypeService},
+		{"go cli", map[string]string{
+			"main.go": "package main\n\nimport \"flag\"\n",
+		}, models.ProjectTypeCLI},
+		{"go service behind a thin main", map[string]string{
+			"cmd/api/main.go":  "package main\n\nfunc main() { server.Run() }\n",
+			"server/server.go": "package server\n\nimport \"github.com/gofiber/fiber/v2\"\n",
+		}, models.ProjectTypeService},
+		{"go library", map[string]string{
+			"go.mod":  "module lib\n",
+			"lib.go":  "package lib\n",
+			"util.go": "package lib\n",
+		}, models.ProjectTypeLibrary},
+		{"react app", map[string]string{
+			"package.json": `{"dependencies": {"react": "^18.0.0"}}`,
+		}, models.ProjectTypeFrontend},
+		{"node cli", map[string]string{
+			"package.json": `{"bin": {"tool": "cli.js"}, "dependencies": {"express": "4"}}`,
+		}, models.ProjectTypeCLI},
+		{"express service", map[string]string{
+			"package.json": `{"dependencies": {"express": "4"}}`,
+		}, models.ProjectTypeService},
+		{"flask app", map[string]string{
+			"app.py": "from flask import Flask\n",
+		}, models.ProjectTypeService},
+		{"static site", map[string]string{
+			"public/index.html": "<html></html>",
+		}, models.ProjectTypeFrontend},
+		{"nothing to go on", map[string]string{
+			"README.md": "# hi\n",
+		}, models.ProjectTypeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			if got := ClassifyProject(dir); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
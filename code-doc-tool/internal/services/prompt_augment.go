@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// PromptAugmentations holds extra agent instructions for matching files.
+// Rules are keyed either by language name ("SQL") or by a glob over the
+// file's path relative to the analysis base; a glob without a slash is
+// matched against the file name alone ("*_test.go").
+type PromptAugmentations struct {
+	rules []augmentRule
+}
+
+type augmentRule struct {
+	language string
+	glob     string
+	text     string
+}
+
+// LoadPromptAugmentations reads a JSON object mapping languages or globs
+// to instructions.
+func LoadPromptAugmentations(file string) (*PromptAugmentations, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid prompt augmentations: %w", err)
+	}
+
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	a := &PromptAugmentations{}
+	for _, key := range keys {
+		text := strings.TrimSpace(raw[key])
+		if text == "" {
+			continue
+		}
+		if lang, ok := NormalizeLanguage(key); ok {
+			a.rules = append(a.rules, augmentRule{language: lang, text: text})
+			continue
+		}
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("invalid prompt augmentation pattern %q: %w", key, err)
+		}
+		a.rules = append(a.rules, augmentRule{glob: key, text: text})
+	}
+	return a, nil
+}
+
+// For returns the instructions for a file, in rule order. rel is the
+// slash separated path relative to the analysis base.
+func (a *PromptAugmentations) For(rel, language string) []string {
+	if a == nil {
+		return nil
+	}
+	var extra []string
+	for _, rule := range a.rules {
+		if rule.language != "" {
+			if rule.language == language {
+				extra = append(extra, rule.text)
+			}
+			continue
+		}
+		target := rel
+		if !strings.Contains(rule.glob, "/") {
+			target = path.Base(rel)
+		}
+		if ok, _ := path.Match(rule.glob, target); ok {
+			extra = append(extra, rule.text)
+		}
+	}
+	return extra
+}
+
+// Key identifies the rules, for caches of documentation produced with them.
+func (a *PromptAugmentations) Key() string {
+	if a == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, rule := range a.rules {
+		if rule.language != "" {
+			fmt.Fprintf(&b, "language:%s\x00%s\x00", rule.language, rule.text)
+		} else {
+			fmt.Fprintf(&b, "glob:%s\x00%s\x00", rule.glob, rule.text)
+		}
+	}
+	return b.String()
+}
+
+// AugmentTemplate appends extra instructions to a format template.
+func AugmentTemplate(formatTemplate string, extra []string) string {
+	if len(extra) == 0 {
+		return formatTemplate
+	}
+	var b strings.Builder
+	b.WriteString(formatTemplate)
+	b.WriteString("\nAdditional instructions:\n")
+	for _, text := range extra {
+		fmt.Fprintf(&b, "- %s\n", text)
+	}
+	return b.String()
+}
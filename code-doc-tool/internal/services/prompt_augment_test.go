@@ -0,0 +1,79 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadAugmentations(t *testing.T, content string) *PromptAugmentations {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "augment.json")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := LoadPromptAugmentations(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestPromptAugmentations(t *testing.T) {
+	a := loadAugmentations(t, `{
+		"sql": "Explain every table.",
+		"*_test.go": "Summarize the behavior under test.",
+		"config/*.yaml": "Explain each setting.",
+		"Go": "   "
+	}`)
+
+	tests := []struct {
+		rel, language string
+		want          []string
+	}{
+		{"db/schema.sql", "SQL", []string{"Explain every table."}},
+		{"pkg/api_test.go", "Go", []string{"Summarize the behavior under test."}},
+		{"config/app.yaml", "YAML", []string{"Explain each setting."}},
+		{"deploy/config/app.yaml", "YAML", nil},
+		{"main.go", "Go", nil},
+	}
+	for _, tt := range tests {
+		if got := a.For(tt.rel, tt.language); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("For(%q, %q) = %q, want %q", tt.rel, tt.language, got, tt.want)
+		}
+	}
+
+	if got := AugmentTemplate("tpl", a.For("db/schema.sql", "SQL")); got != "tpl\nAdditional instructions:\n- Explain every table.\n" {
+		t.Errorf("AugmentTemplate: %q", got)
+	}
+	if got := AugmentTemplate("tpl", nil); got != "tpl" {
+		t.Errorf("AugmentTemplate without instructions: %q", got)
+	}
+}
+
+func TestPromptAugmentationsKey(t *testing.T) {
+	a := loadAugmentations(t, `{"sql": "Explain every table."}`)
+	b := loadAugmentations(t, `{"sql": "Explain every column."}`)
+	if a.Key() == b.Key() {
+		t.Error("different instructions share a key")
+	}
+	if a.Key() != loadAugmentations(t, `{"SQL": "Explain every table."}`).Key() {
+		t.Error("the same rules have different keys")
+	}
+	var none *PromptAugmentations
+	if none.Key() != "" || none.For("a.sql", "SQL") != nil {
+		t.Error("nil augmentations aren't empty")
+	}
+}
+
+func TestLoadPromptAugmentationsInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "augment.json")
+	for _, content := range []string{`["sql"]`, `{"[": "bad glob"}`} {
+		os.WriteFile(file, []byte(content), 0644)
+		if _, err := LoadPromptAugmentations(file); err == nil || !strings.Contains(err.Error(), "prompt augmentation") {
+			t.Errorf("%s: got %v", content, err)
+		}
+	}
+}
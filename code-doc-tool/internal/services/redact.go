@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"regexp"
+)
+
+// secretPattern matches one kind of secret. Replace is expanded like
+// regexp.ReplaceAllString, so a pattern can keep the surrounding syntax
+// and swap only the value.
+type secretPattern struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+var secretPatterns = []secretPattern{
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), "[REDACTED:private-key]"},
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), "[REDACTED:aws-access-key]"},
+	{regexp.MustCompile(`(?i)(aws_?secret_?access_?key\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}`), "${1}[REDACTED:aws-secret-key]"},
+	{regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`), "[REDACTED:github-token]"},
+	{regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`), "[REDACTED:slack-token]"},
+	{regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`), "[REDACTED:google-api-key]"},
+	{regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`), "[REDACTED:stripe-key]"},
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b`), "[REDACTED:jwt]"},
+	// Quoted values assigned to credential-looking names
+	{regexp.MustCompile(`(?i)((?:password|passwd|secret|api_?key|access_?token|auth_?token|client_?secret)["']?\s*[:=]\s*["'])[^"'\s]{8,}(["'])`), "${1}[REDACTED:secret]${2}"},
+}
+
+// RedactSecrets replaces credentials in content with placeholders and
+// returns the redacted content with the number of replacements.
+func RedactSecrets(content []byte) ([]byte, int) {
+	count := 0
+	for _, p := range secretPatterns {
+		n := len(p.re.FindAllIndex(content, -1))
+		if n == 0 {
+			continue
+		}
+		count += n
+		content = p.re.ReplaceAll(content, []byte(p.replace))
+	}
+	return content, count
+}
+
+type redactionKey struct{}
+
+// WithRedactionReporter attaches a callback to ctx that agent calls use to
+// report secrets redacted from path.
+func WithRedactionReporter(ctx context.Context, report func(path string, count int)) context.Context {
+	return context.WithValue(ctx, redactionKey{}, report)
+}
+
+func reportRedactions(ctx context.Context, path string, count int) {
+	if report, ok := ctx.Value(redactionKey{}).(func(string, int)); ok && count > 0 {
+		report(path, count)
+	}
+}
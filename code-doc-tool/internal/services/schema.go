@@ -0,0 +1,441 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"code-doc-tool/internal/models"
+)
+
+// migrationDirs name the directories whose files change a database
+// schema, as Rails, Django, Alembic, Knex and most migration tools lay
+// them out.
+var migrationDirs = map[string]bool{
+	"migrations": true,
+	"migration":  true,
+	"migrate":    true,
+	"alembic":    true,
+}
+
+// SchemaInstruction asks the agent to document a file as part of the
+// project's data model.
+const SchemaInstruction = "This file defines or changes the project's data model. Focus on the schema: " +
+	"the tables or models, their columns and types, keys, relationships and indexes, " +
+	"and for a migration what it changes."
+
+func IsSchemaFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".sql")
+}
+
+// IsMigrationPath reports whether the slash separated path rel sits in a
+// migrations directory.
+func IsMigrationPath(rel string) bool {
+	for _, dir := range strings.Split(path.Dir(rel), "/") {
+		if migrationDirs[strings.ToLower(dir)] {
+			return true
+		}
+	}
+	return false
+}
+
+// FindSchemaFiles lists the SQL files under root: schema files first,
+// then migrations, each in path order, which for numbered or dated
+// migrations is the order they apply in.
+func FindSchemaFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && skippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && IsSchemaFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool {
+		mi, mj := IsMigrationPath(filepath.ToSlash(files[i])), IsMigrationPath(filepath.ToSlash(files[j]))
+		if mi != mj {
+			return mj
+		}
+		return files[i] < files[j]
+	})
+	return files, err
+}
+
+// sqlObjectName matches a possibly qualified name whose parts may be
+// quoted with double quotes, backticks or brackets, which lets them
+// hold spaces.
+const (
+	sqlNamePart   = `(?:"[^"]*"|` + "`[^`]*`" + `|\[[^\]]*\]|[^\s(."` + "`" + `\[]+)`
+	sqlObjectName = sqlNamePart + `(?:\.` + sqlNamePart + `)*`
+)
+
+var (
+	createTable = regexp.MustCompile(`(?is)^create\s+(?:or\s+replace\s+)?(?:(?:global|local)\s+)?(?:(?:temporary|temp|unlogged)\s+)?table\s+(?:if\s+not\s+exists\s+)?(` + sqlObjectName + `)\s*\(`)
+	alterTable  = regexp.MustCompile(`(?is)^alter\s+table\s+(?:if\s+exists\s+)?(?:only\s+)?(` + sqlObjectName + `)\s+(.*)$`)
+	dropTable   = regexp.MustCompile(`(?is)^drop\s+table\s+(?:if\s+exists\s+)?(.*?)(?:\s+(?:cascade|restrict))?$`)
+	createIndex = regexp.MustCompile(`(?is)^create\s+(unique\s+)?index\s+(?:concurrently\s+)?(?:if\s+not\s+exists\s+)?(?:(` + sqlObjectName + `)\s+)?on\s+(?:only\s+)?(` + sqlObjectName + `)\s*(?:using\s+\w+\s*)?\(`)
+
+	// Statements describing the data model beyond tables, left to the agent
+	createOther = regexp.MustCompile(`(?is)^create\s+(?:or\s+replace\s+)?(?:materialized\s+)?(?:view|type|function|procedure|trigger|domain|sequence)\b`)
+
+	addColumn    = regexp.MustCompile(`(?is)^add\s+(?:column\s+)?(?:if\s+not\s+exists\s+)?(.*)$`)
+	dropColumn   = regexp.MustCompile(`(?is)^drop\s+(?:column\s+)?(?:if\s+exists\s+)?(` + sqlObjectName + `)`)
+	renameColumn = regexp.MustCompile(`(?is)^rename\s+(?:column\s+)?(` + sqlObjectName + `)\s+to\s+(` + sqlObjectName + `)$`)
+	renameTable  = regexp.MustCompile(`(?is)^rename\s+to\s+(` + sqlObjectName + `)$`)
+
+	tableConstraint = regexp.MustCompile(`(?is)^(?:constraint\s|primary\s+key|foreign\s+key|unique\s*\(|unique\s+(?:key|index)\b|check\s*\(|exclude\s|(?:key|index|fulltext|spatial)\s+(?:\S+\s*)?\()`)
+	// The first column constraint ends a column's type
+	columnConstraint = regexp.MustCompile(`(?i)\s(?:not\s+null|null|primary\s+key|references|default|unique|check|constraint|auto_increment|autoincrement|generated|collate|identity|comment|on\s+update)\b`)
+	// Constraints that aren't statements of their own in ALTER TABLE ... DROP
+	dropNonColumn = regexp.MustCompile(`(?i)^drop\s+(?:constraint|index|key|primary|foreign|check)\b`)
+
+	dollarQuote = regexp.MustCompile(`^\$\w*\$`)
+	sqlSpaces   = regexp.MustCompile(`\s+`)
+)
+
+// schema replays DDL statements into the tables they leave behind.
+type schema struct {
+	tables []*models.SchemaTable
+}
+
+func (s *schema) find(name string) *models.SchemaTable {
+	for _, t := range s.tables {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *schema) drop(name string) {
+	for i, t := range s.tables {
+		if strings.EqualFold(t.Name, name) {
+			s.tables = append(s.tables[:i], s.tables[i+1:]...)
+			return
+		}
+	}
+}
+
+// apply replays one statement from source. It reports whether the
+// statement describes the data model in a way tables don't capture.
+func (s *schema) apply(source, stmt string) bool {
+	if m := createTable.FindStringSubmatchIndex(stmt); m != nil {
+		body, ok := parenBody(stmt[m[1]-1:])
+		if !ok {
+			return false
+		}
+		table := &models.SchemaTable{Name: sqlName(stmt[m[2]:m[3]]), Source: source}
+		for _, item := range splitTopLevel(body) {
+			addTableItem(table, item)
+		}
+		s.drop(table.Name)
+		s.tables = append(s.tables, table)
+		return false
+	}
+	if m := createIndex.FindStringSubmatchIndex(stmt); m != nil {
+		table := s.find(sqlName(stmt[m[6]:m[7]]))
+		columns, ok := parenBody(stmt[m[1]-1:])
+		if table == nil || !ok {
+			return false
+		}
+		index := "INDEX"
+		if m[2] >= 0 {
+			index = "UNIQUE INDEX"
+		}
+		if m[4] >= 0 {
+			index += " " + sqlName(stmt[m[4]:m[5]])
+		}
+		table.Constraints = append(table.Constraints, index+" ("+collapse(columns)+")")
+		return false
+	}
+	if m := alterTable.FindStringSubmatch(stmt); m != nil {
+		table := s.find(sqlName(m[1]))
+		if table == nil {
+			return false
+		}
+		for _, action := range splitTopLevel(m[2]) {
+			s.alter(table, action)
+		}
+		return false
+	}
+	if m := dropTable.FindStringSubmatch(stmt); m != nil {
+		for _, name := range strings.Split(m[1], ",") {
+			s.drop(sqlName(name))
+		}
+		return false
+	}
+	return createOther.MatchString(stmt)
+}
+
+// alter applies one action of an ALTER TABLE statement.
+func (s *schema) alter(table *models.SchemaTable, action string) {
+	action = collapse(action)
+	switch {
+	case addColumn.MatchString(action):
+		addTableItem(table, addColumn.FindStringSubmatch(action)[1])
+	case dropNonColumn.MatchString(action):
+	case dropColumn.MatchString(action):
+		name := sqlName(dropColumn.FindStringSubmatch(action)[1])
+		for i, c := range table.Columns {
+			if strings.EqualFold(c.Name, name) {
+				table.Columns = append(table.Columns[:i], table.Columns[i+1:]...)
+				break
+			}
+		}
+	case renameTable.MatchString(action):
+		table.Name = sqlName(renameTable.FindStringSubmatch(action)[1])
+	case renameColumn.MatchString(action):
+		m := renameColumn.FindStringSubmatch(action)
+		for i, c := range table.Columns {
+			if strings.EqualFold(c.Name, sqlName(m[1])) {
+				table.Columns[i].Name = sqlName(m[2])
+			}
+		}
+	}
+}
+
+// addTableItem adds a column definition or table constraint.
+func addTableItem(table *models.SchemaTable, item string) {
+	item = collapse(item)
+	if item == "" {
+		return
+	}
+	if tableConstraint.MatchString(item) {
+		table.Constraints = append(table.Constraints, item)
+		return
+	}
+	name, rest := sqlIdentifier(item)
+	column := models.SchemaColumn{Name: sqlName(name), Type: rest}
+	if loc := columnConstraint.FindStringIndex(" " + rest); loc != nil {
+		column.Type = strings.TrimSpace(rest[:loc[0]])
+		column.Constraints = strings.TrimSpace(rest[loc[0]:])
+	}
+	// A migration replayed over a schema dump may add a column again
+	for i, c := range table.Columns {
+		if strings.EqualFold(c.Name, column.Name) {
+			table.Columns[i] = column
+			return
+		}
+	}
+	table.Columns = append(table.Columns, column)
+}
+
+// ReadSchema replays files, in order, into the tables they leave behind.
+// Statements it doesn't follow are ignored, except that files declaring
+// views, functions, triggers and the like are returned for the agent to
+// document.
+func ReadSchema(root string, files []string) ([]models.SchemaTable, []string) {
+	s := &schema{}
+	var rest []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			rel = filepath.Base(file)
+		}
+		other := false
+		for _, stmt := range splitSQLStatements(string(data)) {
+			if s.apply(filepath.ToSlash(rel), stmt) {
+				other = true
+			}
+		}
+		if other {
+			rest = append(rest, file)
+		}
+	}
+
+	tables := make([]models.SchemaTable, len(s.tables))
+	for i, t := range s.tables {
+		tables[i] = *t
+	}
+	return tables, rest
+}
+
+// RenderSchema renders the Data Model / Schema section.
+func RenderSchema(tables []models.SchemaTable) string {
+	cell := func(s string) string {
+		return strings.ReplaceAll(s, "|", "\\|")
+	}
+	var b strings.Builder
+	b.WriteString("## Data Model / Schema\n\n")
+	b.WriteString("| Table | Columns | Declared in |\n")
+	b.WriteString("| --- | --- | --- |\n")
+	for _, t := range tables {
+		fmt.Fprintf(&b, "| %s | %d | %s |\n", cell(t.Name), len(t.Columns), cell(t.Source))
+	}
+
+	for _, t := range tables {
+		fmt.Fprintf(&b, "\n### %s\n\n", t.Name)
+		if len(t.Columns) > 0 {
+			b.WriteString("| Column | Type | Constraints |\n")
+			b.WriteString("| --- | --- | --- |\n")
+			for _, c := range t.Columns {
+				fmt.Fprintf(&b, "| %s | %s | %s |\n", cell(c.Name), cell(c.Type), cell(c.Constraints))
+			}
+		}
+		if len(t.Constraints) > 0 {
+			if len(t.Columns) > 0 {
+				b.WriteString("\n")
+			}
+			for _, c := range t.Constraints {
+				fmt.Fprintf(&b, "- `%s`\n", c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// splitSQLStatements splits SQL on semicolons outside quotes, comments and
+// dollar quoted bodies, dropping the comments.
+func splitSQLStatements(sql string) []string {
+	var stmts []string
+	var b strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(b.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		b.Reset()
+	}
+	for i := 0; i < len(sql); {
+		rest := sql[i:]
+		switch {
+		case strings.HasPrefix(rest, "--") || rest[0] == '#':
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			b.WriteByte(' ')
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 4
+			}
+			b.WriteByte(' ')
+			i += end
+		case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+			end := closingQuote(rest)
+			b.WriteString(rest[:end])
+			i += end
+		case dollarQuote.MatchString(rest):
+			tag := dollarQuote.FindString(rest)
+			end := strings.Index(rest[len(tag):], tag)
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 2 * len(tag)
+			}
+			b.WriteString(rest[:end])
+			i += end
+		case rest[0] == ';':
+			flush()
+			i++
+		default:
+			b.WriteByte(rest[0])
+			i++
+		}
+	}
+	flush()
+	return stmts
+}
+
+// closingQuote returns the length of the quoted string s starts with; a
+// doubled quote stands for itself.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		if s[i] != q {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == q {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(s)
+}
+
+// parenBody returns what lies between the parenthesis s starts with and
+// the one closing it.
+func parenBody(s string) (string, bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i += closingQuote(s[i:]) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[1:i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// splitTopLevel splits s on commas outside parentheses and quotes.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i += closingQuote(s[i:]) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// sqlIdentifier splits the identifier s starts with, quoted or not, from
+// the rest.
+func sqlIdentifier(s string) (string, string) {
+	end := strings.IndexAny(s, " \t\n")
+	switch s[0] {
+	case '"', '`':
+		end = closingQuote(s)
+	case '[':
+		end = strings.IndexByte(s, ']') + 1
+	}
+	if end <= 0 || end > len(s) {
+		return s, ""
+	}
+	return s[:end], strings.TrimSpace(s[end:])
+}
+
+// sqlName strips the quoting from a possibly qualified name.
+func sqlName(name string) string {
+	return strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "").Replace(strings.TrimSpace(name))
+}
+
+func collapse(s string) string {
+	return strings.TrimSpace(sqlSpaces.ReplaceAllString(s, " "))
+}
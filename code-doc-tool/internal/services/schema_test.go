@@ -0,0 +1,176 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	sql := `-- users table; not a statement
+CREATE TABLE users (
+  id int, -- the key; generated
+  name text /* shown; in the UI */
+); /* block; comment */
+INSERT INTO t VALUES ('a;b', "c;d", ` + "`e;f`" + `, 'it''s;');
+# MySQL comment;
+CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+  NEW.note := 'done; $$ inside';
+  RETURN NEW;
+END
+$body$ LANGUAGE plpgsql;
+DO $$ BEGIN PERFORM 1; END $$;
+SELECT 1`
+
+	var got []string
+	for _, stmt := range splitSQLStatements(sql) {
+		got = append(got, collapse(stmt))
+	}
+	want := []string{
+		"CREATE TABLE users ( id int, name text )",
+		"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`, 'it''s;')",
+		"CREATE FUNCTION touch() RETURNS trigger AS $body$ BEGIN NEW.note := 'done; $$ inside'; RETURN NEW; END $body$ LANGUAGE plpgsql",
+		"DO $$ BEGIN PERFORM 1; END $$",
+		"SELECT 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statements:\n%q\nwant:\n%q", got, want)
+	}
+
+	// Unterminated comments and bodies run to the end
+	for sql, want := range map[string][]string{
+		"SELECT 1; /* open; comment":   {"SELECT 1"},
+		"SELECT 1; DO $$ BEGIN; x":     {"SELECT 1", "DO $$ BEGIN; x"},
+		"SELECT 'open; quote":          {"SELECT 'open; quote"},
+		";; -- nothing but comments\n": nil,
+	} {
+		if got := splitSQLStatements(sql); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitSQLStatements(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+// schemaProject is a schema dump followed by migrations that change it.
+var schemaProject = map[string]string{
+	"db/schema.sql": `CREATE TABLE IF NOT EXISTS "users" (
+  "id" SERIAL PRIMARY KEY,
+  email VARCHAR(255) NOT NULL UNIQUE,
+  name text, -- display name
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  CONSTRAINT email_check CHECK (email LIKE '%@%')
+);
+CREATE TABLE [dbo].[Order Items] (
+  [Id] INT NOT NULL,
+  [Unit Price] DECIMAL(10, 2) NULL,
+  [UserId] INT REFERENCES users(id),
+  PRIMARY KEY ([Id])
+);
+CREATE UNIQUE INDEX users_email ON users (lower(email));
+CREATE TABLE ` + "`legacy` (`id` int AUTO_INCREMENT, KEY idx (`id`)) ENGINE=InnoDB;\n",
+	"db/migrations/001_alter.sql": `ALTER TABLE users ADD COLUMN age integer, DROP COLUMN name, RENAME COLUMN email TO email_address;
+ALTER TABLE users ADD CONSTRAINT users_org FOREIGN KEY (org_id) REFERENCES orgs(id);
+ALTER TABLE [dbo].[Order Items] ADD [Note] NVARCHAR(200);
+DROP TABLE IF EXISTS legacy CASCADE;
+CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+  NEW.updated := now(); -- not a statement; of its own
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+`,
+	"db/migrations/002_rename.sql": "ALTER TABLE users RENAME TO accounts;\n",
+	"node_modules/dep/init.sql":    "CREATE TABLE dep (id int);\n",
+}
+
+func TestReadSchema(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, schemaProject)
+
+	files, err := FindSchemaFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relPaths(t, root, files); !reflect.DeepEqual(got, []string{"db/schema.sql", "db/migrations/001_alter.sql", "db/migrations/002_rename.sql"}) {
+		t.Fatalf("schema files %v, want the schema, then the migrations in order", got)
+	}
+
+	tables, rest := ReadSchema(root, files)
+	want := []models.SchemaTable{
+		{
+			Name: "accounts",
+			Columns: []models.SchemaColumn{
+				{Name: "id", Type: "SERIAL", Constraints: "PRIMARY KEY"},
+				{Name: "email_address", Type: "VARCHAR(255)", Constraints: "NOT NULL UNIQUE"},
+				{Name: "created_at", Type: "TIMESTAMP WITH TIME ZONE", Constraints: "DEFAULT now()"},
+				{Name: "age", Type: "integer"},
+			},
+			Constraints: []string{
+				"CONSTRAINT email_check CHECK (email LIKE '%@%')",
+				"UNIQUE INDEX users_email (lower(email))",
+				"CONSTRAINT users_org FOREIGN KEY (org_id) REFERENCES orgs(id)",
+			},
+			Source: "db/schema.sql",
+		},
+		{
+			Name: "dbo.Order Items",
+			Columns: []models.SchemaColumn{
+				{Name: "Id", Type: "INT", Constraints: "NOT NULL"},
+				{Name: "Unit Price", Type: "DECIMAL(10, 2)", Constraints: "NULL"},
+				{Name: "UserId", Type: "INT", Constraints: "REFERENCES users(id)"},
+				{Name: "Note", Type: "NVARCHAR(200)"},
+			},
+			Constraints: []string{"PRIMARY KEY ([Id])"},
+			Source:      "db/schema.sql",
+		},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("tables:\n%+v\nwant:\n%+v", tables, want)
+	}
+	// Only the migration declaring a function needs the agent
+	if got := relPaths(t, root, rest); !reflect.DeepEqual(got, []string{"db/migrations/001_alter.sql"}) {
+		t.Errorf("files left for the agent %v", got)
+	}
+}
+
+func TestRenderSchema(t *testing.T) {
+	section := RenderSchema([]models.SchemaTable{
+		{
+			Name:        "users",
+			Columns:     []models.SchemaColumn{{Name: "id", Type: "int", Constraints: "PRIMARY KEY"}, {Name: "flags", Type: "text", Constraints: "CHECK (flags <> 'a|b')"}},
+			Constraints: []string{"UNIQUE INDEX users_email (email)"},
+			Source:      "schema.sql",
+		},
+		{Name: "empty", Source: "migrations/001.sql"},
+	})
+	for _, want := range []string{
+		"## Data Model / Schema\n\n| Table | Columns | Declared in |\n| --- | --- | --- |\n| users | 2 | schema.sql |\n| empty | 0 | migrations/001.sql |\n",
+		"### users\n\n| Column | Type | Constraints |\n| --- | --- | --- |\n| id | int | PRIMARY KEY |\n| flags | text | CHECK (flags <> 'a\\|b') |\n\n- `UNIQUE INDEX users_email (email)`\n",
+		"### empty\n\n",
+	} {
+		if !strings.Contains(section, want) {
+			t.Errorf("section is missing %q:\n%s", want, section)
+		}
+	}
+}
+
+func TestIsMigrationPath(t *testing.T) {
+	for rel, want := range map[string]bool{
+		"db/migrations/001.sql":       true,
+		"alembic/versions/abc.py":     true,
+		"Migrate/20240101_users.rb":   true,
+		"db/schema.sql":               false,
+		"migrations.sql":              false,
+		"src/migrationhelpers/run.go": false,
+	} {
+		if got := IsMigrationPath(rel); got != want {
+			t.Errorf("IsMigrationPath(%q) = %v, want %v", rel, got, want)
+		}
+	}
+	if !IsSchemaFile(filepath.Join("db", "SCHEMA.SQL")) || IsSchemaFile("schema.sqlite") {
+		t.Error("IsSchemaFile doesn't go by the .sql extension")
+	}
+}
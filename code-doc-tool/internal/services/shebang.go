@@ -0,0 +1,66 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"strings"
+
+	"code-doc-tool/internal/utils"
+)
+
+// shebangReadLimit bounds how much of an extensionless file is read to
+// find its interpreter.
+const shebangReadLimit = 256
+
+// languageByInterpreter maps interpreter names, versions stripped, to
+// languages.
+var languageByInterpreter = map[string]string{
+	"python":  "Python",
+	"node":    "JavaScript",
+	"nodejs":  "JavaScript",
+	"ts-node": "TypeScript",
+	"deno":    "TypeScript",
+	"php":     "PHP",
+	"ruby":    "Ruby",
+	"sh":      "Shell",
+	"bash":    "Shell",
+	"zsh":     "Shell",
+	"dash":    "Shell",
+	"ksh":     "Shell",
+}
+
+// ShebangLanguage reads the "#!" line of the named file, e.g.
+// "#!/usr/bin/env python3", and returns the language of its interpreter.
+func ShebangLanguage(name string) (string, bool) {
+	file, err := utils.Open(name)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	buf := make([]byte, shebangReadLimit)
+	n, _ := io.ReadFull(file, buf)
+	line, _, _ := bytes.Cut(buf[:n], []byte("\n"))
+	if !bytes.HasPrefix(line, []byte("#!")) {
+		return "", false
+	}
+
+	fields := strings.Fields(string(line[2:]))
+	if len(fields) == 0 {
+		return "", false
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env's own flags, e.g. "env -S node --flag"
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = field
+				break
+			}
+		}
+	}
+	lang, ok := languageByInterpreter[strings.TrimRight(interpreter, "0123456789.")]
+	return lang, ok
+}
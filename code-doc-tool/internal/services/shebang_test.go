@@ -0,0 +1,52 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShebangLanguage(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content, want string
+	}{
+		{"#!/usr/bin/env python3\nprint(1)\n", "Python"},
+		{"#!/usr/bin/python3.11\n", "Python"},
+		{"#! /bin/bash -e\n", "Shell"},
+		{"#!/usr/bin/env -S node --no-warnings\n", "JavaScript"},
+		{"#!/usr/bin/env FOO=1 ruby\n", "Ruby"},
+		{"#!/usr/bin/perl\n", ""},
+		{"#!\n", ""},
+		{"print(1)\n", ""},
+		{"", ""},
+		// The interpreter line must start within the read limit
+		{strings.Repeat("\n", shebangReadLimit) + "#!/usr/bin/env python3\n", ""},
+	}
+	for i, tt := range tests {
+		name := filepath.Join(dir, "script"+string(rune('a'+i)))
+		writeFiles(t, dir, map[string]string{filepath.Base(name): tt.content})
+		lang, ok := ShebangLanguage(name)
+		if lang != tt.want || ok != (tt.want != "") {
+			t.Errorf("ShebangLanguage(%q) = %q, %v, want %q", tt.content, lang, ok, tt.want)
+		}
+	}
+	if _, ok := ShebangLanguage(filepath.Join(dir, "missing")); ok {
+		t.Error("ShebangLanguage found a language for a missing file")
+	}
+}
+
+func TestDetectLanguageShebang(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"bin/deploy":   "#!/usr/bin/env python3\n",
+		"bin/tool.txt": "#!/usr/bin/env python3\n",
+	})
+	if got := DetectLanguage(filepath.Join(dir, "bin", "deploy")); got != "Python" {
+		t.Errorf("extensionless script detected as %s, want Python", got)
+	}
+	// An extension wins over the shebang
+	if got := DetectLanguage(filepath.Join(dir, "bin", "tool.txt")); got != "Other" {
+		t.Errorf("tool.txt detected as %s, want Other", got)
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"code-doc-tool/internal/utils"
+)
+
+// fenceTags maps detected languages to markdown code fence tags where the
+// lowercase name isn't what highlighters expect.
+var fenceTags = map[string]string{
+	"C++":   "cpp",
+	"C#":    "csharp",
+	"Other": "",
+}
+
+func fenceTag(language string) string {
+	if tag, ok := fenceTags[language]; ok {
+		return tag
+	}
+	return strings.ToLower(language)
+}
+
+// SourceSnippet renders the first maxLines lines of a file as a fenced code
+// block tagged with language. A maxLines of 0 or less means no limit.
+func SourceSnippet(path, language string, maxLines int) (string, error) {
+	file, err := utils.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var b strings.Builder
+	b.WriteString("```" + fenceTag(language) + "\n")
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lines, truncated := 0, false
+	for scanner.Scan() {
+		if maxLines > 0 && lines == maxLines {
+			truncated = true
+			break
+		}
+		b.WriteString(strings.TrimRight(scanner.Text(), "\r") + "\n")
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	b.WriteString("```\n")
+
+	if truncated {
+		fmt.Fprintf(&b, "\n_Source truncated to the first %d lines._\n", maxLines)
+	}
+	return b.String(), nil
+}
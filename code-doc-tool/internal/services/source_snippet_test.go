@@ -0,0 +1,38 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSourceSnippet(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.cpp": "int a;\r\nint b;\r\nint c;\r\n",
+		"short.go": "package x",
+	})
+
+	snippet, err := SourceSnippet(filepath.Join(dir, "main.cpp"), "C++", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "```cpp\nint a;\nint b;\n```\n\n_Source truncated to the first 2 lines._\n"
+	if snippet != want {
+		t.Errorf("got %q, want %q", snippet, want)
+	}
+
+	snippet, _ = SourceSnippet(filepath.Join(dir, "main.cpp"), "C++", 3)
+	if strings.Contains(snippet, "truncated") {
+		t.Errorf("a file of exactly maxLines was truncated: %q", snippet)
+	}
+
+	snippet, _ = SourceSnippet(filepath.Join(dir, "short.go"), "Go", 0)
+	if snippet != "```go\npackage x\n```\n" {
+		t.Errorf("got %q", snippet)
+	}
+
+	if _, err := SourceSnippet(filepath.Join(dir, "missing.go"), "Go", 5); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
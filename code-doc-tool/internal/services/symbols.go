@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"code-doc-tool/internal/models"
+	"code-doc-tool/internal/utils"
+)
+
+type symbolPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// symbolPatterns find top-level declarations line by line. They're
+// heuristics, good enough for an index rather than a full parse.
+var symbolPatterns = map[string][]symbolPattern{
+	"Go": {
+		{"method", regexp.MustCompile(`^func\s+\([^)]*\)\s*([A-Za-z_]\w*)`)},
+		{"function", regexp.MustCompile(`^func\s+([A-Za-z_]\w*)`)},
+		{"type", regexp.MustCompile(`^type\s+([A-Za-z_]\w*)\s`)},
+	},
+	"Python": {
+		{"function", regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`)},
+		{"class", regexp.MustCompile(`^\s*class\s+([A-Za-z_]\w*)`)},
+	},
+	"JavaScript": {
+		{"function", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`)},
+		{"class", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?class\s+([A-Za-z_$][\w$]*)`)},
+	},
+	"TypeScript": {
+		{"function", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`)},
+		{"class", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`)},
+		{"interface", regexp.MustCompile(`^\s*(?:export\s+)?interface\s+([A-Za-z_$][\w$]*)`)},
+	},
+	"PHP": {
+		{"function", regexp.MustCompile(`^\s*(?:(?:public|protected|private|static|abstract|final)\s+)*function\s+&?([A-Za-z_]\w*)`)},
+		{"class", regexp.MustCompile(`^\s*(?:abstract\s+|final\s+)?class\s+([A-Za-z_]\w*)`)},
+		{"interface", regexp.MustCompile(`^\s*interface\s+([A-Za-z_]\w*)`)},
+	},
+}
+
+// ExtractSymbols lists the functions, classes and types declared in path.
+// rel is recorded as the symbol's file. Unsupported languages yield none.
+func ExtractSymbols(path, rel, language string) ([]models.Symbol, error) {
+	patterns := symbolPatterns[language]
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	f, err := utils.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var symbols []models.Symbol
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		for _, p := range patterns {
+			if m := p.re.FindStringSubmatch(text); m != nil {
+				symbols = append(symbols, models.Symbol{
+					Name:     m[1],
+					Kind:     p.kind,
+					File:     rel,
+					Line:     line,
+					Exported: isExported(m[1], text, language),
+				})
+				break
+			}
+		}
+	}
+	return symbols, scanner.Err()
+}
+
+var (
+	jsExport   = regexp.MustCompile(`^\s*export\s`)
+	phpPrivate = regexp.MustCompile(`^\s*(?:\w+\s+)*(?:private|protected)\s`)
+)
+
+// isExported applies each language's visibility convention to a symbol
+// declared on line: capitalized names in Go, names without a leading
+// underscore in Python (dunder methods included), an export keyword in
+// JavaScript and TypeScript, and anything not private or protected in
+// PHP.
+func isExported(name, line, language string) bool {
+	switch language {
+	case "Go":
+		return name[0] >= 'A' && name[0] <= 'Z'
+	case "Python":
+		return !strings.HasPrefix(name, "_") || (strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__"))
+	case "JavaScript", "TypeScript":
+		return jsExport.MatchString(line)
+	case "PHP":
+		return !phpPrivate.MatchString(line)
+	}
+	return true
+}
+
+// PublicSymbols returns the exported symbols among symbols.
+func PublicSymbols(symbols []models.Symbol) []models.Symbol {
+	var public []models.Symbol
+	for _, s := range symbols {
+		if s.Exported {
+			public = append(public, s)
+		}
+	}
+	return public
+}
+
+// PrivateSymbolNames returns the distinct names of the unexported symbols
+// among symbols, in declaration order.
+func PrivateSymbolNames(symbols []models.Symbol) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, s := range symbols {
+		if !s.Exported && !seen[s.Name] {
+			seen[s.Name] = true
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// maxNamedPrivateSymbols bounds how many names PublicOnlyInstruction
+// spells out.
+const maxNamedPrivateSymbols = 30
+
+// PublicOnlyInstruction asks the agent to document the public API only,
+// naming the file's private symbols to leave out.
+func PublicOnlyInstruction(private []string) string {
+	instruction := "Document only the public API: exported or public functions, types, classes and methods. " +
+		"Leave out unexported, private and internal helpers."
+	if len(private) == 0 {
+		return instruction
+	}
+	if len(private) > maxNamedPrivateSymbols {
+		private = private[:maxNamedPrivateSymbols]
+	}
+	return instruction + " Do not document: " + strings.Join(private, ", ") + "."
+}
+
+var headingSymbol = regexp.MustCompile(`^(?:(?:func|def|function|class|type|interface|method)\s+)?(?:\([^)]*\)\s*)?([A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)*)`)
+
+// DropSymbolSections removes the sections of doc whose heading names one
+// of names, e.g. "### helper()" or "### `func (s *Server) helper`", along
+// with everything under them. Section headings of the document itself
+// ("#") are never removed.
+func DropSymbolSections(doc string, names []string) string {
+	if len(names) == 0 {
+		return doc
+	}
+	drop := map[string]bool{}
+	for _, name := range names {
+		drop[name] = true
+	}
+
+	lines := strings.Split(doc, "\n")
+	kept := lines[:0]
+	inCodeBlock := false
+	dropping := 0 // level of the heading being dropped, 0 when keeping
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCodeBlock = !inCodeBlock
+		} else if m := mdHeading.FindStringSubmatch(trimmed); m != nil && !inCodeBlock {
+			level := len(m[1])
+			if dropping > 0 && level <= dropping {
+				dropping = 0
+			}
+			if dropping == 0 && level > 1 {
+				if s := headingSymbol.FindStringSubmatch(strings.ReplaceAll(m[2], "`", "")); s != nil {
+					name := s[1][strings.LastIndex(s[1], ".")+1:]
+					if drop[name] {
+						dropping = level
+					}
+				}
+			}
+		}
+		if dropping == 0 {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// RenderSymbolIndex renders an alphabetical index of symbols with the file
+// and line each is declared at.
+func RenderSymbolIndex(symbols []models.Symbol) string {
+	sorted := append([]models.Symbol(nil), symbols...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := strings.ToLower(sorted[i].Name), strings.ToLower(sorted[j].Name)
+		if a != b {
+			return a < b
+		}
+		if sorted[i].File != sorted[j].File {
+			return sorted[i].File < sorted[j].File
+		}
+		return sorted[i].Line < sorted[j].Line
+	})
+
+	var b strings.Builder
+	b.WriteString("## Index\n\n")
+	b.WriteString("| Symbol | Kind | File | Line |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, s := range sorted {
+		fmt.Fprintf(&b, "| %s | %s | %s | %d |\n", s.Name, s.Kind, s.File, s.Line)
+	}
+	return b.String()
+}
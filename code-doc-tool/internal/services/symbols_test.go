@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code-doc-tool/internal/models"
+)
+
+func TestExtractSymbols(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"server.go": "package api\n\ntype Server struct{}\n\nfunc (s *Server) Start() {}\n\nfunc helper() {}\n",
+		"tool.py":   "class Tool:\n    def run(self):\n        pass\n\ndef _private():\n    pass\n",
+		"app.js":    "export function render() {}\nclass Widget {}\n",
+	})
+
+	tests := []struct {
+		file, language string
+		want           string
+	}{
+		{"server.go", "Go", "[{Server type 3 true} {Start method 5 true} {helper function 7 false}]"},
+		{"tool.py", "Python", "[{Tool class 1 true} {run function 2 true} {_private function 5 false}]"},
+		{"app.js", "JavaScript", "[{render function 1 true} {Widget class 2 false}]"},
+		{"app.js", "Rust", "[]"},
+	}
+	for _, tt := range tests {
+		symbols, err := ExtractSymbols(filepath.Join(dir, tt.file), tt.file, tt.language)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range symbols {
+			if s.File != tt.file {
+				t.Errorf("%s: symbol %s recorded in %s", tt.file, s.Name, s.File)
+			}
+			got = append(got, fmt.Sprintf("{%s %s %d %t}", s.Name, s.Kind, s.Line, s.Exported))
+		}
+		if g := "[" + strings.Join(got, " ") + "]"; g != tt.want {
+			t.Errorf("%s as %s: got %s, want %s", tt.file, tt.language, g, tt.want)
+		}
+	}
+}
+
+func TestRenderSymbolIndex(t *testing.T) {
+	index := RenderSymbolIndex([]models.Symbol{
+		{Name: "start", Kind: "function", File: "b.go", Line: 9},
+		{Name: "Render", Kind: "function", File: "a.js", Line: 1},
+		{Name: "Start", Kind: "method", File: "a.go", Line: 4},
+	})
+	want := "## Index\n\n" +
+		"| Symbol | Kind | File | Line |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| Render | function | a.js | 1 |\n" +
+		"| Start | method | a.go | 4 |\n" +
+		"| start | function | b.go | 9 |\n"
+	if index != want {
+		t.Errorf("got:\n%s\nwant:\n%s", index, want)
+	}
+}
+
+func TestIsExported(t *testing.T) {
+	tests := []struct {
+		name, line, language string
+		want                 bool
+	}{
+		{"Serve", "func Serve() {}", "Go", true},
+		{"serve", "func serve() {}", "Go", false},
+		{"__init__", "    def __init__(self):", "Python", true},
+		{"_load", "def _load():", "Python", false},
+		{"render", "export default function render() {}", "TypeScript", true},
+		{"render", "function render() {}", "JavaScript", false},
+		{"handle", "    public static function handle()", "PHP", true},
+		{"check", "    private function check()", "PHP", false},
+		{"load", "    protected static function load()", "PHP", false},
+	}
+	for _, tt := range tests {
+		if got := isExported(tt.name, tt.line, tt.language); got != tt.want {
+			t.Errorf("isExported(%q, %q, %s) = %v, want %v", tt.name, tt.line, tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestPublicOnlyInstruction(t *testing.T) {
+	if got := PublicOnlyInstruction(nil); strings.Contains(got, "Do not document") {
+		t.Errorf("instruction names symbols without any: %s", got)
+	}
+	var private []string
+	for i := range 40 {
+		private = append(private, fmt.Sprintf("helper%d", i))
+	}
+	got := PublicOnlyInstruction(private)
+	if !strings.Contains(got, "Do not document: helper0, helper1,") || !strings.HasSuffix(got, "helper29.") {
+		t.Errorf("instruction doesn't name the first 30 symbols: %s", got)
+	}
+}
+
+func TestDropSymbolSections(t *testing.T) {
+	doc := "# server.go\n\n## Functions\n\n" +
+		"### Serve()\nStarts serving.\n```sh\n### helper\n```\n\n" +
+		"### `func (s *Server) helper`\nInternal.\n#### Parameters\nNone.\n\n" +
+		"### server.load\nLoads.\n\n" +
+		"## Notes\nDone.\n"
+	got := DropSymbolSections(doc, []string{"helper", "load"})
+	want := "# server.go\n\n## Functions\n\n" +
+		"### Serve()\nStarts serving.\n```sh\n### helper\n```\n\n" +
+		"## Notes\nDone.\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The document's own title is kept even when it names a symbol
+	if got := DropSymbolSections("# helper\n\nText.\n", []string{"helper"}); got != "# helper\n\nText.\n" {
+		t.Errorf("title dropped: %q", got)
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"code-doc-tool/internal/utils"
+)
+
+type TextGenerator struct {
+	// LineEnding terminates every line of the output
+	LineEnding string
+}
+
+func NewTextGenerator() *TextGenerator {
+	return &TextGenerator{LineEnding: lineEnding}
+}
+
+func (g *TextGenerator) Extension() string { return "txt" }
+
+func (g *TextGenerator) ContentType() string { return "text/plain; charset=utf-8" }
+
+var (
+	mdLink     = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	mdStrong   = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	mdEmphasis = regexp.MustCompile(`(^|[\s(])[*_]([^*_\s][^*_]*)[*_]([\s).,:;!?]|$)`)
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdBullet   = regexp.MustCompile(`^[-*+]\s+`)
+)
+
+// GenerateDocumentation writes docText as plain text with markdown removed.
+func (g *TextGenerator) GenerateDocumentation(docText string, outputPath string) error {
+	text := NormalizeText(RenderPlainText(docText), g.LineEnding)
+	if err := os.WriteFile(outputPath, []byte(text), 0644); err != nil {
+		if utils.IsDiskFull(err) {
+			os.Remove(outputPath)
+			return fmt.Errorf("failed to save text: %w", utils.WrapDiskFull(err))
+		}
+		return fmt.Errorf("failed to save text: %w", err)
+	}
+	return nil
+}
+
+// RenderPlainText converts markdown into readable plain text.
+func RenderPlainText(docText string) string {
+	var b strings.Builder
+	inCodeBlock := false
+
+	for _, line := range strings.Split(docText, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			// Code keeps its own whitespace, indented as a block
+			b.WriteString("    " + strings.TrimRight(line, "\r") + "\n")
+			continue
+		}
+
+		if m := mdHeading.FindStringSubmatch(trimmed); m != nil {
+			title := stripInline(m[2])
+			if len(m[1]) == 1 {
+				title = strings.ToUpper(title)
+				b.WriteString(title + "\n" + strings.Repeat("=", len([]rune(title))) + "\n")
+			} else {
+				b.WriteString(title + "\n" + strings.Repeat("-", len([]rune(title))) + "\n")
+			}
+			continue
+		}
+
+		switch {
+		case trimmed == "---" || trimmed == "***":
+			b.WriteString(strings.Repeat("-", 40) + "\n")
+		case strings.HasPrefix(trimmed, "|"):
+			cells := parseTableRow(trimmed)
+			if isTableSeparator(cells) {
+				continue
+			}
+			for i, cell := range cells {
+				cells[i] = stripInline(cell)
+			}
+			b.WriteString(strings.Join(cells, "  |  ") + "\n")
+		case mdImage.MatchString(trimmed):
+			// Images can't be shown, so only their caption is kept
+			b.WriteString("[" + mdImage.FindStringSubmatch(trimmed)[1] + "]\n")
+		case mdBullet.MatchString(trimmed):
+			b.WriteString("- " + stripInline(mdBullet.ReplaceAllString(trimmed, "")) + "\n")
+		default:
+			b.WriteString(stripInline(trimmed) + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// stripInline removes inline markdown markers from a single line.
+func stripInline(s string) string {
+	s = mdLink.ReplaceAllString(s, "$1 ($2)")
+	s = mdStrong.ReplaceAllString(s, "$2")
+	s = mdEmphasis.ReplaceAllString(s, "$1$2$3")
+	return strings.ReplaceAll(s, "`", "")
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderPlainText(t *testing.T) {
+	doc := strings.Join([]string{
+		"# Title",
+		"## Usage",
+		"Call **Run** with a [config](https://example.com) and `ctx`.",
+		"- first *item*",
+		"* second",
+		"```go",
+		"\tfmt.Println(\"**x**\")",
+		"```",
+		"| Name | Type |",
+		"| --- | --- |",
+		"| id | `int` |",
+		"---",
+	}, "\n")
+	want := strings.Join([]string{
+		"TITLE",
+		"=====",
+		"Usage",
+		"-----",
+		"Call Run with a config (https://example.com) and ctx.",
+		"- first item",
+		"- second",
+		"    \tfmt.Println(\"**x**\")",
+		"Name  |  Type",
+		"id  |  int",
+		strings.Repeat("-", 40),
+		"",
+	}, "\n")
+	if got := RenderPlainText(doc); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTextGenerator(t *testing.T) {
+	generator, err := NewGenerator("text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generator.Extension() != "txt" || !strings.HasPrefix(generator.ContentType(), "text/plain") {
+		t.Errorf("extension %q, content type %q", generator.Extension(), generator.ContentType())
+	}
+	path := filepath.Join(t.TempDir(), "doc.txt")
+	if err := generator.GenerateDocumentation("# Doc\n\n**bold**\n", path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "DOC\n===\n\nbold\n" {
+		t.Errorf("wrote %q", data)
+	}
+}
+
+func TestNewGeneratorUnknown(t *testing.T) {
+	if _, err := NewGenerator("pdf"); err == nil {
+		t.Error("NewGenerator accepted pdf")
+	}
+	if got := ContentTypeFor("x_documentation.txt"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("ContentTypeFor(.txt) = %q", got)
+	}
+}
@@ -0,0 +1,19 @@
+package services
+
+import "context"
+
+type traceIDKey struct{}
+
+// TraceHeader carries the correlation ID to the analyze agent.
+const TraceHeader = "X-Request-ID"
+
+// WithTraceID attaches a correlation ID to ctx for outgoing agent calls.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the correlation ID attached to ctx, if any.
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Workspace tools whose manifests declare the packages of a monorepo.
+const (
+	WorkspaceGo        = "go"        // go.work
+	WorkspacePnpm      = "pnpm"      // pnpm-workspace.yaml
+	WorkspaceNpm       = "npm"       // "workspaces" in package.json, as npm and Yarn read it
+	WorkspaceTurborepo = "turborepo" // turbo.json over pnpm or npm workspaces
+	WorkspaceNx        = "nx"        // nx.json with project.json files or a workspace.json
+)
+
+// workspaceManifests are the files DetectWorkspace reads besides
+// package.json.
+var workspaceManifests = map[string]bool{
+	"go.work":             true,
+	"pnpm-workspace.yaml": true,
+	"turbo.json":          true,
+	"nx.json":             true,
+	"workspace.json":      true,
+	"project.json":        true,
+}
+
+func IsWorkspaceManifest(name string) bool {
+	return workspaceManifests[name]
+}
+
+// Workspace is a monorepo whose package boundaries are declared by its
+// workspace tool rather than guessed from manifests.
+type Workspace struct {
+	Root     string
+	Tool     string
+	Packages []string
+}
+
+// DetectWorkspace reads the workspace manifests in dir and returns the
+// package directories they declare, or nil when dir isn't the root of a
+// workspace or none of its packages exist. Packages nested inside another
+// declared package belong to that package.
+func DetectWorkspace(dir string) *Workspace {
+	ws := &Workspace{Root: dir}
+	if fileExists(filepath.Join(dir, "go.work")) {
+		ws.Tool = WorkspaceGo
+		ws.Packages = goWorkPackages(dir)
+	} else {
+		var patterns []string
+		ws.Tool, patterns = jsWorkspacePatterns(dir)
+		ws.Packages = matchPackageDirs(dir, patterns)
+		switch {
+		case fileExists(filepath.Join(dir, "nx.json")):
+			ws.Tool = WorkspaceNx
+			ws.Packages = append(ws.Packages, nxProjects(dir)...)
+		case fileExists(filepath.Join(dir, "turbo.json")) && ws.Tool != "":
+			ws.Tool = WorkspaceTurborepo
+		}
+	}
+
+	ws.Packages = outermostDirs(ws.Packages)
+	if len(ws.Packages) == 0 {
+		return nil
+	}
+	return ws
+}
+
+// goWorkPackages lists the modules a go.work file uses, in either the
+// single line or the block form of the use directive.
+func goWorkPackages(dir string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, "go.work"))
+	if err != nil {
+		return nil
+	}
+	var packages []string
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case line == "use (":
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "use "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "use "))
+		default:
+			continue
+		}
+		if unquoted, err := strconv.Unquote(line); err == nil {
+			line = unquoted
+		}
+		if line == "" {
+			continue
+		}
+		if path, err := ResolveSubpath(dir, line); err == nil {
+			packages = append(packages, path)
+		}
+	}
+	return packages
+}
+
+// jsWorkspacePatterns returns the package globs of a pnpm workspace or of
+// the "workspaces" field in package.json, with the tool declaring them.
+func jsWorkspacePatterns(dir string) (string, []string) {
+	if data, err := os.ReadFile(filepath.Join(dir, "pnpm-workspace.yaml")); err == nil {
+		doc, _ := parseYAMLLite(string(data))
+		m, _ := doc.(map[string]any)
+		list, _ := m["packages"].([]any)
+		var patterns []string
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				patterns = append(patterns, s)
+			}
+		}
+		return WorkspacePnpm, patterns
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return "", nil
+	}
+	var manifest struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if json.Unmarshal(data, &manifest) != nil || manifest.Workspaces == nil {
+		return "", nil
+	}
+	// Either a list of globs or, as Yarn also allows, {"packages": [...]}
+	var patterns []string
+	if json.Unmarshal(manifest.Workspaces, &patterns) != nil {
+		var nested struct {
+			Packages []string `json:"packages"`
+		}
+		if json.Unmarshal(manifest.Workspaces, &nested) != nil {
+			return "", nil
+		}
+		patterns = nested.Packages
+	}
+	if len(patterns) == 0 {
+		return "", nil
+	}
+	return WorkspaceNpm, patterns
+}
+
+// matchPackageDirs returns the directories under dir that match one of
+// patterns, relative globs where "**" stands for any number of
+// directories and a leading "!" excludes, and carry a project manifest.
+func matchPackageDirs(dir string, patterns []string) []string {
+	var include, exclude [][]string
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.Trim(strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "./"), "/")
+		if pattern == "" {
+			continue
+		}
+		if negated {
+			exclude = append(exclude, strings.Split(pattern, "/"))
+		} else {
+			include = append(include, strings.Split(pattern, "/"))
+		}
+	}
+	if len(include) == 0 {
+		return nil
+	}
+
+	matchAny := func(globs [][]string, name []string) bool {
+		for _, glob := range globs {
+			if matchSegments(glob, name) {
+				return true
+			}
+		}
+		return false
+	}
+	var packages []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || path == dir {
+			return nil
+		}
+		if skippedDirs[info.Name()] {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		name := strings.Split(filepath.ToSlash(rel), "/")
+		if matchAny(include, name) && !matchAny(exclude, name) && hasManifest(path) {
+			packages = append(packages, path)
+		}
+		return nil
+	})
+	return packages
+}
+
+// nxProjects lists the projects of an Nx workspace: the directories
+// holding a project.json and those named in a workspace.json.
+func nxProjects(dir string) []string {
+	var projects []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || path == dir {
+			return nil
+		}
+		if skippedDirs[info.Name()] {
+			return filepath.SkipDir
+		}
+		if fileExists(filepath.Join(path, "project.json")) {
+			projects = append(projects, path)
+		}
+		return nil
+	})
+
+	data, err := os.ReadFile(filepath.Join(dir, "workspace.json"))
+	if err != nil {
+		return projects
+	}
+	var workspace struct {
+		Projects map[string]json.RawMessage `json:"projects"`
+	}
+	if json.Unmarshal(data, &workspace) != nil {
+		return projects
+	}
+	for _, value := range workspace.Projects {
+		// A project is given by its root or by its whole configuration
+		var root string
+		if json.Unmarshal(value, &root) != nil {
+			var config struct {
+				Root string `json:"root"`
+			}
+			json.Unmarshal(value, &config)
+			root = config.Root
+		}
+		if root == "" {
+			continue
+		}
+		if path, err := ResolveSubpath(dir, root); err == nil {
+			projects = append(projects, path)
+		}
+	}
+	return projects
+}
+
+// outermostDirs sorts dirs and drops duplicates and directories inside
+// another of them.
+func outermostDirs(dirs []string) []string {
+	sort.Strings(dirs)
+	var kept []string
+outer:
+	for _, dir := range dirs {
+		for _, k := range kept {
+			if dir == k || strings.HasPrefix(dir, k+string(filepath.Separator)) {
+				continue outer
+			}
+		}
+		kept = append(kept, dir)
+	}
+	return kept
+}
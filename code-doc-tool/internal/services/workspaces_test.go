@@ -0,0 +1,179 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// workspacePackages detects the workspace at dir and returns its tool and
+// packages relative to dir.
+func workspacePackages(t *testing.T, dir string) (string, []string) {
+	t.Helper()
+	ws := DetectWorkspace(dir)
+	if ws == nil {
+		return "", nil
+	}
+	return ws.Tool, relPaths(t, dir, ws.Packages)
+}
+
+func TestDetectWorkspacePnpm(t *testing.T) {
+	packages := map[string]string{
+		"packages/ui/package.json":              "{}",
+		"packages/ui/test/fixture/package.json": "{}",
+		"packages/core/package.json":            "{}",
+		"packages/docs/README.md":               "no manifest",
+		"apps/web/package.json":                 "{}",
+		"apps/web/node_modules/x/package.json":  "{}",
+		"legacy/old/package.json":               "{}",
+		"tools/gen/package.json":                "{}",
+		"e2e/package.json":                      "{}",
+	}
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"single quoted", `packages:
+  # all packages in direct subdirs of packages/
+  - 'packages/*'
+  - 'apps/**'
+  # exclude packages inside test directories
+  - '!**/test/**'
+  - 'e2e'
+`},
+		{"double quoted with comments", `packages:
+  - "packages/*" # libraries
+  - "apps/**"    # deployables
+  - "!**/test/**"
+  - "e2e"
+`},
+		{"plain at the key's indent", `packages:
+- packages/*
+- ./apps/**
+- '!**/test/**'
+- e2e/
+`},
+		{"flow", `packages: ['packages/*', "apps/**", '!**/test/**', e2e]
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, packages)
+			writeFiles(t, dir, map[string]string{"pnpm-workspace.yaml": tt.manifest, "package.json": "{}"})
+
+			tool, got := workspacePackages(t, dir)
+			want := []string{"apps/web", "e2e", "packages/core", "packages/ui"}
+			if tool != WorkspacePnpm || !reflect.DeepEqual(got, want) {
+				t.Errorf("got %s %v, want pnpm %v", tool, got, want)
+			}
+		})
+	}
+}
+
+func TestDetectWorkspaceGoWork(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.work": `go 1.22
+
+use ./api // the service
+use (
+	./libs/auth
+	"./libs/with space"
+	// ./libs/unused
+	./missing
+	../outside
+)
+`,
+		"api/go.mod":             "module api\n",
+		"libs/auth/go.mod":       "module auth\n",
+		"libs/with space/go.mod": "module space\n",
+		"libs/unused/go.mod":     "module unused\n",
+		"tools/go.mod":           "module tools\n",
+	})
+	tool, got := workspacePackages(t, dir)
+	want := []string{"api", "libs/auth", "libs/with space"}
+	if tool != WorkspaceGo || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s %v, want go %v", tool, got, want)
+	}
+}
+
+func TestDetectWorkspaceJS(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		tool  string
+		want  []string
+	}{
+		{"npm list", map[string]string{
+			"package.json":            `{"workspaces": ["packages/*"]}`,
+			"packages/a/package.json": "{}",
+			"packages/b/package.json": "{}",
+		}, WorkspaceNpm, []string{"packages/a", "packages/b"}},
+		{"yarn packages field", map[string]string{
+			"package.json":            `{"workspaces": {"packages": ["libs/*"]}}`,
+			"libs/a/package.json":     "{}",
+			"libs/a/sub/package.json": "{}",
+		}, WorkspaceNpm, []string{"libs/a"}},
+		{"turborepo", map[string]string{
+			"package.json":          `{"workspaces": ["apps/*"]}`,
+			"turbo.json":            "{}",
+			"apps/web/package.json": "{}",
+		}, WorkspaceTurborepo, []string{"apps/web"}},
+		{"nx", map[string]string{
+			"nx.json":                 "{}",
+			"apps/shop/project.json":  "{}",
+			"apps/shop/package.json":  "{}",
+			"libs/ui/project.json":    "{}",
+			"workspace.json":          `{"projects": {"api": "services/api", "worker": {"root": "services/worker"}, "gone": "missing"}}`,
+			"services/api/go.mod":     "module api\n",
+			"services/worker/main.py": "",
+		}, WorkspaceNx, []string{"apps/shop", "libs/ui", "services/api", "services/worker"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			tool, got := workspacePackages(t, dir)
+			if tool != tt.tool || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %s %v, want %s %v", tool, got, tt.tool, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectWorkspaceNone(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"no manifest":      {"a/package.json": "{}"},
+		"no workspaces":    {"package.json": `{"name": "app"}`, "a/package.json": "{}"},
+		"no packages":      {"pnpm-workspace.yaml": "packages:\n  - 'packages/*'\n"},
+		"turbo without js": {"turbo.json": "{}", "a/package.json": "{}"},
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, files)
+		if ws := DetectWorkspace(dir); ws != nil {
+			t.Errorf("%s: detected %+v", name, ws)
+		}
+	}
+}
+
+func TestDetectProjectRootsWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"repo/pnpm-workspace.yaml":     "packages:\n  - 'packages/*'\n",
+		"repo/package.json":            "{}",
+		"repo/packages/a/package.json": "{}",
+		"repo/packages/b/package.json": "{}",
+		"repo/tools/package.json":      "{}",
+		"other/go.mod":                 "module other\n",
+	})
+	roots, workspaces, err := DetectProjectRoots(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relPaths(t, dir, roots); !reflect.DeepEqual(got, []string{"other", "repo/packages/a", "repo/packages/b"}) {
+		t.Errorf("roots %v, want the declared packages and other", got)
+	}
+	if len(workspaces) != 1 || workspaces[0].Tool != WorkspacePnpm || relPaths(t, dir, []string{workspaces[0].Root})[0] != "repo" {
+		t.Errorf("workspaces %+v", workspaces)
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAMLLite parses the block-style YAML subset API specs are written
+// in: nested mappings, lists, plain/quoted scalars and literal (|) or
+// folded (>) blocks. Flow collections are only understood when they are
+// also valid JSON or are lists of scalars. Anchors, tags and
+// multi-document files aren't supported. Scalars are returned as strings.
+func parseYAMLLite(data string) (any, error) {
+	p := &yamlParser{}
+	for _, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		p.raw = append(p.raw, raw)
+	}
+	p.next(0)
+	if p.i >= len(p.raw) {
+		return nil, nil
+	}
+	return p.block(p.indent(p.i))
+}
+
+type yamlParser struct {
+	raw []string
+	i   int
+	// pending replaces the content of the current line, used when a list
+	// item's first mapping key shares the line with its dash
+	pending *yamlLine
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+// next moves i to the first meaningful line at or after from.
+func (p *yamlParser) next(from int) {
+	p.i = from
+	for p.i < len(p.raw) {
+		trimmed := strings.TrimSpace(p.raw[p.i])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && trimmed != "---" && trimmed != "..." {
+			return
+		}
+		p.i++
+	}
+}
+
+func (p *yamlParser) line() yamlLine {
+	if p.pending != nil {
+		return *p.pending
+	}
+	raw := p.raw[p.i]
+	return yamlLine{indent: p.indent(p.i), text: strings.TrimSpace(raw)}
+}
+
+func (p *yamlParser) indent(i int) int {
+	return len(p.raw[i]) - len(strings.TrimLeft(p.raw[i], " "))
+}
+
+func (p *yamlParser) advance() {
+	p.pending = nil
+	p.next(p.i + 1)
+}
+
+func (p *yamlParser) done() bool {
+	return p.pending == nil && p.i >= len(p.raw)
+}
+
+func isListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) block(indent int) (any, error) {
+	if isListItem(p.line().text) {
+		return p.list(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for !p.done() {
+		l := p.line()
+		if l.indent < indent || isListItem(l.text) && l.indent == indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("yaml: unexpected indentation at line %d", p.i+1)
+		}
+
+		key, value, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: expected key at line %d", p.i+1)
+		}
+		line := p.i
+		p.advance()
+
+		switch {
+		case value == "":
+			// Nested block, or a list at the same indent as its key
+			if p.done() {
+				m[key] = nil
+				break
+			}
+			next := p.line()
+			if next.indent > indent || next.indent == indent && isListItem(next.text) {
+				v, err := p.block(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = v
+			} else {
+				m[key] = nil
+			}
+		case value[0] == '|' || value[0] == '>':
+			m[key] = p.blockScalar(line, indent, value[0] == '>')
+		default:
+			m[key] = yamlScalar(value)
+		}
+	}
+	return m, nil
+}
+
+func (p *yamlParser) list(indent int) (any, error) {
+	var items []any
+	for !p.done() {
+		l := p.line()
+		if l.indent != indent || !isListItem(l.text) {
+			break
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(l.text, "-"))
+
+		switch {
+		case rest == "":
+			p.advance()
+			if p.done() || p.line().indent <= indent {
+				items = append(items, nil)
+				continue
+			}
+			v, err := p.block(p.line().indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		case strings.HasPrefix(rest, "{") || strings.HasPrefix(rest, "[") || strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "'"):
+			items = append(items, yamlScalar(rest))
+			p.advance()
+		default:
+			if _, _, ok := splitYAMLKey(rest); ok {
+				// "- key: value" starts a mapping indented past the dash
+				itemIndent := l.indent + len(l.text) - len(rest)
+				p.pending = &yamlLine{indent: itemIndent, text: rest}
+				v, err := p.mapping(itemIndent)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, v)
+				continue
+			}
+			items = append(items, yamlScalar(rest))
+			p.advance()
+		}
+	}
+	return items, nil
+}
+
+// blockScalar collects the lines indented past parent after a | or >
+// indicator on line.
+func (p *yamlParser) blockScalar(line, parent int, folded bool) string {
+	var lines []string
+	i := line + 1
+	for ; i < len(p.raw); i++ {
+		if strings.TrimSpace(p.raw[i]) != "" && p.indent(i) <= parent {
+			break
+		}
+		lines = append(lines, p.raw[i])
+	}
+	p.pending = nil
+	p.next(i)
+
+	// Strip the common indentation of the block
+	common := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if n := len(l) - len(strings.TrimLeft(l, " ")); common < 0 || n < common {
+			common = n
+		}
+	}
+	for j, l := range lines {
+		if len(l) >= common && common > 0 {
+			lines[j] = l[common:]
+		} else {
+			lines[j] = strings.TrimSpace(l)
+		}
+	}
+
+	sep := "\n"
+	if folded {
+		sep = " "
+	}
+	return strings.TrimSpace(strings.Join(lines, sep))
+}
+
+// splitYAMLKey splits "key: value" or "key:", honouring quoted keys.
+func splitYAMLKey(text string) (string, string, bool) {
+	if text == "" {
+		return "", "", false
+	}
+	if q := text[0]; q == '"' || q == '\'' {
+		end := strings.IndexByte(text[1:], q)
+		if end < 0 {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		return text[1 : end+1], strings.TrimSpace(rest[1:]), true
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// yamlScalar converts a scalar or flow value, dropping a trailing comment.
+func yamlScalar(value string) any {
+	switch value[0] {
+	case '"', '\'':
+		end := yamlQuoteEnd(value)
+		if end < 0 {
+			return strings.Trim(value, value[:1])
+		}
+		if value[0] == '\'' {
+			return strings.ReplaceAll(value[1:end], "''", "'")
+		}
+		if s, err := strconv.Unquote(value[:end+1]); err == nil {
+			return s
+		}
+		return value[1:end]
+	case '{', '[':
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err == nil {
+			return v
+		}
+		if list, ok := flowSequence(value); ok {
+			return list
+		}
+		return value
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+// yamlQuoteEnd returns the index of the quote ending the scalar value
+// starts with, or -1 if it isn't closed. Double quoted scalars escape
+// with a backslash, single quoted ones by doubling the quote.
+func yamlQuoteEnd(value string) int {
+	q := value[0]
+	for i := 1; i < len(value); i++ {
+		switch {
+		case q == '"' && value[i] == '\\':
+			i++
+		case value[i] == q && q == '\'' && i+1 < len(value) && value[i+1] == '\'':
+			i++
+		case value[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// flowSequence parses a flow list of scalars, such as ['a/*', "b", c],
+// that isn't valid JSON. Nested collections aren't supported.
+func flowSequence(value string) ([]any, bool) {
+	if value[0] != '[' {
+		return nil, false
+	}
+	items := []any{}
+	rest := value[1:]
+	for {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return nil, false
+		}
+		if rest[0] == ']' {
+			if tail := strings.TrimSpace(rest[1:]); tail != "" && !strings.HasPrefix(tail, "#") {
+				return nil, false
+			}
+			return items, true
+		}
+
+		var item string
+		if rest[0] == '"' || rest[0] == '\'' {
+			end := yamlQuoteEnd(rest)
+			if end < 0 {
+				return nil, false
+			}
+			item, rest = rest[:end+1], rest[end+1:]
+		} else {
+			end := strings.IndexAny(rest, ",]")
+			if end < 0 || strings.ContainsAny(rest[:end], "[{") {
+				return nil, false
+			}
+			item, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+		if item != "" {
+			items = append(items, yamlScalar(item))
+		}
+
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, ",") {
+			rest = rest[1:]
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestYAMLScalar(t *testing.T) {
+	tests := []struct {
+		value string
+		want  any
+	}{
+		{"plain", "plain"},
+		{"plain # comment", "plain"},
+		{"a#b", "a#b"},
+		{`"double \"quoted\""`, `double "quoted"`},
+		{`"packages/*" # libraries`, "packages/*"},
+		{`'it''s' # comment`, "it's"},
+		{`'!**/test/**'`, "!**/test/**"},
+		{`'unclosed`, "unclosed"},
+		{`["a", "b"]`, []any{"a", "b"}},
+		{`['a/*', "b # not a comment", c ] # comment`, []any{"a/*", "b # not a comment", "c"}},
+		{`[]`, []any{}},
+		{`{"a": 1}`, map[string]any{"a": float64(1)}},
+		{`[a, [b]]`, `[a, [b]]`},
+		{`['a' 'b']`, `['a' 'b']`},
+	}
+	for _, tt := range tests {
+		if got := yamlScalar(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("yamlScalar(%q) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}
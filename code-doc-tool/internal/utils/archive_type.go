@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DetectArchiveFormat sniffs the magic bytes of a file and returns the
+// archive extension ExtractArchive expects: ".zip", ".tar" or ".tar.gz".
+func DetectArchiveFormat(path string) (string, error) {
+	file, err := Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// The tar magic lives at offset 257, so read past it
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return ArchiveFormatOf(header[:n])
+}
+
+// ArchiveFormatOf is DetectArchiveFormat for an archive's leading bytes,
+// at least the first 512 when there are that many.
+func ArchiveFormatOf(header []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return ".zip", nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return ".tar.gz", nil
+	case len(header) >= 262 && bytes.Equal(header[257:262], []byte("ustar")):
+		return ".tar", nil
+	default:
+		return "", fmt.Errorf("unrecognized archive format")
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestArchiveFormatOf(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+	tests := []struct {
+		header []byte
+		want   string
+	}{
+		{[]byte("PK\x03\x04rest"), ".zip"},
+		{[]byte("PK\x05\x06"), ".zip"},
+		{[]byte{0x1f, 0x8b, 0x08}, ".tar.gz"},
+		{tar, ".tar"},
+	}
+	for _, tt := range tests {
+		if got, err := ArchiveFormatOf(tt.header); err != nil || got != tt.want {
+			t.Errorf("ArchiveFormatOf(%q) = %q, %v, want %q", tt.header[:4], got, err, tt.want)
+		}
+	}
+	for _, header := range [][]byte{nil, []byte("hello"), bytes.Repeat([]byte{0}, 300)} {
+		if _, err := ArchiveFormatOf(header); err == nil {
+			t.Errorf("ArchiveFormatOf(%q) accepted", header)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+var ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+
+// IsDiskFull reports whether err was caused by the disk running out of space.
+func IsDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, ErrInsufficientDiskSpace)
+}
+
+// WrapDiskFull tags disk-space failures with ErrInsufficientDiskSpace so
+// callers can classify them without knowing about syscall errors.
+func WrapDiskFull(err error) error {
+	if err == nil || errors.Is(err, ErrInsufficientDiskSpace) || !IsDiskFull(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrInsufficientDiskSpace, err)
+}
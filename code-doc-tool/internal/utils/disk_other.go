@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package utils
+
+// AvailableSpace is not implemented on this platform.
+func AvailableSpace(path string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestIsDiskFull(t *testing.T) {
+	enospc := &fs.PathError{Op: "write", Path: "/tmp/x", Err: syscall.ENOSPC}
+	if !IsDiskFull(enospc) {
+		t.Error("ENOSPC isn't reported as disk full")
+	}
+	if IsDiskFull(&fs.PathError{Op: "write", Path: "/tmp/x", Err: syscall.EACCES}) {
+		t.Error("EACCES is reported as disk full")
+	}
+
+	wrapped := WrapDiskFull(enospc)
+	if !errors.Is(wrapped, ErrInsufficientDiskSpace) || !IsDiskFull(wrapped) {
+		t.Errorf("WrapDiskFull(ENOSPC) = %v", wrapped)
+	}
+	if WrapDiskFull(wrapped) != wrapped {
+		t.Error("WrapDiskFull wrapped twice")
+	}
+	other := errors.New("other")
+	if WrapDiskFull(other) != other || WrapDiskFull(nil) != nil {
+		t.Error("WrapDiskFull changed an unrelated error")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package utils
+
+import (
+	"syscall"
+)
+
+// AvailableSpace returns the bytes available to unprivileged users on the
+// filesystem holding path.
+func AvailableSpace(path string) (uint64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), true
+}
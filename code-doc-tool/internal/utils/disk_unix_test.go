@@ -0,0 +1,14 @@
+//go:build linux || darwin
+
+package utils
+
+import "testing"
+
+func TestAvailableSpace(t *testing.T) {
+	if space, ok := AvailableSpace(t.TempDir()); !ok || space == 0 {
+		t.Errorf("AvailableSpace = %d, %v", space, ok)
+	}
+	if _, ok := AvailableSpace("/does/not/exist"); ok {
+		t.Error("AvailableSpace reported a missing path")
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrFileHandleLimit = errors.New("timed out waiting for a free file handle")
+
+// fileHandles bounds the files held open at once across extraction and
+// analysis so concurrent jobs can't exhaust the process descriptor limit.
+var (
+	fileHandles     = make(chan struct{}, 256)
+	fileHandleWait  = 30 * time.Second
+	fileHandleLimit = 256
+)
+
+// SetOpenFileLimit configures the shared open-file limit. It must be called
+// before any files are opened.
+func SetOpenFileLimit(limit int, wait time.Duration) {
+	fileHandles = make(chan struct{}, limit)
+	fileHandleLimit = limit
+	fileHandleWait = wait
+}
+
+// AcquireFileHandle reserves one open-file slot, waiting up to the
+// configured timeout. The returned func releases the slot.
+func AcquireFileHandle() (func(), error) {
+	sem := fileHandles
+	timer := time.NewTimer(fileHandleWait)
+	defer timer.Stop()
+
+	select {
+	case sem <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-sem }) }, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w (limit %d)", ErrFileHandleLimit, fileHandleLimit)
+	}
+}
+
+// LimitedFile is an *os.File whose Close also releases its handle slot.
+type LimitedFile struct {
+	*os.File
+	release func()
+}
+
+func (f *LimitedFile) Close() error {
+	err := f.File.Close()
+	f.release()
+	return err
+}
+
+// Open is os.Open bounded by the shared open-file limit.
+func Open(name string) (*LimitedFile, error) {
+	return OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile is os.OpenFile bounded by the shared open-file limit.
+func OpenFile(name string, flag int, perm os.FileMode) (*LimitedFile, error) {
+	release, err := AcquireFileHandle()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &LimitedFile{File: file, release: release}, nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenFileLimit(t *testing.T) {
+	SetOpenFileLimit(1, 20*time.Millisecond)
+	defer SetOpenFileLimit(256, 30*time.Second)
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(path, []byte("a"), 0644)
+
+	first, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrFileHandleLimit) {
+		t.Fatalf("second open got %v, want ErrFileHandleLimit", err)
+	}
+
+	// Closing frees the slot, once
+	first.Close()
+	first.Close()
+	second, err := Open(path)
+	if err != nil {
+		t.Fatalf("open after close: %v", err)
+	}
+	second.Close()
+
+	// A failed open doesn't keep its slot
+	if _, err := Open(filepath.Join(t.TempDir(), "missing")); err == nil || errors.Is(err, ErrFileHandleLimit) {
+		t.Fatalf("got %v, want a not-exist error", err)
+	}
+	release, err := AcquireFileHandle()
+	if err != nil {
+		t.Fatalf("slot leaked by a failed open: %v", err)
+	}
+	release()
+}
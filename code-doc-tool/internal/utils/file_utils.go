@@ -4,39 +4,145 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func CreateDir(path string) error {
 	return os.MkdirAll(path, 0755)
 }
 
-func ExtractArchive(src, dest string) error {
+// ExtractOptions tunes how archives are extracted.
+type ExtractOptions struct {
+	// Password decrypts ZipCrypto protected zip entries
+	Password string
+
+	// Keep, when set, decides which regular files are written; everything
+	// else is skipped during extraction
+	Keep func(name string) bool
+
+	// SkipCorrupt, when set, is called for each entry that can't be read
+	// and extraction carries on with the rest instead of failing
+	SkipCorrupt func(name string, err error)
+}
+
+func (o ExtractOptions) keep(name string) bool {
+	return o.Keep == nil || o.Keep(name)
+}
+
+// skip reports whether a failed entry may be skipped. Password, disk space
+// and file handle errors affect every entry, so they always fail.
+func (o ExtractOptions) skip(name string, err error) bool {
+	if o.SkipCorrupt == nil || IsDiskFull(err) || errors.Is(err, ErrFileHandleLimit) ||
+		errors.Is(err, ErrPasswordRequired) || errors.Is(err, ErrWrongPassword) || errors.Is(err, ErrUnsupportedCrypt) {
+		return false
+	}
+	o.SkipCorrupt(name, err)
+	return true
+}
+
+func ExtractArchive(src, dest string, opts ExtractOptions) error {
+	err := extractArchive(src, dest, opts)
+	if IsDiskFull(err) {
+		// Don't leave a half-written tree behind on a full disk
+		os.RemoveAll(dest)
+		return WrapDiskFull(err)
+	}
+	return err
+}
+
+// ExtractedSize returns the bytes extracting src with opts would write:
+// the uncompressed sizes of the regular files it keeps. Tar archives are
+// read through to their headers for it.
+func ExtractedSize(src string, opts ExtractOptions) (int64, error) {
+	lower := strings.ToLower(src)
+	if strings.HasSuffix(lower, ".zip") {
+		r, err := zip.OpenReader(src)
+		if err != nil {
+			return 0, err
+		}
+		defer r.Close()
+		var total int64
+		for _, f := range r.File {
+			if !f.FileInfo().IsDir() && opts.keep(f.Name) {
+				total += int64(f.UncompressedSize64)
+			}
+		}
+		return total, nil
+	}
+
+	file, err := Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	var stream io.Reader = file
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"):
+		gzr, err := gzip.NewReader(file)
+		if err != nil {
+			return 0, err
+		}
+		defer gzr.Close()
+		stream = gzr
+	case !strings.HasSuffix(lower, ".tar"):
+		return 0, fmt.Errorf("unsupported archive format: %s", filepath.Ext(src))
+	}
+
+	var total int64
+	tr := tar.NewReader(stream)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+		if header.Typeflag == tar.TypeReg && opts.keep(header.Name) {
+			total += header.Size
+		}
+	}
+}
+
+func extractArchive(src, dest string, opts ExtractOptions) error {
 	ext := strings.ToLower(filepath.Ext(src))
 
 	switch ext {
 	case ".zip":
-		return extractZip(src, dest)
+		return extractZip(src, dest, opts)
 	case ".gz":
 		// Check if it's a .tar.gz file
 		if strings.HasSuffix(strings.ToLower(src), ".tar.gz") {
-			return extractTarGz(src, dest)
+			return extractTarGz(src, dest, opts)
 		}
 		return fmt.Errorf("unsupported gzip format: %s", src)
 	case ".tar":
-		return extractTar(src, dest)
+		return extractTar(src, dest, opts)
 	default:
 		return fmt.Errorf("unsupported archive format: %s", ext)
 	}
 
 }
 
-func extractTar(src, dest string) error {
-	file, err := os.Open(src)
+// openArchive opens an archive for extraction outside the open-file
+// limit. Each entry written takes a slot of its own; were the archive
+// holding one as well, concurrent extractions could each wait on the
+// others' slots. Archives open at once are bounded by the uploads in
+// flight instead.
+func openArchive(name string) (*os.File, error) {
+	return os.Open(name)
+}
+
+func extractTar(src, dest string, opts ExtractOptions) error {
+	file, err := openArchive(src)
 	if err != nil {
 		return err
 	}
@@ -50,10 +156,20 @@ func extractTar(src, dest string) error {
 			break
 		}
 		if err != nil {
+			// A broken tar stream can't be resumed, so keep what we have
+			if opts.skip("(remaining entries)", err) {
+				break
+			}
 			return err
 		}
 
-		target := filepath.Join(dest, header.Name)
+		target, err := entryPath(dest, header.Name)
+		if err != nil {
+			if opts.skip(header.Name, err) {
+				continue
+			}
+			return err
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -61,28 +177,36 @@ func extractTar(src, dest string) error {
 				return err
 			}
 		case tar.TypeReg:
+			if !opts.keep(header.Name) {
+				continue
+			}
 			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 				return err
 			}
 
-			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
+			outFile, err := OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
 			if err != nil {
 				return err
 			}
 
 			if _, err := io.Copy(outFile, tr); err != nil {
 				outFile.Close()
+				os.Remove(target)
+				if opts.skip(header.Name, err) {
+					continue
+				}
 				return err
 			}
 			outFile.Close()
+			restoreModTime(target, header.ModTime)
 		}
 	}
 
 	return nil
 }
 
-func extractTarGz(src, dest string) error {
-	file, err := os.Open(src)
+func extractTarGz(src, dest string, opts ExtractOptions) error {
+	file, err := openArchive(src)
 	if err != nil {
 		return err
 	}
@@ -102,10 +226,20 @@ func extractTarGz(src, dest string) error {
 			break
 		}
 		if err != nil {
+			// A broken tar stream can't be resumed, so keep what we have
+			if opts.skip("(remaining entries)", err) {
+				break
+			}
 			return err
 		}
 
-		target := filepath.Join(dest, header.Name)
+		target, err := entryPath(dest, header.Name)
+		if err != nil {
+			if opts.skip(header.Name, err) {
+				continue
+			}
+			return err
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -113,27 +247,36 @@ func extractTarGz(src, dest string) error {
 				return err
 			}
 		case tar.TypeReg:
+			if !opts.keep(header.Name) {
+				continue
+			}
 			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 				return err
 			}
 
-			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
+			outFile, err := OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
 			if err != nil {
 				return err
 			}
 
 			if _, err := io.Copy(outFile, tr); err != nil {
 				outFile.Close()
+				os.Remove(target)
+				if opts.skip(header.Name, err) {
+					continue
+				}
 				return err
 			}
 			outFile.Close()
+			restoreModTime(target, header.ModTime)
 		}
 	}
 
 	return nil
 }
 
-func extractZip(src, dest string) error {
+func extractZip(src, dest string, opts ExtractOptions) error {
+	// Not counted against the open-file limit; see openArchive
 	r, err := zip.OpenReader(src)
 	if err != nil {
 		return err
@@ -145,46 +288,103 @@ func extractZip(src, dest string) error {
 		return err
 	}
 
-	// Extract files
+	// Fail early when the uncompressed contents clearly won't fit
+	var total uint64
 	for _, f := range r.File {
-		rc, err := f.Open()
-		if err != nil {
-			return err
+		if opts.keep(f.Name) {
+			total += f.UncompressedSize64
 		}
+	}
+	if available, ok := AvailableSpace(dest); ok && total > available {
+		return fmt.Errorf("%w: archive needs %d bytes, %d available", ErrInsufficientDiskSpace, total, available)
+	}
 
-		path := filepath.Join(dest, f.Name)
-
-		// Create directory if needed
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(path, f.FileInfo().Mode())
-			rc.Close()
+	// Extract files
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() && !opts.keep(f.Name) {
 			continue
 		}
-
-		// Create file
-		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			rc.Close()
+		if err := extractZipEntry(f, dest, opts); err != nil {
+			if opts.skip(f.Name, err) {
+				continue
+			}
 			return err
 		}
+	}
 
-		outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.FileInfo().Mode())
-		if err != nil {
-			rc.Close()
-			return err
-		}
+	return nil
+}
 
-		_, err = io.Copy(outFile, rc)
-		outFile.Close()
-		rc.Close()
+func extractZipEntry(f *zip.File, dest string, opts ExtractOptions) error {
+	path, err := entryPath(dest, f.Name)
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			return err
-		}
+	// Create directory if needed
+	if f.FileInfo().IsDir() {
+		return os.MkdirAll(path, f.FileInfo().Mode())
+	}
+
+	rc, err := openZipEntry(f, opts.Password)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Create file
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	outFile, err := OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.FileInfo().Mode())
+	if err != nil {
+		return err
 	}
 
+	_, err = io.Copy(outFile, rc)
+	outFile.Close()
+	if err != nil {
+		// Don't leave a truncated or undecryptable file behind
+		os.Remove(path)
+		return err
+	}
+	restoreModTime(path, f.Modified)
 	return nil
 }
 
+// ErrUnsafeEntryPath is returned for an archive entry whose name would
+// place it outside the extraction directory.
+var ErrUnsafeEntryPath = errors.New("archive entry escapes the extraction directory")
+
+// entryPath returns where the entry called name is extracted under dest.
+// Absolute names are made relative first, as standard extractors do:
+// "/home/user/p/a.go" lands at dest/home/user/p/a.go and "C:\\p\\a.go" at
+// dest/p/a.go. Names still reaching outside dest, through "..", are
+// rejected.
+func entryPath(dest, name string) (string, error) {
+	rel := strings.ReplaceAll(name, "\\", "/")
+	if len(rel) >= 2 && rel[1] == ':' && ('a' <= rel[0]|0x20 && rel[0]|0x20 <= 'z') {
+		rel = rel[2:]
+	}
+	rel = path.Clean(strings.TrimLeft(rel, "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeEntryPath, name)
+	}
+	return filepath.Join(dest, filepath.FromSlash(rel)), nil
+}
+
+// restoreModTime gives an extracted file the modification time stored in
+// the archive, so mtime based filtering sees the original dates.
+func restoreModTime(path string, modTime time.Time) {
+	if modTime.IsZero() {
+		return
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		log.Printf("Failed to restore modification time of %s: %v", path, err)
+	}
+}
+
 func CleanupDir(path string) error {
 	return os.RemoveAll(path)
 }
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeZip writes a zip archive holding files, keyed by path.
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	w := zip.NewWriter(file)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractKeep(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "app.zip")
+	writeZip(t, archive, map[string]string{
+		"src/main.go":   "package main\n",
+		"assets/a.png":  "\x89PNG",
+		"docs/guide.md": "# Guide\n",
+	})
+
+	dest := filepath.Join(dir, "out")
+	err := ExtractArchive(archive, dest, ExtractOptions{Keep: func(name string) bool {
+		return strings.HasSuffix(name, ".go")
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "src", "main.go")); err != nil {
+		t.Errorf("kept file missing: %v", err)
+	}
+	for _, skipped := range []string{"assets/a.png", "docs/guide.md"} {
+		if _, err := os.Stat(filepath.Join(dest, skipped)); err == nil {
+			t.Errorf("%s was extracted", skipped)
+		}
+	}
+}
+
+// writeCorruptZip writes a zip archive of files whose entry named corrupt
+// fails its checksum.
+func writeCorruptZip(t *testing.T, path string, files map[string]string, corrupt string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Replace(buf.Bytes(), []byte(files[corrupt]), bytes.ToUpper([]byte(files[corrupt])), 1)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractSkipCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "app.zip")
+	files := map[string]string{
+		"good.go":   "package good\n",
+		"broken.go": "package broken // damaged in transit\n",
+	}
+	writeCorruptZip(t, archive, files, "broken.go")
+
+	if err := ExtractArchive(archive, filepath.Join(dir, "strict"), ExtractOptions{}); err == nil {
+		t.Error("a corrupt entry didn't fail a strict extraction")
+	}
+
+	var skipped []string
+	dest := filepath.Join(dir, "lenient")
+	err := ExtractArchive(archive, dest, ExtractOptions{SkipCorrupt: func(name string, err error) {
+		skipped = append(skipped, name)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0] != "broken.go" {
+		t.Errorf("skipped %v, want broken.go", skipped)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "good.go")); err != nil || string(data) != files["good.go"] {
+		t.Errorf("good.go: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "broken.go")); err == nil {
+		t.Error("the corrupt entry was left behind")
+	}
+}
+
+func TestExtractedSize(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/main.go":  strings.Repeat("// padding\n", 100),
+		"assets/a.png": "\x89PNG",
+	}
+	goOnly := ExtractOptions{Keep: func(name string) bool { return strings.HasSuffix(name, ".go") }}
+
+	archive := filepath.Join(dir, "app.zip")
+	writeZip(t, archive, files)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "src/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, name := range []string{"src/main.go", "assets/a.png"} {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))})
+		tw.Write([]byte(files[name]))
+	}
+	tw.Close()
+	gw.Close()
+	tarball := filepath.Join(dir, "app.tar.gz")
+	if err := os.WriteFile(tarball, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range []string{archive, tarball} {
+		if size, err := ExtractedSize(src, ExtractOptions{}); err != nil || size != 1104 {
+			t.Errorf("%s: got %d, %v, want 1104", filepath.Base(src), size, err)
+		}
+		if size, err := ExtractedSize(src, goOnly); err != nil || size != 1100 {
+			t.Errorf("%s kept: got %d, %v, want 1100", filepath.Base(src), size, err)
+		}
+	}
+	if _, err := ExtractedSize(filepath.Join(dir, "app.rar"), ExtractOptions{}); err == nil {
+		t.Error("no error for an unsupported format")
+	}
+}
+
+func TestExtractOpenFileLimit(t *testing.T) {
+	// One slot is enough: the archive itself doesn't hold one while its
+	// entries are written, however many extractions run at once
+	SetOpenFileLimit(1, time.Second)
+	defer SetOpenFileLimit(256, 30*time.Second)
+
+	dir := t.TempDir()
+	files := map[string]string{"a.go": "package a\n", "b/b.go": "package b\n"}
+	archive := filepath.Join(dir, "app.zip")
+	writeZip(t, archive, files)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	tarball := filepath.Join(dir, "app.tar")
+	if err := os.WriteFile(tarball, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src := []string{archive, tarball}[i%2]
+			errs <- ExtractArchive(src, filepath.Join(dir, fmt.Sprint("out", i)), ExtractOptions{})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("extraction under a limit of one file: %v", err)
+		}
+	}
+}
+
+func TestEntryPath(t *testing.T) {
+	dest := filepath.Join("out", "job")
+	for name, want := range map[string]string{
+		"src/main.go":                "src/main.go",
+		"/etc/passwd":                "etc/passwd",
+		"//srv/share/x.go":           "srv/share/x.go",
+		`C:\proj\w.go`:               "proj/w.go",
+		"/home/user/../project/a.go": "home/project/a.go",
+	} {
+		got, err := entryPath(dest, name)
+		if err != nil || got != filepath.Join(dest, filepath.FromSlash(want)) {
+			t.Errorf("entryPath(%q) = %q, %v, want %s under dest", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"../evil.go", "a/../../b/../a.go", "/../../evil.go", `..\..\evil.go`, ".."} {
+		if _, err := entryPath(dest, name); !errors.Is(err, ErrUnsafeEntryPath) {
+			t.Errorf("entryPath(%q): got %v, want ErrUnsafeEntryPath", name, err)
+		}
+	}
+}
+
+func TestExtractAbsoluteEntries(t *testing.T) {
+	dir := t.TempDir()
+	zipped := filepath.Join(dir, "abs.zip")
+	writeZip(t, zipped, map[string]string{"/etc/cron.d/job.go": "package cron\n"})
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"/etc/profile.go", "../../evil.go"} {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4})
+		tw.Write([]byte("evil"))
+	}
+	tw.Close()
+	tarball := filepath.Join(dir, "abs.tar")
+	if err := os.WriteFile(tarball, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Absolute names are confined to the extraction root
+	dest := filepath.Join(dir, "a", "b", "zip")
+	if err := ExtractArchive(zipped, dest, ExtractOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "etc", "cron.d", "job.go")); err != nil {
+		t.Errorf("absolute zip entry not under dest: %v", err)
+	}
+
+	// Climbing out is rejected, or skipped when corrupt entries are
+	dest = filepath.Join(dir, "a", "b", "tar")
+	if err := ExtractArchive(tarball, dest, ExtractOptions{}); !errors.Is(err, ErrUnsafeEntryPath) {
+		t.Errorf("got %v, want ErrUnsafeEntryPath", err)
+	}
+	var skipped []string
+	err := ExtractArchive(tarball, dest, ExtractOptions{SkipCorrupt: func(name string, err error) {
+		skipped = append(skipped, name)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0] != "../../evil.go" {
+		t.Errorf("skipped %v, want ../../evil.go", skipped)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "etc", "profile.go")); err != nil {
+		t.Errorf("absolute tar entry not under dest: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.go")); err == nil {
+		t.Error("an entry was written outside dest")
+	}
+}
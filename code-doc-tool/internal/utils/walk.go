@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Walk walks the tree at root like filepath.Walk. With followSymlinks set
+// it also descends into symlinked directories and reports symlinked files
+// with their target's info, under the link's path. Links resolving
+// outside root are skipped, and every directory is walked once, so a
+// symlink loop or a second link to the same directory is not followed.
+func Walk(root string, followSymlinks bool, fn filepath.WalkFunc) error {
+	if !followSymlinks {
+		return filepath.Walk(root, fn)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	info, err := os.Stat(realRoot)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	w := symlinkWalker{root: realRoot, visited: map[string]bool{}, fn: fn}
+	err = w.walk(root, info)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+type symlinkWalker struct {
+	root    string
+	visited map[string]bool
+	fn      filepath.WalkFunc
+}
+
+func (w *symlinkWalker) walk(path string, info fs.FileInfo) error {
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, ok := w.resolve(path)
+		if !ok {
+			return nil
+		}
+		if info, _ = os.Stat(target); info == nil {
+			return nil
+		}
+	}
+	if !info.IsDir() {
+		return w.fn(path, info, nil)
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return w.fn(path, info, err)
+	}
+	if w.visited[real] {
+		return nil
+	}
+	w.visited[real] = true
+
+	if err := w.fn(path, info, nil); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if err := w.fn(path, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+	for _, entry := range entries {
+		name := filepath.Join(path, entry.Name())
+		entryInfo, err := os.Lstat(name)
+		if err != nil {
+			if err := w.fn(name, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := w.walk(name, entryInfo); err != nil {
+			// Directories handle their own SkipDir, so this came from a
+			// file and skips the rest of its directory
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve returns the target of the symlink at path if it stays under
+// the walked root.
+func (w *symlinkWalker) resolve(path string) (string, bool) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	if target != w.root && !strings.HasPrefix(target, w.root+string(filepath.Separator)) {
+		return "", false
+	}
+	return target, true
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// walkFiles returns the paths, relative to root, of the files Walk reports.
+func walkFiles(t *testing.T, root string, followSymlinks bool) []string {
+	t.Helper()
+	var files []string
+	err := Walk(root, followSymlinks, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for path, content := range map[string]string{
+		filepath.Join(root, "src", "a.go"):       "package src\n",
+		filepath.Join(root, "lib", "b.go"):       "package lib\n",
+		filepath.Join(outside, "secret", "s.go"): "package secret\n",
+		filepath.Join(outside, "key.go"):         "package key\n",
+	} {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"vendored":        filepath.Join(root, "lib"),
+		"src/loop":        "..",
+		"src/self":        ".",
+		"escape":          filepath.Join(outside, "secret"),
+		"escape.go":       filepath.Join(outside, "key.go"),
+		"relative_escape": "../" + filepath.Base(outside),
+		"alias.go":        "src/a.go",
+		"dangling.go":     "missing.go",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(link))); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+
+	if got := strings.Join(walkFiles(t, root, false), " "); got != "lib/b.go src/a.go" {
+		t.Errorf("without following: %s", got)
+	}
+
+	// Loops terminate, links leaving root aren't followed and each real
+	// directory is listed once, under whichever path reaches it first
+	got := walkFiles(t, root, true)
+	want := "alias.go lib/b.go src/a.go"
+	if strings.Join(got, " ") != want {
+		t.Errorf("following: %v, want %s", got, want)
+	}
+	for _, file := range got {
+		if strings.Contains(file, "secret") || strings.Contains(file, "key") {
+			t.Errorf("followed a link out of the root to %s", file)
+		}
+	}
+}
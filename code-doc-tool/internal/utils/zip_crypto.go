@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+var (
+	ErrPasswordRequired = errors.New("archive is password-protected")
+	ErrWrongPassword    = errors.New("incorrect archive password")
+	ErrUnsupportedCrypt = errors.New("archive uses an unsupported encryption method")
+)
+
+const (
+	zipFlagEncrypted      = 0x1
+	zipFlagDataDescriptor = 0x8
+	zipMethodAES          = 99
+)
+
+// openZipEntry opens a zip entry, decrypting traditional PKWARE
+// (ZipCrypto) encryption when a password is supplied.
+func openZipEntry(f *zip.File, password string) (io.ReadCloser, error) {
+	if f.Flags&zipFlagEncrypted == 0 {
+		return f.Open()
+	}
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+	if f.Method == zipMethodAES {
+		return nil, fmt.Errorf("%w: AES (entry %s)", ErrUnsupportedCrypt, f.Name)
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := newZipCryptoKeys(password)
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, err
+	}
+	keys.decrypt(header)
+
+	// The last header byte is a password check against the CRC, or the
+	// modification time when sizes live in a trailing data descriptor
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&zipFlagDataDescriptor != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if header[11] != check {
+		return nil, ErrWrongPassword
+	}
+
+	decrypted := &zipCryptoReader{r: raw, keys: keys}
+	var body io.ReadCloser
+	switch f.Method {
+	case zip.Store:
+		body = io.NopCloser(decrypted)
+	case zip.Deflate:
+		body = flate.NewReader(decrypted)
+	default:
+		return nil, zip.ErrAlgorithm
+	}
+	return &crcCheckReader{rc: body, want: f.CRC32, hash: crc32.NewIEEE()}, nil
+}
+
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password string) *zipCryptoKeys {
+	keys := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range []byte(password) {
+		keys.update(b)
+	}
+	return keys
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8)
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+(k[0]&0xff))*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decrypt(buf []byte) {
+	for i := range buf {
+		temp := (k[2] | 2) & 0xffff
+		buf[i] ^= byte((temp * (temp ^ 1)) >> 8)
+		k.update(buf[i])
+	}
+}
+
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	z.keys.decrypt(p[:n])
+	return n, err
+}
+
+// crcCheckReader verifies the decrypted contents against the entry CRC,
+// catching passwords that happen to pass the one-byte header check.
+type crcCheckReader struct {
+	rc   io.ReadCloser
+	want uint32
+	hash interface {
+		io.Writer
+		Sum32() uint32
+	}
+}
+
+func (c *crcCheckReader) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && c.hash.Sum32() != c.want {
+		return n, ErrWrongPassword
+	}
+	return n, err
+}
+
+func (c *crcCheckReader) Close() error {
+	return c.rc.Close()
+}
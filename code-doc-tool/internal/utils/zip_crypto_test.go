@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"archive/zip"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeEncryptedZip writes a zip holding name with content, stored and
+// encrypted with ZipCrypto under password.
+func writeEncryptedZip(t *testing.T, path, name, content, password string) {
+	t.Helper()
+	plain := []byte(content)
+	crc := crc32.ChecksumIEEE(plain)
+
+	// The 12-byte header ends with the CRC's high byte as a password check
+	header := make([]byte, 12)
+	header[11] = byte(crc >> 24)
+	keys := newZipCryptoKeys(password)
+	encrypted := make([]byte, 0, len(header)+len(plain))
+	for _, b := range append(header, plain...) {
+		temp := (keys[2] | 2) & 0xffff
+		encrypted = append(encrypted, b^byte((temp*(temp^1))>>8))
+		keys.update(b)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	w := zip.NewWriter(file)
+	raw, err := w.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		Flags:              zipFlagEncrypted,
+		CRC32:              crc,
+		CompressedSize64:   uint64(len(encrypted)),
+		UncompressedSize64: uint64(len(plain)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Write(encrypted)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractEncryptedZip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "secret.zip")
+	writeEncryptedZip(t, archive, "main.go", "package main\n", "hunter2")
+
+	dest := filepath.Join(dir, "out")
+	if err := ExtractArchive(archive, dest, ExtractOptions{Password: "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "main.go")); string(data) != "package main\n" {
+		t.Errorf("extracted %q", data)
+	}
+
+	err := ExtractArchive(archive, filepath.Join(dir, "none"), ExtractOptions{})
+	if !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("without a password got %v", err)
+	}
+
+	// The one-byte check passes for about 1 in 256 wrong passwords; the
+	// CRC catches those
+	for _, wrong := range []string{"hunter3", "x", "password"} {
+		err := ExtractArchive(archive, filepath.Join(dir, wrong), ExtractOptions{Password: wrong})
+		if !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("password %q got %v", wrong, err)
+		}
+	}
+}